@@ -0,0 +1,4 @@
+// Package watcherv1 is the gRPC API of the watcher.
+package watcherv1
+
+//go:generate protoc -I ../../.. --go_out=../../.. --go_opt=paths=source_relative --go-grpc_out=../../.. --go-grpc_opt=paths=source_relative api/watcher/v1/watcher.proto
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/go-chi/httplog"
+	"go-tsv-watcher/config"
+	"go-tsv-watcher/internal/storage"
+	"go-tsv-watcher/internal/usecase"
+	"go-tsv-watcher/pkg/logger"
+	"io"
+	"os"
+)
+
+const usage = `usage: watcher [-c config.json] [command] [args]
+
+commands:
+  serve                     watch the directories and serve the API (default)
+  ingest <file...>          ingest the files at once without the watcher
+  replay [file...]          ingest the stored files again from the pipeline directory
+  export                    write the events matching the filter as JSON lines or CSV
+  files                     list the files of the ledger with their status
+  validate <file...>        parse the files without storing them and print the row errors
+  migrate up|down|status|force
+
+run "watcher <command> -h" for the flags of the command`
+
+// command runs a subcommand with the loaded config and the arguments after its name.
+type command func(cfg *config.Config, args []string) error
+
+// commands are the subcommands of the watcher, serve runs if none is given.
+var commands = map[string]command{
+	"serve":    runServe,
+	"ingest":   runIngest,
+	"replay":   runReplay,
+	"export":   runExport,
+	"files":    runFiles,
+	"validate": runValidate,
+	"migrate":  runMigrations,
+}
+
+// ErrFailed is returned by the commands which reported their failures already.
+var ErrFailed = errors.New("command failed")
+
+// runCommand runs the subcommand named by the first argument.
+func runCommand(cfg *config.Config, args []string) error {
+	name := "serve"
+	if len(args) > 0 {
+		name, args = args[0], args[1:]
+	}
+
+	run, ok := commands[name]
+	if !ok {
+		return fmt.Errorf("unknown command %q\n%s", name, usage)
+	}
+	return run(cfg, args)
+}
+
+// newFlagSet returns the flag set of the subcommand printing its usage line.
+func newFlagSet(name, usageLine string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), usageLine)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// app is the use case of the config with its opened storages.
+type app struct {
+	logic  *usecase.UseCase
+	logger logger.ILogger
+	// st is the storage of the use case, storages are all opened storages.
+	st       storage.Storage
+	storages []storage.Storage
+}
+
+// newApp creates the use case of the config with the storages of the pipelines.
+func newApp(cfg *config.Config) (*app, error) {
+	loggerInstance := logger.New(httplog.NewLogger("watcher", httplog.Options{
+		Concise:  true,
+		LogLevel: cfg.LogLevel,
+	}))
+
+	st, err := storage.New(cfg.DBConfig, loggerInstance)
+	if err != nil {
+		return nil, err
+	}
+
+	logic := usecase.New(st, cfg.DirectoryOut, loggerInstance)
+	if cfg.MaxUploadSize > 0 {
+		logic.SetMaxUploadSize(cfg.MaxUploadSize)
+	}
+	if cfg.EventCache != nil {
+		logic.SetEventCache(cfg.EventCache.Size, cfg.EventCache.TTL)
+	}
+	logic.SetReportConfig(reportConfig(cfg.Reports))
+
+	pipelines, pipelineStorages, err := openPipelines(cfg, loggerInstance)
+	if err != nil {
+		closeStorages([]storage.Storage{st})
+		return nil, err
+	}
+
+	a := &app{logic: logic, logger: loggerInstance, st: st, storages: append(pipelineStorages, st)}
+	if err = logic.SetPipelines(pipelines); err != nil {
+		a.close()
+		return nil, err
+	}
+
+	// the readiness probe checks that the migrations are still applied
+	logic.AddCheck("migrations", schemaCheck(st, cfg.DBConfig.Type))
+	for i, p := range pipelines {
+		if p.Storage != nil {
+			logic.AddCheck("migrations:"+p.Name, schemaCheck(p.Storage, cfg.Pipelines[i].DBConfig.Type))
+		}
+	}
+	return a, nil
+}
+
+// schemaCheck returns the readiness check of the schema of the storage.
+func schemaCheck(st storage.Storage, vendor string) usecase.Check {
+	return func(ctx context.Context) error {
+		return storage.CheckSchema(ctx, st, vendor)
+	}
+}
+
+// close closes the storages, the errors are logged.
+func (a *app) close() {
+	closeStorages(a.storages)
+}
+
+// configPipeline returns the pipeline of the config, the first one if the name is empty.
+func configPipeline(cfg *config.Config, name string) (config.Pipeline, error) {
+	if name == "" {
+		return cfg.Pipelines[0], nil
+	}
+	for _, p := range cfg.Pipelines {
+		if p.Name == name {
+			return p, nil
+		}
+	}
+	return config.Pipeline{}, fmt.Errorf("%w: %s", usecase.ErrPipelineNotFound, name)
+}
+
+// createOutput returns the file to write to, stdout if the path is empty or "-".
+func createOutput(path string) (io.WriteCloser, error) {
+	if path == "" || path == "-" {
+		return nopCloser{os.Stdout}, nil
+	}
+	return os.Create(path)
+}
+
+// nopCloser keeps stdout open.
+type nopCloser struct {
+	io.Writer
+}
+
+// Close does nothing.
+func (nopCloser) Close() error {
+	return nil
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-tsv-watcher/config"
+	"go-tsv-watcher/internal/events"
+	"go-tsv-watcher/internal/storage/service"
+	"go-tsv-watcher/internal/usecase"
+	"io"
+	"strconv"
+	"time"
+)
+
+const exportUsage = "usage: watcher [-c config.json] export [filter flags] [-format jsonl|csv] [-o file]"
+
+// exportColumns are the CSV columns of the exported events.
+var exportColumns = []string{
+	"ID", "Number", "MQTT", "InventoryID", "UnitGUID", "MessageID", "MessageText", "Context", "MessageClass",
+	"Level", "Area", "Address", "Block", "Type", "Bit", "InvertBit", "SourceFile", "IngestedAt", "Pipeline",
+}
+
+// runExport writes the events matching the filter flags ordered by the ingestion time.
+func runExport(cfg *config.Config, args []string) error {
+	fs := newFlagSet("export", exportUsage)
+	var filter service.EventFilter
+	fs.StringVar(&filter.Pipeline, "pipeline", "", "pipeline of the events, all events of the top level storage by default")
+	fs.StringVar(&filter.UnitGUID, "unit-guid", "", "exact unit guid")
+	fs.StringVar(&filter.InventoryID, "inventory-id", "", "exact inventory id")
+	fs.StringVar(&filter.MessageClass, "message-class", "", "exact message class")
+	fs.StringVar(&filter.MessageID, "message-id", "", "exact message id")
+	fs.StringVar(&filter.Area, "area", "", "exact area")
+	fs.StringVar(&filter.SourceFile, "source-file", "", "exact source file")
+	levelMin := fs.String("level-min", "", "minimal level, inclusive")
+	levelMax := fs.String("level-max", "", "maximal level, inclusive")
+	from := fs.String("from", "", "ingestion time in RFC3339 the events start from")
+	to := fs.String("to", "", "ingestion time in RFC3339 the events end before")
+	format := fs.String("format", "jsonl", "jsonl or csv")
+	output := fs.String("o", "-", "file to write to, stdout by default")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return errors.New(exportUsage)
+	}
+
+	var err error
+	if filter.MinLevel, err = parseLevel("level-min", *levelMin); err != nil {
+		return err
+	}
+	if filter.MaxLevel, err = parseLevel("level-max", *levelMax); err != nil {
+		return err
+	}
+	if filter.From, err = parseTime("from", *from); err != nil {
+		return err
+	}
+	if filter.To, err = parseTime("to", *to); err != nil {
+		return err
+	}
+
+	if *format != "jsonl" && *format != "csv" {
+		return fmt.Errorf("unknown format %q, want jsonl or csv", *format)
+	}
+
+	a, err := newApp(cfg)
+	if err != nil {
+		return err
+	}
+	defer a.close()
+
+	out, err := createOutput(*output)
+	if err != nil {
+		return err
+	}
+
+	write := writeJSONLines(out)
+	if *format == "csv" {
+		write = writeCSV(out)
+	}
+
+	n, err := exportEvents(context.Background(), a.logic, filter, write)
+	if errClose := out.Close(); err == nil {
+		err = errClose
+	}
+	if err != nil {
+		return fmt.Errorf("failed to export events: %w", err)
+	}
+
+	if *output != "-" {
+		fmt.Printf("%d events exported to %s\n", n, *output)
+	}
+	return nil
+}
+
+// exportEvents writes the events of the filter page by page and returns their number.
+func exportEvents(ctx context.Context, logic *usecase.UseCase, filter service.EventFilter,
+	write func(evs []events.Event) error) (int, error) {
+	query := service.EventsQuery{Filter: filter, SortBy: service.SortIngestedAt, Limit: usecase.MaxEventsLimit}
+	n := 0
+	for {
+		page, err := logic.ListEvents(ctx, query)
+		if err != nil {
+			return n, err
+		}
+
+		if err = write(page.Events); err != nil {
+			return n, err
+		}
+		n += len(page.Events)
+
+		if page.Next == nil {
+			return n, nil
+		}
+		query.After = page.Next
+	}
+}
+
+// writeJSONLines returns the writer of the events as JSON objects one per line.
+func writeJSONLines(w io.Writer) func(evs []events.Event) error {
+	enc := json.NewEncoder(w)
+	return func(evs []events.Event) error {
+		for _, e := range evs {
+			if err := enc.Encode(e); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// writeCSV returns the writer of the events as CSV rows of exportColumns, the header is written first.
+func writeCSV(w io.Writer) func(evs []events.Event) error {
+	cw := csv.NewWriter(w)
+	cw.Write(exportColumns)
+	return func(evs []events.Event) error {
+		for _, e := range evs {
+			cw.Write([]string{
+				e.ID, strconv.Itoa(e.Number), e.MQTT, e.InventoryID, e.UnitGUID, e.MessageID, e.MessageText, e.Context,
+				e.MessageClass, strconv.Itoa(e.Level), e.Area, e.Address, strconv.FormatBool(e.Block), e.Type,
+				strconv.Itoa(e.Bit), strconv.Itoa(e.InvertBit), e.SourceFile, e.IngestedAt.UTC().Format(time.RFC3339Nano),
+				e.Pipeline,
+			})
+		}
+		cw.Flush()
+		return cw.Error()
+	}
+}
+
+// parseLevel parses the optional level flag.
+func parseLevel(name, raw string) (*int, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	level, err := strconv.Atoi(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid -%s %q: %w", name, raw, err)
+	}
+	return &level, nil
+}
+
+// parseTime parses the optional RFC3339 time flag.
+func parseTime(name, raw string) (time.Time, error) {
+	if raw == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid -%s %q: %w", name, raw, err)
+	}
+	return t, nil
+}
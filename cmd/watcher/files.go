@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-tsv-watcher/config"
+	"os"
+	"text/tabwriter"
+)
+
+const filesUsage = "usage: watcher [-c config.json] files [-pipeline name] [-status ok|failed|deleted]"
+
+// runFiles prints the files of the ledger with their status.
+func runFiles(cfg *config.Config, args []string) error {
+	fs := newFlagSet("files", filesUsage)
+	pipeline := fs.String("pipeline", "", "pipeline of the files, the files of the top level storage by default")
+	status := fs.String("status", "", "files with the status only")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return errors.New(filesUsage)
+	}
+
+	a, err := newApp(cfg)
+	if err != nil {
+		return err
+	}
+	defer a.close()
+
+	files, err := a.logic.ListFiles(context.Background(), *pipeline, *status)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "PIPELINE\tNAME\tSTATUS\tEVENTS\tPRUNED\tARCHIVED\tERROR")
+	for _, f := range files {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%d\t%d\t%s\n", f.Pipeline, f.Name, f.Status, f.Events, f.Pruned, f.Archived, f.Error)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-tsv-watcher/config"
+	"go-tsv-watcher/internal/storage/service"
+	"path/filepath"
+)
+
+const (
+	ingestUsage = "usage: watcher [-c config.json] ingest [-pipeline name] <file...>"
+	replayUsage = "usage: watcher [-c config.json] replay [-pipeline name] [-status ok|failed|deleted] [file...]"
+)
+
+// runIngest ingests the files by the pipeline at once, the files already in the ledger are skipped.
+func runIngest(cfg *config.Config, args []string) error {
+	fs := newFlagSet("ingest", ingestUsage)
+	pipeline := fs.String("pipeline", "", "pipeline to ingest the files by, the first one by default")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return errors.New(ingestUsage)
+	}
+
+	a, err := newApp(cfg)
+	if err != nil {
+		return err
+	}
+	defer a.close()
+
+	ctx := context.Background()
+	failed := 0
+	for _, path := range fs.Args() {
+		file, err := a.logic.IngestFile(ctx, *pipeline, path)
+		if !printIngested(filepath.Base(path), file, err) {
+			failed++
+		}
+	}
+	return ingestResult(failed, fs.NArg())
+}
+
+// runReplay deletes the events of the stored files of the pipeline and ingests the files again
+// from the pipeline directory, all files except the deleted ones if no file is given.
+func runReplay(cfg *config.Config, args []string) error {
+	fs := newFlagSet("replay", replayUsage)
+	pipeline := fs.String("pipeline", "", "pipeline of the files, the first one by default")
+	status := fs.String("status", "", "replay the files of the ledger with the status only")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	p, err := configPipeline(cfg, *pipeline)
+	if err != nil {
+		return err
+	}
+
+	a, err := newApp(cfg)
+	if err != nil {
+		return err
+	}
+	defer a.close()
+
+	ctx := context.Background()
+	names := fs.Args()
+	if len(names) == 0 {
+		files, err := a.logic.ListFiles(ctx, p.Name, *status)
+		if err != nil {
+			return err
+		}
+		for _, f := range files {
+			if *status == "" && f.Status == service.FileDeleted {
+				continue
+			}
+			names = append(names, f.Name)
+		}
+	}
+
+	failed := 0
+	for _, name := range names {
+		file, err := a.logic.ReplayFile(ctx, p.Name, p.Directory, name)
+		if !printIngested(name, file, err) {
+			failed++
+		}
+	}
+	return ingestResult(failed, len(names))
+}
+
+// printIngested prints the ledger record of the ingested file and reports whether it is ok.
+func printIngested(name string, file service.FileDetails, err error) bool {
+	switch {
+	case err != nil:
+		fmt.Printf("%s: %v\n", name, err)
+		return false
+	case file.Status != service.FileOK:
+		fmt.Printf("%s: %s: %s\n", name, file.Status, file.Error)
+		return false
+	default:
+		fmt.Printf("%s: %s, %d events\n", name, file.Status, file.Events)
+		return true
+	}
+}
+
+// ingestResult returns ErrFailed if some of the files failed.
+func ingestResult(failed, total int) error {
+	if failed == 0 {
+		return nil
+	}
+	fmt.Printf("%d of %d files failed\n", failed, total)
+	return ErrFailed
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"go-tsv-watcher/config"
+	"go-tsv-watcher/internal/storage"
+	"strconv"
+)
+
+const migrateUsage = "usage: watcher [-c config.json] migrate up|down [steps]|status|force <version>"
+
+// runMigrations executes the migrate subcommand on the top level storage and the storages of the pipelines.
+func runMigrations(cfg *config.Config, args []string) error {
+	if err := runMigrate(cfg.DBConfig, args); err != nil {
+		return err
+	}
+	for _, p := range cfg.Pipelines {
+		if p.DBConfig == nil {
+			continue
+		}
+		fmt.Printf("pipeline %s: ", p.Name)
+		if err := runMigrate(p.DBConfig, args); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runMigrate executes the migrate subcommand on the storage.
+func runMigrate(cfg *storage.Config, args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	m, err := storage.NewMigrator(cfg)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	switch args[0] {
+	case "up":
+		err = m.Up()
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil {
+				return fmt.Errorf("invalid steps %q: %w", args[1], err)
+			}
+		}
+		err = m.Down(steps)
+	case "force":
+		if len(args) < 2 {
+			return errors.New(migrateUsage)
+		}
+		version, errConv := strconv.Atoi(args[1])
+		if errConv != nil {
+			return fmt.Errorf("invalid version %q: %w", args[1], errConv)
+		}
+		err = m.Force(version)
+	case "status":
+	default:
+		return errors.New(migrateUsage)
+	}
+	if err != nil {
+		return err
+	}
+
+	status, err := m.Status()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("version: %d, latest: %d, dirty: %v\n", status.Version, status.Latest, status.Dirty)
+	return nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"go-tsv-watcher/config"
+	"go-tsv-watcher/internal/storage"
+	"go-tsv-watcher/internal/usecase"
+	"go-tsv-watcher/pkg/logger"
+	"log"
+)
+
+// openPipelines returns the pipelines of the use case with their own storages opened,
+// the storages are returned to be closed on exit.
+func openPipelines(cfg *config.Config, loggerInstance logger.ILogger) ([]usecase.Pipeline, []storage.Storage, error) {
+	pipelines := make([]usecase.Pipeline, 0, len(cfg.Pipelines))
+	var opened []storage.Storage
+	for _, p := range cfg.Pipelines {
+		pipeline := usecase.Pipeline{Name: p.Name, Columns: p.Columns, DirOut: p.DirectoryOut}
+
+		if p.DBConfig != nil {
+			st, err := storage.New(p.DBConfig, loggerInstance)
+			if err != nil {
+				closeStorages(opened)
+				return nil, nil, fmt.Errorf("failed to open the storage of the %s pipeline: %w", p.Name, err)
+			}
+			pipeline.Storage = st
+			opened = append(opened, st)
+		}
+
+		if p.Reports != nil {
+			reports := reportConfig(*p.Reports)
+			pipeline.Reports = &reports
+		}
+
+		pipelines = append(pipelines, pipeline)
+	}
+	return pipelines, opened, nil
+}
+
+// closeStorages closes the storages, the errors are logged.
+func closeStorages(storages []storage.Storage) {
+	for _, st := range storages {
+		if err := st.Close(); err != nil {
+			log.Println(err)
+		}
+	}
+}
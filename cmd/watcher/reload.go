@@ -0,0 +1,128 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"go-tsv-watcher/config"
+	"go-tsv-watcher/internal/reload"
+	"go-tsv-watcher/internal/usecase"
+	"go-tsv-watcher/pkg/logger"
+)
+
+// newApply returns the reload.Apply loading the config again and applying its live fields,
+// the other changed fields are pending until the restart. The live fields are checked first,
+// so either all of them are applied or none.
+func newApply(logic *usecase.UseCase, started *config.Config) reload.Apply {
+	current := started
+	return func() (changed, pending []string, err error) {
+		next, err := config.Reload()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		changed = next.Changed(current)
+		for _, field := range changed {
+			if config.Live(field) {
+				if err = checkField(logic, next, field); err != nil {
+					return nil, nil, fmt.Errorf("can't apply %s: %w", field, err)
+				}
+			}
+		}
+		for _, field := range changed {
+			if config.Live(field) {
+				if err = applyField(logic, next, field); err != nil {
+					return nil, nil, fmt.Errorf("can't apply %s: %w", field, err)
+				}
+			}
+		}
+
+		for _, field := range next.Changed(started) {
+			if !config.Live(field) {
+				pending = append(pending, field)
+			}
+		}
+
+		current = next
+		return changed, pending, nil
+	}
+}
+
+// watchField reports whether the field changes the watch of the pipelines, of the named one only if set.
+func watchField(field string) (pipeline string, ok bool) {
+	if field == "directory" || field == "refresh_interval" {
+		return "", true
+	}
+	name, sub, ok := config.PipelineField(field)
+	return name, ok && (sub == "directory" || sub == "refresh_interval")
+}
+
+// watched returns the pipelines of the config with the name, all if empty,
+// the pipelines added or removed since the start are pending.
+func watched(logic *usecase.UseCase, cfg *config.Config, name string) []config.Pipeline {
+	var pipelines []config.Pipeline
+	for _, p := range cfg.Pipelines {
+		if name != "" && p.Name != name {
+			continue
+		}
+		if err := logic.Watching(p.Name); errors.Is(err, usecase.ErrPipelineNotFound) {
+			continue
+		}
+		pipelines = append(pipelines, p)
+	}
+	return pipelines
+}
+
+// checkField returns the problem the live field of the config would fail to apply with.
+func checkField(logic *usecase.UseCase, cfg *config.Config, field string) error {
+	name, ok := watchField(field)
+	if !ok {
+		return nil
+	}
+	for _, p := range watched(logic, cfg, name) {
+		if err := logic.Watching(p.Name); err != nil {
+			return fmt.Errorf("pipeline %s: %w", p.Name, err)
+		}
+	}
+	return nil
+}
+
+// applyField applies the live field of the config.
+func applyField(logic *usecase.UseCase, cfg *config.Config, field string) error {
+	if name, ok := watchField(field); ok {
+		for _, p := range watched(logic, cfg, name) {
+			if err := logic.SetWatch(p.Name, p.Refresh, p.Directory); err != nil {
+				return fmt.Errorf("pipeline %s: %w", p.Name, err)
+			}
+		}
+		return nil
+	}
+
+	switch field {
+	case "log_level":
+		return logger.SetLevel(cfg.LogLevel)
+	case "max_upload_size":
+		logic.SetMaxUploadSize(cfg.MaxUploadSize)
+	case "event_cache":
+		if cfg.EventCache == nil {
+			logic.SetEventCache(0, 0)
+		} else {
+			logic.SetEventCache(cfg.EventCache.Size, cfg.EventCache.TTL)
+		}
+	case "reports":
+		logic.SetReportConfig(reportConfig(cfg.Reports))
+	}
+	return nil
+}
+
+// reportConfig returns the report config of the use case.
+func reportConfig(reports config.Reports) usecase.ReportConfig {
+	return usecase.ReportConfig{
+		Mode:    reports.Mode,
+		Window:  reports.Window,
+		Naming:  reports.Naming,
+		Title:   reports.Title,
+		Columns: reports.Columns,
+		Formats: reports.Formats,
+		Fonts:   reports.Fonts,
+	}
+}
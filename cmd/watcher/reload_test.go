@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/go-chi/httplog"
+	"github.com/golang/mock/gomock"
+	"github.com/rs/zerolog"
+	"go-tsv-watcher/config"
+	"go-tsv-watcher/internal/storage/mocks"
+	"go-tsv-watcher/internal/usecase"
+	"go-tsv-watcher/pkg/logger"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// testConfig is the config file of the reload tests.
+type testConfig struct {
+	HTTP      string         `json:"http"`
+	LogLevel  string         `json:"log_level"`
+	Pipelines []testPipeline `json:"pipelines"`
+	Storage   string         `json:"storage_type"`
+	DSN       string         `json:"dsn"`
+}
+
+// testPipeline is a pipeline of the config file of the reload tests.
+type testPipeline struct {
+	Name         string `json:"name"`
+	Directory    string `json:"directory"`
+	DirectoryOut string `json:"directory_out"`
+	Refresh      string `json:"refresh_interval"`
+}
+
+// reloadStep is a change of the config file and the outcome of its reload.
+type reloadStep struct {
+	change      func(c *testConfig)
+	wantChanged []string
+	wantPending []string
+	wantErr     bool
+}
+
+func TestNewApply(t *testing.T) {
+	defer zerolog.SetGlobalLevel(zerolog.GlobalLevel())
+
+	dir := t.TempDir()
+	dirs := make(map[string]string)
+	for _, name := range []string{"a", "a2", "b", "b2", "c", "out"} {
+		dirs[name] = filepath.Join(dir, name)
+		if err := os.Mkdir(dirs[name], 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		// watching are the pipelines of the use case processing their directory
+		watching []string
+		steps    []reloadStep
+		// wantDirs are the watched directories after the reloads
+		wantDirs  map[string]string
+		wantLevel zerolog.Level
+	}{
+		{
+			name:     "live fields",
+			watching: []string{"a", "b"},
+			steps: []reloadStep{{
+				change: func(c *testConfig) {
+					c.LogLevel = "debug"
+					c.Pipelines[0].Directory = dirs["a2"]
+				},
+				wantChanged: []string{"log_level", "pipelines.a.directory"},
+			}},
+			wantDirs:  map[string]string{"a": dirs["a2"], "b": dirs["b"]},
+			wantLevel: zerolog.DebugLevel,
+		},
+		{
+			// b is not watching yet, so nothing is applied, not even the fields checked before it
+			name:     "failing field rolls back nothing",
+			watching: []string{"a"},
+			steps: []reloadStep{{
+				change: func(c *testConfig) {
+					c.LogLevel = "debug"
+					c.Pipelines[0].Directory = dirs["a2"]
+					c.Pipelines[1].Directory = dirs["b2"]
+				},
+				wantErr: true,
+			}},
+			wantDirs:  map[string]string{"a": dirs["a"]},
+			wantLevel: zerolog.InfoLevel,
+		},
+		{
+			name:     "pending against the started config",
+			watching: []string{"a", "b"},
+			steps: []reloadStep{
+				{
+					change:      func(c *testConfig) { c.HTTP = ":8081" },
+					wantChanged: []string{"http"},
+					wantPending: []string{"http"},
+				},
+				{
+					// unchanged since the last reload, still pending
+					change:      func(c *testConfig) { c.LogLevel = "warn" },
+					wantChanged: []string{"log_level"},
+					wantPending: []string{"http"},
+				},
+				{
+					// back to the started value, nothing waits for the restart
+					change:      func(c *testConfig) { c.HTTP = ":8080" },
+					wantChanged: []string{"http"},
+				},
+			},
+			wantDirs:  map[string]string{"a": dirs["a"], "b": dirs["b"]},
+			wantLevel: zerolog.WarnLevel,
+		},
+		{
+			name:     "pipelines added and removed by name",
+			watching: []string{"a", "b"},
+			steps: []reloadStep{{
+				change: func(c *testConfig) {
+					c.Pipelines[0].Directory = dirs["a2"]
+					c.Pipelines[1] = testPipeline{Name: "c", Directory: dirs["c"], DirectoryOut: dirs["out"], Refresh: "1s"}
+				},
+				wantChanged: []string{"pipelines.a.directory", "pipelines.c", "pipelines.b"},
+				wantPending: []string{"pipelines.c", "pipelines.b"},
+			}},
+			// the removed pipeline keeps watching until the restart
+			wantDirs:  map[string]string{"a": dirs["a2"], "b": dirs["b"]},
+			wantLevel: zerolog.InfoLevel,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			zerolog.SetGlobalLevel(zerolog.InfoLevel)
+
+			cfg := testConfig{
+				HTTP:     ":8080",
+				LogLevel: "info",
+				Storage:  "sqlite3",
+				DSN:      filepath.Join(dir, "watcher.db"),
+				Pipelines: []testPipeline{
+					{Name: "a", Directory: dirs["a"], DirectoryOut: dirs["out"], Refresh: "1s"},
+					{Name: "b", Directory: dirs["b"], DirectoryOut: dirs["out"], Refresh: "1s"},
+				},
+			}
+			file := filepath.Join(t.TempDir(), "config.json")
+			writeConfig(t, file, cfg)
+			t.Setenv("WATCHER_CONFIG", file)
+
+			started, err := config.Reload()
+			if err != nil {
+				t.Fatalf("Reload() error = %v", err)
+			}
+			logic := watchingUseCase(t, started, tt.watching)
+
+			apply := newApply(logic, started)
+			for i, step := range tt.steps {
+				step.change(&cfg)
+				writeConfig(t, file, cfg)
+
+				changed, pending, err := apply()
+				if (err != nil) != step.wantErr {
+					t.Fatalf("step %d: apply() error = %v, wantErr %v", i, err, step.wantErr)
+				}
+				if !reflect.DeepEqual(changed, step.wantChanged) {
+					t.Errorf("step %d: apply() changed = %v, want %v", i, changed, step.wantChanged)
+				}
+				if !reflect.DeepEqual(pending, step.wantPending) {
+					t.Errorf("step %d: apply() pending = %v, want %v", i, pending, step.wantPending)
+				}
+			}
+
+			dirsOf := make(map[string]string)
+			for _, p := range logic.Status(context.Background()).Pipelines {
+				if p.Watching {
+					dirsOf[p.Name] = p.Directory
+				}
+			}
+			if !reflect.DeepEqual(dirsOf, tt.wantDirs) {
+				t.Errorf("watched directories = %v, want %v", dirsOf, tt.wantDirs)
+			}
+			if level := zerolog.GlobalLevel(); level != tt.wantLevel {
+				t.Errorf("log level = %v, want %v", level, tt.wantLevel)
+			}
+		})
+	}
+}
+
+// writeConfig writes the config file.
+func writeConfig(t *testing.T, file string, cfg testConfig) {
+	content, err := json.Marshal(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(file, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// watchingUseCase returns the use case of the pipelines of the config with the named ones processing their directory.
+func watchingUseCase(t *testing.T, cfg *config.Config, watching []string) *usecase.UseCase {
+	c := gomock.NewController(t)
+	st := mocks.NewMockStorage(c)
+	st.EXPECT().LoadFilenames(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	st.EXPECT().Ping(gomock.Any()).Return(nil).AnyTimes()
+
+	logic := usecase.New(st, t.TempDir(), logger.New(httplog.NewLogger("watcher", httplog.Options{Concise: true})))
+	pipelines := make([]usecase.Pipeline, 0, len(cfg.Pipelines))
+	for _, p := range cfg.Pipelines {
+		pipelines = append(pipelines, usecase.Pipeline{Name: p.Name})
+	}
+	if err := logic.SetPipelines(pipelines); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	for _, p := range cfg.Pipelines {
+		for _, name := range watching {
+			if p.Name == name {
+				go logic.Process(ctx, p.Name, p.Refresh, p.Directory)
+			}
+		}
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for _, name := range watching {
+		for logic.Watching(name) != nil {
+			if time.Now().After(deadline) {
+				t.Fatalf("pipeline %s is not watching", name)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	return logic
+}
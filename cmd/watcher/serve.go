@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"go-tsv-watcher/config"
+	"go-tsv-watcher/internal/archive"
+	"go-tsv-watcher/internal/auth"
+	"go-tsv-watcher/internal/grpcserver"
+	"go-tsv-watcher/internal/handler"
+	"go-tsv-watcher/internal/ratelimit"
+	"go-tsv-watcher/internal/reload"
+	"go-tsv-watcher/internal/storage/service"
+	"go-tsv-watcher/internal/tlsconfig"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+const serveUsage = "usage: watcher [-c config.json] [serve]"
+
+// runServe watches the directories of the pipelines and serves the API until SIGINT or SIGTERM.
+func runServe(cfg *config.Config, args []string) error {
+	if len(args) > 0 {
+		return errors.New(serveUsage)
+	}
+
+	a, err := newApp(cfg)
+	if err != nil {
+		return err
+	}
+	logic := a.logic
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// the storages are closed after the watchers and the background jobs return
+	var processes, jobs sync.WaitGroup
+	for _, p := range cfg.Pipelines {
+		processes.Add(1)
+		go func(p config.Pipeline) {
+			defer processes.Done()
+			err := logic.Process(ctx, p.Name, p.Refresh, p.Directory)
+			if err != nil && !errors.Is(err, context.Canceled) {
+				log.Fatal(err)
+			}
+		}(p)
+	}
+
+	if cfg.Retention != nil {
+		policy := service.RetentionPolicy{
+			MaxAge:     cfg.Retention.MaxAge,
+			MaxPerUnit: cfg.Retention.MaxPerUnit,
+			MaxEvents:  cfg.Retention.MaxEvents,
+			BatchSize:  cfg.Retention.BatchSize,
+		}
+
+		if cfg.Retention.ArchiveDir != "" {
+			policy.Archiver, err = archive.New(cfg.Retention.ArchiveDir)
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+
+		jobs.Add(1)
+		go func() {
+			defer jobs.Done()
+			logic.Prune(ctx, cfg.Retention.Interval, policy)
+		}()
+	}
+
+	var authenticator *auth.Authenticator
+	if cfg.Auth != nil {
+		authenticator, err = auth.New(*cfg.Auth, logic)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	reloader := reload.New(config.File(), newApply(logic, cfg), a.logger)
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	jobs.Add(1)
+	go func() {
+		defer jobs.Done()
+		reloader.Run(ctx, reload.DefaultInterval, hup)
+	}()
+
+	h := handler.New(logic)
+	h.SetAuthenticator(authenticator)
+	h.SetReloader(reloader)
+	h.SetStreamOrigins(cfg.StreamOrigins)
+
+	var quota *ratelimit.Quota
+	var limits *ratelimit.Limits
+	if cfg.RateLimit != nil {
+		limits = &ratelimit.Limits{
+			Groups:         make(map[string]*ratelimit.Limiter, len(cfg.RateLimit.Groups)),
+			IPHeader:       cfg.RateLimit.ClientIPHeader,
+			TrustedProxies: cfg.RateLimit.TrustedProxies,
+		}
+		for group, rule := range cfg.RateLimit.Groups {
+			limits.Groups[group] = ratelimit.NewLimiter(rule)
+		}
+
+		if cfg.RateLimit.DailyQuota > 0 {
+			var store ratelimit.Store
+			if cfg.RateLimit.PersistInterval > 0 {
+				store = a.st
+			}
+			quota = ratelimit.NewQuota(cfg.RateLimit.DailyQuota, store, a.logger)
+			if err = quota.Load(ctx); err != nil {
+				log.Fatal(err)
+			}
+			if store != nil {
+				jobs.Add(1)
+				go func() {
+					defer jobs.Done()
+					quota.Run(ctx, cfg.RateLimit.PersistInterval)
+				}()
+			}
+			limits.Quota = quota
+		}
+
+		h.SetRateLimits(limits)
+	}
+
+	router := chi.NewRouter()
+	router.Group(h.PublicRoutes)
+	router.Group(h.PrivateRoutes)
+
+	var tlsConfig *tls.Config
+	if cfg.HTTPS != "" {
+		tlsConfig, err = tlsconfig.New(*cfg.TLS, a.logger)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// http server
+	httpServer := &http.Server{Addr: cfg.HTTP, Handler: router}
+	if cfg.HTTPS != "" {
+		httpServer.Addr, httpServer.TLSConfig = cfg.HTTPS, tlsConfig
+	}
+	go func() {
+		var err error
+		if cfg.HTTPS != "" {
+			log.Println("HTTPS server started on ", cfg.HTTPS)
+			err = httpServer.ListenAndServeTLS("", "")
+		} else {
+			log.Println("HTTP server started on ", cfg.HTTP)
+			err = httpServer.ListenAndServe()
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err)
+		}
+	}()
+
+	// grpc server
+	var grpcServer *grpc.Server
+	if cfg.GRPC != "" {
+		lis, err := net.Listen("tcp", cfg.GRPC)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		// with https the gRPC connections share its certificate reloading and client verification
+		var opts []grpc.ServerOption
+		if tlsConfig != nil {
+			opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+		}
+
+		grpcServer = grpcserver.NewGRPC(logic, authenticator, limits, a.logger, opts...)
+		go func() {
+			log.Println("gRPC server started on ", cfg.GRPC)
+			if err := grpcServer.Serve(lis); err != nil {
+				log.Fatal(err)
+			}
+		}()
+	}
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+
+	<-quit
+	log.Println("Shutting down...")
+
+	r := running{stop: cancel, processes: &processes, jobs: &jobs, http: httpServer, grpc: grpcServer, quota: quota,
+		logic: logic, closeStorages: a.close}
+	if err = r.shutdown(cfg.ShutdownTimeout); err != nil {
+		return err
+	}
+
+	log.Println("Done!")
+	return nil
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"go-tsv-watcher/internal/ratelimit"
+	"google.golang.org/grpc"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// errShutdownTimeout error occurs when the shutdown cut off the work not finished in time.
+var errShutdownTimeout = errors.New("shutdown timed out")
+
+// abortTimeout is how long the shutdown waits for the aborted files and the background jobs
+// once the shutdown timeout is over, the storages are closed under them after it.
+var abortTimeout = 5 * time.Second
+
+// ingestion is the part of the use case stopped by the shutdown.
+type ingestion interface {
+	AbortIngestion()
+	CloseStreams()
+}
+
+// running is the work stopped by the shutdown, nil if not started.
+type running struct {
+	// stop cancels the context of the watchers and the background jobs
+	stop      context.CancelFunc
+	processes *sync.WaitGroup
+	// jobs are the background jobs using the storages: the retention, the config reload and the quotas
+	jobs  *sync.WaitGroup
+	http  *http.Server
+	grpc  *grpc.Server
+	quota *ratelimit.Quota
+	logic ingestion
+	// closeStorages closes the storages of the app
+	closeStorages func()
+}
+
+// shutdown stops the work in order within the timeout: the watchers stop taking new files and finish
+// the current ones, the background jobs stop, the streams are closed, the servers finish the current requests,
+// the quotas are saved and the storages are closed. The files not finished in time are aborted
+// and waited for abortTimeout more before the storages are closed,
+// they are not in the ledger and are ingested again on the next start.
+func (r running) shutdown(timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	drained := true
+	r.stop()
+	if !wait(ctx, r.processes.Wait) {
+		log.Println("Shutdown: the current files are not finished in time, aborting them")
+		r.logic.AbortIngestion()
+		drained = false
+		if !waitFor(abortTimeout, r.processes.Wait) {
+			log.Println("Shutdown: the aborted files are not finished in time")
+		}
+	}
+	if !wait(ctx, r.jobs.Wait) {
+		drained = false
+		if !waitFor(abortTimeout, r.jobs.Wait) {
+			log.Println("Shutdown: the background jobs are not finished in time")
+		}
+	}
+
+	r.logic.CloseStreams()
+	if r.http != nil {
+		if err := r.http.Shutdown(ctx); err != nil {
+			log.Printf("Shutdown: the http requests are not finished in time: %v", err)
+			r.http.Close()
+			drained = false
+		}
+	}
+	if r.grpc != nil && !wait(ctx, r.grpc.GracefulStop) {
+		log.Println("Shutdown: the grpc requests are not finished in time")
+		r.grpc.Stop()
+		drained = false
+	}
+
+	if r.quota != nil {
+		if err := r.quota.Flush(context.Background()); err != nil {
+			log.Println(err)
+		}
+	}
+
+	r.closeStorages()
+
+	if !drained {
+		return errShutdownTimeout
+	}
+	return nil
+}
+
+// waitFor calls the blocking function and reports whether it returned within the timeout.
+func waitFor(timeout time.Duration, f func()) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return wait(ctx, f)
+}
+
+// wait calls the blocking function and reports whether it returned before the context is done.
+func wait(ctx context.Context, f func()) bool {
+	done := make(chan struct{})
+	go func() {
+		f()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// steps records the order of the shutdown steps.
+type steps struct {
+	mu   sync.Mutex
+	list []string
+	// aborted is closed by AbortIngestion
+	aborted chan struct{}
+}
+
+func (s *steps) add(step string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.list = append(s.list, step)
+}
+
+func (s *steps) get() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.list...)
+}
+
+// AbortIngestion implements ingestion.
+func (s *steps) AbortIngestion() {
+	s.add("abort")
+	close(s.aborted)
+}
+
+// CloseStreams implements ingestion.
+func (s *steps) CloseStreams() {
+	s.add("streams")
+}
+
+func TestRunning_shutdown(t *testing.T) {
+	defer func(timeout time.Duration) { abortTimeout = timeout }(abortTimeout)
+	abortTimeout = 50 * time.Millisecond
+
+	tests := []struct {
+		name string
+		// process and job are the work stopped by the context, stuck is closed after the test
+		process func(ctx context.Context, s *steps, stuck <-chan struct{})
+		job     func(ctx context.Context, s *steps)
+		want    []string
+		wantErr error
+	}{
+		{
+			name: "drained",
+			process: func(ctx context.Context, s *steps, _ <-chan struct{}) {
+				<-ctx.Done()
+				s.add("file finished")
+			},
+			want: []string{"file finished", "streams", "close"},
+		},
+		{
+			name: "aborted",
+			process: func(ctx context.Context, s *steps, _ <-chan struct{}) {
+				<-s.aborted
+				s.add("file aborted")
+			},
+			want:    []string{"abort", "file aborted", "streams", "close"},
+			wantErr: errShutdownTimeout,
+		},
+		{
+			name: "stuck",
+			process: func(ctx context.Context, s *steps, stuck <-chan struct{}) {
+				<-stuck
+			},
+			want:    []string{"abort", "streams", "close"},
+			wantErr: errShutdownTimeout,
+		},
+		{
+			name: "job",
+			job: func(ctx context.Context, s *steps) {
+				<-ctx.Done()
+				time.Sleep(20 * time.Millisecond)
+				s.add("job finished")
+			},
+			want: []string{"job finished", "streams", "close"},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			s := &steps{aborted: make(chan struct{})}
+			stuck := make(chan struct{})
+			defer close(stuck)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			var processes, jobs sync.WaitGroup
+			if tt.process != nil {
+				processes.Add(1)
+				go func() {
+					defer processes.Done()
+					tt.process(ctx, s, stuck)
+				}()
+			}
+			if tt.job != nil {
+				jobs.Add(1)
+				go func() {
+					defer jobs.Done()
+					tt.job(ctx, s)
+				}()
+			}
+
+			r := running{
+				stop:          cancel,
+				processes:     &processes,
+				jobs:          &jobs,
+				http:          &http.Server{},
+				logic:         s,
+				closeStorages: func() { s.add("close") },
+			}
+			start := time.Now()
+			err := r.shutdown(30 * time.Millisecond)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("shutdown() error = %v, want %v", err, tt.wantErr)
+			}
+			if elapsed := time.Since(start); elapsed > time.Second {
+				t.Errorf("shutdown() took %v", elapsed)
+			}
+			if got := s.get(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("shutdown() steps = %v, want %v", got, tt.want)
+			}
+			cancel()
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"go-tsv-watcher/config"
+	"go-tsv-watcher/internal/events"
+	"path/filepath"
+)
+
+const validateUsage = "usage: watcher [-c config.json] validate [-pipeline name] <file...>"
+
+// runValidate parses the files with the settings of the pipeline without storing them
+// and prints the rows which can't be parsed.
+func runValidate(cfg *config.Config, args []string) error {
+	fs := newFlagSet("validate", validateUsage)
+	pipeline := fs.String("pipeline", "", "pipeline to parse the files by, the first one by default")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return errors.New(validateUsage)
+	}
+
+	p, err := configPipeline(cfg, *pipeline)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, path := range fs.Args() {
+		name := filepath.Base(path)
+		es, err := events.New(path, events.Options{Pipeline: p.Name, Columns: p.Columns})
+		if err != nil {
+			fmt.Printf("%s: %v\n", name, err)
+			failed++
+			continue
+		}
+
+		rows, errRows, err := es.Validate()
+		if err != nil {
+			fmt.Printf("%s: %d rows, %v\n", name, rows, err)
+			failed++
+			continue
+		}
+
+		fmt.Printf("%s: %d rows, %d errors\n", name, rows, len(errRows))
+		for _, e := range errRows {
+			fmt.Printf("  %v\n", e)
+		}
+		if len(errRows) > 0 {
+			failed++
+		}
+	}
+	return ingestResult(failed, fs.NArg())
+}
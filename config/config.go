@@ -1,16 +1,31 @@
 package config
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"github.com/rs/zerolog"
+	"go-tsv-watcher/internal/auth"
+	"go-tsv-watcher/internal/events"
+	"go-tsv-watcher/internal/ratelimit"
+	"go-tsv-watcher/internal/report"
 	"go-tsv-watcher/internal/storage"
+	"go-tsv-watcher/internal/tlsconfig"
 	"io"
+	"net/url"
 	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
 	"time"
 )
 
-// Flag struct for parsing from env and cmd args.
+// Flag struct for parsing from the config file, env and cmd args.
 type Flag struct {
 	// config filename
 	ConfigFile *string `json:"-"`
@@ -23,13 +38,184 @@ type Flag struct {
 	DSN string `json:"dsn"`
 	// storage type (e.g. postgres, sqlite3)
 	Storage string `json:"storage_type"`
+	// apply pending migrations on start, true by default
+	AutoMigrate *bool `json:"auto_migrate,omitempty"`
 
 	// http(s) server config
 	HTTP  string `json:"http,omitempty"`
 	HTTPS string `json:"https,omitempty"`
+	// certificate of the https server, required with https
+	TLS *TLSFlag `json:"tls,omitempty"`
+	// grpc server address, disabled if not set
+	GRPC string `json:"grpc,omitempty"`
+	// origins allowed to open the websocket streams besides the host of the server
+	// (e.g. https://dashboard.example.com), "*" for any
+	StreamOrigins []string `json:"stream_origins,omitempty"`
+	// authentication of the api, anonymous if not set
+	Auth *AuthFlag `json:"auth,omitempty"`
+	// rate limits and daily quotas of the clients, unlimited if not set
+	RateLimit *RateLimitFlag `json:"rate_limit,omitempty"`
 
 	// refresh interval
 	Refresh string `json:"refresh_interval"`
+
+	// how long the shutdown waits for the current files and requests, 30s by default
+	ShutdownTimeout string `json:"shutdown_timeout,omitempty"`
+
+	// log level: debug, info, warn or error, info by default
+	LogLevel string `json:"log_level,omitempty"`
+
+	// biggest upload in bytes, 32 MiB if zero
+	MaxUploadSize int64 `json:"max_upload_size,omitempty"`
+
+	// cache of the event lookups, disabled if not set
+	EventCache *EventCacheFlag `json:"event_cache,omitempty"`
+
+	// events retention, disabled if not set
+	Retention *RetentionFlag `json:"retention,omitempty"`
+
+	// reports, one PDF per unit from the processed file if not set
+	Reports *ReportsFlag `json:"reports,omitempty"`
+
+	// named watch pipelines replacing directory and directory_out, one default pipeline if not set
+	Pipelines []PipelineFlag `json:"pipelines,omitempty"`
+}
+
+// PipelineFlag struct for parsing a named watch pipeline.
+type PipelineFlag struct {
+	// recorded on the events and the files: lowercase letters, digits, - and _
+	Name string `json:"name"`
+	// directory to watch
+	Directory string `json:"directory"`
+	// directory to write the reports to
+	DirectoryOut string `json:"directory_out"`
+	// refresh interval, the top level one if not set
+	Refresh string `json:"refresh_interval,omitempty"`
+	// renames the columns of the file header to the event columns (e.g. guid: unit_guid)
+	Columns map[string]string `json:"columns,omitempty"`
+	// own storage of the pipeline, the top level storage if not set
+	Storage string `json:"storage_type,omitempty"`
+	DSN     string `json:"dsn,omitempty"`
+	// reports of the pipeline, the top level reports if not set
+	Reports *ReportsFlag `json:"reports,omitempty"`
+}
+
+// EventCacheFlag struct for parsing the event cache.
+type EventCacheFlag struct {
+	// max number of cached events
+	Size int `json:"size"`
+	// how long an event is cached (e.g. 5m), until invalidated if not set
+	TTL string `json:"ttl,omitempty"`
+}
+
+// TLSFlag struct for parsing the TLS config of the https server.
+type TLSFlag struct {
+	// PEM certificate chain and private key, reloaded when the files change
+	CertFile string `json:"cert_file,omitempty"`
+	KeyFile  string `json:"key_file,omitempty"`
+	// how often the files are checked for rotation, 1m by default
+	ReloadInterval string `json:"reload_interval,omitempty"`
+	// 1.0, 1.1, 1.2 or 1.3, 1.2 by default
+	MinVersion string `json:"min_version,omitempty"`
+	// cipher suites of TLS 1.2 and older (e.g. TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256)
+	CipherSuites []string `json:"cipher_suites,omitempty"`
+	// PEM bundle of the client CAs, enables mutual TLS
+	ClientCAFile string `json:"client_ca_file,omitempty"`
+	// require or verify_if_given, require by default
+	ClientAuth string `json:"client_auth,omitempty"`
+	// serve a certificate generated on start, for development only
+	SelfSigned bool `json:"self_signed,omitempty"`
+}
+
+// AuthFlag struct for parsing the authentication of the api.
+type AuthFlag struct {
+	// api keys accepted besides the stored ones
+	APIKeys []APIKeyFlag `json:"api_keys,omitempty"`
+	// JWKS file verifying the JWTs, the JWTs are rejected if not set
+	JWKSFile string `json:"jwks_file,omitempty"`
+	// expected iss and aud claims of the JWTs, not checked if not set
+	Issuer   string `json:"issuer,omitempty"`
+	Audience string `json:"audience,omitempty"`
+}
+
+// APIKeyFlag struct for parsing an api key.
+type APIKeyFlag struct {
+	Name string `json:"name"`
+	// hex SHA-256 of the key
+	Hash string `json:"hash"`
+	// events:read, files:manage, files:upload or admin
+	Scopes []string `json:"scopes"`
+}
+
+// RateLimitFlag struct for parsing the rate limits and the quotas of the api clients.
+type RateLimitFlag struct {
+	// token buckets by route group: events, stream, files, uploads or admin
+	Groups map[string]RuleFlag `json:"groups,omitempty"`
+	// requests per client per UTC day over all groups, not counted if not set
+	DailyQuota int `json:"daily_quota,omitempty"`
+	// how often the quotas are saved to the storage, kept in memory only if not set
+	PersistInterval string `json:"persist_interval,omitempty"`
+	// header with the client IP set by a trusted proxy (e.g. X-Forwarded-For)
+	ClientIPHeader string `json:"client_ip_header,omitempty"`
+	// number of the proxies appending to client_ip_header, 1 by default
+	TrustedProxies int `json:"trusted_proxies,omitempty"`
+}
+
+// RuleFlag struct for parsing a token bucket.
+type RuleFlag struct {
+	// requests per interval
+	Rate int `json:"rate"`
+	// 1s by default
+	Interval string `json:"interval,omitempty"`
+	// biggest burst, rate by default
+	Burst int `json:"burst,omitempty"`
+}
+
+// ReportsFlag struct for parsing how the reports are built.
+type ReportsFlag struct {
+	// file, history or window
+	Mode string `json:"mode,omitempty"`
+	// rolling window of the window mode (e.g. 168h)
+	Window string `json:"window,omitempty"`
+	// unit (<unit>.<format>) or file (<unit>/<file>.<format>)
+	Naming string `json:"naming,omitempty"`
+	// saved formats: pdf, html, csv or xlsx
+	Formats []string `json:"formats,omitempty"`
+	// title of the report
+	Title string `json:"title,omitempty"`
+	// event fields shown in the events table
+	Columns []string `json:"columns,omitempty"`
+	// fonts of the pdf reports, the embedded ones if not set
+	Fonts *FontsFlag `json:"fonts,omitempty"`
+}
+
+// FontsFlag struct for parsing the fonts of the pdf reports.
+type FontsFlag struct {
+	// paths of the TrueType fonts by family, added to the embedded liberation-serif and dejavu-sans
+	Families map[string]string `json:"families,omitempty"`
+	// main family, liberation-serif if not set
+	Family string `json:"family,omitempty"`
+	// size of the events table text, the rest is scaled with it
+	Size float64 `json:"size,omitempty"`
+	// families tried in order for the glyphs missing from the main family
+	// by Unicode script (e.g. Han, Arabic) or "*" for any script
+	Fallbacks map[string][]string `json:"fallbacks,omitempty"`
+}
+
+// RetentionFlag struct for parsing the retention policy.
+type RetentionFlag struct {
+	// how often to prune
+	Interval string `json:"interval"`
+	// max age of an event (e.g. 720h)
+	MaxAge string `json:"max_age,omitempty"`
+	// max number of events kept per unit
+	MaxPerUnit int `json:"max_per_unit,omitempty"`
+	// max number of events kept in total, the total size is counted in events
+	MaxEvents int `json:"max_events,omitempty"`
+	// number of events deleted at once
+	BatchSize int `json:"batch_size,omitempty"`
+	// directory to export events to before deletion
+	ArchiveDir string `json:"archive_dir,omitempty"`
 }
 
 // Config struct for storing config values.
@@ -37,6 +223,16 @@ type Config struct {
 	// mode of the server
 	HTTP  string
 	HTTPS string
+	// TLS config of the https server, nil without https
+	TLS *tlsconfig.Config
+	// grpc server address, empty if disabled
+	GRPC string
+	// origins allowed to open the websocket streams besides the host of the server
+	StreamOrigins []string
+	// authentication of the api, nil if anonymous
+	Auth *auth.Config
+	// rate limits of the api, nil if unlimited
+	RateLimit *RateLimit
 
 	// directories
 	Directory    string
@@ -46,56 +242,740 @@ type Config struct {
 	DBConfig *storage.Config
 	// refresh interval
 	Refresh time.Duration
+	// how long the shutdown waits for the current files and requests
+	ShutdownTimeout time.Duration
+	// log level
+	LogLevel string
+	// biggest upload in bytes, 0 for the default
+	MaxUploadSize int64
+	// event cache, nil if disabled
+	EventCache *EventCache
+
+	// retention policy, nil if disabled
+	Retention *Retention
+
+	// reports config
+	Reports Reports
+
+	// watch pipelines, the default pipeline of the directories if none are configured
+	Pipelines []Pipeline
+
+	// flags the config is built from, compared by Changed
+	flags Flag
 }
 
-var f Flag
+// Pipeline struct for storing a named watch pipeline.
+type Pipeline struct {
+	Name         string
+	Directory    string
+	DirectoryOut string
+	Refresh      time.Duration
+	Columns      map[string]string
+	// own storage config, nil for the top level storage
+	DBConfig *storage.Config
+	// own reports, nil for the top level reports
+	Reports *Reports
+}
+
+// RateLimit struct for storing the rate limits and the quotas of the api clients.
+type RateLimit struct {
+	Groups     map[string]ratelimit.Rule
+	DailyQuota int
+	// PersistInterval is zero if the quotas are kept in memory only
+	PersistInterval time.Duration
+	ClientIPHeader  string
+	TrustedProxies  int
+}
+
+// EventCache struct for storing the event cache config.
+type EventCache struct {
+	Size int
+	TTL  time.Duration
+}
+
+// Reports struct for storing how the reports are built.
+type Reports struct {
+	Mode    string
+	Window  time.Duration
+	Naming  string
+	Title   string
+	Columns []string
+	Formats []string
+	Fonts   *report.Fonts
+}
+
+// Retention struct for storing the retention policy.
+type Retention struct {
+	Interval   time.Duration
+	MaxAge     time.Duration
+	MaxPerUnit int
+	MaxEvents  int
+	BatchSize  int
+	ArchiveDir string
+}
+
+// DefaultShutdownTimeout is how long the shutdown waits unless shutdown_timeout is set.
+const DefaultShutdownTimeout = 30 * time.Second
+
+// ErrConfigPrinted error occurs when -print-config printed the config instead of loading it.
+var ErrConfigPrinted = errors.New("config printed")
+
+// live are the fields applied by Reload without a restart.
+var live = map[string]bool{
+	"directory":        true,
+	"refresh_interval": true,
+	"log_level":        true,
+	"max_upload_size":  true,
+	"event_cache":      true,
+	"reports":          true,
+}
+
+// livePipeline are the fields of the running pipelines applied by Reload without a restart.
+var livePipeline = map[string]bool{
+	"directory":        true,
+	"refresh_interval": true,
+}
+
+// pipelineName matches the names of the pipelines.
+var pipelineName = regexp.MustCompile(`^[a-z0-9_-]+$`)
+
+var (
+	f           Flag
+	printConfig *bool
+	flags       []*override
+	// loaded is the config file loaded by the last load, empty if none
+	loaded string
+)
 
 func init() {
-	f.ConfigFile = flag.String("c", "config.json", "-c=config.json")
+	f.ConfigFile = flag.String("c", "config.json", "-c=config.json, $WATCHER_CONFIG")
+	printConfig = flag.Bool("print-config", false, "print the effective config with the secrets redacted and exit")
+	flags = registerOverrides(flag.CommandLine)
+}
+
+// defaults returns the flags used unless set by the config file, env or cmd args.
+func defaults() Flag {
+	autoMigrate := true
+	return Flag{
+		AutoMigrate:     &autoMigrate,
+		ShutdownTimeout: DefaultShutdownTimeout.String(),
+		LogLevel:        "info",
+		Reports:         &ReportsFlag{Mode: "file", Naming: "unit", Formats: []string{"pdf"}},
+	}
 }
 
 // New returns a new Config struct.
+// The cmd args override the env, the env overrides the config file, the file overrides the defaults.
+// All problems of the config are returned at once as a *ValidationError.
 func New() (*Config, error) {
 	flag.Parse()
 
-	if f.ConfigFile == nil {
-		return nil, fmt.Errorf("config file is required")
+	errs := &ValidationError{}
+	if err := load(errs); err != nil {
+		return nil, err
 	}
 
-	err := Modify(*f.ConfigFile)
-	if err != nil {
-		return nil, fmt.Errorf("can't modify config: %v", err)
+	if *printConfig {
+		out, err := json.MarshalIndent(redact(f), "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("can't marshal config: %v", err)
+		}
+		fmt.Println(string(out))
+		return nil, ErrConfigPrinted
 	}
 
-	dur, err := time.ParseDuration(f.Refresh)
-	if err != nil {
-		return nil, fmt.Errorf("can't parse refresh duration: %v", err)
+	cfg := build(f, errs)
+	if err := errs.err(); err != nil {
+		return nil, err
+	}
+
+	for _, p := range cfg.Pipelines {
+		err := os.Mkdir(p.DirectoryOut, 0755)
+		if err != nil && !os.IsExist(err) {
+			return nil, fmt.Errorf("can't create directory_out of the %s pipeline: %v", p.Name, err)
+		}
+	}
+
+	return cfg, nil
+}
+
+// Reload loads the config again from the same file, env and cmd args as New.
+func Reload() (*Config, error) {
+	errs := &ValidationError{}
+	if err := load(errs); err != nil {
+		return nil, err
+	}
+
+	cfg := build(f, errs)
+	if err := errs.err(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// File returns the loaded config file, empty if the config is loaded from the env and cmd args only.
+func File() string {
+	return loaded
+}
+
+// Live reports whether the field returned by Changed is applied by a reload, the rest require a restart.
+func Live(field string) bool {
+	if _, name, ok := PipelineField(field); ok {
+		return livePipeline[name]
+	}
+	return live[field]
+}
+
+// PipelineField splits the field pipelines.<pipeline>.<name> returned by Changed.
+func PipelineField(field string) (pipeline, name string, ok bool) {
+	parts := strings.Split(field, ".")
+	if len(parts) != 3 || parts[0] != "pipelines" {
+		return "", "", false
+	}
+	return parts[1], parts[2], true
+}
+
+// Changed returns the top level fields of the config changed since the old one, the changes
+// of the pipelines are returned as pipelines.<pipeline>.<name> for the fields of the pipelines
+// in both configs, pipelines.<pipeline> for the added and removed ones and pipelines for the new order.
+func (c *Config) Changed(old *Config) []string {
+	var changed []string
+	t, cur, prev := reflect.TypeOf(Flag{}), reflect.ValueOf(c.flags), reflect.ValueOf(old.flags)
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name == "-" || reflect.DeepEqual(cur.Field(i).Interface(), prev.Field(i).Interface()) {
+			continue
+		}
+		if name == "pipelines" {
+			changed = append(changed, pipelineChanges(c.flags.Pipelines, old.flags.Pipelines)...)
+			continue
+		}
+		changed = append(changed, name)
+	}
+	return changed
+}
+
+// pipelineChanges returns the changes of the pipelines in the format of Changed.
+func pipelineChanges(cur, prev []PipelineFlag) []string {
+	old := make(map[string]PipelineFlag, len(prev))
+	for _, p := range prev {
+		old[p.Name] = p
+	}
+
+	var changed, kept []string
+	t := reflect.TypeOf(PipelineFlag{})
+	for _, p := range cur {
+		o, ok := old[p.Name]
+		if !ok {
+			changed = append(changed, "pipelines."+p.Name)
+			continue
+		}
+		delete(old, p.Name)
+		kept = append(kept, p.Name)
+
+		a, b := reflect.ValueOf(p), reflect.ValueOf(o)
+		for i := 0; i < t.NumField(); i++ {
+			if !reflect.DeepEqual(a.Field(i).Interface(), b.Field(i).Interface()) {
+				name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+				changed = append(changed, "pipelines."+p.Name+"."+name)
+			}
+		}
+	}
+
+	var before []string
+	for _, p := range prev {
+		if _, removed := old[p.Name]; removed {
+			changed = append(changed, "pipelines."+p.Name)
+			continue
+		}
+		before = append(before, p.Name)
+	}
+
+	// the first pipeline is the default one
+	if !reflect.DeepEqual(kept, before) {
+		changed = append(changed, "pipelines")
+	}
+	return changed
+}
+
+// load fills f from the defaults, the config file, the env and the cmd args,
+// the problems of the config file are added to errs.
+// The config file is optional unless set by -c or $WATCHER_CONFIG.
+func load(errs *ValidationError) error {
+	configFile := f.ConfigFile
+	f = defaults()
+	f.ConfigFile = configFile
+
+	filename, explicit := *configFile, false
+	flag.Visit(func(fl *flag.Flag) {
+		explicit = explicit || fl.Name == "c"
+	})
+	if env, ok := os.LookupEnv(envPrefix + "CONFIG"); ok && !explicit {
+		filename, explicit = env, true
+	}
+
+	loaded = ""
+	if _, err := os.Stat(filename); explicit || !os.IsNotExist(err) {
+		loaded = filename
+		var ve *ValidationError
+		err = Modify(filename)
+		switch {
+		case errors.As(err, &ve):
+			errs.Problems = append(errs.Problems, ve.Problems...)
+		case err != nil:
+			return fmt.Errorf("can't modify config: %v", err)
+		}
+	}
+
+	if err := applyEnv(&f, flags, os.LookupEnv); err != nil {
+		return err
+	}
+	return applyFlags(&f, flags)
+}
+
+// build validates the flags and converts them to the config, the problems are added to errs.
+func build(fl Flag, errs *ValidationError) *Config {
+	// the pipelines with their own refresh interval don't need the top level one
+	var refresh time.Duration
+	if fl.Refresh != "" || len(fl.Pipelines) == 0 {
+		refresh = parsePositive(errs, "refresh_interval", fl.Refresh)
+	}
+
+	shutdownTimeout := DefaultShutdownTimeout
+	if fl.ShutdownTimeout != "" {
+		shutdownTimeout = parsePositive(errs, "shutdown_timeout", fl.ShutdownTimeout)
+	}
+
+	if fl.MaxUploadSize < 0 {
+		errs.add("max_upload_size", "must not be negative")
+	}
+
+	logLevel := fl.LogLevel
+	if logLevel == "" {
+		logLevel = "info"
+	}
+	if _, err := zerolog.ParseLevel(logLevel); err != nil {
+		errs.add("log_level", "unknown level %q, expected debug, info, warn or error", logLevel)
 	}
 
-	if f.DirectoryOut == "" {
-		return nil, fmt.Errorf("directory_out is required")
+	autoMigrate := true
+	if fl.AutoMigrate != nil {
+		autoMigrate = *fl.AutoMigrate
 	}
+	pipelines := newPipelines(fl, refresh, autoMigrate, errs)
+	checkStorage(errs, "", fl.Storage, fl.DSN)
 
-	err = os.Mkdir(f.DirectoryOut, 0755)
-	if err != nil && !os.IsExist(err) {
-		return nil, fmt.Errorf("can't create directory_out: %v", err)
+	switch {
+	case fl.HTTP == "" && fl.HTTPS == "":
+		errs.add("http", "http or https is required")
+	case fl.HTTP != "" && fl.HTTPS != "":
+		errs.add("https", "can't be used with http")
 	}
+	checkAddress(errs, "http", fl.HTTP)
+	checkAddress(errs, "https", fl.HTTPS)
+	checkAddress(errs, "grpc", fl.GRPC)
 
 	return &Config{
-		HTTP:  f.HTTP,
-		HTTPS: f.HTTPS,
+		HTTP:          fl.HTTP,
+		HTTPS:         fl.HTTPS,
+		TLS:           newTLS(fl.TLS, fl.HTTPS, errs),
+		GRPC:          fl.GRPC,
+		StreamOrigins: newStreamOrigins(fl.StreamOrigins, errs),
+		Auth:          newAuth(fl.Auth, errs),
+		RateLimit:     newRateLimit(fl.RateLimit, errs),
 
 		DBConfig: &storage.Config{
-			Type:           f.Storage,
-			DataSourceCred: f.DSN,
+			Type:           fl.Storage,
+			DataSourceCred: fl.DSN,
+			AutoMigrate:    autoMigrate,
 		},
-		DirectoryOut: f.DirectoryOut,
-		Directory:    f.Directory,
-		Refresh:      dur,
-	}, nil
+		DirectoryOut:    fl.DirectoryOut,
+		Directory:       fl.Directory,
+		Refresh:         refresh,
+		ShutdownTimeout: shutdownTimeout,
+		LogLevel:        logLevel,
+		MaxUploadSize:   fl.MaxUploadSize,
+		EventCache:      newEventCache(fl.EventCache, errs),
+		Retention:       newRetention(fl.Retention, errs),
+		Reports:         newReports(fl.Reports, "reports", errs),
+		Pipelines:       pipelines,
+
+		flags: fl,
+	}
+}
+
+// newStreamOrigins validates the stream origins and converts them to the lowercase scheme://host form.
+func newStreamOrigins(origins []string, errs *ValidationError) []string {
+	var out []string
+	for i, origin := range origins {
+		if origin == "*" {
+			out = append(out, origin)
+			continue
+		}
+		u, err := url.Parse(origin)
+		if err != nil || u.Scheme == "" || u.Host == "" || strings.Trim(u.Path, "/") != "" {
+			errs.add(fmt.Sprintf("stream_origins[%d]", i), "must be scheme://host[:port] or *, got %q", origin)
+			continue
+		}
+		out = append(out, strings.ToLower(u.Scheme+"://"+u.Host))
+	}
+	return out
+}
+
+// parsePositive parses the positive duration of the field.
+func parsePositive(errs *ValidationError, field, value string) time.Duration {
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		errs.add(field, "must be a positive duration, got %q", value)
+		return 0
+	}
+	return d
+}
+
+// newPipelines validates and converts the pipelines flags,
+// the top level directories make the default pipeline if no pipelines are set.
+func newPipelines(fl Flag, refresh time.Duration, autoMigrate bool, errs *ValidationError) []Pipeline {
+	if len(fl.Pipelines) == 0 {
+		checkDirectory(errs, "directory", fl.Directory)
+		checkDirectoryOut(errs, "directory_out", fl.DirectoryOut)
+		return []Pipeline{{
+			Name:         events.DefaultPipeline,
+			Directory:    fl.Directory,
+			DirectoryOut: fl.DirectoryOut,
+			Refresh:      refresh,
+		}}
+	}
+
+	if fl.Directory != "" {
+		errs.add("directory", "can't be used with pipelines")
+	}
+	if fl.DirectoryOut != "" {
+		errs.add("directory_out", "can't be used with pipelines")
+	}
+
+	pipelines := make([]Pipeline, 0, len(fl.Pipelines))
+	names, dirs := make(map[string]bool), make(map[string]string)
+	for i, pf := range fl.Pipelines {
+		field := fmt.Sprintf("pipelines[%d]", i)
+		switch {
+		case pf.Name == "":
+			errs.add(field+".name", "is required")
+		case !pipelineName.MatchString(pf.Name):
+			errs.add(field+".name", "must be lowercase letters, digits, - and _, got %q", pf.Name)
+		case names[pf.Name]:
+			errs.add(field+".name", "%q is duplicated", pf.Name)
+		}
+		names[pf.Name] = true
+
+		checkDirectory(errs, field+".directory", pf.Directory)
+		if other, ok := dirs[filepath.Clean(pf.Directory)]; ok && pf.Directory != "" {
+			errs.add(field+".directory", "%s is watched by %s", pf.Directory, other)
+		}
+		dirs[filepath.Clean(pf.Directory)] = field
+		checkDirectoryOut(errs, field+".directory_out", pf.DirectoryOut)
+
+		p := Pipeline{
+			Name:         pf.Name,
+			Directory:    pf.Directory,
+			DirectoryOut: pf.DirectoryOut,
+			Refresh:      refresh,
+			Columns:      pf.Columns,
+		}
+
+		switch {
+		case pf.Refresh != "":
+			p.Refresh = parsePositive(errs, field+".refresh_interval", pf.Refresh)
+		case fl.Refresh == "":
+			errs.add(field+".refresh_interval", "is required without the top level refresh_interval")
+		}
+
+		if err := events.ValidateColumns(pf.Columns); err != nil {
+			errs.addErr(field+".columns", err)
+		}
+
+		if pf.Storage != "" || pf.DSN != "" {
+			checkStorage(errs, field+".", pf.Storage, pf.DSN)
+			p.DBConfig = &storage.Config{Type: pf.Storage, DataSourceCred: pf.DSN, AutoMigrate: autoMigrate}
+		}
+
+		if pf.Reports != nil {
+			reports := newReports(pf.Reports, field+".reports", errs)
+			p.Reports = &reports
+		}
+
+		pipelines = append(pipelines, p)
+	}
+	return pipelines
+}
+
+// newRetention validates and converts the retention flags.
+func newRetention(rf *RetentionFlag, errs *ValidationError) *Retention {
+	if rf == nil {
+		return nil
+	}
+
+	r := &Retention{
+		Interval:   parsePositive(errs, "retention.interval", rf.Interval),
+		MaxPerUnit: rf.MaxPerUnit,
+		MaxEvents:  rf.MaxEvents,
+		BatchSize:  rf.BatchSize,
+		ArchiveDir: rf.ArchiveDir,
+	}
+	if rf.MaxAge != "" {
+		r.MaxAge = parsePositive(errs, "retention.max_age", rf.MaxAge)
+	}
+
+	if rf.MaxAge == "" && rf.MaxPerUnit <= 0 && rf.MaxEvents <= 0 {
+		errs.add("retention", "requires max_age, max_per_unit or max_events")
+	}
+	if rf.BatchSize < 0 {
+		errs.add("retention.batch_size", "must not be negative")
+	}
+
+	return r
 }
 
-// Modify modifies the config by the file provided.
+// newTLS validates and converts the TLS flags of the https server.
+func newTLS(tf *TLSFlag, https string, errs *ValidationError) *tlsconfig.Config {
+	if https == "" {
+		if tf != nil {
+			errs.add("tls", "requires https")
+		}
+		return nil
+	}
+	if tf == nil {
+		errs.addErr("tls", fmt.Errorf("is required with https: %w", tlsconfig.ErrNoCertificate))
+		return nil
+	}
+
+	if (tf.CertFile == "") != (tf.KeyFile == "") {
+		errs.add("tls", "cert_file and key_file must be set together")
+	}
+	if tf.CertFile == "" && tf.KeyFile == "" && !tf.SelfSigned {
+		errs.addErr("tls", tlsconfig.ErrNoCertificate)
+	}
+	if tf.CertFile != "" && tf.SelfSigned {
+		errs.add("tls.self_signed", "can't be used with cert_file")
+	}
+
+	tc := &tlsconfig.Config{
+		CertFile:     tf.CertFile,
+		KeyFile:      tf.KeyFile,
+		ClientCAFile: tf.ClientCAFile,
+		SelfSigned:   tf.SelfSigned,
+	}
+
+	if tf.ReloadInterval != "" {
+		tc.ReloadInterval = parsePositive(errs, "tls.reload_interval", tf.ReloadInterval)
+	}
+
+	var err error
+	if tf.MinVersion != "" {
+		if tc.MinVersion, err = tlsconfig.ParseVersion(tf.MinVersion); err != nil {
+			errs.addErr("tls.min_version", err)
+		}
+	}
+
+	if tc.CipherSuites, err = tlsconfig.ParseCipherSuites(tf.CipherSuites); err != nil {
+		errs.addErr("tls.cipher_suites", err)
+	}
+
+	if tf.ClientAuth != "" && tf.ClientCAFile == "" {
+		errs.add("tls.client_auth", "requires client_ca_file")
+	}
+	if tc.ClientAuth, err = tlsconfig.ParseClientAuth(tf.ClientAuth); err != nil {
+		errs.addErr("tls.client_auth", err)
+	}
+
+	return tc
+}
+
+// newAuth validates and converts the authentication flags.
+func newAuth(af *AuthFlag, errs *ValidationError) *auth.Config {
+	if af == nil {
+		return nil
+	}
+
+	ac := &auth.Config{Issuer: af.Issuer, Audience: af.Audience}
+	for i, k := range af.APIKeys {
+		field := fmt.Sprintf("auth.api_keys[%d]", i)
+		if k.Name == "" {
+			errs.add(field+".name", "is required")
+		}
+		if _, err := hex.DecodeString(k.Hash); err != nil || len(k.Hash) != 2*sha256.Size {
+			errs.add(field+".hash", "must be the hex SHA-256 of the key")
+		}
+		if len(k.Scopes) == 0 {
+			errs.add(field+".scopes", "is required")
+		} else if err := auth.ValidateScopes(k.Scopes); err != nil {
+			errs.addErr(field+".scopes", err)
+		}
+		ac.Keys = append(ac.Keys, auth.Key{Name: k.Name, Hash: k.Hash, Scopes: k.Scopes})
+	}
+
+	if af.JWKSFile != "" {
+		jwks, err := auth.LoadJWKS(af.JWKSFile)
+		if err != nil {
+			errs.addErr("auth.jwks_file", err)
+		}
+		ac.JWKS = jwks
+	}
+
+	return ac
+}
+
+// newEventCache validates and converts the event cache flags.
+func newEventCache(cf *EventCacheFlag, errs *ValidationError) *EventCache {
+	if cf == nil {
+		return nil
+	}
+	if cf.Size <= 0 {
+		errs.add("event_cache.size", "must be positive")
+	}
+
+	ec := &EventCache{Size: cf.Size}
+	if cf.TTL != "" {
+		ec.TTL = parsePositive(errs, "event_cache.ttl", cf.TTL)
+	}
+	return ec
+}
+
+// newRateLimit validates and converts the rate limit flags.
+func newRateLimit(rf *RateLimitFlag, errs *ValidationError) *RateLimit {
+	if rf == nil {
+		return nil
+	}
+
+	rl := &RateLimit{
+		Groups:         make(map[string]ratelimit.Rule, len(rf.Groups)),
+		DailyQuota:     rf.DailyQuota,
+		ClientIPHeader: rf.ClientIPHeader,
+		TrustedProxies: rf.TrustedProxies,
+	}
+
+	groups := make([]string, 0, len(rf.Groups))
+	for group := range rf.Groups {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+
+	for _, group := range groups {
+		rule, field := rf.Groups[group], "rate_limit.groups."+group
+		if !validGroup(group) {
+			errs.add(field, "unknown group, expected one of %s", strings.Join(ratelimit.RouteGroups, ", "))
+			continue
+		}
+		if rule.Rate <= 0 {
+			errs.add(field+".rate", "must be positive")
+		}
+		if rule.Burst < 0 {
+			errs.add(field+".burst", "must not be negative")
+		}
+
+		interval := time.Second
+		if rule.Interval != "" {
+			interval = parsePositive(errs, field+".interval", rule.Interval)
+		}
+		rl.Groups[group] = ratelimit.Rule{Rate: rule.Rate, Interval: interval, Burst: rule.Burst}
+	}
+
+	if rf.DailyQuota < 0 {
+		errs.add("rate_limit.daily_quota", "must not be negative")
+	}
+	if rf.TrustedProxies < 0 {
+		errs.add("rate_limit.trusted_proxies", "must not be negative")
+	} else if rf.TrustedProxies > 0 && rf.ClientIPHeader == "" {
+		errs.add("rate_limit.trusted_proxies", "requires client_ip_header")
+	}
+	if rf.PersistInterval != "" {
+		if rf.DailyQuota == 0 {
+			errs.add("rate_limit.persist_interval", "requires daily_quota")
+		}
+		rl.PersistInterval = parsePositive(errs, "rate_limit.persist_interval", rf.PersistInterval)
+	}
+
+	return rl
+}
+
+// validGroup reports whether the route group exists.
+func validGroup(group string) bool {
+	for _, g := range ratelimit.RouteGroups {
+		if g == group {
+			return true
+		}
+	}
+	return false
+}
+
+// newReports validates and converts the reports flags of the field.
+func newReports(rf *ReportsFlag, field string, errs *ValidationError) Reports {
+	reports := Reports{Mode: "file", Naming: "unit", Formats: []string{"pdf"}}
+
+	var fc report.FontConfig
+	if rf != nil && rf.Fonts != nil {
+		fc = report.FontConfig{
+			Families:  rf.Fonts.Families,
+			Family:    rf.Fonts.Family,
+			Size:      rf.Fonts.Size,
+			Fallbacks: rf.Fonts.Fallbacks,
+		}
+	}
+	fonts, err := report.LoadFonts(fc)
+	if err != nil {
+		errs.addErr(field+".fonts", err)
+	}
+	reports.Fonts = fonts
+
+	if rf == nil {
+		return reports
+	}
+
+	if rf.Mode != "" {
+		reports.Mode = rf.Mode
+	}
+	if rf.Naming != "" {
+		reports.Naming = rf.Naming
+	}
+
+	if err := report.ValidateColumns(rf.Columns); err != nil {
+		errs.addErr(field+".columns", err)
+	}
+	reports.Title, reports.Columns = rf.Title, rf.Columns
+
+	for i, format := range rf.Formats {
+		switch format {
+		case "pdf", "html", "csv", "xlsx":
+		default:
+			errs.add(fmt.Sprintf("%s.formats[%d]", field, i), "unknown format %q", format)
+		}
+	}
+	if len(rf.Formats) != 0 {
+		reports.Formats = rf.Formats
+	}
+
+	switch reports.Mode {
+	case "file", "history":
+	case "window":
+		reports.Window = parsePositive(errs, field+".window", rf.Window)
+	default:
+		errs.add(field+".mode", "unknown mode %q", reports.Mode)
+	}
+
+	switch reports.Naming {
+	case "unit":
+	case "file":
+		if reports.Mode != "file" {
+			errs.add(field+".naming", "file requires mode file")
+		}
+	default:
+		errs.add(field+".naming", "unknown naming %q", reports.Naming)
+	}
+
+	return reports
+}
+
+// Modify modifies the config by the JSON, YAML or TOML file provided, chosen by the extension.
+// The unknown keys and the values of wrong types are returned as a *ValidationError.
 func Modify(filename string) error {
 	file, err := os.Open(filename)
 	if err != nil {
@@ -108,9 +988,9 @@ func Modify(filename string) error {
 		return fmt.Errorf("can't read %s: %v", filename, err)
 	}
 
-	err = json.Unmarshal(all, &f)
-	if err != nil {
+	errs := &ValidationError{}
+	if err = decodeFile(filename, all, &f, errs); err != nil {
 		return fmt.Errorf("can't unmarshal %s: %v", filename, err)
 	}
-	return nil
+	return errs.err()
 }
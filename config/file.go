@@ -0,0 +1,171 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+	"math"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// decodeFile decodes the JSON, YAML or TOML config file chosen by the extension into fl,
+// the unknown keys and the values of wrong types are added to errs.
+func decodeFile(filename string, data []byte, fl *Flag, errs *ValidationError) error {
+	var (
+		tree interface{}
+		err  error
+	)
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &tree)
+	case ".toml":
+		var table map[string]interface{}
+		err = toml.Unmarshal(data, &table)
+		tree = table
+	default:
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		err = dec.Decode(&tree)
+	}
+	if err != nil {
+		return err
+	}
+	if tree == nil {
+		return nil
+	}
+
+	before := len(errs.Problems)
+	check(tree, reflect.TypeOf(Flag{}), "", errs)
+
+	// the values of wrong types are skipped, the rest is decoded
+	all, err := json.Marshal(tree)
+	if err == nil {
+		err = json.Unmarshal(all, fl)
+	}
+	if err != nil && len(errs.Problems) == before {
+		return err
+	}
+	return nil
+}
+
+// check adds the keys of the tree unknown to the type and the values of wrong types to errs.
+func check(tree interface{}, t reflect.Type, path string, errs *ValidationError) {
+	if tree == nil {
+		return
+	}
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		object, ok := tree.(map[string]interface{})
+		if !ok {
+			errs.add(root(path), "must be an object")
+			return
+		}
+		for _, key := range sortedKeys(object) {
+			field, ok := fieldByTag(t, key)
+			if !ok {
+				errs.add(join(path, key), "unknown field")
+				continue
+			}
+			check(object[key], field.Type, join(path, key), errs)
+		}
+	case reflect.Map:
+		object, ok := tree.(map[string]interface{})
+		if !ok {
+			errs.add(root(path), "must be an object with string keys")
+			return
+		}
+		for _, key := range sortedKeys(object) {
+			check(object[key], t.Elem(), join(path, key), errs)
+		}
+	case reflect.Slice:
+		list := reflect.ValueOf(tree)
+		if list.Kind() != reflect.Slice {
+			errs.add(path, "must be a list")
+			return
+		}
+		for i := 0; i < list.Len(); i++ {
+			check(list.Index(i).Interface(), t.Elem(), fmt.Sprintf("%s[%d]", path, i), errs)
+		}
+	case reflect.String:
+		if _, ok := tree.(string); !ok {
+			errs.add(path, "must be a string")
+		}
+	case reflect.Bool:
+		if _, ok := tree.(bool); !ok {
+			errs.add(path, "must be a boolean")
+		}
+	case reflect.Int, reflect.Int64:
+		if n, ok := number(tree); !ok || n != math.Trunc(n) {
+			errs.add(path, "must be an integer")
+		}
+	case reflect.Float64:
+		if _, ok := number(tree); !ok {
+			errs.add(path, "must be a number")
+		}
+	}
+}
+
+// sortedKeys returns the keys of the object in order.
+func sortedKeys(object map[string]interface{}) []string {
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// fieldByTag returns the field of the struct type by its json tag.
+func fieldByTag(t reflect.Type, key string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		tag, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if tag != "-" && tag == key {
+			return t.Field(i), true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// number returns the value of a JSON, YAML or TOML number.
+func number(value interface{}) (float64, bool) {
+	switch n := value.(type) {
+	case json.Number:
+		f, err := strconv.ParseFloat(string(n), 64)
+		return f, err == nil
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+// join returns the path of the key of the object at the path.
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// root names the top level of the file in the problems.
+func root(path string) string {
+	if path == "" {
+		return "(root)"
+	}
+	return path
+}
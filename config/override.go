@@ -0,0 +1,239 @@
+package config
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// envPrefix is the prefix of the environment variables overriding the config fields.
+const envPrefix = "WATCHER_"
+
+// redacted replaces the secrets printed by -print-config.
+const redacted = "REDACTED"
+
+// override is a config field settable from the environment and the command line.
+type override struct {
+	// name is the path of the field in the config file, e.g. tls.cert_file
+	name string
+	// index is the path of the field in Flag
+	index []int
+	// value is the value given on the command line, nil if not given
+	value *string
+	bool  bool
+}
+
+// overrides returns the overridable fields of Flag, the nested structs are flattened.
+func overrides() []*override {
+	return fieldsOf(reflect.TypeOf(Flag{}), "", nil)
+}
+
+// fieldsOf returns the overridable fields of the struct type.
+func fieldsOf(t reflect.Type, prefix string, index []int) []*override {
+	var list []*override
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if tag == "-" || tag == "" {
+			continue
+		}
+
+		name := prefix + tag
+		path := append(append([]int(nil), index...), i)
+
+		ft := field.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if ft.Kind() == reflect.Struct {
+			list = append(list, fieldsOf(ft, name+".", path)...)
+			continue
+		}
+		list = append(list, &override{name: name, index: path, bool: ft.Kind() == reflect.Bool})
+	}
+	return list
+}
+
+// env returns the environment variable of the field, e.g. WATCHER_TLS_CERT_FILE.
+func (o *override) env() string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(o.name, ".", "_"))
+}
+
+// flag returns the command line flag of the field, e.g. tls-cert-file.
+func (o *override) flag() string {
+	return strings.NewReplacer(".", "-", "_", "-").Replace(o.name)
+}
+
+// String implements flag.Value.
+func (o *override) String() string {
+	if o == nil || o.value == nil {
+		return ""
+	}
+	return *o.value
+}
+
+// Set implements flag.Value, the value is applied after the environment.
+func (o *override) Set(value string) error {
+	o.value = &value
+	return nil
+}
+
+// IsBoolFlag allows the bool flags without a value.
+func (o *override) IsBoolFlag() bool {
+	return o.bool
+}
+
+// registerOverrides adds a flag for every overridable field to the flag set.
+func registerOverrides(fs *flag.FlagSet) []*override {
+	list := overrides()
+	for _, o := range list {
+		fs.Var(o, o.flag(), fmt.Sprintf("overrides %s of the config file and $%s", o.name, o.env()))
+	}
+	return list
+}
+
+// applyEnv sets the fields from the environment variables found by lookup.
+func applyEnv(fl *Flag, list []*override, lookup func(string) (string, bool)) error {
+	for _, o := range list {
+		value, ok := lookup(o.env())
+		if !ok {
+			continue
+		}
+		if err := o.apply(fl, value); err != nil {
+			return fmt.Errorf("can't parse $%s: %v", o.env(), err)
+		}
+	}
+	return nil
+}
+
+// applyFlags sets the fields given on the command line.
+func applyFlags(fl *Flag, list []*override) error {
+	for _, o := range list {
+		if o.value == nil {
+			continue
+		}
+		if err := o.apply(fl, *o.value); err != nil {
+			return fmt.Errorf("can't parse -%s: %v", o.flag(), err)
+		}
+	}
+	return nil
+}
+
+// apply sets the field of fl, creating the structs on the way.
+func (o *override) apply(fl *Flag, value string) error {
+	v := reflect.ValueOf(fl).Elem()
+	for _, i := range o.index {
+		if v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(i)
+	}
+	return setValue(v, value)
+}
+
+// setValue parses the value by the kind of v, the lists are comma-separated
+// and the maps and lists of objects are JSON.
+func setValue(v reflect.Value, value string) error {
+	switch v.Kind() {
+	case reflect.Ptr:
+		elem := reflect.New(v.Type().Elem())
+		if err := setValue(elem.Elem(), value); err != nil {
+			return err
+		}
+		v.Set(elem)
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		if v.OverflowInt(n) {
+			return fmt.Errorf("%d is out of range", n)
+		}
+		v.SetInt(n)
+	case reflect.Float64:
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(n)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String {
+			return setJSON(v, value)
+		}
+		var list []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		v.Set(reflect.ValueOf(list))
+	default:
+		return setJSON(v, value)
+	}
+	return nil
+}
+
+// setJSON unmarshals the value into a new value of the type of v.
+func setJSON(v reflect.Value, value string) error {
+	ptr := reflect.New(v.Type())
+	if err := json.Unmarshal([]byte(value), ptr.Interface()); err != nil {
+		return err
+	}
+	v.Set(ptr.Elem())
+	return nil
+}
+
+// passwordParam matches the password of a key=value DSN.
+var passwordParam = regexp.MustCompile(`(?i)(password\s*=\s*)('[^']*'|\S+)`)
+
+// redact returns a copy of the flags with the secrets replaced.
+func redact(fl Flag) Flag {
+	fl.DSN = redactDSN(fl.DSN)
+
+	if fl.Auth != nil {
+		a := *fl.Auth
+		a.APIKeys = make([]APIKeyFlag, len(fl.Auth.APIKeys))
+		for i, k := range fl.Auth.APIKeys {
+			k.Hash = redacted
+			a.APIKeys[i] = k
+		}
+		fl.Auth = &a
+	}
+
+	if fl.Pipelines != nil {
+		pipelines := make([]PipelineFlag, len(fl.Pipelines))
+		for i, p := range fl.Pipelines {
+			p.DSN = redactDSN(p.DSN)
+			pipelines[i] = p
+		}
+		fl.Pipelines = pipelines
+	}
+	return fl
+}
+
+// redactDSN replaces the password of a URL or key=value DSN.
+func redactDSN(dsn string) string {
+	if u, err := url.Parse(dsn); err == nil && u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), redacted)
+			return u.String()
+		}
+	}
+	return passwordParam.ReplaceAllString(dsn, "${1}"+redacted)
+}
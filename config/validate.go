@@ -0,0 +1,212 @@
+package config
+
+import (
+	"fmt"
+	"go-tsv-watcher/internal/storage"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FieldError is a problem of a config field.
+type FieldError struct {
+	// Field is the path of the field in the config file, e.g. tls.cert_file
+	Field string
+	Err   error
+}
+
+// Error implements error.
+func (e FieldError) Error() string {
+	return e.Field + ": " + e.Err.Error()
+}
+
+// Unwrap returns the problem.
+func (e FieldError) Unwrap() error {
+	return e.Err
+}
+
+// ValidationError lists all problems of the config.
+type ValidationError struct {
+	Problems []FieldError
+}
+
+// Error implements error.
+func (e *ValidationError) Error() string {
+	var sb strings.Builder
+	sb.WriteString("invalid config:")
+	for _, p := range e.Problems {
+		sb.WriteString("\n  ")
+		sb.WriteString(p.Error())
+	}
+	return sb.String()
+}
+
+// add adds a problem of the field.
+func (e *ValidationError) add(field, format string, args ...interface{}) {
+	e.addErr(field, fmt.Errorf(format, args...))
+}
+
+// addErr adds the error as a problem of the field,
+// only the first problem of a field is kept, e.g. a value of a wrong type is not reported as empty.
+func (e *ValidationError) addErr(field string, err error) {
+	for _, p := range e.Problems {
+		if p.Field == field {
+			return
+		}
+	}
+	e.Problems = append(e.Problems, FieldError{Field: field, Err: err})
+}
+
+// err returns e if there are problems, nil otherwise.
+func (e *ValidationError) err() error {
+	if len(e.Problems) == 0 {
+		return nil
+	}
+	return e
+}
+
+// checkDirectory checks that the directory exists and is readable.
+func checkDirectory(errs *ValidationError, field, dir string) {
+	if dir == "" {
+		errs.add(field, "is required")
+		return
+	}
+
+	info, err := os.Stat(dir)
+	if err != nil {
+		errs.add(field, "can't stat %s: %v", dir, err)
+		return
+	}
+	if !info.IsDir() {
+		errs.add(field, "%s is not a directory", dir)
+		return
+	}
+
+	d, err := os.Open(dir)
+	if err == nil {
+		_, err = d.Readdirnames(1)
+		d.Close()
+	}
+	if err != nil && err != io.EOF {
+		errs.add(field, "%s is not readable: %v", dir, err)
+	}
+}
+
+// checkDirectoryOut checks that the output directory is a directory or can be created.
+func checkDirectoryOut(errs *ValidationError, field, dir string) {
+	if dir == "" {
+		errs.add(field, "is required")
+		return
+	}
+
+	info, err := os.Stat(dir)
+	switch {
+	case os.IsNotExist(err):
+		if _, err = os.Stat(filepath.Dir(dir)); err != nil {
+			errs.add(field, "can't create %s: %v", dir, err)
+		}
+	case err != nil:
+		errs.add(field, "can't stat %s: %v", dir, err)
+	case !info.IsDir():
+		errs.add(field, "%s is not a directory", dir)
+	}
+}
+
+// checkAddress checks the host:port address if set.
+func checkAddress(errs *ValidationError, field, addr string) {
+	if addr == "" {
+		return
+	}
+	if err := parseAddress(addr); err != nil {
+		errs.addErr(field, err)
+	}
+}
+
+// parseAddress parses the host:port address, the port is a number or a service name.
+func parseAddress(addr string) error {
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	if _, err = net.LookupPort("tcp", port); err != nil {
+		return fmt.Errorf("invalid port of %s: %v", addr, err)
+	}
+	return nil
+}
+
+// checkStorage checks that the storage type is known and the DSN is parseable by it,
+// the fields are prefixed with the path of their object.
+func checkStorage(errs *ValidationError, prefix, storageType, dsn string) {
+	known := false
+	for _, t := range storage.Types {
+		known = known || t == storageType
+	}
+	switch {
+	case storageType == "":
+		errs.add(prefix+"storage_type", "is required")
+	case !known:
+		errs.add(prefix+"storage_type", "unknown type %q, expected one of %s", storageType, strings.Join(storage.Types, ", "))
+	}
+
+	if dsn == "" {
+		errs.add(prefix+"dsn", "is required")
+		return
+	}
+
+	var err error
+	switch storageType {
+	case "postgres":
+		err = parsePostgresDSN(dsn)
+	case "sqlite3":
+		if strings.HasPrefix(dsn, "file:") {
+			_, err = url.Parse(dsn)
+		}
+	case "itisadb":
+		err = parseAddress(dsn)
+	}
+	if err != nil {
+		errs.add(prefix+"dsn", "can't parse the %s dsn: %v", storageType, err)
+	}
+}
+
+// parsePostgresDSN parses the URL or key=value postgres DSN,
+// the errors don't quote the DSN as it may contain a password.
+func parsePostgresDSN(dsn string) error {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		u, err := url.Parse(dsn)
+		if err != nil {
+			return fmt.Errorf("invalid url")
+		}
+		if u.Host == "" {
+			return fmt.Errorf("url has no host")
+		}
+		return nil
+	}
+
+	for s := strings.TrimSpace(dsn); s != ""; s = strings.TrimSpace(s) {
+		key, rest, ok := strings.Cut(s, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" || strings.ContainsAny(key, " '") {
+			return fmt.Errorf("expected key=value pairs")
+		}
+
+		s = strings.TrimLeft(rest, " ")
+		if strings.HasPrefix(s, "'") {
+			end := strings.Index(s[1:], "'")
+			if end < 0 {
+				return fmt.Errorf("unterminated quoted value of %s", key)
+			}
+			s = s[end+2:]
+			continue
+		}
+		if i := strings.IndexByte(s, ' '); i >= 0 {
+			s = s[i:]
+		} else {
+			s = ""
+		}
+	}
+	return nil
+}
@@ -3,6 +3,7 @@ module go-tsv-watcher
 go 1.19
 
 require (
+	github.com/BurntSushi/toml v1.5.0
 	github.com/docker/distribution v2.8.1+incompatible
 	github.com/dogenzaka/tsv v0.0.0-20150215104501-8e02e611b1fb
 	github.com/dolthub/swiss v0.1.0
@@ -20,6 +21,11 @@ require (
 	github.com/rs/zerolog v1.27.0
 	github.com/signintech/gopdf v0.16.1
 	github.com/stretchr/testify v1.8.1
+	golang.org/x/net v0.8.0
+	google.golang.org/grpc v1.54.0
+	google.golang.org/protobuf v1.28.1
+	gopkg.in/yaml.v3 v3.0.1
+	modernc.org/sqlite v1.22.1
 )
 
 require (
@@ -49,14 +55,10 @@ require (
 	go.uber.org/zap v1.24.0 // indirect
 	golang.org/x/crypto v0.0.0-20220722155217-630584e8d5aa // indirect
 	golang.org/x/mod v0.8.0 // indirect
-	golang.org/x/net v0.8.0 // indirect
 	golang.org/x/sys v0.6.0 // indirect
 	golang.org/x/text v0.9.0 // indirect
 	golang.org/x/tools v0.6.0 // indirect
 	google.golang.org/genproto v0.0.0-20230110181048-76db0878b65f // indirect
-	google.golang.org/grpc v1.54.0 // indirect
-	google.golang.org/protobuf v1.28.1 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 	lukechampine.com/uint128 v1.2.0 // indirect
 	modernc.org/cc/v3 v3.40.0 // indirect
 	modernc.org/ccgo/v3 v3.16.13 // indirect
@@ -64,7 +66,6 @@ require (
 	modernc.org/mathutil v1.5.0 // indirect
 	modernc.org/memory v1.5.0 // indirect
 	modernc.org/opt v0.1.3 // indirect
-	modernc.org/sqlite v1.22.1 // indirect
 	modernc.org/strutil v1.1.3 // indirect
 	modernc.org/token v1.0.1 // indirect
 )
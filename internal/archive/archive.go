@@ -0,0 +1,84 @@
+package archive
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"go-tsv-watcher/internal/events"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Archiver exports events to gzipped JSON lines files, one file per batch.
+type Archiver struct {
+	dir string
+}
+
+// New creates a new Archiver writing to dir.
+func New(dir string) (*Archiver, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, fmt.Errorf("can't create archive directory: %w", err)
+	}
+
+	return &Archiver{dir: dir}, nil
+}
+
+// Archive writes the events to the file of the batch in the archive directory. The file is named
+// by the events, so a batch archived again after its deletion failed replaces its file.
+func (a *Archiver) Archive(ctx context.Context, evs []events.Event) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	path := filepath.Join(a.dir, name(evs))
+	tmp := path + ".tmp"
+
+	file, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", tmp, err)
+	}
+
+	err = write(file, evs)
+	if errClose := file.Close(); err == nil {
+		err = errClose
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write %s: %w", tmp, err)
+	}
+
+	return os.Rename(tmp, path)
+}
+
+// name returns the file name of the batch: the ingestion time of its first event and the hash of its events.
+func name(evs []events.Event) string {
+	var first time.Time
+	if len(evs) != 0 {
+		first = evs[0].IngestedAt
+	}
+
+	h := sha256.New()
+	for _, e := range evs {
+		fmt.Fprintf(h, "%s\x00%s\x00%s\x00%d\n", e.Pipeline, e.ID, e.UnitGUID, e.Number)
+	}
+
+	return fmt.Sprintf("events-%s-%x.jsonl.gz", first.UTC().Format("20060102T150405"), h.Sum(nil)[:8])
+}
+
+// write writes the events as gzipped JSON lines.
+func write(file *os.File, evs []events.Event) error {
+	zw := gzip.NewWriter(file)
+	enc := json.NewEncoder(zw)
+
+	for _, e := range evs {
+		if err := enc.Encode(e); err != nil {
+			return err
+		}
+	}
+
+	return zw.Close()
+}
@@ -0,0 +1,86 @@
+package archive
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"go-tsv-watcher/internal/events"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestArchiver_Archive(t *testing.T) {
+	dir := t.TempDir()
+
+	a, err := New(filepath.Join(dir, "archive"))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	evs := []events.Event{
+		{ID: "1", UnitGUID: "unit1", SourceFile: "a.tsv"},
+		{ID: "2", UnitGUID: "unit2", Block: true},
+	}
+
+	// the batch archived again, e.g. after its deletion failed, replaces its file
+	for i := 0; i < 2; i++ {
+		if err = a.Archive(context.Background(), evs); err != nil {
+			t.Fatalf("Archive() error = %v", err)
+		}
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "archive", "*.jsonl.gz"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("expected one archive, got %v (%v)", files, err)
+	}
+
+	f, err := os.Open(files[0])
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer f.Close()
+
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("gzip.NewReader() error = %v", err)
+	}
+
+	var got []events.Event
+	dec := json.NewDecoder(zr)
+	for dec.More() {
+		var e events.Event
+		if err = dec.Decode(&e); err != nil {
+			t.Fatalf("Decode() error = %v", err)
+		}
+		got = append(got, e)
+	}
+
+	if !reflect.DeepEqual(got, evs) {
+		t.Errorf("Archive() got = %v, want %v", got, evs)
+	}
+}
+
+func TestArchiver_Archive_batches(t *testing.T) {
+	dir := t.TempDir()
+
+	a, err := New(dir)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	for _, evs := range [][]events.Event{
+		{{ID: "1", UnitGUID: "unit1"}, {ID: "2", UnitGUID: "unit1"}},
+		{{ID: "3", UnitGUID: "unit1"}},
+	} {
+		if err = a.Archive(context.Background(), evs); err != nil {
+			t.Fatalf("Archive() error = %v", err)
+		}
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.jsonl.gz"))
+	if err != nil || len(files) != 2 {
+		t.Errorf("expected an archive per batch, got %v (%v)", files, err)
+	}
+}
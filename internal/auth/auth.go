@@ -0,0 +1,205 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"go-tsv-watcher/internal/storage/service"
+	"strings"
+	"time"
+)
+
+// Scopes of the API.
+const (
+	// ScopeReadEvents reads the events, units, reports and files.
+	ScopeReadEvents = "events:read"
+	// ScopeManageFiles reprocesses and deletes the files.
+	ScopeManageFiles = "files:manage"
+	// ScopeUpload uploads the files.
+	ScopeUpload = "files:upload"
+	// ScopeAdmin manages the API keys and grants all the other scopes.
+	ScopeAdmin = "admin"
+)
+
+// Scopes are the known scopes.
+var Scopes = []string{ScopeReadEvents, ScopeManageFiles, ScopeUpload, ScopeAdmin}
+
+// keyPrefix marks the generated API keys, the tokens without it are checked as JWTs.
+const keyPrefix = "wtk_"
+
+// ErrUnauthenticated error occurs when the credentials are missing or invalid
+var ErrUnauthenticated = errors.New("unauthenticated")
+
+// ErrForbidden error occurs when the credentials lack the scope
+var ErrForbidden = errors.New("forbidden")
+
+// ErrUnknownScope error occurs when the scope is not one of Scopes
+var ErrUnknownScope = errors.New("unknown scope")
+
+// ValidateScopes checks that the scopes are known.
+func ValidateScopes(scopes []string) error {
+	for _, scope := range scopes {
+		if !known(scope) {
+			return fmt.Errorf("%w: %q", ErrUnknownScope, scope)
+		}
+	}
+	return nil
+}
+
+// known reports whether the scope is one of Scopes.
+func known(scope string) bool {
+	for _, s := range Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Principal is the authenticated caller.
+type Principal struct {
+	// Subject is the name of the API key or the sub claim of the JWT.
+	Subject string
+	Scopes  []string
+}
+
+// Has reports whether the principal is granted the scope, admin is granted every scope.
+func (p Principal) Has(scope string) bool {
+	for _, s := range p.Scopes {
+		if s == scope || s == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns the context with the principal.
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// FromContext returns the principal of the context.
+func FromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
+
+// HashKey returns the hex SHA-256 of the API key, the API keys are random so no salt is needed.
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// GenerateKey returns a new random API key.
+func GenerateKey() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate api key: %w", err)
+	}
+	return keyPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// KeyStore finds the stored API keys.
+type KeyStore interface {
+	GetAPIKey(ctx context.Context, hash string) (service.APIKey, error)
+}
+
+// Key is an API key of the config.
+type Key struct {
+	Name string
+	// Hash is the hex SHA-256 of the key.
+	Hash   string
+	Scopes []string
+}
+
+// Config selects the accepted credentials.
+type Config struct {
+	// Keys are the API keys of the config, checked before the stored ones.
+	Keys []Key
+	// JWKS verifies the JWTs, they are rejected if nil.
+	JWKS *JWKS
+	// Issuer and Audience are checked when set.
+	Issuer   string
+	Audience string
+}
+
+// Authenticator checks the API keys and the JWTs.
+type Authenticator struct {
+	keys  map[string]Key
+	store KeyStore
+	cfg   Config
+	now   func() time.Time
+}
+
+// New Authenticator constructor, the store may be nil.
+func New(cfg Config, store KeyStore) (*Authenticator, error) {
+	keys := make(map[string]Key, len(cfg.Keys))
+	for _, k := range cfg.Keys {
+		hash := strings.ToLower(k.Hash)
+		if _, err := hex.DecodeString(hash); err != nil || len(hash) != sha256.Size*2 {
+			return nil, fmt.Errorf("api key %q: hash must be a hex SHA-256", k.Name)
+		}
+		if err := ValidateScopes(k.Scopes); err != nil {
+			return nil, fmt.Errorf("api key %q: %w", k.Name, err)
+		}
+		keys[hash] = k
+	}
+
+	return &Authenticator{keys: keys, store: store, cfg: cfg, now: time.Now}, nil
+}
+
+// Authenticate returns the principal of the API key or the JWT.
+func (a *Authenticator) Authenticate(ctx context.Context, token string) (Principal, error) {
+	if token == "" {
+		return Principal{}, fmt.Errorf("%w: no credentials", ErrUnauthenticated)
+	}
+
+	if strings.HasPrefix(token, keyPrefix) || strings.Count(token, ".") != 2 {
+		return a.authenticateKey(ctx, token)
+	}
+	return a.authenticateJWT(token)
+}
+
+// authenticateKey finds the API key in the config, then in the store.
+func (a *Authenticator) authenticateKey(ctx context.Context, token string) (Principal, error) {
+	hash := HashKey(token)
+	if k, ok := a.keys[hash]; ok {
+		return Principal{Subject: k.Name, Scopes: k.Scopes}, nil
+	}
+
+	if a.store == nil {
+		return Principal{}, fmt.Errorf("%w: unknown api key", ErrUnauthenticated)
+	}
+
+	k, err := a.store.GetAPIKey(ctx, hash)
+	if errors.Is(err, service.ErrAPIKeyNotFound) {
+		return Principal{}, fmt.Errorf("%w: unknown api key", ErrUnauthenticated)
+	}
+	if err != nil {
+		return Principal{}, fmt.Errorf("failed to get api key: %w", err)
+	}
+	return Principal{Subject: k.Name, Scopes: k.Scopes}, nil
+}
+
+// authenticateJWT verifies the JWT and its claims.
+func (a *Authenticator) authenticateJWT(token string) (Principal, error) {
+	if a.cfg.JWKS == nil {
+		return Principal{}, fmt.Errorf("%w: jwt is not accepted", ErrUnauthenticated)
+	}
+
+	claims, err := a.cfg.JWKS.Verify(token)
+	if err != nil {
+		return Principal{}, fmt.Errorf("%w: %v", ErrUnauthenticated, err)
+	}
+	if err = claims.validate(a.now(), a.cfg.Issuer, a.cfg.Audience); err != nil {
+		return Principal{}, fmt.Errorf("%w: %v", ErrUnauthenticated, err)
+	}
+
+	return Principal{Subject: claims.Subject, Scopes: claims.scopes()}, nil
+}
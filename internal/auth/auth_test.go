@@ -0,0 +1,296 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go-tsv-watcher/internal/storage/service"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+// storeStub finds the API keys by hash.
+type storeStub map[string]service.APIKey
+
+func (s storeStub) GetAPIKey(_ context.Context, hash string) (service.APIKey, error) {
+	key, ok := s[hash]
+	if !ok {
+		return service.APIKey{}, service.ErrAPIKeyNotFound
+	}
+	return key, nil
+}
+
+func TestPrincipal_Has(t *testing.T) {
+	reader := Principal{Scopes: []string{ScopeReadEvents}}
+	assert.True(t, reader.Has(ScopeReadEvents))
+	assert.False(t, reader.Has(ScopeUpload))
+
+	admin := Principal{Scopes: []string{ScopeAdmin}}
+	for _, scope := range Scopes {
+		assert.True(t, admin.Has(scope), scope)
+	}
+}
+
+func TestNew(t *testing.T) {
+	_, err := New(Config{Keys: []Key{{Name: "a", Hash: "abc", Scopes: []string{ScopeAdmin}}}}, nil)
+	assert.Error(t, err, "bad hash")
+
+	_, err = New(Config{Keys: []Key{{Name: "a", Hash: HashKey("a"), Scopes: []string{"files:write"}}}}, nil)
+	assert.ErrorIs(t, err, ErrUnknownScope)
+}
+
+func TestAuthenticator_keys(t *testing.T) {
+	generated, err := GenerateKey()
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(generated, keyPrefix))
+
+	store := storeStub{HashKey(generated): {Name: "stored", Scopes: []string{ScopeUpload}}}
+	a, err := New(Config{Keys: []Key{
+		{Name: "config", Hash: strings.ToUpper(HashKey("secret")), Scopes: []string{ScopeReadEvents}},
+	}}, store)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		token   string
+		want    Principal
+		wantErr error
+	}{
+		{name: "config", token: "secret", want: Principal{Subject: "config", Scopes: []string{ScopeReadEvents}}},
+		{name: "stored", token: generated, want: Principal{Subject: "stored", Scopes: []string{ScopeUpload}}},
+		{name: "unknown", token: "wtk_unknown", wantErr: ErrUnauthenticated},
+		{name: "empty", token: "", wantErr: ErrUnauthenticated},
+		{name: "jwt without jwks", token: "a.b.c", wantErr: ErrUnauthenticated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := a.Authenticate(context.Background(), tt.token)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	// the storage errors are not authentication failures
+	a.store = brokenStore{}
+	_, err = a.Authenticate(context.Background(), "wtk_unknown")
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, ErrUnauthenticated)
+}
+
+// brokenStore fails to find the API keys.
+type brokenStore struct{}
+
+func (brokenStore) GetAPIKey(context.Context, string) (service.APIKey, error) {
+	return service.APIKey{}, errors.New("storage is down")
+}
+
+// signer signs the JWTs of the tests.
+type signer struct {
+	alg  string
+	kid  string
+	sign func(signed []byte) []byte
+	jwk  map[string]string
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func signers(t *testing.T) []signer {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	edPub, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	digest := func(h crypto.Hash, signed []byte) []byte {
+		d := h.New()
+		d.Write(signed)
+		return d.Sum(nil)
+	}
+	rsaJWK := map[string]string{"kty": "RSA", "kid": "rsa", "n": b64(rsaKey.N.Bytes()), "e": b64(big.NewInt(int64(rsaKey.E)).Bytes())}
+
+	return []signer{
+		{alg: "RS256", kid: "rsa", jwk: rsaJWK, sign: func(signed []byte) []byte {
+			sig, err := rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, digest(crypto.SHA256, signed))
+			require.NoError(t, err)
+			return sig
+		}},
+		{alg: "PS384", kid: "rsa", jwk: rsaJWK, sign: func(signed []byte) []byte {
+			sig, err := rsa.SignPSS(rand.Reader, rsaKey, crypto.SHA384, digest(crypto.SHA384, signed), nil)
+			require.NoError(t, err)
+			return sig
+		}},
+		{alg: "ES256", kid: "ec", jwk: map[string]string{
+			"kty": "EC", "kid": "ec", "crv": "P-256", "x": b64(ecKey.X.FillBytes(make([]byte, 32))), "y": b64(ecKey.Y.FillBytes(make([]byte, 32))),
+		}, sign: func(signed []byte) []byte {
+			r, s, err := ecdsa.Sign(rand.Reader, ecKey, digest(crypto.SHA256, signed))
+			require.NoError(t, err)
+			return append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+		}},
+		{alg: "EdDSA", kid: "ed", jwk: map[string]string{"kty": "OKP", "kid": "ed", "crv": "Ed25519", "x": b64(edPub)}, sign: func(signed []byte) []byte {
+			return ed25519.Sign(edKey, signed)
+		}},
+	}
+}
+
+// token returns the JWT of the claims signed by the signer.
+func (s signer) token(t *testing.T, alg string, claims map[string]any) string {
+	header, err := json.Marshal(map[string]string{"alg": alg, "kid": s.kid, "typ": "JWT"})
+	require.NoError(t, err)
+	payload, err := json.Marshal(claims)
+	require.NoError(t, err)
+
+	signed := b64(header) + "." + b64(payload)
+	return signed + "." + b64(s.sign([]byte(signed)))
+}
+
+func TestAuthenticator_jwt(t *testing.T) {
+	signers := signers(t)
+
+	var set struct {
+		Keys []map[string]string `json:"keys"`
+	}
+	for _, s := range signers {
+		set.Keys = append(set.Keys, s.jwk)
+	}
+	set.Keys = append(set.Keys, map[string]string{"kty": "oct", "kid": "enc", "use": "enc"})
+	data, err := json.Marshal(set)
+	require.NoError(t, err)
+
+	jwks, err := ParseJWKS(data)
+	require.NoError(t, err)
+
+	now := time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)
+	a, err := New(Config{JWKS: jwks, Issuer: "https://issuer", Audience: "watcher"}, nil)
+	require.NoError(t, err)
+	a.now = func() time.Time { return now }
+
+	valid := func() map[string]any {
+		return map[string]any{
+			"sub":   "alice",
+			"iss":   "https://issuer",
+			"aud":   []string{"other", "watcher"},
+			"exp":   now.Add(time.Hour).Unix(),
+			"scope": "events:read unknown",
+		}
+	}
+
+	for _, s := range signers {
+		t.Run(s.alg, func(t *testing.T) {
+			got, err := a.Authenticate(context.Background(), s.token(t, s.alg, valid()))
+			require.NoError(t, err)
+			assert.Equal(t, Principal{Subject: "alice", Scopes: []string{ScopeReadEvents}}, got)
+		})
+	}
+
+	rs := signers[0]
+	tests := []struct {
+		name   string
+		token  func() string
+		scopes []string
+	}{
+		{name: "scp array", scopes: []string{ScopeUpload, ScopeManageFiles}, token: func() string {
+			c := valid()
+			delete(c, "scope")
+			c["scp"] = []string{ScopeUpload, ScopeManageFiles}
+			return rs.token(t, "RS256", c)
+		}},
+		{name: "audience string", scopes: []string{ScopeReadEvents}, token: func() string {
+			c := valid()
+			c["aud"] = "watcher"
+			return rs.token(t, "RS256", c)
+		}},
+		{name: "expired", token: func() string {
+			c := valid()
+			c["exp"] = now.Add(-2 * time.Minute).Unix()
+			return rs.token(t, "RS256", c)
+		}},
+		{name: "no exp", token: func() string {
+			c := valid()
+			delete(c, "exp")
+			return rs.token(t, "RS256", c)
+		}},
+		{name: "not yet valid", token: func() string {
+			c := valid()
+			c["nbf"] = now.Add(time.Hour).Unix()
+			return rs.token(t, "RS256", c)
+		}},
+		{name: "issuer", token: func() string {
+			c := valid()
+			c["iss"] = "https://other"
+			return rs.token(t, "RS256", c)
+		}},
+		{name: "audience", token: func() string {
+			c := valid()
+			c["aud"] = "other"
+			return rs.token(t, "RS256", c)
+		}},
+		{name: "alg none", token: func() string {
+			token := rs.token(t, "none", valid())
+			return token[:strings.LastIndex(token, ".")+1]
+		}},
+		{name: "alg of another key type", token: func() string {
+			return rs.token(t, "ES256", valid())
+		}},
+		{name: "unknown kid", token: func() string {
+			s := rs
+			s.kid = "other"
+			return s.token(t, "RS256", valid())
+		}},
+		{name: "tampered", token: func() string {
+			parts := strings.Split(rs.token(t, "RS256", valid()), ".")
+			c := valid()
+			c["scope"] = ScopeAdmin
+			payload, err := json.Marshal(c)
+			require.NoError(t, err)
+			return parts[0] + "." + b64(payload) + "." + parts[2]
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := a.Authenticate(context.Background(), tt.token())
+			if tt.scopes == nil {
+				assert.ErrorIs(t, err, ErrUnauthenticated)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.scopes, got.Scopes)
+		})
+	}
+}
+
+func TestParseJWKS(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{name: "not json", data: "keys"},
+		{name: "empty", data: `{"keys": []}`},
+		{name: "unknown type", data: `{"keys": [{"kty": "oct", "kid": "a"}]}`},
+		{name: "short rsa", data: `{"keys": [{"kty": "RSA", "kid": "a", "n": "AQAB", "e": "AQAB"}]}`},
+		{name: "point off the curve", data: `{"keys": [{"kty": "EC", "kid": "a", "crv": "P-256", "x": "AQ", "y": "AQ"}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseJWKS([]byte(tt.data))
+			assert.Error(t, err)
+		})
+	}
+}
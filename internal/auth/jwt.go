@@ -0,0 +1,308 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	_ "crypto/sha256" // SHA-256 of RS256, PS256 and ES256
+	_ "crypto/sha512" // SHA-384 and SHA-512 of the other algorithms
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+)
+
+// leeway is the accepted clock skew of the exp and nbf claims.
+const leeway = time.Minute
+
+// JWKS is a set of the public keys verifying the JWTs.
+type JWKS struct {
+	keys map[string]crypto.PublicKey
+}
+
+// jwk is a JSON Web Key, RFC 7517.
+type jwk struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// LoadJWKS reads the JWKS file.
+func LoadJWKS(path string) (*JWKS, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read jwks file: %w", err)
+	}
+	return ParseJWKS(data)
+}
+
+// ParseJWKS parses the RSA, EC and Ed25519 keys of the JWKS, the keys not used for signatures are skipped.
+func ParseJWKS(data []byte) (*JWKS, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("failed to parse jwks: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("jwk %q: %w", k.Kid, err)
+		}
+		keys[k.Kid] = key
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no signing keys in jwks")
+	}
+
+	return &JWKS{keys: keys}, nil
+}
+
+// publicKey decodes the key parameters.
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 {
+			return nil, errors.New("bad RSA exponent")
+		}
+		if n.BitLen() < 2048 {
+			return nil, errors.New("RSA key is shorter than 2048 bits")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		curves := map[string]elliptic.Curve{"P-256": elliptic.P256(), "P-384": elliptic.P384(), "P-521": elliptic.P521()}
+		curve, ok := curves[k.Crv]
+		if !ok {
+			return nil, fmt.Errorf("unknown curve %q", k.Crv)
+		}
+		x, err := decodeInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("point is not on the curve")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unknown curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("bad Ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("unknown key type %q", k.Kty)
+	}
+}
+
+// decodeInt decodes a base64url big-endian integer.
+func decodeInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(b) == 0 {
+		return nil, errors.New("bad key parameter")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+// hashes are the hashes of the algorithms by suffix.
+var hashes = map[string]crypto.Hash{"256": crypto.SHA256, "384": crypto.SHA384, "512": crypto.SHA512}
+
+// audience is the aud claim, a string or an array.
+type audience []string
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var one string
+	if err := json.Unmarshal(data, &one); err == nil {
+		*a = audience{one}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return errors.New("aud must be a string or an array")
+	}
+	*a = many
+	return nil
+}
+
+// claims are the checked claims of the JWT.
+type claims struct {
+	Subject   string          `json:"sub"`
+	Issuer    string          `json:"iss"`
+	Audience  audience        `json:"aud"`
+	ExpiresAt *int64          `json:"exp"`
+	NotBefore *int64          `json:"nbf"`
+	Scope     string          `json:"scope"`
+	Scp       json.RawMessage `json:"scp"`
+}
+
+// Verify checks the signature of the JWT, returns its claims.
+func (s *JWKS) Verify(token string) (*claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed jwt")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("malformed jwt header: %w", err)
+	}
+
+	key, ok := s.keys[header.Kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key %q", header.Kid)
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.New("malformed jwt signature")
+	}
+	if err = verify(header.Alg, key, []byte(parts[0]+"."+parts[1]), sig); err != nil {
+		return nil, err
+	}
+
+	c := &claims{}
+	if err = decodeSegment(parts[1], c); err != nil {
+		return nil, fmt.Errorf("malformed jwt claims: %w", err)
+	}
+	return c, nil
+}
+
+// decodeSegment decodes a base64url JSON segment of the JWT.
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// verify checks the signature by the algorithm, the algorithm must match the key type.
+func verify(alg string, key crypto.PublicKey, signed, sig []byte) error {
+	if alg == "EdDSA" {
+		k, ok := key.(ed25519.PublicKey)
+		if !ok || !ed25519.Verify(k, signed, sig) {
+			return errors.New("invalid jwt signature")
+		}
+		return nil
+	}
+
+	if len(alg) != 5 {
+		return fmt.Errorf("unsupported jwt algorithm %q", alg)
+	}
+	hash, ok := hashes[alg[2:]]
+	if !ok {
+		return fmt.Errorf("unsupported jwt algorithm %q", alg)
+	}
+	h := hash.New()
+	h.Write(signed)
+	digest := h.Sum(nil)
+
+	switch alg[:2] {
+	case "RS":
+		if k, ok := key.(*rsa.PublicKey); ok && rsa.VerifyPKCS1v15(k, hash, digest, sig) == nil {
+			return nil
+		}
+	case "PS":
+		if k, ok := key.(*rsa.PublicKey); ok && rsa.VerifyPSS(k, hash, digest, sig, nil) == nil {
+			return nil
+		}
+	case "ES":
+		k, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			break
+		}
+		size := (k.Curve.Params().BitSize + 7) / 8
+		if len(sig) != 2*size {
+			break
+		}
+		r, s := new(big.Int).SetBytes(sig[:size]), new(big.Int).SetBytes(sig[size:])
+		if ecdsa.Verify(k, digest, r, s) {
+			return nil
+		}
+	default:
+		return fmt.Errorf("unsupported jwt algorithm %q", alg)
+	}
+	return errors.New("invalid jwt signature")
+}
+
+// validate checks the time claims, the issuer and the audience.
+func (c *claims) validate(now time.Time, issuer, aud string) error {
+	if c.ExpiresAt == nil {
+		return errors.New("jwt has no exp claim")
+	}
+	if now.Add(-leeway).Unix() >= *c.ExpiresAt {
+		return errors.New("jwt is expired")
+	}
+	if c.NotBefore != nil && now.Add(leeway).Unix() < *c.NotBefore {
+		return errors.New("jwt is not valid yet")
+	}
+	if issuer != "" && c.Issuer != issuer {
+		return fmt.Errorf("unexpected jwt issuer %q", c.Issuer)
+	}
+	if aud != "" {
+		found := false
+		for _, a := range c.Audience {
+			found = found || a == aud
+		}
+		if !found {
+			return errors.New("jwt is not issued for this audience")
+		}
+	}
+	return nil
+}
+
+// scopes returns the known scopes of the scope claim or of the scp claim, a string or an array.
+func (c *claims) scopes() []string {
+	fields := strings.Fields(c.Scope)
+	if len(c.Scp) > 0 {
+		var one string
+		var many []string
+		if json.Unmarshal(c.Scp, &one) == nil {
+			fields = append(fields, strings.Fields(one)...)
+		} else if json.Unmarshal(c.Scp, &many) == nil {
+			fields = append(fields, many...)
+		}
+	}
+
+	scopes := make([]string, 0, len(fields))
+	for _, f := range fields {
+		if known(f) {
+			scopes = append(scopes, f)
+		}
+	}
+	return scopes
+}
@@ -1,14 +1,52 @@
 package events
 
 import (
+	"bufio"
+	"encoding/csv"
+	"errors"
 	"fmt"
 	"github.com/dogenzaka/tsv"
 	"github.com/google/uuid"
+	"io"
 	"log"
 	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 )
 
+// DefaultPipeline is the pipeline of the events when the config has a single watched directory.
+const DefaultPipeline = "default"
+
+// Options are the parser settings of a pipeline.
+type Options struct {
+	// Pipeline is recorded on every event, DefaultPipeline if empty.
+	Pipeline string
+	// Columns renames the columns of the file header to the tsv columns of Event (e.g. guid: unit_guid).
+	Columns map[string]string
+}
+
+// ErrUnknownColumn error occurs when a column is renamed to a column the event has not
+var ErrUnknownColumn = errors.New("unknown event column")
+
+// ValidateColumns checks that every column is renamed to a tsv column of the event.
+func ValidateColumns(columns map[string]string) error {
+	t := reflect.TypeOf(Event{})
+	for from, to := range columns {
+		found := false
+		for i := 0; i < t.NumField() && !found; i++ {
+			found = t.Field(i).Tag.Get("tsv") == to
+		}
+		if !found {
+			return fmt.Errorf("%w: %q of %q", ErrUnknownColumn, to, from)
+		}
+	}
+	return nil
+}
+
 // Event is event struct for parsing
 type Event struct {
 	ID           string
@@ -27,6 +65,13 @@ type Event struct {
 	Type         string `tsv:"type"`
 	Bit          int    `tsv:"bit"`
 	InvertBit    int    `tsv:"invert_bit"`
+
+	// SourceFile is the name of the file the event was parsed from.
+	SourceFile string
+	// IngestedAt is the time the event was saved to the storage.
+	IngestedAt time.Time
+	// Pipeline is the name of the pipeline the file was ingested by.
+	Pipeline string
 }
 
 // parser is interface for parsing
@@ -41,20 +86,28 @@ type Events struct {
 	events  []Event
 	parser  parser
 	file    *os.File
+	source  string
+	opts    Options
 
 	mu *sync.Mutex
 }
 
-// New creates new events
-func New(filename string) (*Events, error) {
+// New creates new events of the file parsed with the options.
+func New(filename string, opts Options) (*Events, error) {
 	f, err := os.Open(filename)
 	if err != nil {
 		return nil, err
 	}
 
+	if opts.Pipeline == "" {
+		opts.Pipeline = DefaultPipeline
+	}
+
 	return &Events{
 		current: new(Event),
 		file:    f,
+		source:  filepath.Base(filename),
+		opts:    opts,
 		parser:  nil,
 		events:  make([]Event, 0),
 		mu:      &sync.Mutex{},
@@ -74,7 +127,35 @@ func (es *Events) prepare() error {
 
 // newParser creates new parser
 func (es *Events) newParser() (parser, error) {
-	return tsv.NewParser(es.file, es.current)
+	if len(es.opts.Columns) == 0 {
+		return tsv.NewParser(es.file, es.current)
+	}
+
+	r, err := renameColumns(es.file, es.opts.Columns)
+	if err != nil {
+		return nil, err
+	}
+	return tsv.NewParser(r, es.current)
+}
+
+// renameColumns returns the reader with the columns of the header line renamed.
+func renameColumns(r io.Reader, columns map[string]string) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	header, err := br.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	line := strings.TrimRight(header, "\r\n")
+	names := strings.Split(line, "\t")
+	for i, name := range names {
+		if renamed, ok := columns[name]; ok {
+			names[i] = renamed
+		}
+	}
+
+	renamed := strings.Join(names, "\t") + header[len(line):]
+	return io.MultiReader(strings.NewReader(renamed), br), nil
 }
 
 // closeDevices closes events
@@ -105,11 +186,80 @@ func (es *Events) Fill() error {
 		}
 
 		es.current.ID = uuid.New().String()
+		es.current.Pipeline = es.opts.Pipeline
 
 		es.events = append(es.events, *es.current)
 	}
 }
 
+// RowError is a row of the file which can't be parsed.
+type RowError struct {
+	Line int
+	Err  error
+}
+
+// Error returns the error with the line of the row.
+func (e RowError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+// Validate parses the whole file without keeping the events
+// and returns the number of parsed rows and the errors of the rows which can't be parsed.
+func (es *Events) Validate() (int, []RowError, error) {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+
+	err := es.prepare()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	defer es.closeEvents()
+
+	var (
+		rows   int
+		errRow []RowError
+	)
+	for {
+		eof, err := es.parser.Next()
+		if eof {
+			return rows, errRow, nil
+		}
+
+		if err != nil {
+			line, ok := es.errorLine(err)
+			if !ok {
+				return rows, errRow, err
+			}
+			errRow = append(errRow, RowError{Line: line, Err: err})
+			continue
+		}
+
+		rows++
+	}
+}
+
+// errorLine returns the line of the row which can't be parsed,
+// false if the error is not a row error and the file can't be read further.
+func (es *Events) errorLine(err error) (int, bool) {
+	var errParse *csv.ParseError
+	if errors.As(err, &errParse) {
+		return errParse.StartLine, true
+	}
+
+	var errNum *strconv.NumError
+	if !errors.As(err, &errNum) {
+		return 0, false
+	}
+
+	p, ok := es.parser.(*tsv.Parser)
+	if !ok {
+		return 0, true
+	}
+	line, _ := p.Reader.FieldPos(0)
+	return line, true
+}
+
 // Print prints events.
 func (es *Events) Print() {
 	for _, d := range es.events {
@@ -117,6 +267,16 @@ func (es *Events) Print() {
 	}
 }
 
+// Source returns the name of the parsed file.
+func (es *Events) Source() string {
+	return es.source
+}
+
+// Pipeline returns the name of the pipeline of the events.
+func (es *Events) Pipeline() string {
+	return es.opts.Pipeline
+}
+
 // Iter iterates over events by giving function.
 func (es *Events) Iter(cb func(d Event) (stop bool)) {
 	es.mu.Lock()
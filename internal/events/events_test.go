@@ -1,7 +1,9 @@
 package events
 
 import (
+	"errors"
 	"os"
+	"path/filepath"
 	"reflect"
 	"sync"
 	"testing"
@@ -41,6 +43,7 @@ func TestEvents_Fill(t *testing.T) {
 						Type:         "",
 						Bit:          0,
 						InvertBit:    0,
+						Pipeline:     DefaultPipeline,
 					},
 				},
 				mu: &sync.Mutex{},
@@ -69,6 +72,7 @@ func TestEvents_Fill(t *testing.T) {
 						Type:         "",
 						Bit:          0,
 						InvertBit:    0,
+						Pipeline:     DefaultPipeline,
 					},
 					{
 						Number:       2,
@@ -86,6 +90,7 @@ func TestEvents_Fill(t *testing.T) {
 						Type:         "",
 						Bit:          0,
 						InvertBit:    0,
+						Pipeline:     DefaultPipeline,
 					},
 				},
 				mu: &sync.Mutex{},
@@ -109,7 +114,7 @@ func TestEvents_Fill(t *testing.T) {
 			f.Sync()
 			f.Close()
 
-			es, err := New(tt.filename)
+			es, err := New(tt.filename, Options{})
 			if err != nil {
 				t.Fatalf("New() error = %v", err)
 			}
@@ -148,3 +153,76 @@ func TestEvents_Iter(t *testing.T) {
 		}
 	}
 }
+
+func TestEvents_FillOptions(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "line2.tsv")
+	data := "n\tguid\tclass\tlevel\r\n1\tunit-1\twaiting\t100\r\n2\tunit-2\talarm\t7\r\n"
+	if err := os.WriteFile(filename, []byte(data), 0644); err != nil {
+		t.Fatalf("os.WriteFile() error = %v", err)
+	}
+
+	es, err := New(filename, Options{Pipeline: "line2", Columns: map[string]string{"guid": "unit_guid"}})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if err = es.Fill(); err != nil {
+		t.Fatalf("Fill() error = %v", err)
+	}
+
+	want := []Event{
+		{Number: 1, UnitGUID: "unit-1", MessageClass: "waiting", Level: 100, Pipeline: "line2"},
+		{Number: 2, UnitGUID: "unit-2", MessageClass: "alarm", Level: 7, Pipeline: "line2"},
+	}
+	if len(es.events) != len(want) {
+		t.Fatalf("Fill() got %d events, want %d", len(es.events), len(want))
+	}
+	for i := range es.events {
+		es.events[i].ID = ""
+		if !reflect.DeepEqual(es.events[i], want[i]) {
+			t.Errorf("Fill() es.events[%d] = %+v,\n want %+v", i, es.events[i], want[i])
+		}
+	}
+	if got := es.Pipeline(); got != "line2" {
+		t.Errorf("Pipeline() = %q, want line2", got)
+	}
+}
+
+func TestValidateColumns(t *testing.T) {
+	if err := ValidateColumns(map[string]string{"guid": "unit_guid", "severity": "level"}); err != nil {
+		t.Errorf("ValidateColumns() error = %v", err)
+	}
+	if err := ValidateColumns(map[string]string{"guid": "UnitGUID"}); !errors.Is(err, ErrUnknownColumn) {
+		t.Errorf("ValidateColumns() error = %v, want %v", err, ErrUnknownColumn)
+	}
+}
+
+func TestEvents_Validate(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "broken.tsv")
+	data := "n\tunit_guid\tlevel\n1\tunit-1\t100\nx\tunit-2\t7\n3\tunit-3\n4\tunit-4\t1\n"
+	if err := os.WriteFile(filename, []byte(data), 0644); err != nil {
+		t.Fatalf("os.WriteFile() error = %v", err)
+	}
+
+	es, err := New(filename, Options{})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	rows, errRows, err := es.Validate()
+	if err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if rows != 2 {
+		t.Errorf("Validate() rows = %d, want 2", rows)
+	}
+
+	var lines []int
+	for _, e := range errRows {
+		lines = append(lines, e.Line)
+	}
+	if want := []int{3, 4}; !reflect.DeepEqual(lines, want) {
+		t.Errorf("Validate() error lines = %v, want %v (%v)", lines, want, errRows)
+	}
+	if len(es.events) != 0 {
+		t.Errorf("Validate() kept %d events, want 0", len(es.events))
+	}
+}
@@ -0,0 +1,78 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	watcherv1 "go-tsv-watcher/api/watcher/v1"
+	"go-tsv-watcher/internal/auth"
+	"go-tsv-watcher/internal/ratelimit"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"strings"
+)
+
+// watcherMethods prefixes the methods of the Watcher service,
+// the health and reflection services stay anonymous.
+var watcherMethods = "/" + watcherv1.Watcher_ServiceDesc.ServiceName + "/"
+
+// authorize returns the context with the principal of the metadata credentials,
+// the Watcher methods require the events:read scope. The client IP failing too often
+// is denied before its credentials are looked up.
+func authorize(ctx context.Context, a *auth.Authenticator, limits *ratelimit.Limits, method string) (context.Context, error) {
+	if a == nil || !strings.HasPrefix(method, watcherMethods) {
+		return ctx, nil
+	}
+
+	failures, ip := authFailures(limits), ""
+	if failures != nil {
+		ip = clientIP(ctx, limits)
+		if d := failures.Peek(ip); !d.Allowed {
+			return nil, exhausted(d, "too many failed authentications")
+		}
+	}
+
+	principal, err := a.Authenticate(ctx, metadataCredentials(ctx))
+	if err != nil {
+		if errors.Is(err, auth.ErrUnauthenticated) {
+			if failures != nil {
+				failures.Allow(ip)
+			}
+			return nil, status.Error(codes.Unauthenticated, err.Error())
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	if !principal.Has(auth.ScopeReadEvents) {
+		return nil, status.Errorf(codes.PermissionDenied, "%s scope is required", auth.ScopeReadEvents)
+	}
+	return auth.WithPrincipal(ctx, principal), nil
+}
+
+// metadataCredentials returns the bearer token of the authorization metadata or the x-api-key metadata.
+func metadataCredentials(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get("authorization"); len(values) > 0 {
+		scheme, token, _ := strings.Cut(values[0], " ")
+		if strings.EqualFold(scheme, "Bearer") {
+			return strings.TrimSpace(token)
+		}
+		return ""
+	}
+	if values := md.Get("x-api-key"); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// authStream is the server stream with the context of the principal.
+type authStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+// Context implements grpc.ServerStream.
+func (s authStream) Context() context.Context {
+	return s.ctx
+}
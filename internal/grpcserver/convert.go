@@ -0,0 +1,102 @@
+package grpcserver
+
+import (
+	"fmt"
+	watcherv1 "go-tsv-watcher/api/watcher/v1"
+	"go-tsv-watcher/internal/events"
+	"go-tsv-watcher/internal/storage/service"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"time"
+)
+
+// eventOf returns the message of the event.
+func eventOf(e events.Event) *watcherv1.Event {
+	return &watcherv1.Event{
+		Id:           e.ID,
+		Number:       int64(e.Number),
+		Mqtt:         e.MQTT,
+		InventoryId:  e.InventoryID,
+		UnitGuid:     e.UnitGUID,
+		MessageId:    e.MessageID,
+		MessageText:  e.MessageText,
+		Context:      e.Context,
+		MessageClass: e.MessageClass,
+		Level:        int64(e.Level),
+		Area:         e.Area,
+		Address:      e.Address,
+		Block:        e.Block,
+		Type:         e.Type,
+		Bit:          int64(e.Bit),
+		InvertBit:    int64(e.InvertBit),
+		SourceFile:   e.SourceFile,
+		IngestedAt:   timestampOf(e.IngestedAt),
+		Pipeline:     e.Pipeline,
+	}
+}
+
+// unitOf returns the message of the unit.
+func unitOf(u service.Unit) *watcherv1.Unit {
+	return &watcherv1.Unit{
+		Guid:         u.GUID,
+		InventoryIds: u.InventoryIDs,
+		Events:       int64(u.Events),
+		FirstSeen:    timestampOf(u.FirstSeen),
+		LastSeen:     timestampOf(u.LastSeen),
+		LatestLevel:  int64(u.LatestLevel),
+	}
+}
+
+// fileOf returns the message of the file.
+func fileOf(f service.File) *watcherv1.File {
+	file := &watcherv1.File{
+		Name:     f.Name,
+		Status:   f.Status,
+		Error:    f.Error,
+		Events:   int64(f.Events),
+		Pruned:   int64(f.Pruned),
+		Archived: int64(f.Archived),
+		Pipeline: f.Pipeline,
+	}
+	if f.DeletedAt != nil {
+		file.DeletedAt = timestamppb.New(*f.DeletedAt)
+	}
+	return file
+}
+
+// counts returns the counts of the summary as a message map.
+func counts(m map[string]int) map[string]int64 {
+	out := make(map[string]int64, len(m))
+	for k, v := range m {
+		out[k] = int64(v)
+	}
+	return out
+}
+
+// timestampOf returns the timestamp of the time, nil if it is zero.
+func timestampOf(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
+
+// optionalInt returns the value of the wrapper, nil if it is not set.
+func optionalInt(v *wrapperspb.Int64Value) *int {
+	if v == nil {
+		return nil
+	}
+	n := int(v.Value)
+	return &n
+}
+
+// optionalTime returns the time of the timestamp, zero if it is not set.
+func optionalTime(ts *timestamppb.Timestamp, name string) (time.Time, error) {
+	if ts == nil {
+		return time.Time{}, nil
+	}
+	if err := ts.CheckValid(); err != nil {
+		return time.Time{}, fmt.Errorf("%w: %s must be a valid timestamp", service.ErrInvalidQuery, name)
+	}
+	return ts.AsTime(), nil
+}
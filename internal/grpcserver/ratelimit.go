@@ -0,0 +1,68 @@
+package grpcserver
+
+import (
+	"context"
+	"go-tsv-watcher/internal/auth"
+	"go-tsv-watcher/internal/ratelimit"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"strings"
+	"time"
+)
+
+// limit takes a token of the group and counts the call in the daily quota like the HTTP routes do,
+// the client is the authenticated principal or the client IP.
+func limit(ctx context.Context, limits *ratelimit.Limits, method, group string) error {
+	if limits == nil || !strings.HasPrefix(method, watcherMethods) {
+		return nil
+	}
+
+	client := "ip:" + clientIP(ctx, limits)
+	if principal, ok := auth.FromContext(ctx); ok {
+		client = "key:" + principal.Subject
+	}
+
+	if limiter, ok := limits.Groups[group]; ok {
+		if d := limiter.Allow(client); !d.Allowed {
+			return exhausted(d, "rate limit exceeded")
+		}
+	}
+	if limits.Quota != nil {
+		if d := limits.Quota.Use(client); !d.Allowed {
+			return exhausted(d, "daily quota exceeded")
+		}
+	}
+	return nil
+}
+
+// authFailures returns the limiter of the failed authentications, nil if they are not limited.
+func authFailures(limits *ratelimit.Limits) *ratelimit.Limiter {
+	if limits == nil {
+		return nil
+	}
+	return limits.Groups[ratelimit.GroupAuth]
+}
+
+// clientIP returns the IP of the client, from the IPHeader metadata set by a trusted proxy or the peer address.
+func clientIP(ctx context.Context, limits *ratelimit.Limits) string {
+	var header, addr string
+	if limits.IPHeader != "" {
+		md, _ := metadata.FromIncomingContext(ctx)
+		header = strings.Join(md.Get(limits.IPHeader), ",")
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		addr = p.Addr.String()
+	}
+	return limits.ClientIP(header, addr)
+}
+
+// exhausted returns the ResourceExhausted status with the seconds to wait.
+func exhausted(d ratelimit.Decision, msg string) error {
+	seconds := int64((d.RetryAfter + time.Second - 1) / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+	return status.Errorf(codes.ResourceExhausted, "%s, retry after %ds", msg, seconds)
+}
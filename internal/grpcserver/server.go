@@ -0,0 +1,258 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	watcherv1 "go-tsv-watcher/api/watcher/v1"
+	"go-tsv-watcher/internal/auth"
+	"go-tsv-watcher/internal/ratelimit"
+	"go-tsv-watcher/internal/storage/service"
+	"go-tsv-watcher/internal/usecase"
+	"go-tsv-watcher/pkg/logger"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"strings"
+)
+
+// Server implements the Watcher gRPC service over the use case.
+type Server struct {
+	watcherv1.UnimplementedWatcherServer
+
+	logic usecase.IUseCase
+}
+
+// New Server constructor
+func New(logic usecase.IUseCase) *Server {
+	return &Server{logic: logic}
+}
+
+// NewGRPC returns a gRPC server with the Watcher, health and reflection services,
+// the Watcher methods are authenticated unless the authenticator is nil and limited by the events and stream
+// groups of the limits unless they are nil, the internal errors are logged.
+func NewGRPC(logic usecase.IUseCase, a *auth.Authenticator, limits *ratelimit.Limits, loggerInstance logger.ILogger,
+	opts ...grpc.ServerOption) *grpc.Server {
+	opts = append(opts,
+		grpc.ChainUnaryInterceptor(func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			ctx, err := authorize(ctx, a, limits, info.FullMethod)
+			if err != nil {
+				return nil, err
+			}
+			if err = limit(ctx, limits, info.FullMethod, ratelimit.GroupEvents); err != nil {
+				return nil, err
+			}
+			resp, err := handler(ctx, req)
+			logError(loggerInstance, info.FullMethod, err)
+			return resp, err
+		}),
+		grpc.ChainStreamInterceptor(func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			ctx, err := authorize(ss.Context(), a, limits, info.FullMethod)
+			if err != nil {
+				return err
+			}
+			if err = limit(ctx, limits, info.FullMethod, ratelimit.GroupStream); err != nil {
+				return err
+			}
+			err = handler(srv, authStream{ServerStream: ss, ctx: ctx})
+			logError(loggerInstance, info.FullMethod, err)
+			return err
+		}),
+	)
+
+	s := grpc.NewServer(opts...)
+	watcherv1.RegisterWatcherServer(s, New(logic))
+
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus(watcherv1.Watcher_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(s, healthServer)
+
+	reflection.Register(s)
+	return s
+}
+
+// logError logs the errors of the server side.
+func logError(loggerInstance logger.ILogger, method string, err error) {
+	switch status.Code(err) {
+	case codes.Internal, codes.Unavailable, codes.Unknown:
+		loggerInstance.Warn(fmt.Sprintf("%s: %v", method, err))
+	}
+}
+
+// statusError returns the gRPC status of the use case error.
+func statusError(err error) error {
+	switch {
+	case errors.Is(err, service.ErrEventNotFound),
+		errors.Is(err, service.ErrUnitNotFound),
+		errors.Is(err, service.ErrFileNotFound),
+		errors.Is(err, usecase.ErrPipelineNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, service.ErrInvalidQuery):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, usecase.ErrStorageIsUnavailable):
+		return status.Error(codes.Unavailable, err.Error())
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
+
+// GetEvent implements watcherv1.WatcherServer.
+func (s *Server) GetEvent(ctx context.Context, req *watcherv1.GetEventRequest) (*watcherv1.Event, error) {
+	e, err := s.logic.GetEventByNumber(ctx, req.Pipeline, req.UnitGuid, int(req.Number))
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return eventOf(e), nil
+}
+
+// ListEvents implements watcherv1.WatcherServer.
+func (s *Server) ListEvents(ctx context.Context, req *watcherv1.ListEventsRequest) (*watcherv1.ListEventsResponse, error) {
+	query, err := eventsQuery(req)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	page, err := s.logic.ListEvents(ctx, query)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	resp := &watcherv1.ListEventsResponse{
+		Events:     make([]*watcherv1.Event, 0, len(page.Events)),
+		NextCursor: service.EncodeCursor(page.Next),
+	}
+	for _, e := range page.Events {
+		resp.Events = append(resp.Events, eventOf(e))
+	}
+	return resp, nil
+}
+
+// eventsQuery returns the filters, sorting and pagination of the events list.
+func eventsQuery(req *watcherv1.ListEventsRequest) (service.EventsQuery, error) {
+	query := service.EventsQuery{
+		Filter: service.EventFilter{
+			UnitGUID:     req.UnitGuid,
+			InventoryID:  req.InventoryId,
+			MessageClass: req.MessageClass,
+			MessageID:    req.MessageId,
+			Area:         req.Area,
+			SourceFile:   req.SourceFile,
+			Pipeline:     req.Pipeline,
+			MinLevel:     optionalInt(req.LevelMin),
+			MaxLevel:     optionalInt(req.LevelMax),
+		},
+		Limit: int(req.Limit),
+	}
+
+	var err error
+	if query.Filter.From, err = optionalTime(req.From, "from"); err != nil {
+		return query, err
+	}
+	if query.Filter.To, err = optionalTime(req.To, "to"); err != nil {
+		return query, err
+	}
+
+	if req.Sort != "" {
+		query.Desc = strings.HasPrefix(req.Sort, "-")
+		query.SortBy = strings.TrimPrefix(req.Sort, "-")
+		if _, ok := service.SortFields[query.SortBy]; !ok {
+			return query, fmt.Errorf("%w: unknown sort field %q", service.ErrInvalidQuery, query.SortBy)
+		}
+	}
+
+	if req.Cursor != "" {
+		if query.After, err = service.DecodeCursor(req.Cursor); err != nil {
+			return query, fmt.Errorf("%w: bad cursor", err)
+		}
+	}
+
+	return query, nil
+}
+
+// StreamEvents implements watcherv1.WatcherServer.
+func (s *Server) StreamEvents(req *watcherv1.StreamEventsRequest, srv watcherv1.Watcher_StreamEventsServer) error {
+	filter := service.EventFilter{
+		UnitGUID:     req.UnitGuid,
+		MessageClass: req.MessageClass,
+		MinLevel:     optionalInt(req.LevelMin),
+		Pipeline:     req.Pipeline,
+	}
+
+	ctx := srv.Context()
+	stream, err := s.logic.Stream(ctx, filter, req.LastEventId)
+	if err != nil {
+		return statusError(err)
+	}
+
+	for e := range stream {
+		if err := srv.Send(&watcherv1.StreamEventsResponse{Id: e.ID, Event: eventOf(e.Event)}); err != nil {
+			return err
+		}
+	}
+
+	if ctx.Err() != nil {
+		return status.FromContextError(ctx.Err()).Err()
+	}
+	// the reader lagged behind or the storage failed
+	return status.Error(codes.Unavailable, "stream is closed, resume it with the id of the last event")
+}
+
+// ListUnits implements watcherv1.WatcherServer.
+func (s *Server) ListUnits(ctx context.Context, req *watcherv1.ListUnitsRequest) (*watcherv1.ListUnitsResponse, error) {
+	units, err := s.logic.ListUnits(ctx, req.Pipeline)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	resp := &watcherv1.ListUnitsResponse{Units: make([]*watcherv1.Unit, 0, len(units))}
+	for _, u := range units {
+		resp.Units = append(resp.Units, unitOf(u))
+	}
+	return resp, nil
+}
+
+// GetUnit implements watcherv1.WatcherServer.
+func (s *Server) GetUnit(ctx context.Context, req *watcherv1.GetUnitRequest) (*watcherv1.UnitSummary, error) {
+	unit, err := s.logic.GetUnit(ctx, req.Pipeline, req.Guid)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return &watcherv1.UnitSummary{
+		Unit:           unitOf(unit.Unit),
+		ByMessageClass: counts(unit.ByMessageClass),
+		ByArea:         counts(unit.ByArea),
+	}, nil
+}
+
+// ListFiles implements watcherv1.WatcherServer.
+func (s *Server) ListFiles(ctx context.Context, req *watcherv1.ListFilesRequest) (*watcherv1.ListFilesResponse, error) {
+	files, err := s.logic.ListFiles(ctx, req.Pipeline, req.Status)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	resp := &watcherv1.ListFilesResponse{Files: make([]*watcherv1.File, 0, len(files))}
+	for _, f := range files {
+		resp.Files = append(resp.Files, fileOf(f))
+	}
+	return resp, nil
+}
+
+// GetFile implements watcherv1.WatcherServer.
+func (s *Server) GetFile(ctx context.Context, req *watcherv1.GetFileRequest) (*watcherv1.FileDetails, error) {
+	file, err := s.logic.GetFile(ctx, req.Pipeline, req.Name)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return &watcherv1.FileDetails{
+		File:   fileOf(file.File),
+		Stored: int64(file.Stored),
+		Units:  file.Units,
+		Pdfs:   file.PDFs,
+	}, nil
+}
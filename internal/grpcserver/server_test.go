@@ -0,0 +1,477 @@
+package grpcserver
+
+import (
+	"context"
+	"github.com/go-chi/httplog"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	watcherv1 "go-tsv-watcher/api/watcher/v1"
+	"go-tsv-watcher/internal/auth"
+	"go-tsv-watcher/internal/events"
+	"go-tsv-watcher/internal/ratelimit"
+	"go-tsv-watcher/internal/storage/service"
+	"go-tsv-watcher/internal/usecase"
+	mocks "go-tsv-watcher/internal/usecase/mocks"
+	"go-tsv-watcher/pkg/logger"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"net"
+	"testing"
+	"time"
+)
+
+// dial starts the server over the use case and returns a connection to it.
+func dial(t *testing.T, logic usecase.IUseCase) *grpc.ClientConn {
+	return dialAuth(t, logic, nil)
+}
+
+// dialAuth starts the server with the authenticator and returns a connection to it.
+func dialAuth(t *testing.T, logic usecase.IUseCase, a *auth.Authenticator) *grpc.ClientConn {
+	return dialLimits(t, logic, a, nil)
+}
+
+// dialLimits starts the server with the authenticator and the limits and returns a connection to it.
+func dialLimits(t *testing.T, logic usecase.IUseCase, a *auth.Authenticator, limits *ratelimit.Limits) *grpc.ClientConn {
+	lis := bufconn.Listen(1 << 20)
+	s := NewGRPC(logic, a, limits, logger.New(httplog.NewLogger("watcher", httplog.Options{Concise: true})))
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestServer_GetEvent(t *testing.T) {
+	type mockBehavior func(r *mocks.MockIUseCase)
+
+	ingested := time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name         string
+		req          *watcherv1.GetEventRequest
+		mockBehavior mockBehavior
+		want         *watcherv1.Event
+		wantCode     codes.Code
+	}{
+		{
+			name: "Ok",
+			req:  &watcherv1.GetEventRequest{UnitGuid: "unit-a", Number: 1},
+			mockBehavior: func(r *mocks.MockIUseCase) {
+				r.EXPECT().GetEventByNumber(gomock.Any(), "", "unit-a", 1).
+					Return(events.Event{ID: "123", Number: 1, UnitGUID: "unit-a", Level: 3, Block: true, IngestedAt: ingested}, nil)
+			},
+			want:     &watcherv1.Event{Id: "123", Number: 1, UnitGuid: "unit-a", Level: 3, Block: true, IngestedAt: timestamppb.New(ingested)},
+			wantCode: codes.OK,
+		},
+		{
+			name: "NotFound",
+			req:  &watcherv1.GetEventRequest{UnitGuid: "unit-b", Number: 1},
+			mockBehavior: func(r *mocks.MockIUseCase) {
+				r.EXPECT().GetEventByNumber(gomock.Any(), "", "unit-b", 1).Return(events.Event{}, service.ErrEventNotFound)
+			},
+			wantCode: codes.NotFound,
+		},
+		{
+			name: "Unavailable",
+			req:  &watcherv1.GetEventRequest{UnitGuid: "unit-a", Number: 2},
+			mockBehavior: func(r *mocks.MockIUseCase) {
+				r.EXPECT().GetEventByNumber(gomock.Any(), "", "unit-a", 2).Return(events.Event{}, usecase.ErrStorageIsUnavailable)
+			},
+			wantCode: codes.Unavailable,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			logic := mocks.NewMockIUseCase(c)
+			tt.mockBehavior(logic)
+
+			client := watcherv1.NewWatcherClient(dial(t, logic))
+			got, err := client.GetEvent(context.Background(), tt.req)
+			assert.Equal(t, tt.wantCode, status.Code(err))
+			if tt.want != nil {
+				assert.Equal(t, tt.want.String(), got.String())
+			}
+		})
+	}
+}
+
+func TestServer_ListEvents(t *testing.T) {
+	type mockBehavior func(r *mocks.MockIUseCase)
+
+	from := time.Date(2023, 5, 1, 0, 0, 0, 0, time.UTC)
+	minLevel := 2
+	next := &service.Cursor{Value: "2023-05-01T10:00:00Z", ID: "2"}
+
+	tests := []struct {
+		name         string
+		req          *watcherv1.ListEventsRequest
+		mockBehavior mockBehavior
+		wantIDs      []string
+		wantNext     string
+		wantCode     codes.Code
+	}{
+		{
+			name: "Ok",
+			req: &watcherv1.ListEventsRequest{
+				UnitGuid: "unit-a",
+				LevelMin: wrapperspb.Int64(2),
+				From:     timestamppb.New(from),
+				Sort:     "-level",
+				Limit:    2,
+			},
+			mockBehavior: func(r *mocks.MockIUseCase) {
+				r.EXPECT().ListEvents(gomock.Any(), service.EventsQuery{
+					Filter: service.EventFilter{UnitGUID: "unit-a", MinLevel: &minLevel, From: from},
+					SortBy: "level",
+					Desc:   true,
+					Limit:  2,
+				}).Return(service.EventsPage{Events: []events.Event{{ID: "1"}, {ID: "2"}}, Next: next}, nil)
+			},
+			wantIDs:  []string{"1", "2"},
+			wantNext: service.EncodeCursor(next),
+			wantCode: codes.OK,
+		},
+		{
+			name:         "Bad sort",
+			req:          &watcherv1.ListEventsRequest{Sort: "text"},
+			mockBehavior: func(r *mocks.MockIUseCase) {},
+			wantCode:     codes.InvalidArgument,
+		},
+		{
+			name:         "Bad cursor",
+			req:          &watcherv1.ListEventsRequest{Cursor: "!"},
+			mockBehavior: func(r *mocks.MockIUseCase) {},
+			wantCode:     codes.InvalidArgument,
+		},
+		{
+			name: "Internal",
+			req:  &watcherv1.ListEventsRequest{},
+			mockBehavior: func(r *mocks.MockIUseCase) {
+				r.EXPECT().ListEvents(gomock.Any(), gomock.Any()).Return(service.EventsPage{}, assert.AnError)
+			},
+			wantCode: codes.Internal,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			logic := mocks.NewMockIUseCase(c)
+			tt.mockBehavior(logic)
+
+			client := watcherv1.NewWatcherClient(dial(t, logic))
+			got, err := client.ListEvents(context.Background(), tt.req)
+			require.Equal(t, tt.wantCode, status.Code(err))
+			if err != nil {
+				return
+			}
+
+			var ids []string
+			for _, e := range got.Events {
+				ids = append(ids, e.Id)
+			}
+			assert.Equal(t, tt.wantIDs, ids)
+			assert.Equal(t, tt.wantNext, got.NextCursor)
+		})
+	}
+}
+
+func TestServer_Units(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+
+	seen := time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)
+	unit := service.Unit{GUID: "unit-a", InventoryIDs: []string{"inv-1"}, Events: 3, FirstSeen: seen, LastSeen: seen, LatestLevel: 2}
+
+	logic := mocks.NewMockIUseCase(c)
+	logic.EXPECT().ListUnits(gomock.Any(), "").Return([]service.Unit{unit}, nil)
+	logic.EXPECT().GetUnit(gomock.Any(), "", "unit-a").Return(service.UnitSummary{
+		Unit:           unit,
+		ByMessageClass: map[string]int{"alarm": 2, "info": 1},
+		ByArea:         map[string]int{"LOC": 3},
+	}, nil)
+	logic.EXPECT().GetUnit(gomock.Any(), "", "unit-b").Return(service.UnitSummary{}, service.ErrUnitNotFound)
+
+	client := watcherv1.NewWatcherClient(dial(t, logic))
+
+	units, err := client.ListUnits(context.Background(), &watcherv1.ListUnitsRequest{})
+	require.NoError(t, err)
+	require.Len(t, units.Units, 1)
+	assert.Equal(t, "unit-a", units.Units[0].Guid)
+	assert.Equal(t, []string{"inv-1"}, units.Units[0].InventoryIds)
+	assert.Equal(t, int64(3), units.Units[0].Events)
+	assert.Equal(t, seen, units.Units[0].LastSeen.AsTime())
+
+	summary, err := client.GetUnit(context.Background(), &watcherv1.GetUnitRequest{Guid: "unit-a"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{"alarm": 2, "info": 1}, summary.ByMessageClass)
+	assert.Equal(t, map[string]int64{"LOC": 3}, summary.ByArea)
+
+	_, err = client.GetUnit(context.Background(), &watcherv1.GetUnitRequest{Guid: "unit-b"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestServer_Files(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+
+	deleted := time.Date(2023, 5, 2, 0, 0, 0, 0, time.UTC)
+
+	logic := mocks.NewMockIUseCase(c)
+	logic.EXPECT().ListFiles(gomock.Any(), "line2", service.FileDeleted).Return([]service.File{
+		{Pipeline: "line2", Name: "a.tsv", Status: service.FileDeleted, Events: 2, DeletedAt: &deleted},
+	}, nil)
+	logic.EXPECT().ListFiles(gomock.Any(), "", "bad").Return(nil, service.ErrInvalidQuery)
+	logic.EXPECT().GetFile(gomock.Any(), "", "b.tsv").Return(service.FileDetails{
+		File:   service.File{Name: "b.tsv", Status: "ok", Events: 2},
+		Stored: 2,
+		Units:  []string{"unit-a"},
+		PDFs:   []string{"unit-a.pdf"},
+	}, nil)
+	logic.EXPECT().GetFile(gomock.Any(), "", "c.tsv").Return(service.FileDetails{}, service.ErrFileNotFound)
+	logic.EXPECT().GetFile(gomock.Any(), "nope", "b.tsv").Return(service.FileDetails{}, usecase.ErrPipelineNotFound)
+
+	client := watcherv1.NewWatcherClient(dial(t, logic))
+
+	files, err := client.ListFiles(context.Background(), &watcherv1.ListFilesRequest{Status: service.FileDeleted, Pipeline: "line2"})
+	require.NoError(t, err)
+	require.Len(t, files.Files, 1)
+	assert.Equal(t, "a.tsv", files.Files[0].Name)
+	assert.Equal(t, "line2", files.Files[0].Pipeline)
+	assert.Equal(t, deleted, files.Files[0].DeletedAt.AsTime())
+
+	_, err = client.ListFiles(context.Background(), &watcherv1.ListFilesRequest{Status: "bad"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	file, err := client.GetFile(context.Background(), &watcherv1.GetFileRequest{Name: "b.tsv"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), file.Stored)
+	assert.Equal(t, []string{"unit-a"}, file.Units)
+	assert.Equal(t, []string{"unit-a.pdf"}, file.Pdfs)
+	assert.Nil(t, file.File.DeletedAt)
+
+	_, err = client.GetFile(context.Background(), &watcherv1.GetFileRequest{Name: "c.tsv"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = client.GetFile(context.Background(), &watcherv1.GetFileRequest{Name: "b.tsv", Pipeline: "nope"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestServer_StreamEvents(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+
+	minLevel := 2
+	stream := make(chan usecase.StreamEvent, 2)
+	stream <- usecase.StreamEvent{ID: "id-1", Event: events.Event{ID: "1", UnitGUID: "unit-a"}}
+	stream <- usecase.StreamEvent{ID: "id-2", Event: events.Event{ID: "2", UnitGUID: "unit-a"}}
+	close(stream)
+
+	logic := mocks.NewMockIUseCase(c)
+	logic.EXPECT().Stream(gomock.Any(), service.EventFilter{UnitGUID: "unit-a", MinLevel: &minLevel}, "id-0").
+		Return((<-chan usecase.StreamEvent)(stream), nil)
+	logic.EXPECT().Stream(gomock.Any(), gomock.Any(), "bad").
+		Return(nil, service.ErrInvalidQuery)
+
+	client := watcherv1.NewWatcherClient(dial(t, logic))
+
+	s, err := client.StreamEvents(context.Background(), &watcherv1.StreamEventsRequest{
+		UnitGuid:    "unit-a",
+		LevelMin:    wrapperspb.Int64(2),
+		LastEventId: "id-0",
+	})
+	require.NoError(t, err)
+
+	for _, id := range []string{"id-1", "id-2"} {
+		msg, err := s.Recv()
+		require.NoError(t, err)
+		assert.Equal(t, id, msg.Id)
+		assert.Equal(t, "unit-a", msg.Event.UnitGuid)
+	}
+	// the closed stream is resumed by the client
+	_, err = s.Recv()
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+
+	s, err = client.StreamEvents(context.Background(), &watcherv1.StreamEventsRequest{LastEventId: "bad"})
+	require.NoError(t, err)
+	_, err = s.Recv()
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestServer_healthAndReflection(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+
+	conn := dial(t, mocks.NewMockIUseCase(c))
+
+	for _, name := range []string{"", watcherv1.Watcher_ServiceDesc.ServiceName} {
+		resp, err := healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{Service: name})
+		require.NoError(t, err)
+		assert.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.Status)
+	}
+
+	stream, err := reflectionpb.NewServerReflectionClient(conn).ServerReflectionInfo(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, stream.Send(&reflectionpb.ServerReflectionRequest{
+		MessageRequest: &reflectionpb.ServerReflectionRequest_ListServices{},
+	}))
+	resp, err := stream.Recv()
+	require.NoError(t, err)
+
+	var services []string
+	for _, s := range resp.GetListServicesResponse().Service {
+		services = append(services, s.Name)
+	}
+	assert.Contains(t, services, watcherv1.Watcher_ServiceDesc.ServiceName)
+	assert.Contains(t, services, "grpc.health.v1.Health")
+
+	// the descriptors of the messages are served too
+	require.NoError(t, stream.Send(&reflectionpb.ServerReflectionRequest{
+		MessageRequest: &reflectionpb.ServerReflectionRequest_FileContainingSymbol{FileContainingSymbol: "watcher.v1.Watcher"},
+	}))
+	resp, err = stream.Recv()
+	require.NoError(t, err)
+	assert.NotEmpty(t, resp.GetFileDescriptorResponse().GetFileDescriptorProto())
+}
+
+func TestServer_auth(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+	logic := mocks.NewMockIUseCase(c)
+
+	a, err := auth.New(auth.Config{Keys: []auth.Key{
+		{Name: "reader", Hash: auth.HashKey("reader-key"), Scopes: []string{auth.ScopeReadEvents}},
+		{Name: "uploader", Hash: auth.HashKey("uploader-key"), Scopes: []string{auth.ScopeUpload}},
+	}}, logic)
+	require.NoError(t, err)
+	conn := dialAuth(t, logic, a)
+	client := watcherv1.NewWatcherClient(conn)
+
+	logic.EXPECT().GetAPIKey(gomock.Any(), auth.HashKey("unknown-key")).Return(service.APIKey{}, service.ErrAPIKeyNotFound)
+	logic.EXPECT().ListUnits(gomock.Any(), "").Return([]service.Unit{}, nil)
+
+	tests := []struct {
+		name string
+		md   metadata.MD
+		want codes.Code
+	}{
+		{name: "no credentials", md: metadata.MD{}, want: codes.Unauthenticated},
+		{name: "unknown key", md: metadata.Pairs("x-api-key", "unknown-key"), want: codes.Unauthenticated},
+		{name: "no scope", md: metadata.Pairs("authorization", "Bearer uploader-key"), want: codes.PermissionDenied},
+		{name: "ok", md: metadata.Pairs("authorization", "Bearer reader-key"), want: codes.OK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := metadata.NewOutgoingContext(context.Background(), tt.md)
+			_, err := client.ListUnits(ctx, &watcherv1.ListUnitsRequest{})
+			assert.Equal(t, tt.want, status.Code(err))
+		})
+	}
+
+	// the streams are authenticated too
+	stream, err := client.StreamEvents(context.Background(), &watcherv1.StreamEventsRequest{})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	// the health service stays anonymous
+	resp, err := healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.Status)
+}
+
+func TestServer_rateLimit(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+	logic := mocks.NewMockIUseCase(c)
+	logic.EXPECT().ListUnits(gomock.Any(), "").Return([]service.Unit{}, nil).Times(2)
+	logic.EXPECT().Stream(gomock.Any(), gomock.Any(), "").Return(nil, service.ErrInvalidQuery)
+
+	limits := &ratelimit.Limits{Groups: map[string]*ratelimit.Limiter{
+		ratelimit.GroupEvents: ratelimit.NewLimiter(ratelimit.Rule{Rate: 2, Interval: time.Hour}),
+		ratelimit.GroupStream: ratelimit.NewLimiter(ratelimit.Rule{Rate: 1, Interval: time.Hour}),
+	}}
+	conn := dialLimits(t, logic, nil, limits)
+	client := watcherv1.NewWatcherClient(conn)
+
+	for i, want := range []codes.Code{codes.OK, codes.OK, codes.ResourceExhausted} {
+		_, err := client.ListUnits(context.Background(), &watcherv1.ListUnitsRequest{})
+		assert.Equal(t, want, status.Code(err), "call %d", i+1)
+	}
+
+	// the streams have their own group
+	for i, want := range []codes.Code{codes.InvalidArgument, codes.ResourceExhausted} {
+		s, err := client.StreamEvents(context.Background(), &watcherv1.StreamEventsRequest{})
+		require.NoError(t, err)
+		_, err = s.Recv()
+		assert.Equal(t, want, status.Code(err), "stream %d", i+1)
+	}
+
+	// the health service is not limited
+	_, err := healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{})
+	assert.NoError(t, err)
+}
+
+func TestServer_quota(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+	logic := mocks.NewMockIUseCase(c)
+	logic.EXPECT().ListUnits(gomock.Any(), "").Return([]service.Unit{}, nil)
+
+	limits := &ratelimit.Limits{
+		Groups: map[string]*ratelimit.Limiter{},
+		Quota:  ratelimit.NewQuota(1, nil, logger.New(httplog.NewLogger("watcher", httplog.Options{Concise: true}))),
+	}
+	client := watcherv1.NewWatcherClient(dialLimits(t, logic, nil, limits))
+
+	for i, want := range []codes.Code{codes.OK, codes.ResourceExhausted} {
+		_, err := client.ListUnits(context.Background(), &watcherv1.ListUnitsRequest{})
+		assert.Equal(t, want, status.Code(err), "call %d", i+1)
+	}
+}
+
+func TestServer_authFailures(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+	logic := mocks.NewMockIUseCase(c)
+	logic.EXPECT().GetAPIKey(gomock.Any(), auth.HashKey("unknown-key")).
+		Return(service.APIKey{}, service.ErrAPIKeyNotFound).Times(2)
+
+	a, err := auth.New(auth.Config{Keys: []auth.Key{
+		{Name: "reader", Hash: auth.HashKey("reader-key"), Scopes: []string{auth.ScopeReadEvents}},
+	}}, logic)
+	require.NoError(t, err)
+
+	limits := &ratelimit.Limits{Groups: map[string]*ratelimit.Limiter{
+		ratelimit.GroupAuth: ratelimit.NewLimiter(ratelimit.Rule{Rate: 2, Interval: time.Hour}),
+	}}
+	client := watcherv1.NewWatcherClient(dialLimits(t, logic, a, limits))
+
+	unknown := metadata.NewOutgoingContext(context.Background(), metadata.Pairs("x-api-key", "unknown-key"))
+	for i := 0; i < 2; i++ {
+		_, err := client.ListUnits(unknown, &watcherv1.ListUnitsRequest{})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err), "call %d", i+1)
+	}
+
+	// the client IP is denied before the credentials are checked, even the valid ones
+	reader := metadata.NewOutgoingContext(context.Background(), metadata.Pairs("x-api-key", "reader-key"))
+	_, err = client.ListUnits(reader, &watcherv1.ListUnitsRequest{})
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+}
@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"errors"
+	bettererror "github.com/egorgasay/bettererrors"
+	"github.com/go-chi/chi/v5"
+	"go-tsv-watcher/internal/schema"
+	"go-tsv-watcher/internal/storage/service"
+	"go-tsv-watcher/internal/usecase"
+	"net/http"
+)
+
+// ListAPIKeys godoc
+// @Summary List API keys
+// @Description List stored API keys without their secrets
+// @Tags admin
+// @Produce  json
+// @Success 200 {object} schema.APIKeysResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/admin/keys [get]
+func (h Handler) ListAPIKeys() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		keys, err := h.logic.ListAPIKeys(r.Context())
+		if err != nil {
+			writeError(w, r, http.StatusInternalServerError, err, bettererror.Storage)
+			return
+		}
+
+		writeJSON(w, r, http.StatusOK, schema.APIKeysResponse{Keys: keys})
+	}
+}
+
+// CreateAPIKey godoc
+// @Summary Create API key
+// @Description Create an API key with the scopes, its secret is returned only once
+// @Tags admin
+// @Accept  json
+// @Produce  json
+// @Param key body schema.APIKeyRequest true "name and scopes"
+// @Success 201 {object} usecase.CreatedAPIKey
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/admin/keys [post]
+func (h Handler) CreateAPIKey() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer r.Body.Close()
+
+		var req schema.APIKeyRequest
+		if err := BindJSON(r, &req); err != nil {
+			writeError(w, r, http.StatusBadRequest, err, bettererror.Handler)
+			return
+		}
+
+		key, err := h.logic.CreateAPIKey(r.Context(), req.Name, req.Scopes)
+		if err != nil {
+			if errors.Is(err, usecase.ErrInvalidAPIKey) {
+				writeError(w, r, http.StatusBadRequest, err, bettererror.Logic)
+				return
+			}
+			writeError(w, r, http.StatusInternalServerError, err, bettererror.Storage)
+			return
+		}
+
+		w.Header().Set("Location", "/api/v1/admin/keys/"+key.ID)
+		writeJSON(w, r, http.StatusCreated, key)
+	}
+}
+
+// DeleteAPIKey godoc
+// @Summary Delete API key
+// @Description Revoke the stored API key
+// @Tags admin
+// @Param id path string true "key id"
+// @Success 204
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/admin/keys/{id} [delete]
+func (h Handler) DeleteAPIKey() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := h.logic.DeleteAPIKey(r.Context(), chi.URLParam(r, "id"))
+		if err != nil {
+			if errors.Is(err, service.ErrAPIKeyNotFound) {
+				writeError(w, r, http.StatusNotFound, err, bettererror.Storage)
+				return
+			}
+			writeError(w, r, http.StatusInternalServerError, err, bettererror.Storage)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
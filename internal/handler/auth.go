@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	bettererror "github.com/egorgasay/bettererrors"
+	"go-tsv-watcher/internal/auth"
+	"net/http"
+	"strings"
+)
+
+// ErrAuthDisabled error occurs when the administrative endpoints are called without the authentication configured
+var ErrAuthDisabled = errors.New("authentication is not configured")
+
+// SetAuthenticator enables the authentication of the routes, they are anonymous
+// and the administrative routes are denied while it is not set.
+func (h *Handler) SetAuthenticator(a *auth.Authenticator) {
+	h.auth = a
+}
+
+// authenticate puts the principal of the request credentials into the context,
+// the access_token query parameter is accepted only if queryToken is set,
+// since EventSource and WebSocket clients of the browsers can't send headers.
+func (h Handler) authenticate(queryToken bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if h.auth == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// the client IP failing too often is denied before its credentials are looked up
+			failures, ip := h.authFailures(), ""
+			if failures != nil {
+				ip = h.clientIP(r)
+				if d := failures.Peek(ip); !d.Allowed {
+					setLimitHeaders(w.Header(), "X-RateLimit-", d)
+					writeTooManyRequests(w, r, d, ErrTooManyFailures)
+					return
+				}
+			}
+
+			principal, err := h.auth.Authenticate(r.Context(), credentials(r, queryToken))
+			if err != nil {
+				if errors.Is(err, auth.ErrUnauthenticated) {
+					if failures != nil {
+						failures.Allow(ip)
+					}
+					w.Header().Set("WWW-Authenticate", `Bearer realm="watcher"`)
+					writeError(w, r, http.StatusUnauthorized, err, bettererror.Handler)
+					return
+				}
+				writeError(w, r, http.StatusInternalServerError, err, bettererror.Storage)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), principal)))
+		})
+	}
+}
+
+// require denies the requests of the principals without the scope.
+func (h Handler) require(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if h.auth == nil {
+				if scope == auth.ScopeAdmin {
+					writeError(w, r, http.StatusForbidden, ErrAuthDisabled, bettererror.Handler)
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			principal, ok := auth.FromContext(r.Context())
+			if !ok || !principal.Has(scope) {
+				writeError(w, r, http.StatusForbidden, fmt.Errorf("%w: %s scope is required", auth.ErrForbidden, scope), bettererror.Handler)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// credentials returns the bearer token or the API key of the request.
+func credentials(r *http.Request, queryToken bool) string {
+	if header := r.Header.Get("Authorization"); header != "" {
+		scheme, token, _ := strings.Cut(header, " ")
+		if strings.EqualFold(scheme, "Bearer") {
+			return strings.TrimSpace(token)
+		}
+		return ""
+	}
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return key
+	}
+	if queryToken {
+		return r.URL.Query().Get("access_token")
+	}
+	return ""
+}
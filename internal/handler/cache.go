@@ -0,0 +1,18 @@
+package handler
+
+import (
+	"net/http"
+)
+
+// GetCacheStats godoc
+// @Summary Get cache statistics
+// @Description Get the size, hits, misses, evictions and invalidations of the event cache
+// @Tags event
+// @Produce  json
+// @Success 200 {object} usecase.CacheStats
+// @Router /api/v1/cache/stats [get]
+func (h Handler) GetCacheStats() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, r, http.StatusOK, h.logic.CacheStats())
+	}
+}
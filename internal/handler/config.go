@@ -0,0 +1,37 @@
+package handler
+
+import (
+	"errors"
+	bettererror "github.com/egorgasay/bettererrors"
+	"go-tsv-watcher/internal/reload"
+	"net/http"
+)
+
+// ErrReloadDisabled error occurs when the config status is requested without the reloader configured
+var ErrReloadDisabled = errors.New("config reload is not configured")
+
+// SetReloader enables the status of the loaded config.
+func (h *Handler) SetReloader(r *reload.Reloader) {
+	h.reloader = r
+}
+
+// GetConfigStatus godoc
+// @Summary Get config status
+// @Description Get the version of the loaded config, the changed fields and the ones waiting for a restart
+// @Tags admin
+// @Produce  json
+// @Success 200 {object} reload.Status
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /api/v1/admin/config [get]
+func (h Handler) GetConfigStatus() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if h.reloader == nil {
+			writeError(w, r, http.StatusNotFound, ErrReloadDisabled, bettererror.Handler)
+			return
+		}
+
+		writeJSON(w, r, http.StatusOK, h.reloader.Status())
+	}
+}
@@ -0,0 +1,208 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	bettererror "github.com/egorgasay/bettererrors"
+	"go-tsv-watcher/internal/events"
+	"go-tsv-watcher/internal/schema"
+	"go-tsv-watcher/internal/storage/service"
+	"go-tsv-watcher/internal/usecase"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ListEvents godoc
+// @Summary List events
+// @Description List events by filters with cursor-based pagination
+// @Tags event
+// @Produce  json
+// @Param unit_guid query string false "unit guid"
+// @Param inventory_id query string false "inventory id"
+// @Param message_class query string false "message class"
+// @Param message_id query string false "message id"
+// @Param area query string false "area"
+// @Param source_file query string false "source file"
+// @Param pipeline query string false "pipeline, all pipelines if empty"
+// @Param level_min query int false "min level"
+// @Param level_max query int false "max level"
+// @Param from query string false "ingested at or after, RFC3339"
+// @Param to query string false "ingested before, RFC3339"
+// @Param sort query string false "ingested_at, number, level, unit_guid or message_class, prefixed with - for descending order"
+// @Param limit query int false "page size"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param fields query string false "comma separated event fields"
+// @Success 200 {object} schema.EventsResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/events [get]
+func (h Handler) ListEvents() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query, err := parseEventsQuery(r.URL.Query())
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, err, bettererror.Handler)
+			return
+		}
+
+		fields, err := parseFields(r.URL.Query().Get("fields"))
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, err, bettererror.Handler)
+			return
+		}
+
+		page, err := h.logic.ListEvents(r.Context(), query)
+		if err != nil {
+			if errors.Is(err, service.ErrInvalidQuery) {
+				writeError(w, r, http.StatusBadRequest, err, bettererror.Logic)
+				return
+			}
+			if errors.Is(err, usecase.ErrPipelineNotFound) {
+				writeError(w, r, http.StatusNotFound, err, bettererror.Logic)
+				return
+			}
+			writeError(w, r, http.StatusInternalServerError, err, bettererror.Storage)
+			return
+		}
+
+		response := schema.EventsResponse{
+			Events:     make([]map[string]any, 0, len(page.Events)),
+			NextCursor: service.EncodeCursor(page.Next),
+		}
+		for _, e := range page.Events {
+			response.Events = append(response.Events, selectFields(e, fields))
+		}
+
+		writeJSON(w, r, http.StatusOK, response)
+	}
+}
+
+// parseEventsQuery parses the filters, sorting and pagination of the events list.
+func parseEventsQuery(values url.Values) (service.EventsQuery, error) {
+	query := service.EventsQuery{
+		Filter: service.EventFilter{
+			UnitGUID:     values.Get("unit_guid"),
+			InventoryID:  values.Get("inventory_id"),
+			MessageClass: values.Get("message_class"),
+			MessageID:    values.Get("message_id"),
+			Area:         values.Get("area"),
+			SourceFile:   values.Get("source_file"),
+			Pipeline:     values.Get("pipeline"),
+		},
+	}
+
+	var err error
+	if query.Filter.MinLevel, err = parseOptionalInt(values, "level_min"); err != nil {
+		return query, err
+	}
+	if query.Filter.MaxLevel, err = parseOptionalInt(values, "level_max"); err != nil {
+		return query, err
+	}
+	if query.Filter.From, err = parseOptionalTime(values, "from"); err != nil {
+		return query, err
+	}
+	if query.Filter.To, err = parseOptionalTime(values, "to"); err != nil {
+		return query, err
+	}
+
+	if sortBy := values.Get("sort"); sortBy != "" {
+		query.Desc = strings.HasPrefix(sortBy, "-")
+		query.SortBy = strings.TrimPrefix(sortBy, "-")
+		if _, ok := service.SortFields[query.SortBy]; !ok {
+			return query, fmt.Errorf("%w: unknown sort field %q", service.ErrInvalidQuery, query.SortBy)
+		}
+	}
+
+	if limit, err := parseOptionalInt(values, "limit"); err != nil {
+		return query, err
+	} else if limit != nil {
+		query.Limit = *limit
+	}
+
+	if cursor := values.Get("cursor"); cursor != "" {
+		if query.After, err = service.DecodeCursor(cursor); err != nil {
+			return query, fmt.Errorf("%w: bad cursor", err)
+		}
+	}
+
+	return query, nil
+}
+
+// parseOptionalInt parses the integer parameter, nil if it is not set.
+func parseOptionalInt(values url.Values, name string) (*int, error) {
+	raw := values.Get(name)
+	if raw == "" {
+		return nil, nil
+	}
+
+	n, err := strconv.Atoi(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s must be an integer", service.ErrInvalidQuery, name)
+	}
+	return &n, nil
+}
+
+// parseOptionalTime parses the RFC3339 time parameter, zero if it is not set.
+func parseOptionalTime(values url.Values, name string) (time.Time, error) {
+	raw := values.Get(name)
+	if raw == "" {
+		return time.Time{}, nil
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, raw)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: %s must be an RFC3339 time", service.ErrInvalidQuery, name)
+	}
+	return t, nil
+}
+
+// eventFields are the names of events.Event fields.
+var eventFields = func() map[string]string {
+	fields := make(map[string]string)
+	t := reflect.TypeOf(events.Event{})
+	for i := 0; i < t.NumField(); i++ {
+		fields[strings.ToLower(t.Field(i).Name)] = t.Field(i).Name
+	}
+	return fields
+}()
+
+// parseFields parses the comma separated list of event fields, nil means all fields.
+func parseFields(raw string) ([]string, error) {
+	if raw == "" {
+		return nil, nil
+	}
+
+	var fields []string
+	for _, name := range strings.Split(raw, ",") {
+		field, ok := eventFields[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown field %q", service.ErrInvalidQuery, name)
+		}
+		fields = append(fields, field)
+	}
+
+	return fields, nil
+}
+
+// selectFields returns the fields of the event by name, all fields if none are given.
+func selectFields(e events.Event, fields []string) map[string]any {
+	ev := reflect.ValueOf(e)
+
+	if fields == nil {
+		selected := make(map[string]any, ev.NumField())
+		for i := 0; i < ev.NumField(); i++ {
+			selected[ev.Type().Field(i).Name] = ev.Field(i).Interface()
+		}
+		return selected
+	}
+
+	selected := make(map[string]any, len(fields))
+	for _, name := range fields {
+		selected[name] = ev.FieldByName(name).Interface()
+	}
+	return selected
+}
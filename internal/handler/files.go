@@ -0,0 +1,128 @@
+package handler
+
+import (
+	"errors"
+	bettererror "github.com/egorgasay/bettererrors"
+	"github.com/go-chi/chi/v5"
+	"go-tsv-watcher/internal/schema"
+	"go-tsv-watcher/internal/storage/service"
+	"go-tsv-watcher/internal/usecase"
+	"net/http"
+)
+
+// ListFiles godoc
+// @Summary List files
+// @Description List ingested files
+// @Tags file
+// @Produce  json
+// @Param status query string false "ok, failed or deleted"
+// @Param pipeline query string false "pipeline, all pipelines if empty"
+// @Success 200 {object} schema.FilesResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/files [get]
+func (h Handler) ListFiles() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		files, err := h.logic.ListFiles(r.Context(), r.URL.Query().Get("pipeline"), r.URL.Query().Get("status"))
+		if err != nil {
+			if errors.Is(err, service.ErrInvalidQuery) {
+				writeError(w, r, http.StatusBadRequest, err, bettererror.Logic)
+				return
+			}
+			if errors.Is(err, usecase.ErrPipelineNotFound) {
+				writeError(w, r, http.StatusNotFound, err, bettererror.Logic)
+				return
+			}
+			writeError(w, r, http.StatusInternalServerError, err, bettererror.Storage)
+			return
+		}
+
+		writeJSON(w, r, http.StatusOK, schema.FilesResponse{Files: files})
+	}
+}
+
+// GetFile godoc
+// @Summary Get file
+// @Description Get ingested file with its error, counts, units and produced PDFs
+// @Tags file
+// @Produce  json
+// @Param name path string true "file name"
+// @Param pipeline query string false "pipeline, the first one if empty"
+// @Success 200 {object} service.FileDetails
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/files/{name} [get]
+func (h Handler) GetFile() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		file, err := h.logic.GetFile(r.Context(), r.URL.Query().Get("pipeline"), chi.URLParam(r, "name"))
+		if err != nil {
+			writeFileError(w, r, err)
+			return
+		}
+
+		writeJSON(w, r, http.StatusOK, file)
+	}
+}
+
+// ReprocessFile godoc
+// @Summary Reprocess file
+// @Description Delete events of the file and ingest it again
+// @Tags file
+// @Produce  json
+// @Param name path string true "file name"
+// @Param pipeline query string false "pipeline, the first one if empty"
+// @Success 202 {object} schema.FileActionResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Router /api/v1/files/{name}/reprocess [post]
+func (h Handler) ReprocessFile() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := chi.URLParam(r, "name")
+		if err := h.logic.ReprocessFile(r.Context(), r.URL.Query().Get("pipeline"), name); err != nil {
+			writeFileError(w, r, err)
+			return
+		}
+
+		writeJSON(w, r, http.StatusAccepted, schema.FileActionResponse{Name: name, Status: "queued"})
+	}
+}
+
+// DeleteFile godoc
+// @Summary Delete file
+// @Description Delete events of the file, the file is not ingested again
+// @Tags file
+// @Produce  json
+// @Param name path string true "file name"
+// @Param pipeline query string false "pipeline, the first one if empty"
+// @Success 200 {object} schema.FileActionResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/files/{name} [delete]
+func (h Handler) DeleteFile() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := chi.URLParam(r, "name")
+		deleted, err := h.logic.DeleteFile(r.Context(), r.URL.Query().Get("pipeline"), name)
+		if err != nil {
+			writeFileError(w, r, err)
+			return
+		}
+
+		writeJSON(w, r, http.StatusOK, schema.FileActionResponse{Name: name, Status: service.FileDeleted, Deleted: deleted})
+	}
+}
+
+// writeFileError writes the error of a file operation with its status code.
+func writeFileError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, service.ErrFileNotFound):
+		writeError(w, r, http.StatusNotFound, err, bettererror.Storage)
+	case errors.Is(err, usecase.ErrPipelineNotFound):
+		writeError(w, r, http.StatusNotFound, err, bettererror.Logic)
+	case errors.Is(err, usecase.ErrNotWatching):
+		writeError(w, r, http.StatusServiceUnavailable, err, bettererror.Logic)
+	default:
+		writeError(w, r, http.StatusInternalServerError, err, bettererror.Storage)
+	}
+}
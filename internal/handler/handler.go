@@ -5,6 +5,9 @@ import (
 	"errors"
 	bettererror "github.com/egorgasay/bettererrors"
 	"github.com/go-chi/httplog"
+	"go-tsv-watcher/internal/auth"
+	"go-tsv-watcher/internal/ratelimit"
+	"go-tsv-watcher/internal/reload"
 	"go-tsv-watcher/internal/schema"
 	"go-tsv-watcher/internal/storage/service"
 	"go-tsv-watcher/internal/usecase"
@@ -13,7 +16,12 @@ import (
 
 // Handler struct for handler
 type Handler struct {
-	logic usecase.IUseCase
+	logic    usecase.IUseCase
+	auth     *auth.Authenticator
+	limits   *ratelimit.Limits
+	reloader *reload.Reloader
+	// origins allowed to open the websocket streams besides the host of the server
+	origins []string
 }
 
 // New Handler constructor
@@ -21,6 +29,31 @@ func New(logic usecase.IUseCase) *Handler {
 	return &Handler{logic: logic}
 }
 
+// writeJSON writes the object as indented JSON with the status code.
+func writeJSON(w http.ResponseWriter, r *http.Request, status int, obj any) {
+	response, err := json.MarshalIndent(obj, "", "  ")
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, err, bettererror.Handler)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(response)
+}
+
+// writeError writes the error as JSON with the status code, server errors are logged.
+func writeError(w http.ResponseWriter, r *http.Request, status int, err error, layer string) {
+	if status >= http.StatusInternalServerError {
+		oplog := httplog.LogEntry(r.Context())
+		oplog.Error().Msg(err.Error())
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(bettererror.New(err).SetAppLayer(layer).JSONPretty())
+}
+
 // BindJSON godoc
 // @Summary Bind JSON
 // @Description Bind JSON
@@ -59,9 +92,9 @@ func (h Handler) PostEvent() http.HandlerFunc {
 		}
 		defer r.Body.Close()
 
-		event, err := h.logic.GetEventByNumber(r.Context(), eventRequest.UnitGUID, eventRequest.Page)
+		event, err := h.logic.GetEventByNumber(r.Context(), eventRequest.Pipeline, eventRequest.UnitGUID, eventRequest.Page)
 		if err != nil {
-			if errors.Is(err, service.ErrEventNotFound) {
+			if errors.Is(err, service.ErrEventNotFound) || errors.Is(err, usecase.ErrPipelineNotFound) {
 				w.WriteHeader(http.StatusNotFound)
 				w.Write(bettererror.New(err).SetAppLayer(bettererror.Storage).JSONPretty())
 				return
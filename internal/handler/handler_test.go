@@ -1,17 +1,30 @@
 package handler
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
 	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/httplog"
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go-tsv-watcher/internal/auth"
 	"go-tsv-watcher/internal/events"
+	"go-tsv-watcher/internal/ratelimit"
+	"go-tsv-watcher/internal/reload"
+	"go-tsv-watcher/internal/schema"
 	"go-tsv-watcher/internal/storage/service"
 	"go-tsv-watcher/internal/usecase"
 	mocks "go-tsv-watcher/internal/usecase/mocks"
+	"go-tsv-watcher/pkg/logger"
+	"golang.org/x/net/websocket"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestHandler_GetEvent(t *testing.T) {
@@ -29,12 +42,12 @@ func TestHandler_GetEvent(t *testing.T) {
 	}{
 		{
 			name:               "Ok",
-			body:               `{"unit_guid": "01749246-95f6-57db-b7c3-2ae0e8be6715","page": 1}`,
-			expectedBody:       "{\n  \"ID\": \"123\",\n  \"Number\": 0,\n  \"MQTT\": \"\",\n  \"InventoryID\": \"\",\n  \"UnitGUID\": \"01749246-95f6-57db-b7c3-2ae0e8be6715\",\n  \"MessageID\": \"\",\n  \"MessageText\": \"\",\n  \"Context\": \"\",\n  \"MessageClass\": \"\",\n  \"Level\": 0,\n  \"Area\": \"\",\n  \"Address\": \"\",\n  \"Block\": false,\n  \"Type\": \"\",\n  \"Bit\": 0,\n  \"InvertBit\": 0\n}",
+			body:               `{"unit_guid": "01749246-95f6-57db-b7c3-2ae0e8be6715","page": 1,"pipeline": "line2"}`,
+			expectedBody:       "{\n  \"ID\": \"123\",\n  \"Number\": 0,\n  \"MQTT\": \"\",\n  \"InventoryID\": \"\",\n  \"UnitGUID\": \"01749246-95f6-57db-b7c3-2ae0e8be6715\",\n  \"MessageID\": \"\",\n  \"MessageText\": \"\",\n  \"Context\": \"\",\n  \"MessageClass\": \"\",\n  \"Level\": 0,\n  \"Area\": \"\",\n  \"Address\": \"\",\n  \"Block\": false,\n  \"Type\": \"\",\n  \"Bit\": 0,\n  \"InvertBit\": 0,\n  \"SourceFile\": \"\",\n  \"IngestedAt\": \"0001-01-01T00:00:00Z\",\n  \"Pipeline\": \"line2\"\n}",
 			expectedStatusCode: 202,
 			mockBehavior: func(r *mocks.MockIUseCase) {
-				r.EXPECT().GetEventByNumber(gomock.Any(), "01749246-95f6-57db-b7c3-2ae0e8be6715", 1).
-					Return(events.Event{UnitGUID: "01749246-95f6-57db-b7c3-2ae0e8be6715", ID: "123"}, nil).AnyTimes()
+				r.EXPECT().GetEventByNumber(gomock.Any(), "line2", "01749246-95f6-57db-b7c3-2ae0e8be6715", 1).
+					Return(events.Event{UnitGUID: "01749246-95f6-57db-b7c3-2ae0e8be6715", ID: "123", Pipeline: "line2"}, nil).AnyTimes()
 			},
 		},
 		{
@@ -42,7 +55,7 @@ func TestHandler_GetEvent(t *testing.T) {
 			body:               ``,
 			expectedStatusCode: 400,
 			mockBehavior: func(r *mocks.MockIUseCase) {
-				r.EXPECT().GetEventByNumber(gomock.Any(), "", 0).
+				r.EXPECT().GetEventByNumber(gomock.Any(), "", "", 0).
 					Return(events.Event{}, nil).AnyTimes()
 			},
 		},
@@ -51,7 +64,7 @@ func TestHandler_GetEvent(t *testing.T) {
 			body:               `{"unit_guid": "01749246-95f6-57db-b7c3-2ae0e8be6716","page": 1}`,
 			expectedStatusCode: 404,
 			mockBehavior: func(r *mocks.MockIUseCase) {
-				r.EXPECT().GetEventByNumber(gomock.Any(), "01749246-95f6-57db-b7c3-2ae0e8be6716", 1).
+				r.EXPECT().GetEventByNumber(gomock.Any(), "", "01749246-95f6-57db-b7c3-2ae0e8be6716", 1).
 					Return(events.Event{}, service.ErrEventNotFound).AnyTimes()
 			},
 		},
@@ -60,7 +73,7 @@ func TestHandler_GetEvent(t *testing.T) {
 			body:               `{"unit_guid": "01749246-95f6-57db-b7c3-2ae0e8be6716","page": 1}`,
 			expectedStatusCode: 500,
 			mockBehavior: func(r *mocks.MockIUseCase) {
-				r.EXPECT().GetEventByNumber(gomock.Any(), "01749246-95f6-57db-b7c3-2ae0e8be6716", 1).
+				r.EXPECT().GetEventByNumber(gomock.Any(), "", "01749246-95f6-57db-b7c3-2ae0e8be6716", 1).
 					Return(events.Event{}, usecase.ErrStorageIsUnavailable).AnyTimes()
 			},
 		},
@@ -90,3 +103,1290 @@ func TestHandler_GetEvent(t *testing.T) {
 		})
 	}
 }
+
+func TestHandler_ListEvents(t *testing.T) {
+	type mockBehavior func(r *mocks.MockIUseCase)
+
+	minLevel := 50
+	tests := []struct {
+		name               string
+		url                string
+		expectedBody       string
+		mockBehavior       mockBehavior
+		expectedStatusCode int
+	}{
+		{
+			name:               "Ok",
+			url:                "/api/v1/events?unit_guid=unit1&level_min=50&sort=-level&limit=1&fields=ID,level",
+			expectedBody:       "{\n  \"events\": [\n    {\n      \"ID\": \"1\",\n      \"Level\": 100\n    }\n  ],\n  \"next_cursor\": \"eyJ2IjoiMTAwIiwiaWQiOiIxIn0\"\n}",
+			expectedStatusCode: 200,
+			mockBehavior: func(r *mocks.MockIUseCase) {
+				r.EXPECT().ListEvents(gomock.Any(), service.EventsQuery{
+					Filter: service.EventFilter{UnitGUID: "unit1", MinLevel: &minLevel},
+					SortBy: service.SortLevel,
+					Desc:   true,
+					Limit:  1,
+				}).Return(service.EventsPage{
+					Events: []events.Event{{ID: "1", Level: 100, UnitGUID: "unit1"}},
+					Next:   &service.Cursor{Value: "100", ID: "1"},
+				}, nil)
+			},
+		},
+		{
+			name:               "Next page",
+			url:                "/api/v1/events?cursor=eyJ2IjoiMTAwIiwiaWQiOiIxIn0&fields=id",
+			expectedBody:       "{\n  \"events\": []\n}",
+			expectedStatusCode: 200,
+			mockBehavior: func(r *mocks.MockIUseCase) {
+				r.EXPECT().ListEvents(gomock.Any(), service.EventsQuery{
+					After: &service.Cursor{Value: "100", ID: "1"},
+				}).Return(service.EventsPage{}, nil)
+			},
+		},
+		{
+			name:               "Unknown field",
+			url:                "/api/v1/events?fields=nope",
+			expectedStatusCode: 400,
+			mockBehavior:       func(r *mocks.MockIUseCase) {},
+		},
+		{
+			name:               "Unknown sort",
+			url:                "/api/v1/events?sort=nope",
+			expectedStatusCode: 400,
+			mockBehavior:       func(r *mocks.MockIUseCase) {},
+		},
+		{
+			name:               "Bad time",
+			url:                "/api/v1/events?from=yesterday",
+			expectedStatusCode: 400,
+			mockBehavior:       func(r *mocks.MockIUseCase) {},
+		},
+		{
+			name:               "Storage Error",
+			url:                "/api/v1/events",
+			expectedStatusCode: 500,
+			mockBehavior: func(r *mocks.MockIUseCase) {
+				r.EXPECT().ListEvents(gomock.Any(), gomock.Any()).
+					Return(service.EventsPage{}, usecase.ErrStorageIsUnavailable)
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+			logic := mocks.NewMockIUseCase(c)
+			test.mockBehavior(logic)
+
+			h := New(logic)
+
+			r := httptest.NewRequest(http.MethodGet, test.url, nil)
+			w := httptest.NewRecorder()
+
+			router := chi.NewRouter()
+			router.Group(h.PublicRoutes)
+			router.ServeHTTP(w, r)
+
+			// Assert
+			assert.Equal(t, test.expectedStatusCode, w.Code)
+			if test.expectedBody != "" {
+				assert.Equal(t, test.expectedBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandler_Units(t *testing.T) {
+	type mockBehavior func(r *mocks.MockIUseCase)
+
+	tests := []struct {
+		name               string
+		url                string
+		expectedBody       string
+		mockBehavior       mockBehavior
+		expectedStatusCode int
+	}{
+		{
+			name:               "List",
+			url:                "/api/v1/units",
+			expectedBody:       "{\n  \"units\": [\n    {\n      \"unit_guid\": \"unit1\",\n      \"inventory_ids\": [\n        \"inv1\"\n      ],\n      \"events\": 2,\n      \"first_seen\": \"0001-01-01T00:00:00Z\",\n      \"last_seen\": \"0001-01-01T00:00:00Z\",\n      \"latest_level\": 100\n    }\n  ]\n}",
+			expectedStatusCode: 200,
+			mockBehavior: func(r *mocks.MockIUseCase) {
+				r.EXPECT().ListUnits(gomock.Any(), "").Return([]service.Unit{
+					{GUID: "unit1", InventoryIDs: []string{"inv1"}, Events: 2, LatestLevel: 100},
+				}, nil)
+			},
+		},
+		{
+			name:               "List Storage Error",
+			url:                "/api/v1/units",
+			expectedStatusCode: 500,
+			mockBehavior: func(r *mocks.MockIUseCase) {
+				r.EXPECT().ListUnits(gomock.Any(), "").Return(nil, usecase.ErrStorageIsUnavailable)
+			},
+		},
+		{
+			name:               "Get",
+			url:                "/api/v1/units/unit1",
+			expectedStatusCode: 200,
+			mockBehavior: func(r *mocks.MockIUseCase) {
+				r.EXPECT().GetUnit(gomock.Any(), "", "unit1").Return(service.UnitSummary{
+					Unit:           service.Unit{GUID: "unit1", Events: 1},
+					ByMessageClass: map[string]int{"alarm": 1},
+					ByArea:         map[string]int{"LOCAL": 1},
+				}, nil)
+			},
+		},
+		{
+			name:               "Get Not Found",
+			url:                "/api/v1/units/unit2",
+			expectedStatusCode: 404,
+			mockBehavior: func(r *mocks.MockIUseCase) {
+				r.EXPECT().GetUnit(gomock.Any(), "", "unit2").Return(service.UnitSummary{}, service.ErrUnitNotFound)
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+			logic := mocks.NewMockIUseCase(c)
+			test.mockBehavior(logic)
+
+			h := New(logic)
+
+			r := httptest.NewRequest(http.MethodGet, test.url, nil)
+			w := httptest.NewRecorder()
+
+			router := chi.NewRouter()
+			router.Group(h.PublicRoutes)
+			router.ServeHTTP(w, r)
+
+			// Assert
+			assert.Equal(t, test.expectedStatusCode, w.Code)
+			if test.expectedBody != "" {
+				assert.Equal(t, test.expectedBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandler_Files(t *testing.T) {
+	type mockBehavior func(r *mocks.MockIUseCase)
+
+	tests := []struct {
+		name               string
+		method             string
+		url                string
+		expectedBody       string
+		mockBehavior       mockBehavior
+		expectedStatusCode int
+	}{
+		{
+			name:               "List",
+			method:             http.MethodGet,
+			url:                "/api/v1/files?status=failed&pipeline=line2",
+			expectedBody:       "{\n  \"files\": [\n    {\n      \"pipeline\": \"line2\",\n      \"name\": \"a.tsv\",\n      \"status\": \"failed\",\n      \"error\": \"bad file\",\n      \"events\": 0,\n      \"pruned\": 0,\n      \"archived\": 0\n    }\n  ]\n}",
+			expectedStatusCode: 200,
+			mockBehavior: func(r *mocks.MockIUseCase) {
+				f := service.NewFile("a.tsv", "bad file", nil)
+				f.Pipeline = "line2"
+				r.EXPECT().ListFiles(gomock.Any(), "line2", "failed").Return([]service.File{f}, nil)
+			},
+		},
+		{
+			name:               "List Bad Status",
+			method:             http.MethodGet,
+			url:                "/api/v1/files?status=nope",
+			expectedStatusCode: 400,
+			mockBehavior: func(r *mocks.MockIUseCase) {
+				r.EXPECT().ListFiles(gomock.Any(), "", "nope").Return(nil, service.ErrInvalidQuery)
+			},
+		},
+		{
+			name:               "Get Not Found",
+			method:             http.MethodGet,
+			url:                "/api/v1/files/b.tsv",
+			expectedStatusCode: 404,
+			mockBehavior: func(r *mocks.MockIUseCase) {
+				r.EXPECT().GetFile(gomock.Any(), "", "b.tsv").Return(service.FileDetails{}, service.ErrFileNotFound)
+			},
+		},
+		{
+			name:               "Get Unknown Pipeline",
+			method:             http.MethodGet,
+			url:                "/api/v1/files/a.tsv?pipeline=nope",
+			expectedStatusCode: 404,
+			mockBehavior: func(r *mocks.MockIUseCase) {
+				r.EXPECT().GetFile(gomock.Any(), "nope", "a.tsv").Return(service.FileDetails{}, usecase.ErrPipelineNotFound)
+			},
+		},
+		{
+			name:               "Reprocess",
+			method:             http.MethodPost,
+			url:                "/api/v1/files/a.tsv/reprocess",
+			expectedStatusCode: 202,
+			mockBehavior: func(r *mocks.MockIUseCase) {
+				r.EXPECT().ReprocessFile(gomock.Any(), "", "a.tsv").Return(nil)
+			},
+		},
+		{
+			name:               "Reprocess Not Watching",
+			method:             http.MethodPost,
+			url:                "/api/v1/files/a.tsv/reprocess",
+			expectedStatusCode: 503,
+			mockBehavior: func(r *mocks.MockIUseCase) {
+				r.EXPECT().ReprocessFile(gomock.Any(), "", "a.tsv").Return(usecase.ErrNotWatching)
+			},
+		},
+		{
+			name:               "Delete",
+			method:             http.MethodDelete,
+			url:                "/api/v1/files/a.tsv",
+			expectedBody:       "{\n  \"name\": \"a.tsv\",\n  \"status\": \"deleted\",\n  \"deleted\": 3\n}",
+			expectedStatusCode: 200,
+			mockBehavior: func(r *mocks.MockIUseCase) {
+				r.EXPECT().DeleteFile(gomock.Any(), "", "a.tsv").Return(3, nil)
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+			logic := mocks.NewMockIUseCase(c)
+			test.mockBehavior(logic)
+
+			h := New(logic)
+
+			r := httptest.NewRequest(test.method, test.url, nil)
+			w := httptest.NewRecorder()
+
+			router := chi.NewRouter()
+			router.Group(h.PublicRoutes)
+			router.ServeHTTP(w, r)
+
+			// Assert
+			assert.Equal(t, test.expectedStatusCode, w.Code)
+			if test.expectedBody != "" {
+				assert.Equal(t, test.expectedBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandler_Uploads(t *testing.T) {
+	type mockBehavior func(r *mocks.MockIUseCase)
+
+	upload := usecase.Upload{ID: "4a5e7a8e-3d0a-4c1b-9b86-6f0c1f9e2a11", Status: usecase.UploadPending}
+	tests := []struct {
+		name               string
+		method             string
+		url                string
+		contentType        string
+		body               string
+		mockBehavior       mockBehavior
+		expectedStatusCode int
+	}{
+		{
+			name:               "Raw",
+			method:             http.MethodPost,
+			url:                "/api/v1/uploads",
+			contentType:        "text/tab-separated-values",
+			body:               "n\tunit_guid\n",
+			expectedStatusCode: 202,
+			mockBehavior: func(r *mocks.MockIUseCase) {
+				r.EXPECT().Upload(gomock.Any(), "", readerOf("n\tunit_guid\n")).Return(upload, nil)
+			},
+		},
+		{
+			name:               "Multipart",
+			method:             http.MethodPost,
+			url:                "/api/v1/uploads",
+			contentType:        "multipart/form-data; boundary=b",
+			body:               "--b\r\nContent-Disposition: form-data; name=\"file\"; filename=\"a.tsv\"\r\n\r\nn\tunit_guid\n\r\n--b--\r\n",
+			expectedStatusCode: 202,
+			mockBehavior: func(r *mocks.MockIUseCase) {
+				r.EXPECT().Upload(gomock.Any(), "", readerOf("n\tunit_guid\n")).Return(upload, nil)
+			},
+		},
+		{
+			name:               "Multipart Without File",
+			method:             http.MethodPost,
+			url:                "/api/v1/uploads",
+			contentType:        "multipart/form-data; boundary=b",
+			body:               "--b\r\nContent-Disposition: form-data; name=\"other\"\r\n\r\nx\r\n--b--\r\n",
+			expectedStatusCode: 400,
+			mockBehavior:       func(r *mocks.MockIUseCase) {},
+		},
+		{
+			name:               "Too Large",
+			method:             http.MethodPost,
+			url:                "/api/v1/uploads",
+			body:               "n",
+			expectedStatusCode: 413,
+			mockBehavior: func(r *mocks.MockIUseCase) {
+				r.EXPECT().Upload(gomock.Any(), "", gomock.Any()).Return(usecase.Upload{}, usecase.ErrUploadTooLarge)
+			},
+		},
+		{
+			name:               "Status",
+			method:             http.MethodGet,
+			url:                "/api/v1/uploads/" + upload.ID,
+			expectedStatusCode: 200,
+			mockBehavior: func(r *mocks.MockIUseCase) {
+				r.EXPECT().GetUpload(gomock.Any(), "", upload.ID).Return(upload, nil)
+			},
+		},
+		{
+			name:               "Status Not Found",
+			method:             http.MethodGet,
+			url:                "/api/v1/uploads/nope",
+			expectedStatusCode: 404,
+			mockBehavior: func(r *mocks.MockIUseCase) {
+				r.EXPECT().GetUpload(gomock.Any(), "", "nope").Return(usecase.Upload{}, usecase.ErrUploadNotFound)
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+			logic := mocks.NewMockIUseCase(c)
+			test.mockBehavior(logic)
+
+			h := New(logic)
+
+			r := httptest.NewRequest(test.method, test.url, strings.NewReader(test.body))
+			if test.contentType != "" {
+				r.Header.Set("Content-Type", test.contentType)
+			}
+			w := httptest.NewRecorder()
+
+			router := chi.NewRouter()
+			router.Group(h.PublicRoutes)
+			router.ServeHTTP(w, r)
+
+			// Assert
+			assert.Equal(t, test.expectedStatusCode, w.Code)
+		})
+	}
+}
+
+// readerMatcher matches a reader by its content.
+type readerMatcher string
+
+func readerOf(content string) gomock.Matcher {
+	return readerMatcher(content)
+}
+
+func (m readerMatcher) Matches(x any) bool {
+	r, ok := x.(io.Reader)
+	if !ok {
+		return false
+	}
+	content, err := io.ReadAll(r)
+	return err == nil && string(content) == string(m)
+}
+
+func (m readerMatcher) String() string {
+	return fmt.Sprintf("reads %q", string(m))
+}
+
+func TestHandler_GetReport(t *testing.T) {
+	type mockBehavior func(r *mocks.MockIUseCase)
+
+	report := func() usecase.Report {
+		return usecase.Report{
+			ReadSeekCloser: nopCloser{strings.NewReader("%PDF-1.4 report")},
+			Name:           "unit1.pdf",
+			ContentType:    "application/pdf",
+			ModTime:        time.Date(2023, 5, 1, 0, 0, 0, 0, time.UTC),
+			ETag:           `"abc"`,
+		}
+	}
+
+	tests := []struct {
+		name               string
+		url                string
+		headers            map[string]string
+		expectedBody       string
+		expectedFormat     string
+		mockBehavior       mockBehavior
+		expectedStatusCode int
+	}{
+		{
+			name:               "Ok",
+			url:                "/api/v1/units/unit1/report.pdf",
+			expectedBody:       "%PDF-1.4 report",
+			expectedStatusCode: 200,
+			mockBehavior: func(r *mocks.MockIUseCase) {
+				r.EXPECT().Report(gomock.Any(), "unit1", usecase.ReportOptions{Format: "pdf"}).Return(report(), nil)
+			},
+		},
+		{
+			name:               "Range",
+			url:                "/api/v1/units/unit1/report.pdf",
+			headers:            map[string]string{"Range": "bytes=0-3"},
+			expectedBody:       "%PDF",
+			expectedStatusCode: 206,
+			mockBehavior: func(r *mocks.MockIUseCase) {
+				r.EXPECT().Report(gomock.Any(), "unit1", usecase.ReportOptions{Format: "pdf"}).Return(report(), nil)
+			},
+		},
+		{
+			name:               "Not Modified",
+			url:                "/api/v1/units/unit1/report.pdf",
+			headers:            map[string]string{"If-None-Match": `"abc"`},
+			expectedStatusCode: 304,
+			mockBehavior: func(r *mocks.MockIUseCase) {
+				r.EXPECT().Report(gomock.Any(), "unit1", usecase.ReportOptions{Format: "pdf"}).Return(report(), nil)
+			},
+		},
+		{
+			name:               "Filtered",
+			url:                "/api/v1/units/unit1/report.pdf?regenerate=true&source_file=a.tsv&from=2023-05-01T00:00:00Z",
+			expectedStatusCode: 200,
+			mockBehavior: func(r *mocks.MockIUseCase) {
+				r.EXPECT().Report(gomock.Any(), "unit1", usecase.ReportOptions{
+					Regenerate: true,
+					SourceFile: "a.tsv",
+					From:       time.Date(2023, 5, 1, 0, 0, 0, 0, time.UTC),
+					Format:     "pdf",
+				}).Return(report(), nil)
+			},
+		},
+		{
+			name:               "Bad Regenerate",
+			url:                "/api/v1/units/unit1/report.pdf?regenerate=maybe",
+			expectedStatusCode: 400,
+			mockBehavior:       func(r *mocks.MockIUseCase) {},
+		},
+		{
+			name:               "Not Found",
+			url:                "/api/v1/units/unit2/report.pdf",
+			expectedStatusCode: 404,
+			mockBehavior: func(r *mocks.MockIUseCase) {
+				r.EXPECT().Report(gomock.Any(), "unit2", usecase.ReportOptions{Format: "pdf"}).
+					Return(usecase.Report{}, usecase.ErrReportNotFound)
+			},
+		},
+		{
+			name:               "Format Parameter",
+			url:                "/api/v1/units/unit1/report?format=csv",
+			headers:            map[string]string{"Accept": "text/html"},
+			expectedStatusCode: 200,
+			mockBehavior: func(r *mocks.MockIUseCase) {
+				r.EXPECT().Report(gomock.Any(), "unit1", usecase.ReportOptions{Format: "csv"}).Return(report(), nil)
+			},
+		},
+		{
+			name:               "Default Format",
+			url:                "/api/v1/units/unit1/report",
+			expectedBody:       "%PDF-1.4 report",
+			expectedFormat:     "application/pdf",
+			expectedStatusCode: 200,
+			mockBehavior: func(r *mocks.MockIUseCase) {
+				r.EXPECT().Report(gomock.Any(), "unit1", usecase.ReportOptions{Format: "pdf"}).Return(report(), nil)
+			},
+		},
+		{
+			name:               "Accept",
+			url:                "/api/v1/units/unit1/report",
+			headers:            map[string]string{"Accept": "text/csv;q=0.5, application/vnd.openxmlformats-officedocument.spreadsheetml.sheet;q=0.9, */*;q=0.1"},
+			expectedStatusCode: 200,
+			mockBehavior: func(r *mocks.MockIUseCase) {
+				r.EXPECT().Report(gomock.Any(), "unit1", usecase.ReportOptions{Format: "xlsx"}).Return(report(), nil)
+			},
+		},
+		{
+			name:               "Accept Wildcard",
+			url:                "/api/v1/units/unit1/report",
+			headers:            map[string]string{"Accept": "text/*"},
+			expectedStatusCode: 200,
+			mockBehavior: func(r *mocks.MockIUseCase) {
+				r.EXPECT().Report(gomock.Any(), "unit1", usecase.ReportOptions{Format: "html"}).Return(report(), nil)
+			},
+		},
+		{
+			name:               "Not Acceptable",
+			url:                "/api/v1/units/unit1/report",
+			headers:            map[string]string{"Accept": "image/png"},
+			expectedStatusCode: 406,
+			mockBehavior:       func(r *mocks.MockIUseCase) {},
+		},
+		{
+			name:               "Unknown Format",
+			url:                "/api/v1/units/unit1/report?format=doc",
+			expectedStatusCode: 400,
+			mockBehavior: func(r *mocks.MockIUseCase) {
+				r.EXPECT().Report(gomock.Any(), "unit1", usecase.ReportOptions{Format: "doc"}).
+					Return(usecase.Report{}, usecase.ErrUnknownFormat)
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+			logic := mocks.NewMockIUseCase(c)
+			test.mockBehavior(logic)
+
+			h := New(logic)
+
+			r := httptest.NewRequest(http.MethodGet, test.url, nil)
+			for k, v := range test.headers {
+				r.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+
+			router := chi.NewRouter()
+			router.Group(h.PublicRoutes)
+			router.ServeHTTP(w, r)
+
+			// Assert
+			assert.Equal(t, test.expectedStatusCode, w.Code)
+			if test.expectedBody != "" {
+				assert.Equal(t, test.expectedBody, w.Body.String())
+				assert.Equal(t, `"abc"`, w.Header().Get("ETag"))
+			}
+			if test.expectedFormat != "" {
+				assert.Equal(t, test.expectedFormat, w.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
+
+// nopCloser is a report content without resources to release.
+type nopCloser struct {
+	io.ReadSeeker
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
+
+// streamOf returns a stream of the events closed after the delay.
+func streamOf(delay time.Duration, evs ...events.Event) <-chan usecase.StreamEvent {
+	stream := make(chan usecase.StreamEvent, len(evs))
+	for _, e := range evs {
+		stream <- usecase.StreamEvent{ID: "id-" + e.ID, Event: e}
+	}
+	go func() {
+		time.Sleep(delay)
+		close(stream)
+	}()
+	return stream
+}
+
+func TestHandler_StreamEvents(t *testing.T) {
+	type mockBehavior func(r *mocks.MockIUseCase)
+
+	defer func(interval time.Duration) { heartbeatInterval = interval }(heartbeatInterval)
+	heartbeatInterval = 10 * time.Millisecond
+
+	minLevel := 2
+	tests := []struct {
+		name               string
+		url                string
+		headers            map[string]string
+		expectedBody       []string
+		mockBehavior       mockBehavior
+		expectedStatusCode int
+	}{
+		{
+			name:               "Ok",
+			url:                "/api/v1/events/stream?unit_guid=unit1&message_class=alarm&level_min=2&fields=ID,Level",
+			expectedStatusCode: 200,
+			expectedBody: []string{
+				"id: id-e1\nevent: event\ndata: {\"ID\":\"e1\",\"Level\":3}\n\n",
+				"id: id-e2\nevent: event\ndata: {\"ID\":\"e2\",\"Level\":4}\n\n",
+			},
+			mockBehavior: func(r *mocks.MockIUseCase) {
+				r.EXPECT().Stream(gomock.Any(), service.EventFilter{UnitGUID: "unit1", MessageClass: "alarm", MinLevel: &minLevel}, "").
+					Return(streamOf(0, events.Event{ID: "e1", Level: 3}, events.Event{ID: "e2", Level: 4}), nil)
+			},
+		},
+		{
+			name:               "Resume And Heartbeat",
+			url:                "/api/v1/events/stream",
+			headers:            map[string]string{"Last-Event-ID": "id-e1"},
+			expectedStatusCode: 200,
+			expectedBody:       []string{": heartbeat\n\n"},
+			mockBehavior: func(r *mocks.MockIUseCase) {
+				r.EXPECT().Stream(gomock.Any(), service.EventFilter{}, "id-e1").Return(streamOf(50*time.Millisecond), nil)
+			},
+		},
+		{
+			name:               "Bad Level",
+			url:                "/api/v1/events/stream?level_min=high",
+			expectedStatusCode: 400,
+			mockBehavior:       func(r *mocks.MockIUseCase) {},
+		},
+		{
+			name:               "Bad Last Event ID",
+			url:                "/api/v1/events/stream?last_event_id=nope",
+			expectedStatusCode: 400,
+			mockBehavior: func(r *mocks.MockIUseCase) {
+				r.EXPECT().Stream(gomock.Any(), service.EventFilter{}, "nope").Return(nil, service.ErrInvalidQuery)
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+			logic := mocks.NewMockIUseCase(c)
+			test.mockBehavior(logic)
+
+			h := New(logic)
+
+			r := httptest.NewRequest(http.MethodGet, test.url, nil)
+			for k, v := range test.headers {
+				r.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+
+			router := chi.NewRouter()
+			router.Group(h.PublicRoutes)
+			router.ServeHTTP(w, r)
+
+			// Assert
+			assert.Equal(t, test.expectedStatusCode, w.Code)
+			for _, body := range test.expectedBody {
+				assert.Contains(t, w.Body.String(), body)
+			}
+			if test.expectedStatusCode == 200 {
+				assert.Equal(t, "text/event-stream", w.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
+
+func TestHandler_StreamEventsWS(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+	logic := mocks.NewMockIUseCase(c)
+
+	defer func(interval time.Duration) { heartbeatInterval = interval }(heartbeatInterval)
+	heartbeatInterval = 20 * time.Millisecond
+
+	logic.EXPECT().Stream(gomock.Any(), service.EventFilter{UnitGUID: "unit1"}, "id-e0").
+		Return(streamOf(time.Second, events.Event{ID: "e1", UnitGUID: "unit1"}), nil)
+
+	router := chi.NewRouter()
+	router.Group(New(logic).PublicRoutes)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/api/v1/events/ws?unit_guid=unit1&last_event_id=id-e0&fields=id"
+	ws, err := websocket.Dial(url, "", server.URL)
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	defer ws.Close()
+
+	var msg schema.StreamMessage
+	if err = websocket.JSON.Receive(ws, &msg); err != nil {
+		t.Fatalf("Receive() error = %v", err)
+	}
+	assert.Equal(t, schema.StreamMessage{Type: "event", ID: "id-e1", Event: map[string]any{"ID": "e1"}}, msg)
+
+	msg = schema.StreamMessage{}
+	if err = websocket.JSON.Receive(ws, &msg); err != nil {
+		t.Fatalf("Receive() error = %v", err)
+	}
+	assert.Equal(t, "heartbeat", msg.Type)
+
+	// the bad parameters are rejected before the upgrade
+	_, err = websocket.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/api/v1/events/ws?level_min=high", "", server.URL)
+	assert.Error(t, err)
+}
+
+func TestHandler_StreamEventsWS_origin(t *testing.T) {
+	tests := []struct {
+		name       string
+		origins    []string
+		origin     string
+		wantStatus int
+	}{
+		{
+			name:       "other origin",
+			origin:     "https://evil.example",
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "allowed origin",
+			origins:    []string{"https://dashboard.example"},
+			origin:     "https://Dashboard.example",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "any origin",
+			origins:    []string{"*"},
+			origin:     "https://evil.example",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "same host",
+			origin:     "http://example.com",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "no origin",
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			h := New(mocks.NewMockIUseCase(c))
+			h.SetStreamOrigins(tt.origins)
+			router := chi.NewRouter()
+			router.Group(h.PublicRoutes)
+
+			// the accepted handshakes fail on the bad parameter without a stream
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/events/ws?level_min=high", nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+		})
+	}
+}
+
+func TestHandler_auth(t *testing.T) {
+	type mockBehavior func(r *mocks.MockIUseCase)
+
+	keys := []auth.Key{
+		{Name: "reader", Hash: auth.HashKey("reader-key"), Scopes: []string{auth.ScopeReadEvents}},
+		{Name: "uploader", Hash: auth.HashKey("uploader-key"), Scopes: []string{auth.ScopeUpload}},
+		{Name: "admin", Hash: auth.HashKey("admin-key"), Scopes: []string{auth.ScopeAdmin}},
+	}
+	tests := []struct {
+		name               string
+		disabled           bool
+		method             string
+		url                string
+		header             map[string]string
+		mockBehavior       mockBehavior
+		expectedStatusCode int
+	}{
+		{
+			name:               "No Credentials",
+			method:             http.MethodGet,
+			url:                "/api/v1/units",
+			expectedStatusCode: 401,
+			mockBehavior:       func(r *mocks.MockIUseCase) {},
+		},
+		{
+			name:               "Unknown Key",
+			method:             http.MethodGet,
+			url:                "/api/v1/units",
+			header:             map[string]string{"X-API-Key": "wtk_unknown"},
+			expectedStatusCode: 401,
+			mockBehavior: func(r *mocks.MockIUseCase) {
+				r.EXPECT().GetAPIKey(gomock.Any(), auth.HashKey("wtk_unknown")).Return(service.APIKey{}, service.ErrAPIKeyNotFound)
+			},
+		},
+		{
+			name:               "Bearer",
+			method:             http.MethodGet,
+			url:                "/api/v1/units",
+			header:             map[string]string{"Authorization": "Bearer reader-key"},
+			expectedStatusCode: 200,
+			mockBehavior: func(r *mocks.MockIUseCase) {
+				r.EXPECT().ListUnits(gomock.Any(), "").Return([]service.Unit{}, nil)
+			},
+		},
+		{
+			name:               "Stored Key",
+			method:             http.MethodGet,
+			url:                "/api/v1/units",
+			header:             map[string]string{"X-API-Key": "wtk_stored"},
+			expectedStatusCode: 200,
+			mockBehavior: func(r *mocks.MockIUseCase) {
+				r.EXPECT().GetAPIKey(gomock.Any(), auth.HashKey("wtk_stored")).
+					Return(service.APIKey{Name: "stored", Scopes: []string{auth.ScopeReadEvents}}, nil)
+				r.EXPECT().ListUnits(gomock.Any(), "").Return([]service.Unit{}, nil)
+			},
+		},
+		{
+			name:               "Query Token Of Other Routes",
+			method:             http.MethodGet,
+			url:                "/api/v1/units?access_token=reader-key",
+			expectedStatusCode: 401,
+			mockBehavior:       func(r *mocks.MockIUseCase) {},
+		},
+		{
+			name:               "Missing Scope",
+			method:             http.MethodDelete,
+			url:                "/api/v1/files/a.tsv",
+			header:             map[string]string{"X-API-Key": "reader-key"},
+			expectedStatusCode: 403,
+			mockBehavior:       func(r *mocks.MockIUseCase) {},
+		},
+		{
+			name:               "Upload Scope",
+			method:             http.MethodGet,
+			url:                "/api/v1/uploads/nope",
+			header:             map[string]string{"X-API-Key": "uploader-key"},
+			expectedStatusCode: 404,
+			mockBehavior: func(r *mocks.MockIUseCase) {
+				r.EXPECT().GetUpload(gomock.Any(), "", "nope").Return(usecase.Upload{}, usecase.ErrUploadNotFound)
+			},
+		},
+		{
+			name:               "Admin Grants All Scopes",
+			method:             http.MethodDelete,
+			url:                "/api/v1/files/a.tsv",
+			header:             map[string]string{"X-API-Key": "admin-key"},
+			expectedStatusCode: 200,
+			mockBehavior: func(r *mocks.MockIUseCase) {
+				r.EXPECT().DeleteFile(gomock.Any(), "", "a.tsv").Return(1, nil)
+			},
+		},
+		{
+			name:               "Private Without Admin",
+			method:             http.MethodGet,
+			url:                "/api/v1/admin/keys",
+			header:             map[string]string{"X-API-Key": "reader-key"},
+			expectedStatusCode: 403,
+			mockBehavior:       func(r *mocks.MockIUseCase) {},
+		},
+		{
+			name:               "Disabled",
+			disabled:           true,
+			method:             http.MethodGet,
+			url:                "/api/v1/units",
+			expectedStatusCode: 200,
+			mockBehavior: func(r *mocks.MockIUseCase) {
+				r.EXPECT().ListUnits(gomock.Any(), "").Return([]service.Unit{}, nil)
+			},
+		},
+		{
+			name:               "Private Disabled",
+			disabled:           true,
+			method:             http.MethodGet,
+			url:                "/api/v1/admin/keys",
+			expectedStatusCode: 403,
+			mockBehavior:       func(r *mocks.MockIUseCase) {},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+			logic := mocks.NewMockIUseCase(c)
+			test.mockBehavior(logic)
+
+			h := New(logic)
+			if !test.disabled {
+				a, err := auth.New(auth.Config{Keys: keys}, logic)
+				require.NoError(t, err)
+				h.SetAuthenticator(a)
+			}
+
+			r := httptest.NewRequest(test.method, test.url, nil)
+			for k, v := range test.header {
+				r.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+
+			router := chi.NewRouter()
+			router.Group(h.PublicRoutes)
+			router.Group(h.PrivateRoutes)
+			router.ServeHTTP(w, r)
+
+			// Assert
+			assert.Equal(t, test.expectedStatusCode, w.Code)
+			if w.Code == http.StatusUnauthorized {
+				assert.NotEmpty(t, w.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
+
+func TestHandler_StreamEvents_queryToken(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+	logic := mocks.NewMockIUseCase(c)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream := make(chan usecase.StreamEvent)
+	close(stream)
+	logic.EXPECT().Stream(gomock.Any(), gomock.Any(), "").Return((<-chan usecase.StreamEvent)(stream), nil)
+
+	a, err := auth.New(auth.Config{Keys: []auth.Key{
+		{Name: "reader", Hash: auth.HashKey("reader-key"), Scopes: []string{auth.ScopeReadEvents}},
+	}}, logic)
+	require.NoError(t, err)
+	h := New(logic)
+	h.SetAuthenticator(a)
+
+	router := chi.NewRouter()
+	router.Group(h.PublicRoutes)
+
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/events/stream?access_token=reader-key", nil).WithContext(ctx)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestHandler_APIKeys(t *testing.T) {
+	type mockBehavior func(r *mocks.MockIUseCase)
+
+	created := time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)
+	key := service.APIKey{ID: "k1", Name: "ci", Hash: "secret hash", Scopes: []string{auth.ScopeUpload}, CreatedAt: created}
+	tests := []struct {
+		name               string
+		method             string
+		url                string
+		body               string
+		expectedBody       string
+		mockBehavior       mockBehavior
+		expectedStatusCode int
+	}{
+		{
+			name:               "List",
+			method:             http.MethodGet,
+			url:                "/api/v1/admin/keys",
+			expectedBody:       "{\n  \"keys\": [\n    {\n      \"id\": \"k1\",\n      \"name\": \"ci\",\n      \"scopes\": [\n        \"files:upload\"\n      ],\n      \"created_at\": \"2023-05-01T10:00:00Z\"\n    }\n  ]\n}",
+			expectedStatusCode: 200,
+			mockBehavior: func(r *mocks.MockIUseCase) {
+				r.EXPECT().ListAPIKeys(gomock.Any()).Return([]service.APIKey{key}, nil)
+			},
+		},
+		{
+			name:               "Create",
+			method:             http.MethodPost,
+			url:                "/api/v1/admin/keys",
+			body:               `{"name": "ci", "scopes": ["files:upload"]}`,
+			expectedBody:       "{\n  \"id\": \"k1\",\n  \"name\": \"ci\",\n  \"scopes\": [\n    \"files:upload\"\n  ],\n  \"created_at\": \"2023-05-01T10:00:00Z\",\n  \"key\": \"wtk_secret\"\n}",
+			expectedStatusCode: 201,
+			mockBehavior: func(r *mocks.MockIUseCase) {
+				r.EXPECT().CreateAPIKey(gomock.Any(), "ci", []string{auth.ScopeUpload}).
+					Return(usecase.CreatedAPIKey{APIKey: key, Key: "wtk_secret"}, nil)
+			},
+		},
+		{
+			name:               "Create Unknown Scope",
+			method:             http.MethodPost,
+			url:                "/api/v1/admin/keys",
+			body:               `{"name": "ci", "scopes": ["root"]}`,
+			expectedStatusCode: 400,
+			mockBehavior: func(r *mocks.MockIUseCase) {
+				r.EXPECT().CreateAPIKey(gomock.Any(), "ci", []string{"root"}).
+					Return(usecase.CreatedAPIKey{}, usecase.ErrInvalidAPIKey)
+			},
+		},
+		{
+			name:               "Create Bad Body",
+			method:             http.MethodPost,
+			url:                "/api/v1/admin/keys",
+			body:               `{"name": "ci", "role": "admin"}`,
+			expectedStatusCode: 400,
+			mockBehavior:       func(r *mocks.MockIUseCase) {},
+		},
+		{
+			name:               "Delete",
+			method:             http.MethodDelete,
+			url:                "/api/v1/admin/keys/k1",
+			expectedStatusCode: 204,
+			mockBehavior: func(r *mocks.MockIUseCase) {
+				r.EXPECT().DeleteAPIKey(gomock.Any(), "k1").Return(nil)
+			},
+		},
+		{
+			name:               "Delete Not Found",
+			method:             http.MethodDelete,
+			url:                "/api/v1/admin/keys/k2",
+			expectedStatusCode: 404,
+			mockBehavior: func(r *mocks.MockIUseCase) {
+				r.EXPECT().DeleteAPIKey(gomock.Any(), "k2").Return(service.ErrAPIKeyNotFound)
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+			logic := mocks.NewMockIUseCase(c)
+			test.mockBehavior(logic)
+
+			a, err := auth.New(auth.Config{Keys: []auth.Key{
+				{Name: "admin", Hash: auth.HashKey("admin-key"), Scopes: []string{auth.ScopeAdmin}},
+			}}, logic)
+			require.NoError(t, err)
+			h := New(logic)
+			h.SetAuthenticator(a)
+
+			r := httptest.NewRequest(test.method, test.url, strings.NewReader(test.body))
+			r.Header.Set("Authorization", "Bearer admin-key")
+			w := httptest.NewRecorder()
+
+			router := chi.NewRouter()
+			router.Group(h.PrivateRoutes)
+			router.ServeHTTP(w, r)
+
+			// Assert
+			assert.Equal(t, test.expectedStatusCode, w.Code)
+			if test.expectedBody != "" {
+				assert.Equal(t, test.expectedBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandler_rateLimit(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+	logic := mocks.NewMockIUseCase(c)
+	logic.EXPECT().ListUnits(gomock.Any(), "").Return([]service.Unit{}, nil).AnyTimes()
+
+	a, err := auth.New(auth.Config{Keys: []auth.Key{
+		{Name: "reader", Hash: auth.HashKey("reader-key"), Scopes: []string{auth.ScopeReadEvents}},
+	}}, logic)
+	require.NoError(t, err)
+
+	get := func(h *Handler, key, remoteAddr string) *httptest.ResponseRecorder {
+		router := chi.NewRouter()
+		router.Group(h.PublicRoutes)
+
+		r := httptest.NewRequest(http.MethodGet, "/api/v1/units", nil)
+		if key != "" {
+			r.Header.Set("X-API-Key", key)
+		}
+		r.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		return w
+	}
+
+	t.Run("Rate", func(t *testing.T) {
+		h := New(logic)
+		h.SetAuthenticator(a)
+		h.SetRateLimits(&ratelimit.Limits{
+			Groups: map[string]*ratelimit.Limiter{ratelimit.GroupEvents: ratelimit.NewLimiter(ratelimit.Rule{Rate: 2, Interval: time.Hour})},
+			Quota:  ratelimit.NewQuota(10, nil, nil),
+		})
+
+		w := get(h, "reader-key", "10.0.0.1:1000")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "2", w.Header().Get("X-RateLimit-Limit"))
+		assert.Equal(t, "1", w.Header().Get("X-RateLimit-Remaining"))
+		assert.Equal(t, "9", w.Header().Get("X-Quota-Remaining"))
+
+		// the same key from another address shares the bucket
+		assert.Equal(t, http.StatusOK, get(h, "reader-key", "10.0.0.2:1000").Code)
+
+		w = get(h, "reader-key", "10.0.0.1:1000")
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		assert.Equal(t, "1800", w.Header().Get("Retry-After"))
+	})
+
+	t.Run("Quota", func(t *testing.T) {
+		h := New(logic)
+		h.SetRateLimits(&ratelimit.Limits{Quota: ratelimit.NewQuota(2, nil, nil), IPHeader: "X-Forwarded-For"})
+
+		// the anonymous clients are counted by ip
+		for i := 0; i < 2; i++ {
+			assert.Equal(t, http.StatusOK, get(h, "", fmt.Sprintf("10.0.0.3:%d", 1000+i)).Code)
+		}
+		w := get(h, "", "10.0.0.3:3000")
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		assert.Equal(t, "0", w.Header().Get("X-Quota-Remaining"))
+		assert.NotEmpty(t, w.Header().Get("Retry-After"))
+
+		forwarded := func(h *Handler, value string) int {
+			r := httptest.NewRequest(http.MethodGet, "/api/v1/units", nil)
+			r.Header.Set("X-Forwarded-For", value)
+			r.RemoteAddr = "10.0.0.4:4000"
+			w := httptest.NewRecorder()
+			router := chi.NewRouter()
+			router.Group(h.PublicRoutes)
+			router.ServeHTTP(w, r)
+			return w.Code
+		}
+		assert.Equal(t, http.StatusOK, forwarded(h, "10.0.0.3, 192.168.1.1"), "ip appended by the proxy")
+		// the entries before the one of the proxy are set by the client
+		assert.Equal(t, http.StatusTooManyRequests, forwarded(h, "203.0.113.9, 10.0.0.3"), "spoofed ip")
+
+		h.SetRateLimits(&ratelimit.Limits{Quota: ratelimit.NewQuota(1, nil, nil), IPHeader: "X-Forwarded-For", TrustedProxies: 2})
+		assert.Equal(t, http.StatusOK, forwarded(h, "203.0.113.9, 10.0.0.5, 192.168.1.1"))
+		assert.Equal(t, http.StatusTooManyRequests, forwarded(h, "198.51.100.1, 10.0.0.5, 192.168.1.2"), "ip of the farthest proxy")
+	})
+
+	t.Run("Failed authentications", func(t *testing.T) {
+		h := New(logic)
+		h.SetAuthenticator(a)
+		h.SetRateLimits(&ratelimit.Limits{
+			Groups: map[string]*ratelimit.Limiter{ratelimit.GroupAuth: ratelimit.NewLimiter(ratelimit.Rule{Rate: 2, Interval: time.Hour})},
+		})
+		logic.EXPECT().GetAPIKey(gomock.Any(), gomock.Any()).Return(service.APIKey{}, service.ErrAPIKeyNotFound).Times(2)
+
+		assert.Equal(t, http.StatusUnauthorized, get(h, "wtk_bad1", "10.0.0.6:1000").Code)
+		assert.Equal(t, http.StatusOK, get(h, "reader-key", "10.0.0.6:1000").Code, "successes are not counted")
+		assert.Equal(t, http.StatusUnauthorized, get(h, "wtk_bad2", "10.0.0.6:1000").Code)
+
+		// the keys are not looked up any more
+		w := get(h, "wtk_bad3", "10.0.0.6:1000")
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		assert.Equal(t, "1800", w.Header().Get("Retry-After"))
+		assert.Equal(t, http.StatusOK, get(h, "reader-key", "10.0.0.7:1000").Code, "other ip")
+	})
+}
+
+func TestHandler_GetCacheStats(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+	logic := mocks.NewMockIUseCase(c)
+	logic.EXPECT().CacheStats().Return(usecase.CacheStats{Enabled: true, Size: 1, Capacity: 10, TTL: "1m0s", Hits: 3, Misses: 1, HitRatio: 0.75})
+
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/cache/stats", nil)
+	w := httptest.NewRecorder()
+
+	router := chi.NewRouter()
+	router.Group(New(logic).PublicRoutes)
+	router.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "{\n  \"enabled\": true,\n  \"size\": 1,\n  \"capacity\": 10,\n  \"ttl\": \"1m0s\",\n  \"hits\": 3,\n  \"misses\": 1,\n  \"evictions\": 0,\n  \"invalidations\": 0,\n  \"hit_ratio\": 0.75\n}", w.Body.String())
+}
+
+func TestHandler_GetConfigStatus(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+	logic := mocks.NewMockIUseCase(c)
+
+	a, err := auth.New(auth.Config{Keys: []auth.Key{
+		{Name: "admin", Hash: auth.HashKey("admin-key"), Scopes: []string{auth.ScopeAdmin}},
+	}}, logic)
+	require.NoError(t, err)
+	h := New(logic)
+	h.SetAuthenticator(a)
+
+	get := func() *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/api/v1/admin/config", nil)
+		r.Header.Set("Authorization", "Bearer admin-key")
+		w := httptest.NewRecorder()
+
+		router := chi.NewRouter()
+		router.Group(h.PrivateRoutes)
+		router.ServeHTTP(w, r)
+		return w
+	}
+
+	assert.Equal(t, http.StatusNotFound, get().Code)
+
+	reloader := reload.New("", func() ([]string, []string, error) {
+		return []string{"log_level", "http"}, []string{"http"}, nil
+	}, logger.New(httplog.NewLogger("watcher", httplog.Options{Concise: true})))
+	require.NoError(t, reloader.Reload())
+	h.SetReloader(reloader)
+
+	w := get()
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var status reload.Status
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+	assert.Equal(t, 2, status.Version)
+	assert.Equal(t, []string{"log_level", "http"}, status.Changed)
+	assert.Equal(t, []string{"http"}, status.Pending)
+}
+
+func TestHandler_Health(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+	logic := mocks.NewMockIUseCase(c)
+
+	// the probes are open even with the authentication configured
+	a, err := auth.New(auth.Config{Keys: []auth.Key{
+		{Name: "admin", Hash: auth.HashKey("admin-key"), Scopes: []string{auth.ScopeAdmin}},
+	}}, logic)
+	require.NoError(t, err)
+	h := New(logic)
+	h.SetAuthenticator(a)
+
+	router := chi.NewRouter()
+	router.Group(h.PublicRoutes)
+	router.Group(h.PrivateRoutes)
+	get := func(target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		return w
+	}
+
+	w := get("/healthz")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "{\n  \"status\": \"ok\"\n}", w.Body.String())
+
+	components := []usecase.Component{
+		{Name: "storage", State: usecase.StateOK},
+		{Name: "directory:default", State: usecase.StateFailed, Error: "files are not watched yet"},
+	}
+	logic.EXPECT().Ready(gomock.Any()).Return(false, components)
+	w = get("/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Contains(t, w.Body.String(), "\"status\": \"not_ready\"")
+	assert.Contains(t, w.Body.String(), "\"state\": \"failed\"")
+	assert.NotContains(t, w.Body.String(), "files are not watched yet", "the errors are in the status only")
+
+	logic.EXPECT().Ready(gomock.Any()).Return(true, components[:1])
+	assert.Equal(t, http.StatusOK, get("/readyz").Code)
+
+	// the status requires the events:read scope
+	assert.Equal(t, http.StatusUnauthorized, get("/api/v1/status").Code)
+
+	logic.EXPECT().Status(gomock.Any()).Return(usecase.Status{
+		Ready:     true,
+		Pipelines: []usecase.PipelineStatus{{Name: "default", Watching: true, Queued: 2}},
+	})
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/status", nil)
+	r.Header.Set("Authorization", "Bearer admin-key")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var status schema.StatusResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+	assert.True(t, status.Ready)
+	assert.Equal(t, 2, status.Pipelines[0].Queued)
+	assert.Nil(t, status.Config)
+
+	// the readers see the version of the config and the fields waiting for a restart
+	reloader := reload.New("", func() ([]string, []string, error) {
+		return []string{"log_level", "http"}, []string{"http"}, nil
+	}, logger.New(httplog.NewLogger("watcher", httplog.Options{Concise: true})))
+	require.NoError(t, reloader.Reload())
+	h.SetReloader(reloader)
+	router = chi.NewRouter()
+	router.Group(h.PublicRoutes)
+
+	logic.EXPECT().Status(gomock.Any()).Return(usecase.Status{Ready: true})
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	status = schema.StatusResponse{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+	require.NotNil(t, status.Config)
+	assert.Equal(t, 2, status.Config.Version)
+	assert.Equal(t, []string{"http"}, status.Config.Pending)
+}
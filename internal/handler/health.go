@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"go-tsv-watcher/internal/schema"
+	"net/http"
+)
+
+// Healthz godoc
+// @Summary Liveness probe
+// @Description Answers 200 while the process is alive
+// @Tags health
+// @Produce  json
+// @Success 200 {object} schema.HealthResponse
+// @Router /healthz [get]
+func (h Handler) Healthz() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, r, http.StatusOK, schema.HealthResponse{Status: "ok"})
+	}
+}
+
+// Readyz godoc
+// @Summary Readiness probe
+// @Description Checks the storages, the watched directories, the migrations and the fonts, answers 503 if some are failed.
+// @Description The errors of the components are returned by the status only.
+// @Tags health
+// @Produce  json
+// @Success 200 {object} schema.HealthResponse
+// @Failure 503 {object} schema.HealthResponse
+// @Router /readyz [get]
+func (h Handler) Readyz() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ready, components := h.logic.Ready(r.Context())
+
+		// the probe is anonymous, so the errors with the paths and the driver details are not shown
+		states := make([]schema.ComponentState, 0, len(components))
+		for _, c := range components {
+			states = append(states, schema.ComponentState{Name: c.Name, State: c.State})
+		}
+
+		if !ready {
+			writeJSON(w, r, http.StatusServiceUnavailable, schema.HealthResponse{Status: "not_ready", Components: states})
+			return
+		}
+
+		writeJSON(w, r, http.StatusOK, schema.HealthResponse{Status: "ready", Components: states})
+	}
+}
+
+// GetStatus godoc
+// @Summary Get service status
+// @Description Get the state of the components and of the watchers of the pipelines: last scan, queued files and last error,
+// @Description and the version of the loaded config with the fields waiting for a restart
+// @Tags health
+// @Produce  json
+// @Success 200 {object} schema.StatusResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Router /api/v1/status [get]
+func (h Handler) GetStatus() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		resp := schema.StatusResponse{Status: h.logic.Status(r.Context())}
+		if h.reloader != nil {
+			config := h.reloader.Status()
+			resp.Config = &schema.ConfigVersion{Version: config.Version, LoadedAt: config.LoadedAt, Pending: config.Pending}
+		}
+		writeJSON(w, r, http.StatusOK, resp)
+	}
+}
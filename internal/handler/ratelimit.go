@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"errors"
+	bettererror "github.com/egorgasay/bettererrors"
+	"go-tsv-watcher/internal/auth"
+	"go-tsv-watcher/internal/ratelimit"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ErrRateLimited error occurs when the client runs out of tokens of the route group
+var ErrRateLimited = errors.New("rate limit exceeded")
+
+// ErrTooManyFailures error occurs when the client IP failed to authenticate too many times
+var ErrTooManyFailures = errors.New("too many failed authentications")
+
+// ErrQuotaExceeded error occurs when the client used up its daily quota
+var ErrQuotaExceeded = errors.New("daily quota exceeded")
+
+// SetRateLimits enables the rate limits and the quotas of the route groups.
+func (h *Handler) SetRateLimits(limits *ratelimit.Limits) {
+	h.limits = limits
+}
+
+// limit takes a token of the route group and counts the request in the daily quota,
+// the client is the authenticated principal or the client IP.
+func (h Handler) limit(group string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if h.limits == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			client := h.client(r)
+
+			if limiter, ok := h.limits.Groups[group]; ok {
+				d := limiter.Allow(client)
+				setLimitHeaders(w.Header(), "X-RateLimit-", d)
+				if !d.Allowed {
+					writeTooManyRequests(w, r, d, ErrRateLimited)
+					return
+				}
+			}
+
+			if h.limits.Quota != nil {
+				d := h.limits.Quota.Use(client)
+				setLimitHeaders(w.Header(), "X-Quota-", d)
+				if !d.Allowed {
+					writeTooManyRequests(w, r, d, ErrQuotaExceeded)
+					return
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// client returns the key of the client of the request.
+func (h Handler) client(r *http.Request) string {
+	if principal, ok := auth.FromContext(r.Context()); ok {
+		return "key:" + principal.Subject
+	}
+	return "ip:" + h.clientIP(r)
+}
+
+// clientIP returns the IP of the client of the request.
+func (h Handler) clientIP(r *http.Request) string {
+	var header string
+	if h.limits.IPHeader != "" {
+		header = r.Header.Get(h.limits.IPHeader)
+	}
+	return h.limits.ClientIP(header, r.RemoteAddr)
+}
+
+// authFailures returns the limiter of the failed authentications, nil if they are not limited.
+func (h Handler) authFailures() *ratelimit.Limiter {
+	if h.limits == nil {
+		return nil
+	}
+	return h.limits.Groups[ratelimit.GroupAuth]
+}
+
+// setLimitHeaders sets the limit, the remaining requests and the Unix time of the reset.
+func setLimitHeaders(header http.Header, prefix string, d ratelimit.Decision) {
+	header.Set(prefix+"Limit", strconv.Itoa(d.Limit))
+	header.Set(prefix+"Remaining", strconv.Itoa(d.Remaining))
+	header.Set(prefix+"Reset", strconv.FormatInt(d.Reset.Unix(), 10))
+}
+
+// writeTooManyRequests writes 429 with the seconds to wait in Retry-After.
+func writeTooManyRequests(w http.ResponseWriter, r *http.Request, d ratelimit.Decision, err error) {
+	seconds := int64((d.RetryAfter + time.Second - 1) / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+	w.Header().Set("Retry-After", strconv.FormatInt(seconds, 10))
+	writeError(w, r, http.StatusTooManyRequests, err, bettererror.Handler)
+}
@@ -2,9 +2,54 @@ package handler
 
 import (
 	"github.com/go-chi/chi/v5"
+	"go-tsv-watcher/internal/auth"
+	"go-tsv-watcher/internal/ratelimit"
 )
 
-// PublicRoutes - Routes for public endpoints
+// PublicRoutes - Routes for public endpoints, each group requires its scope and has its rate limit,
+// the probes are open to the orchestrator
 func (h Handler) PublicRoutes(r chi.Router) {
-	r.Post("/api/v1/event", h.PostEvent())
+	r.Get("/healthz", h.Healthz())
+	r.Get("/readyz", h.Readyz())
+
+	r.Group(func(r chi.Router) {
+		r.Use(h.authenticate(false), h.limit(ratelimit.GroupEvents), h.require(auth.ScopeReadEvents))
+		r.Post("/api/v1/event", h.PostEvent())
+		r.Get("/api/v1/events", h.ListEvents())
+		r.Get("/api/v1/units", h.ListUnits())
+		r.Get("/api/v1/units/{guid}", h.GetUnit())
+		r.Get("/api/v1/units/{guid}/report", h.GetReportFormat())
+		r.Get("/api/v1/units/{guid}/report.pdf", h.GetReport())
+		r.Get("/api/v1/files", h.ListFiles())
+		r.Get("/api/v1/files/{name}", h.GetFile())
+		r.Get("/api/v1/cache/stats", h.GetCacheStats())
+		r.Get("/api/v1/status", h.GetStatus())
+	})
+
+	r.Group(func(r chi.Router) {
+		r.Use(h.authenticate(true), h.limit(ratelimit.GroupStream), h.require(auth.ScopeReadEvents))
+		r.Get("/api/v1/events/stream", h.StreamEvents())
+		r.Get("/api/v1/events/ws", h.StreamEventsWS())
+	})
+
+	r.Group(func(r chi.Router) {
+		r.Use(h.authenticate(false), h.limit(ratelimit.GroupFiles), h.require(auth.ScopeManageFiles))
+		r.Post("/api/v1/files/{name}/reprocess", h.ReprocessFile())
+		r.Delete("/api/v1/files/{name}", h.DeleteFile())
+	})
+
+	r.Group(func(r chi.Router) {
+		r.Use(h.authenticate(false), h.limit(ratelimit.GroupUploads), h.require(auth.ScopeUpload))
+		r.Post("/api/v1/uploads", h.PostUpload())
+		r.Get("/api/v1/uploads/{id}", h.GetUpload())
+	})
+}
+
+// PrivateRoutes - Routes for administrative endpoints, they require the admin scope
+func (h Handler) PrivateRoutes(r chi.Router) {
+	r.Use(h.authenticate(false), h.limit(ratelimit.GroupAdmin), h.require(auth.ScopeAdmin))
+	r.Get("/api/v1/admin/keys", h.ListAPIKeys())
+	r.Post("/api/v1/admin/keys", h.CreateAPIKey())
+	r.Delete("/api/v1/admin/keys/{id}", h.DeleteAPIKey())
+	r.Get("/api/v1/admin/config", h.GetConfigStatus())
 }
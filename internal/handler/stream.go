@@ -0,0 +1,243 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	bettererror "github.com/egorgasay/bettererrors"
+	"go-tsv-watcher/internal/schema"
+	"go-tsv-watcher/internal/storage/service"
+	"go-tsv-watcher/internal/usecase"
+	"golang.org/x/net/websocket"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// heartbeatInterval is how often the streams send a heartbeat.
+var heartbeatInterval = 15 * time.Second
+
+// StreamEvents godoc
+// @Summary Stream events
+// @Description Push the events as they are stored as Server-Sent Events with heartbeat comments.
+// @Description The id of an event resumes the stream after it with the Last-Event-ID header or the last_event_id parameter.
+// @Tags event
+// @Produce  text/event-stream
+// @Param unit_guid query string false "unit guid"
+// @Param message_class query string false "message class"
+// @Param level_min query int false "min level"
+// @Param pipeline query string false "pipeline, all pipelines if empty"
+// @Param fields query string false "comma separated event fields"
+// @Param last_event_id query string false "id of the last received event"
+// @Success 200
+// @Failure 400 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/events/stream [get]
+func (h Handler) StreamEvents() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			writeError(w, r, http.StatusInternalServerError, errors.New("streaming is not supported"), bettererror.Handler)
+			return
+		}
+
+		req, err := parseStreamRequest(r)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, err, bettererror.Handler)
+			return
+		}
+
+		stream, err := h.logic.Stream(r.Context(), req.filter, req.lastEventID)
+		if err != nil {
+			writeStreamError(w, r, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		ticker := time.NewTicker(heartbeatInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case e, ok := <-stream:
+				if !ok {
+					return
+				}
+				data, err := json.Marshal(selectFields(e.Event, req.fields))
+				if err != nil {
+					return
+				}
+				fmt.Fprintf(w, "id: %s\nevent: event\ndata: %s\n\n", e.ID, data)
+			case <-ticker.C:
+				fmt.Fprint(w, ": heartbeat\n\n")
+			case <-r.Context().Done():
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// StreamEventsWS godoc
+// @Summary Stream events over WebSocket
+// @Description Push the events as they are stored as JSON messages of the event type
+// @Description and the heartbeat messages. The id of an event resumes the stream after it with last_event_id.
+// @Tags event
+// @Param unit_guid query string false "unit guid"
+// @Param message_class query string false "message class"
+// @Param level_min query int false "min level"
+// @Param pipeline query string false "pipeline, all pipelines if empty"
+// @Param fields query string false "comma separated event fields"
+// @Param last_event_id query string false "id of the last received event"
+// @Success 101 {object} schema.StreamMessage
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/events/ws [get]
+func (h Handler) StreamEventsWS() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := h.checkOrigin(r); err != nil {
+			writeError(w, r, http.StatusForbidden, err, bettererror.Handler)
+			return
+		}
+
+		req, err := parseStreamRequest(r)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, err, bettererror.Handler)
+			return
+		}
+
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+
+		stream, err := h.logic.Stream(ctx, req.filter, req.lastEventID)
+		if err != nil {
+			writeStreamError(w, r, err)
+			return
+		}
+
+		server := websocket.Server{
+			// the origin is checked before the stream starts
+			Handshake: func(*websocket.Config, *http.Request) error { return nil },
+			Handler: func(ws *websocket.Conn) {
+				defer cancel()
+
+				// the client messages are ignored, reading detects the closed connection
+				go func() {
+					io.Copy(io.Discard, ws)
+					cancel()
+				}()
+
+				ticker := time.NewTicker(heartbeatInterval)
+				defer ticker.Stop()
+
+				for {
+					var msg schema.StreamMessage
+					select {
+					case e, ok := <-stream:
+						if !ok {
+							return
+						}
+						msg = schema.StreamMessage{Type: "event", ID: e.ID, Event: selectFields(e.Event, req.fields)}
+					case t := <-ticker.C:
+						msg = schema.StreamMessage{Type: "heartbeat", Time: t.UTC().Format(time.RFC3339)}
+					case <-ctx.Done():
+						return
+					}
+
+					if err := websocket.JSON.Send(ws, msg); err != nil {
+						return
+					}
+				}
+			},
+		}
+		server.ServeHTTP(w, r)
+	}
+}
+
+// SetStreamOrigins sets the origins allowed to open the websocket streams besides the host of the server,
+// "*" allows any origin.
+func (h *Handler) SetStreamOrigins(origins []string) {
+	h.origins = origins
+}
+
+// ErrOriginNotAllowed error occurs when a browser on an origin not allowed opens a websocket stream
+var ErrOriginNotAllowed = errors.New("origin is not allowed")
+
+// checkOrigin accepts the websocket handshakes from the host of the server, the allowed origins
+// and the clients without the Origin header.
+func (h Handler) checkOrigin(r *http.Request) error {
+	header := r.Header.Get("Origin")
+	if header == "" {
+		return nil
+	}
+	origin, err := url.ParseRequestURI(header)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrOriginNotAllowed, header)
+	}
+	if strings.EqualFold(origin.Host, r.Host) {
+		return nil
+	}
+
+	allowed := strings.ToLower(origin.Scheme + "://" + origin.Host)
+	for _, o := range h.origins {
+		if o == "*" || o == allowed {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s", ErrOriginNotAllowed, header)
+}
+
+// streamRequest is the parsed stream parameters.
+type streamRequest struct {
+	filter      service.EventFilter
+	fields      []string
+	lastEventID string
+}
+
+// parseStreamRequest parses the filters, the fields and the last event id of the stream.
+func parseStreamRequest(r *http.Request) (streamRequest, error) {
+	values := r.URL.Query()
+	req := streamRequest{
+		filter: service.EventFilter{
+			UnitGUID:     values.Get("unit_guid"),
+			MessageClass: values.Get("message_class"),
+			Pipeline:     values.Get("pipeline"),
+		},
+		lastEventID: r.Header.Get("Last-Event-ID"),
+	}
+	if id := values.Get("last_event_id"); id != "" {
+		req.lastEventID = id
+	}
+
+	var err error
+	if req.filter.MinLevel, err = parseOptionalInt(values, "level_min"); err != nil {
+		return req, err
+	}
+	if req.fields, err = parseFields(values.Get("fields")); err != nil {
+		return req, err
+	}
+
+	return req, nil
+}
+
+// writeStreamError writes the error of starting a stream.
+func writeStreamError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, service.ErrInvalidQuery) {
+		writeError(w, r, http.StatusBadRequest, err, bettererror.Logic)
+		return
+	}
+	if errors.Is(err, usecase.ErrPipelineNotFound) {
+		writeError(w, r, http.StatusNotFound, err, bettererror.Logic)
+		return
+	}
+	writeError(w, r, http.StatusInternalServerError, err, bettererror.Logic)
+}
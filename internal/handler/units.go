@@ -0,0 +1,242 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	bettererror "github.com/egorgasay/bettererrors"
+	"github.com/go-chi/chi/v5"
+	"go-tsv-watcher/internal/schema"
+	"go-tsv-watcher/internal/storage/service"
+	"go-tsv-watcher/internal/usecase"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ListUnits godoc
+// @Summary List units
+// @Description List units with event counts, inventory ids and latest level
+// @Tags unit
+// @Produce  json
+// @Param pipeline query string false "pipeline, all pipelines if empty"
+// @Success 200 {object} schema.UnitsResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/units [get]
+func (h Handler) ListUnits() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		units, err := h.logic.ListUnits(r.Context(), r.URL.Query().Get("pipeline"))
+		if err != nil {
+			if errors.Is(err, usecase.ErrPipelineNotFound) {
+				writeError(w, r, http.StatusNotFound, err, bettererror.Logic)
+				return
+			}
+			writeError(w, r, http.StatusInternalServerError, err, bettererror.Storage)
+			return
+		}
+
+		writeJSON(w, r, http.StatusOK, schema.UnitsResponse{Units: units})
+	}
+}
+
+// GetUnit godoc
+// @Summary Get unit
+// @Description Get unit with its events broken down by message class and area
+// @Tags unit
+// @Produce  json
+// @Param guid path string true "unit guid"
+// @Param pipeline query string false "pipeline, all pipelines if empty"
+// @Success 200 {object} service.UnitSummary
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/units/{guid} [get]
+func (h Handler) GetUnit() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		unit, err := h.logic.GetUnit(r.Context(), r.URL.Query().Get("pipeline"), chi.URLParam(r, "guid"))
+		if err != nil {
+			if errors.Is(err, service.ErrUnitNotFound) || errors.Is(err, usecase.ErrPipelineNotFound) {
+				writeError(w, r, http.StatusNotFound, err, bettererror.Storage)
+				return
+			}
+			writeError(w, r, http.StatusInternalServerError, err, bettererror.Storage)
+			return
+		}
+
+		writeJSON(w, r, http.StatusOK, unit)
+	}
+}
+
+// GetReport godoc
+// @Summary Get unit PDF report
+// @Description Get the PDF report of the unit, supports ETag, Last-Modified and Range requests.
+// @Description With regenerate the report is rendered from the storage and saved,
+// @Description with from, to or source_file it is rendered for these events only and not saved.
+// @Tags unit
+// @Produce  application/pdf
+// @Param guid path string true "unit guid"
+// @Param regenerate query bool false "render from the storage"
+// @Param from query string false "ingested at or after, RFC3339"
+// @Param to query string false "ingested before, RFC3339"
+// @Param source_file query string false "source file"
+// @Param pipeline query string false "pipeline, the first one if empty"
+// @Success 200 {file} file
+// @Success 206 {file} file
+// @Success 304
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/units/{guid}/report.pdf [get]
+func (h Handler) GetReport() http.HandlerFunc {
+	return h.serveReport(func(*http.Request) (string, error) {
+		return usecase.FormatPDF, nil
+	})
+}
+
+// GetReportFormat godoc
+// @Summary Get unit report
+// @Description Get the report of the unit in the format of the format parameter or the Accept header, PDF by default.
+// @Description The formats which are not saved are rendered from the storage.
+// @Tags unit
+// @Produce  application/pdf,text/html,text/csv,application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Param guid path string true "unit guid"
+// @Param format query string false "pdf, html, csv or xlsx"
+// @Param regenerate query bool false "render from the storage"
+// @Param from query string false "ingested at or after, RFC3339"
+// @Param to query string false "ingested before, RFC3339"
+// @Param source_file query string false "source file"
+// @Param pipeline query string false "pipeline, the first one if empty"
+// @Success 200 {file} file
+// @Success 206 {file} file
+// @Success 304
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 406 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/units/{guid}/report [get]
+func (h Handler) GetReportFormat() http.HandlerFunc {
+	return h.serveReport(reportFormat)
+}
+
+// serveReport serves the report of the unit in the format picked from the request.
+func (h Handler) serveReport(format func(r *http.Request) (string, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		opts, err := parseReportOptions(r)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, err, bettererror.Handler)
+			return
+		}
+
+		opts.Format, err = format(r)
+		if err != nil {
+			writeError(w, r, http.StatusNotAcceptable, err, bettererror.Handler)
+			return
+		}
+
+		report, err := h.logic.Report(r.Context(), chi.URLParam(r, "guid"), opts)
+		if err != nil {
+			switch {
+			case errors.Is(err, usecase.ErrReportNotFound), errors.Is(err, usecase.ErrPipelineNotFound):
+				writeError(w, r, http.StatusNotFound, err, bettererror.Logic)
+			case errors.Is(err, usecase.ErrUnknownFormat):
+				writeError(w, r, http.StatusBadRequest, err, bettererror.Logic)
+			default:
+				writeError(w, r, http.StatusInternalServerError, err, bettererror.Logic)
+			}
+			return
+		}
+		defer report.Close()
+
+		w.Header().Set("Content-Type", report.ContentType)
+		w.Header().Set("ETag", report.ETag)
+		w.Header().Add("Vary", "Accept")
+		http.ServeContent(w, r, report.Name, report.ModTime, report)
+	}
+}
+
+// ErrNotAcceptable error occurs when no report format matches the Accept header
+var ErrNotAcceptable = errors.New("no acceptable report format")
+
+// reportFormat returns the format parameter or the best format of the Accept header.
+func reportFormat(r *http.Request) (string, error) {
+	if format := r.URL.Query().Get("format"); format != "" {
+		return format, nil
+	}
+
+	accept := r.Header.Get("Accept")
+	if strings.TrimSpace(accept) == "" {
+		return usecase.FormatPDF, nil
+	}
+
+	best, bestQ := "", 0.0
+	for _, part := range strings.Split(accept, ",") {
+		mediaRange, q := parseMediaRange(part)
+		if q <= bestQ {
+			continue
+		}
+		for _, format := range usecase.ReportFormats {
+			renderer, err := usecase.ReportRenderer(format)
+			if err != nil {
+				continue
+			}
+			if matchMediaRange(mediaRange, renderer.ContentType()) {
+				best, bestQ = format, q
+				break
+			}
+		}
+	}
+
+	if best == "" {
+		return "", fmt.Errorf("%w: %s", ErrNotAcceptable, accept)
+	}
+	return best, nil
+}
+
+// parseMediaRange returns the media range of the Accept header part and its quality.
+func parseMediaRange(part string) (string, float64) {
+	params := strings.Split(part, ";")
+	q := 1.0
+	for _, p := range params[1:] {
+		name, value, ok := strings.Cut(strings.TrimSpace(p), "=")
+		if !ok || strings.ToLower(strings.TrimSpace(name)) != "q" {
+			continue
+		}
+		v, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			v = 0
+		}
+		q = v
+	}
+	return strings.ToLower(strings.TrimSpace(params[0])), q
+}
+
+// matchMediaRange reports whether the content type is in the media range, e.g. text/* or */*.
+func matchMediaRange(mediaRange, contentType string) bool {
+	contentType, _, _ = strings.Cut(contentType, ";")
+	if mediaRange == "*/*" || mediaRange == contentType {
+		return true
+	}
+	return strings.HasSuffix(mediaRange, "/*") && strings.HasPrefix(contentType, strings.TrimSuffix(mediaRange, "*"))
+}
+
+// parseReportOptions parses the report query parameters.
+func parseReportOptions(r *http.Request) (usecase.ReportOptions, error) {
+	values := r.URL.Query()
+	opts := usecase.ReportOptions{SourceFile: values.Get("source_file"), Pipeline: values.Get("pipeline")}
+
+	var err error
+	if raw := values.Get("regenerate"); raw != "" {
+		opts.Regenerate, err = strconv.ParseBool(raw)
+		if err != nil {
+			return opts, fmt.Errorf("%w: regenerate must be a boolean", service.ErrInvalidQuery)
+		}
+	}
+
+	if opts.From, err = parseOptionalTime(values, "from"); err != nil {
+		return opts, err
+	}
+	if opts.To, err = parseOptionalTime(values, "to"); err != nil {
+		return opts, err
+	}
+
+	return opts, nil
+}
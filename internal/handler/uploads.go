@@ -0,0 +1,117 @@
+package handler
+
+import (
+	"errors"
+	bettererror "github.com/egorgasay/bettererrors"
+	"github.com/go-chi/chi/v5"
+	"go-tsv-watcher/internal/usecase"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+)
+
+// uploadField is the multipart form field of the uploaded file.
+const uploadField = "file"
+
+// ErrNoUploadFile error occurs when the multipart upload has no file field
+var ErrNoUploadFile = errors.New("multipart upload has no \"" + uploadField + "\" field")
+
+// PostUpload godoc
+// @Summary Upload file
+// @Description Upload a TSV file as a raw body or as the "file" field of a multipart form,
+// @Description it is ingested the same way as files dropped into the watched directory
+// @Tags upload
+// @Accept  text/tab-separated-values,multipart/form-data
+// @Produce  json
+// @Param pipeline query string false "pipeline, the first one if empty"
+// @Success 202 {object} usecase.Upload
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 413 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Router /api/v1/uploads [post]
+func (h Handler) PostUpload() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer r.Body.Close()
+
+		body, err := uploadBody(r)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, err, bettererror.Handler)
+			return
+		}
+
+		upload, err := h.logic.Upload(r.Context(), r.URL.Query().Get("pipeline"), body)
+		if err != nil {
+			switch {
+			case errors.Is(err, usecase.ErrUploadTooLarge):
+				writeError(w, r, http.StatusRequestEntityTooLarge, err, bettererror.Logic)
+			case errors.Is(err, usecase.ErrPipelineNotFound):
+				writeError(w, r, http.StatusNotFound, err, bettererror.Logic)
+			case errors.Is(err, usecase.ErrNotWatching):
+				writeError(w, r, http.StatusServiceUnavailable, err, bettererror.Logic)
+			default:
+				writeError(w, r, http.StatusInternalServerError, err, bettererror.Logic)
+			}
+			return
+		}
+
+		w.Header().Set("Location", "/api/v1/uploads/"+upload.ID+"?pipeline="+url.QueryEscape(upload.Pipeline))
+		writeJSON(w, r, http.StatusAccepted, upload)
+	}
+}
+
+// uploadBody returns the file part of a multipart request or the raw body.
+func uploadBody(r *http.Request) (io.Reader, error) {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/form-data" {
+		return r.Body, nil
+	}
+
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		part, err := mr.NextPart()
+		if errors.Is(err, io.EOF) {
+			return nil, ErrNoUploadFile
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if part.FormName() == uploadField {
+			return part, nil
+		}
+	}
+}
+
+// GetUpload godoc
+// @Summary Get upload
+// @Description Get ingestion status and row counts of the upload
+// @Tags upload
+// @Produce  json
+// @Param id path string true "upload id"
+// @Param pipeline query string false "pipeline, the first one if empty"
+// @Success 200 {object} usecase.Upload
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/uploads/{id} [get]
+func (h Handler) GetUpload() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		upload, err := h.logic.GetUpload(r.Context(), r.URL.Query().Get("pipeline"), chi.URLParam(r, "id"))
+		if err != nil {
+			if errors.Is(err, usecase.ErrUploadNotFound) || errors.Is(err, usecase.ErrPipelineNotFound) {
+				writeError(w, r, http.StatusNotFound, err, bettererror.Logic)
+				return
+			}
+			writeError(w, r, http.StatusInternalServerError, err, bettererror.Storage)
+			return
+		}
+
+		writeJSON(w, r, http.StatusOK, upload)
+	}
+}
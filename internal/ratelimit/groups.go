@@ -0,0 +1,49 @@
+package ratelimit
+
+import (
+	"net"
+	"strings"
+)
+
+// Route groups limited together.
+const (
+	// GroupEvents are the reads of the events, units, reports and files.
+	GroupEvents = "events"
+	// GroupStream are the connections of the event streams.
+	GroupStream = "stream"
+	// GroupFiles are the reprocessing and deletion of the files.
+	GroupFiles = "files"
+	// GroupUploads are the uploads.
+	GroupUploads = "uploads"
+	// GroupAdmin are the administrative routes.
+	GroupAdmin = "admin"
+	// GroupAuth are the failed authentications of any route by client IP, the client IP
+	// out of tokens is denied before its credentials are checked.
+	GroupAuth = "auth"
+)
+
+// RouteGroups are the route groups.
+var RouteGroups = []string{GroupEvents, GroupStream, GroupFiles, GroupUploads, GroupAdmin, GroupAuth}
+
+// ClientIP returns the IP of the client, from the entry of the IPHeader value appended by the farthest
+// trusted proxy if the value is set, from the remote address otherwise.
+func (l *Limits) ClientIP(header, remoteAddr string) string {
+	if l.IPHeader != "" && header != "" {
+		hops := l.TrustedProxies
+		if hops < 1 {
+			hops = 1
+		}
+		entries := strings.Split(header, ",")
+		i := len(entries) - hops
+		if i < 0 {
+			i = 0
+		}
+		return strings.TrimSpace(entries[i])
+	}
+
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	return host
+}
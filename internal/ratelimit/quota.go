@@ -0,0 +1,134 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"go-tsv-watcher/pkg/logger"
+	"sync"
+	"time"
+)
+
+// dayLayout formats the UTC days of the quotas.
+const dayLayout = "2006-01-02"
+
+// Store persists the used quotas of a day by client.
+type Store interface {
+	LoadQuotas(ctx context.Context, day string) (map[string]int, error)
+	SaveQuotas(ctx context.Context, day string, used map[string]int) error
+}
+
+// Quota counts the requests of the clients per UTC day.
+type Quota struct {
+	limit  int
+	store  Store
+	logger logger.ILogger
+
+	mu    sync.Mutex
+	day   string
+	used  map[string]int
+	dirty map[string]struct{}
+	now   func() time.Time
+}
+
+// NewQuota Quota constructor, the quotas are kept in memory only if the store is nil.
+func NewQuota(limit int, store Store, loggerInstance logger.ILogger) *Quota {
+	return &Quota{
+		limit:  limit,
+		store:  store,
+		logger: loggerInstance,
+		used:   make(map[string]int),
+		dirty:  make(map[string]struct{}),
+		now:    time.Now,
+	}
+}
+
+// Load restores the quotas of the day from the store.
+func (q *Quota) Load(ctx context.Context) error {
+	if q.store == nil {
+		return nil
+	}
+
+	day := q.now().UTC().Format(dayLayout)
+	used, err := q.store.LoadQuotas(ctx, day)
+	if err != nil {
+		return fmt.Errorf("failed to load quotas: %w", err)
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.day, q.used, q.dirty = day, used, make(map[string]struct{})
+	return nil
+}
+
+// Use counts a request of the client, the denied requests are not counted.
+func (q *Quota) Use(client string) Decision {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := q.now().UTC()
+	if day := now.Format(dayLayout); day != q.day {
+		q.day, q.used, q.dirty = day, make(map[string]int), make(map[string]struct{})
+	}
+	reset := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+
+	d := Decision{Limit: q.limit, Reset: reset}
+	if q.used[client] >= q.limit {
+		d.RetryAfter = reset.Sub(now)
+		return d
+	}
+
+	q.used[client]++
+	q.dirty[client] = struct{}{}
+	d.Allowed, d.Remaining = true, q.limit-q.used[client]
+	return d
+}
+
+// Flush saves the quotas changed since the previous flush.
+func (q *Quota) Flush(ctx context.Context) error {
+	if q.store == nil {
+		return nil
+	}
+
+	q.mu.Lock()
+	day, changed := q.day, make(map[string]int, len(q.dirty))
+	for client := range q.dirty {
+		changed[client] = q.used[client]
+	}
+	q.dirty = make(map[string]struct{})
+	q.mu.Unlock()
+
+	if len(changed) == 0 {
+		return nil
+	}
+
+	if err := q.store.SaveQuotas(ctx, day, changed); err != nil {
+		// saved again by the next flush unless the day is over
+		q.mu.Lock()
+		if q.day == day {
+			for client := range changed {
+				q.dirty[client] = struct{}{}
+			}
+		}
+		q.mu.Unlock()
+		return fmt.Errorf("failed to save quotas: %w", err)
+	}
+	return nil
+}
+
+// Run flushes the quotas every interval until the context is done,
+// the last changes are saved by a final Flush.
+func (q *Quota) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := q.Flush(ctx); err != nil {
+				q.logger.Warn(err.Error())
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
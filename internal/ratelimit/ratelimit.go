@@ -0,0 +1,150 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// idleBuckets is the number of buckets after which the full buckets are dropped.
+const idleBuckets = 10000
+
+// minSweep is the shortest time between two drops of the full buckets.
+const minSweep = time.Second
+
+// Rule is a token bucket refilled by Rate tokens per Interval up to Burst tokens.
+type Rule struct {
+	Rate     int
+	Interval time.Duration
+	// Burst is Rate if zero.
+	Burst int
+}
+
+// Decision is the outcome of a request for a token or a quota.
+type Decision struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is when the bucket is full again or the quota is renewed.
+	Reset time.Time
+	// RetryAfter is how long to wait for the next token, zero if allowed.
+	RetryAfter time.Duration
+}
+
+// Limits are the limiters of the route groups and the daily quota of the clients.
+type Limits struct {
+	// Groups are the limiters by route group, the groups without one are not limited.
+	Groups map[string]*Limiter
+	// Quota is nil if the requests are not counted.
+	Quota *Quota
+	// IPHeader is the header with the client IP set by a trusted proxy (e.g. X-Forwarded-For),
+	// the remote address is used if empty.
+	IPHeader string
+	// TrustedProxies is the number of the proxies appending to IPHeader, 1 if zero. The client IP is
+	// the entry appended by the farthest one, the earlier entries are set by the client.
+	TrustedProxies int
+}
+
+// Limiter keeps a token bucket per client.
+type Limiter struct {
+	rule  Rule
+	every time.Duration
+	// sweep is the time between two drops of the full buckets, at least the time to refill one
+	sweep time.Duration
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	swept   time.Time
+	now     func() time.Time
+}
+
+// bucket holds the tokens of a client at the time of the last request.
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewLimiter Limiter constructor, the rule must have a positive rate and interval.
+func NewLimiter(rule Rule) *Limiter {
+	if rule.Burst <= 0 {
+		rule.Burst = rule.Rate
+	}
+
+	every := rule.Interval / time.Duration(rule.Rate)
+	if every <= 0 {
+		every = 1
+	}
+
+	sweep := every * time.Duration(rule.Burst)
+	if sweep < minSweep {
+		sweep = minSweep
+	}
+
+	return &Limiter{
+		rule:    rule,
+		every:   every,
+		sweep:   sweep,
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+// Allow takes a token of the client.
+func (l *Limiter) Allow(client string) Decision {
+	return l.decide(client, true)
+}
+
+// Peek returns the decision of the next request of the client without taking a token.
+func (l *Limiter) Peek(client string) Decision {
+	return l.decide(client, false)
+}
+
+// decide refills the bucket of the client and takes a token if allowed and asked to.
+func (l *Limiter) decide(client string, take bool) Decision {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	// the buckets are scanned once per sweep, not on every request of a new client
+	if len(l.buckets) >= idleBuckets && now.Sub(l.swept) >= l.sweep {
+		l.dropFull(now)
+		l.swept = now
+	}
+
+	b, ok := l.buckets[client]
+	if !ok {
+		b = &bucket{tokens: float64(l.rule.Burst), last: now}
+		l.buckets[client] = b
+	}
+	b.tokens = l.refill(b, now)
+	b.last = now
+
+	d := Decision{Limit: l.rule.Burst}
+	if b.tokens >= 1 {
+		if take {
+			b.tokens--
+		}
+		d.Allowed = true
+	} else {
+		d.RetryAfter = time.Duration((1 - b.tokens) * float64(l.every))
+	}
+
+	d.Remaining = int(math.Floor(b.tokens))
+	d.Reset = now.Add(time.Duration((float64(l.rule.Burst) - b.tokens) * float64(l.every)))
+	return d
+}
+
+// refill returns the tokens of the bucket at the time.
+func (l *Limiter) refill(b *bucket, now time.Time) float64 {
+	tokens := b.tokens + float64(now.Sub(b.last))/float64(l.every)
+	return math.Min(tokens, float64(l.rule.Burst))
+}
+
+// dropFull drops the buckets refilled to the burst, they are the same as the new ones.
+func (l *Limiter) dropFull(now time.Time) {
+	for client, b := range l.buckets {
+		if l.refill(b, now) >= float64(l.rule.Burst) {
+			delete(l.buckets, client)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"github.com/go-chi/httplog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go-tsv-watcher/pkg/logger"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// clock is the controlled time of the tests.
+type clock struct {
+	t time.Time
+}
+
+func (c *clock) now() time.Time {
+	return c.t
+}
+
+func TestLimiter_Allow(t *testing.T) {
+	c := &clock{t: time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)}
+	l := NewLimiter(Rule{Rate: 2, Interval: time.Second, Burst: 3})
+	l.now = c.now
+
+	for i := 2; i >= 0; i-- {
+		d := l.Allow("a")
+		require.True(t, d.Allowed)
+		assert.Equal(t, i, d.Remaining)
+		assert.Equal(t, 3, d.Limit)
+	}
+
+	d := l.Allow("a")
+	assert.False(t, d.Allowed)
+	assert.Equal(t, 500*time.Millisecond, d.RetryAfter)
+	assert.Equal(t, c.t.Add(1500*time.Millisecond), d.Reset)
+
+	// the other clients have their own buckets
+	assert.True(t, l.Allow("b").Allowed)
+
+	c.t = c.t.Add(500 * time.Millisecond)
+	assert.True(t, l.Allow("a").Allowed)
+	assert.False(t, l.Allow("a").Allowed)
+
+	// refilled up to the burst only
+	c.t = c.t.Add(time.Hour)
+	for i := 0; i < 3; i++ {
+		assert.True(t, l.Allow("a").Allowed)
+	}
+	assert.False(t, l.Allow("a").Allowed)
+}
+
+func TestLimiter_Peek(t *testing.T) {
+	c := &clock{t: time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)}
+	l := NewLimiter(Rule{Rate: 1, Interval: time.Second, Burst: 2})
+	l.now = c.now
+
+	// peeking takes no token
+	for i := 0; i < 3; i++ {
+		d := l.Peek("a")
+		require.True(t, d.Allowed)
+		assert.Equal(t, 2, d.Remaining)
+	}
+
+	l.Allow("a")
+	l.Allow("a")
+	d := l.Peek("a")
+	assert.False(t, d.Allowed)
+	assert.Equal(t, time.Second, d.RetryAfter)
+}
+
+func TestLimiter_dropFull(t *testing.T) {
+	c := &clock{t: time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)}
+	l := NewLimiter(Rule{Rate: 1, Interval: time.Second})
+	l.now = c.now
+
+	l.Allow("a")
+	c.t = c.t.Add(time.Second)
+	l.Allow("b")
+
+	l.dropFull(c.t)
+	assert.NotContains(t, l.buckets, "a")
+	assert.Contains(t, l.buckets, "b")
+}
+
+func TestLimiter_sweep(t *testing.T) {
+	c := &clock{t: time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)}
+	// refilled in 100ms, swept once per second
+	l := NewLimiter(Rule{Rate: 10, Interval: time.Second})
+	l.now = c.now
+
+	fill := func() {
+		for i := 0; i < idleBuckets; i++ {
+			l.Allow(strconv.Itoa(i))
+		}
+	}
+
+	fill()
+	c.t = c.t.Add(200 * time.Millisecond)
+	l.Allow("x")
+	assert.Len(t, l.buckets, 1, "the full buckets are dropped")
+
+	// the next sweep waits for a second even though the buckets are full again
+	fill()
+	c.t = c.t.Add(200 * time.Millisecond)
+	l.Allow("y")
+	assert.Len(t, l.buckets, idleBuckets+2)
+
+	c.t = c.t.Add(time.Second)
+	l.Allow("z")
+	assert.Len(t, l.buckets, 1)
+}
+
+// storeStub keeps the quotas in memory.
+type storeStub struct {
+	days map[string]map[string]int
+	err  error
+}
+
+func (s *storeStub) LoadQuotas(_ context.Context, day string) (map[string]int, error) {
+	used := make(map[string]int)
+	for client, n := range s.days[day] {
+		used[client] = n
+	}
+	return used, s.err
+}
+
+func (s *storeStub) SaveQuotas(_ context.Context, day string, used map[string]int) error {
+	if s.err != nil {
+		return s.err
+	}
+	if s.days[day] == nil {
+		s.days[day] = make(map[string]int)
+	}
+	for client, n := range used {
+		s.days[day][client] = n
+	}
+	return nil
+}
+
+func TestQuota(t *testing.T) {
+	c := &clock{t: time.Date(2023, 5, 1, 22, 0, 0, 0, time.UTC)}
+	store := &storeStub{days: map[string]map[string]int{"2023-05-01": {"a": 1}}}
+
+	q := NewQuota(2, store, logger.New(httplog.NewLogger("watcher", httplog.Options{Concise: true})))
+	q.now = c.now
+	require.NoError(t, q.Load(context.Background()))
+
+	// a used its quota before the restart
+	d := q.Use("a")
+	require.True(t, d.Allowed)
+	assert.Equal(t, 0, d.Remaining)
+
+	d = q.Use("a")
+	assert.False(t, d.Allowed)
+	assert.Equal(t, 2*time.Hour, d.RetryAfter)
+	assert.Equal(t, time.Date(2023, 5, 2, 0, 0, 0, 0, time.UTC), d.Reset)
+
+	assert.True(t, q.Use("b").Allowed)
+
+	require.NoError(t, q.Flush(context.Background()))
+	assert.Equal(t, map[string]int{"a": 2, "b": 1}, store.days["2023-05-01"])
+
+	// the failed flush is retried by the next one
+	store.err = errors.New("storage is down")
+	q.Use("b")
+	assert.Error(t, q.Flush(context.Background()))
+	store.err = nil
+	require.NoError(t, q.Flush(context.Background()))
+	assert.Equal(t, 2, store.days["2023-05-01"]["b"])
+
+	// renewed on the next day
+	c.t = c.t.Add(3 * time.Hour)
+	assert.True(t, q.Use("a").Allowed)
+	require.NoError(t, q.Flush(context.Background()))
+	assert.Equal(t, map[string]int{"a": 1}, store.days["2023-05-02"])
+}
+
+func TestQuota_memory(t *testing.T) {
+	q := NewQuota(1, nil, nil)
+	require.NoError(t, q.Load(context.Background()))
+
+	assert.True(t, q.Use("a").Allowed)
+	assert.False(t, q.Use("a").Allowed)
+	assert.NoError(t, q.Flush(context.Background()))
+}
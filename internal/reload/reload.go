@@ -0,0 +1,136 @@
+package reload
+
+import (
+	"context"
+	"fmt"
+	"go-tsv-watcher/pkg/logger"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultInterval is how often the config file is checked for changes.
+const DefaultInterval = 2 * time.Second
+
+// Apply loads the config again and applies the fields safe to change live,
+// it returns the fields changed since the previous load and the ones waiting for a restart.
+type Apply func() (changed, pending []string, err error)
+
+// Status is the state of the loaded config.
+type Status struct {
+	// Version is incremented by every load changing the config, the config loaded on start is 1.
+	Version  int       `json:"version"`
+	LoadedAt time.Time `json:"loaded_at"`
+	// File is the watched config file, empty if the config is loaded from the env and cmd args only.
+	File string `json:"file,omitempty"`
+	// Changed are the fields changed by the last load.
+	Changed []string `json:"changed,omitempty"`
+	// Pending are the changed fields applied on the next restart.
+	Pending []string `json:"pending,omitempty"`
+	// Error is the problem of the last reload, the previous config is kept.
+	Error string `json:"error,omitempty"`
+}
+
+// Reloader reloads the config when its file changes or on demand.
+type Reloader struct {
+	file   string
+	apply  Apply
+	logger logger.ILogger
+	// version of the file when it was loaded last
+	version string
+
+	mu     sync.Mutex
+	status Status
+	now    func() time.Time
+}
+
+// New Reloader constructor, the config is already loaded from the file.
+func New(file string, apply Apply, loggerInstance logger.ILogger) *Reloader {
+	version, _ := fileVersion(file)
+	return &Reloader{
+		file:    file,
+		apply:   apply,
+		logger:  loggerInstance,
+		version: version,
+		status:  Status{Version: 1, LoadedAt: time.Now(), File: file},
+		now:     time.Now,
+	}
+}
+
+// Status returns the state of the loaded config.
+func (r *Reloader) Status() Status {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	status := r.status
+	status.Changed = append([]string(nil), status.Changed...)
+	status.Pending = append([]string(nil), status.Pending...)
+	return status
+}
+
+// Reload loads and applies the config, the previous config is kept on failure.
+func (r *Reloader) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	changed, pending, err := r.apply()
+	if err != nil {
+		r.status.Error = err.Error()
+		r.logger.Warn(fmt.Sprintf("Failed to reload config, the previous one is kept: %v", err))
+		return err
+	}
+
+	r.status.Error, r.status.Pending = "", pending
+	if len(changed) == 0 {
+		return nil
+	}
+
+	r.status.Version++
+	r.status.LoadedAt, r.status.Changed = r.now(), changed
+	r.logger.Info(fmt.Sprintf("Config reloaded, version %d, changed: %s", r.status.Version, strings.Join(changed, ", ")))
+	if len(pending) > 0 {
+		r.logger.Warn(fmt.Sprintf("Config changes waiting for a restart: %s", strings.Join(pending, ", ")))
+	}
+	return nil
+}
+
+// Run reloads the config when the file changes, checked every interval, and on every signal of hup.
+func (r *Reloader) Run(ctx context.Context, interval time.Duration, hup <-chan os.Signal) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			r.logger.Info("Reloading config on SIGHUP")
+			r.version, _ = fileVersion(r.file)
+			r.Reload()
+		case <-ticker.C:
+			if r.file == "" {
+				continue
+			}
+			// the file may be missing for a moment while it is replaced
+			version, err := fileVersion(r.file)
+			if err != nil || version == r.version {
+				continue
+			}
+			r.version = version
+			r.Reload()
+		}
+	}
+}
+
+// fileVersion returns the modification time and the size of the file.
+func fileVersion(path string) (string, error) {
+	if path == "" {
+		return "", nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d/%d", info.ModTime().UnixNano(), info.Size()), nil
+}
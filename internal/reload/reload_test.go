@@ -0,0 +1,109 @@
+package reload
+
+import (
+	"context"
+	"errors"
+	"github.com/go-chi/httplog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go-tsv-watcher/pkg/logger"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// applyStub returns the results in order.
+type applyStub struct {
+	results chan result
+	calls   chan struct{}
+}
+
+type result struct {
+	changed, pending []string
+	err              error
+}
+
+func (a *applyStub) apply() ([]string, []string, error) {
+	res := <-a.results
+	a.calls <- struct{}{}
+	return res.changed, res.pending, res.err
+}
+
+func newStub(results ...result) *applyStub {
+	a := &applyStub{results: make(chan result, len(results)), calls: make(chan struct{}, len(results))}
+	for _, res := range results {
+		a.results <- res
+	}
+	return a
+}
+
+func newLogger() logger.ILogger {
+	return logger.New(httplog.NewLogger("watcher", httplog.Options{Concise: true}))
+}
+
+func TestReloader_Reload(t *testing.T) {
+	stub := newStub(
+		result{changed: []string{"refresh_interval", "http"}, pending: []string{"http"}},
+		result{err: errors.New("invalid config")},
+		result{},
+		result{changed: []string{"http"}},
+	)
+	r := New("config.json", stub.apply, newLogger())
+	loadedAt := time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)
+	r.now = func() time.Time { return loadedAt }
+
+	require.NoError(t, r.Reload())
+	assert.Equal(t, Status{
+		Version: 2, LoadedAt: loadedAt, File: "config.json",
+		Changed: []string{"refresh_interval", "http"}, Pending: []string{"http"},
+	}, r.Status())
+
+	// the failed reload keeps the previous config
+	assert.Error(t, r.Reload())
+	status := r.Status()
+	assert.Equal(t, 2, status.Version)
+	assert.Equal(t, "invalid config", status.Error)
+
+	// nothing changed
+	require.NoError(t, r.Reload())
+	status = r.Status()
+	assert.Equal(t, 2, status.Version)
+	assert.Empty(t, status.Error)
+	assert.Empty(t, status.Pending)
+
+	// the pending change is reverted
+	require.NoError(t, r.Reload())
+	status = r.Status()
+	assert.Equal(t, 3, status.Version)
+	assert.Empty(t, status.Pending)
+}
+
+func TestReloader_Run(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.json")
+	require.NoError(t, os.WriteFile(file, []byte(`{}`), 0644))
+
+	stub := newStub(result{changed: []string{"log_level"}}, result{changed: []string{"reports"}})
+	r := New(file, stub.apply, newLogger())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	hup := make(chan os.Signal, 1)
+	go r.Run(ctx, 10*time.Millisecond, hup)
+
+	wait := func() {
+		select {
+		case <-stub.calls:
+		case <-time.After(5 * time.Second):
+			t.Fatal("config is not reloaded")
+		}
+	}
+
+	require.NoError(t, os.WriteFile(file, []byte(`{"log_level": "debug"}`), 0644))
+	wait()
+
+	hup <- syscall.SIGHUP
+	wait()
+	assert.Equal(t, 3, r.Status().Version)
+}
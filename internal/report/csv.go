@@ -0,0 +1,53 @@
+package report
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// CSV writes the events table of the report, the sections follow each other.
+func CSV(w io.Writer, d Data) error {
+	cw := csv.NewWriter(w)
+
+	header := make([]string, len(d.Columns))
+	for i, c := range d.Columns {
+		header[i] = c.Field
+	}
+	if err := cw.Write(header); err != nil {
+		return fmt.Errorf("failed to write CSV: %w", err)
+	}
+
+	record := make([]string, len(d.Columns))
+	for _, s := range d.Sections {
+		for _, row := range s.Rows {
+			record = record[:0]
+			for _, cell := range row {
+				record = append(record, escapeCell(cell))
+			}
+			if err := cw.Write(record); err != nil {
+				return fmt.Errorf("failed to write CSV: %w", err)
+			}
+		}
+	}
+
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return fmt.Errorf("failed to write CSV: %w", err)
+	}
+	return nil
+}
+
+// escapeCell prefixes the text starting like a formula with a quote, so the spreadsheets opening
+// the report show the text of the event instead of running it. The numbers are kept as is.
+func escapeCell(cell string) string {
+	if cell == "" || !strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+		return cell
+	}
+	if _, err := strconv.ParseFloat(cell, 64); err == nil {
+		return cell
+	}
+	return "'" + cell
+}
@@ -0,0 +1,265 @@
+package report
+
+import (
+	"errors"
+	"fmt"
+	"github.com/signintech/gopdf/fontmaker/core"
+	"go-tsv-watcher/resources"
+	"os"
+	"sort"
+	"sync"
+	"unicode"
+)
+
+// Embedded font families.
+const (
+	// FamilySerif is the default main family.
+	FamilySerif = "liberation-serif"
+	// FamilySans is the default fallback of the glyphs missing from the main family.
+	FamilySans = "dejavu-sans"
+)
+
+// embeddedFamilies are the file names of the embedded families.
+var embeddedFamilies = map[string]string{
+	FamilySerif: "LiberationSerif-Regular.ttf",
+	FamilySans:  "DejaVuSans.ttf",
+}
+
+// DefaultFontSize is the size of the events table text, the rest of the report is scaled with it.
+const DefaultFontSize = 8.0
+
+// AnyScript selects the fallbacks of the glyphs of any script.
+const AnyScript = "*"
+
+// ErrFontSize error occurs when the font size leaves less than two rows of the events table per page
+var ErrFontSize = errors.New("font size is too large")
+
+// ErrUnknownFamily error occurs when the font family is neither embedded nor configured
+var ErrUnknownFamily = errors.New("unknown font family")
+
+// ErrUnknownScript error occurs when the fallback script is not a Unicode script name
+var ErrUnknownScript = errors.New("unknown script")
+
+// FontConfig selects the fonts of the PDF reports.
+type FontConfig struct {
+	// Families are the paths of the TrueType fonts by the family name,
+	// they are added to the embedded families or replace them.
+	Families map[string]string
+	// Family is the main family, FamilySerif if empty.
+	Family string
+	// Size is the size of the events table text, DefaultFontSize if zero.
+	Size float64
+	// Fallbacks are the families tried in order for the glyphs missing from the main family
+	// by the Unicode script name (e.g. Han, Arabic) or AnyScript, FamilySans for any script if nil.
+	Fallbacks map[string][]string
+}
+
+// font is a loaded TrueType font.
+type font struct {
+	family string
+	data   []byte
+	chars  map[int]uint
+	groups []core.CmapFormat12GroupingTable
+}
+
+// has reports whether the font has the glyph of the rune.
+func (f *font) has(r rune) bool {
+	if f.chars[int(r)] != 0 {
+		return true
+	}
+	for _, g := range f.groups {
+		if uint(r) >= g.StartCharCode && uint(r) <= g.EndCharCode {
+			return true
+		}
+	}
+	return false
+}
+
+// fallback is the fallback fonts of a script.
+type fallback struct {
+	script *unicode.RangeTable
+	fonts  []*font
+}
+
+// Fonts are the loaded fonts of the PDF reports, they are shared by the renders.
+type Fonts struct {
+	size  float64
+	main  *font
+	fonts []*font
+
+	// fallbacks of the scripts in the order of the names, then of any script
+	fallbacks []fallback
+	any       []*font
+}
+
+// LoadFonts loads and checks the fonts of the config.
+func LoadFonts(cfg FontConfig) (*Fonts, error) {
+	if cfg.Size < 0 {
+		return nil, fmt.Errorf("font size must not be negative: %v", cfg.Size)
+	}
+
+	fs := &Fonts{size: cfg.Size}
+	if fs.size == 0 {
+		fs.size = DefaultFontSize
+	}
+	// a table page has the column titles and at least one row
+	if rowsPerPage(fs.size/DefaultFontSize) < 2 {
+		return nil, fmt.Errorf("%w: %v leaves less than 2 table rows per page", ErrFontSize, cfg.Size)
+	}
+
+	loaded := make(map[string]*font)
+	load := func(family string) (*font, error) {
+		if f, ok := loaded[family]; ok {
+			return f, nil
+		}
+
+		f, err := loadFont(family, cfg.Families[family])
+		if err != nil {
+			return nil, err
+		}
+		loaded[family] = f
+		fs.fonts = append(fs.fonts, f)
+		return f, nil
+	}
+
+	family := cfg.Family
+	if family == "" {
+		family = FamilySerif
+	}
+	main, err := load(family)
+	if err != nil {
+		return nil, err
+	}
+	fs.main = main
+
+	fallbacks := cfg.Fallbacks
+	if fallbacks == nil {
+		fallbacks = map[string][]string{AnyScript: {FamilySans}}
+	}
+
+	scripts := make([]string, 0, len(fallbacks))
+	for script := range fallbacks {
+		scripts = append(scripts, script)
+	}
+	sort.Strings(scripts)
+
+	for _, script := range scripts {
+		table, ok := unicode.Scripts[script]
+		if !ok && script != AnyScript {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownScript, script)
+		}
+
+		var fonts []*font
+		for _, family := range fallbacks[script] {
+			f, err := load(family)
+			if err != nil {
+				return nil, err
+			}
+			fonts = append(fonts, f)
+		}
+
+		if script == AnyScript {
+			fs.any = fonts
+			continue
+		}
+		fs.fallbacks = append(fs.fallbacks, fallback{script: table, fonts: fonts})
+	}
+
+	return fs, nil
+}
+
+// loadFont reads the family from the path or the embedded fonts and parses it.
+func loadFont(family, path string) (*font, error) {
+	var data []byte
+	var err error
+	if path != "" {
+		data, err = os.ReadFile(path)
+	} else if name, ok := embeddedFamilies[family]; ok {
+		data, err = resources.Fonts.ReadFile(name)
+	} else {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownFamily, family)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read font %q: %w", family, err)
+	}
+
+	var parser core.TTFParser
+	if err = parser.ParseFontData(data); err != nil {
+		return nil, fmt.Errorf("failed to parse font %q: %w", family, err)
+	}
+
+	return &font{
+		family: family,
+		data:   data,
+		chars:  parser.Chars(),
+		groups: parser.GroupingTables(),
+	}, nil
+}
+
+var (
+	defaultFontsOnce sync.Once
+	defaultFonts     *Fonts
+	defaultFontsErr  error
+)
+
+// DefaultFonts returns the embedded fonts.
+func DefaultFonts() (*Fonts, error) {
+	defaultFontsOnce.Do(func() {
+		defaultFonts, defaultFontsErr = LoadFonts(FontConfig{})
+	})
+	return defaultFonts, defaultFontsErr
+}
+
+// fontOf returns the font of the glyph of the rune, the main one if no font has it.
+func (fs *Fonts) fontOf(r rune) *font {
+	if fs.main.has(r) {
+		return fs.main
+	}
+
+	for _, fb := range fs.fallbacks {
+		if !unicode.Is(fb.script, r) {
+			continue
+		}
+		for _, f := range fb.fonts {
+			if f.has(r) {
+				return f
+			}
+		}
+	}
+	for _, f := range fs.any {
+		if f.has(r) {
+			return f
+		}
+	}
+
+	return fs.main
+}
+
+// run is a part of a text drawn with one font.
+type run struct {
+	font *font
+	text string
+}
+
+// runs splits the text into the parts drawn with the same font,
+// spaces and punctuation are kept in the current run if its font has them.
+func (fs *Fonts) runs(text string) []run {
+	var runs []run
+	start := 0
+	var current *font
+	for i, r := range text {
+		f := current
+		if f == nil || !f.has(r) || !unicode.Is(unicode.Common, r) {
+			f = fs.fontOf(r)
+		}
+		if f != current && current != nil {
+			runs = append(runs, run{font: current, text: text[start:i]})
+			start = i
+		}
+		current = f
+	}
+	if current != nil {
+		runs = append(runs, run{font: current, text: text[start:]})
+	}
+	return runs
+}
@@ -0,0 +1,131 @@
+package report
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLoadFonts(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     FontConfig
+		wantErr error
+		wantAny bool
+	}{
+		{
+			name: "embedded",
+			cfg:  FontConfig{},
+		},
+		{
+			name: "configured family",
+			cfg: FontConfig{
+				Families:  map[string]string{"serif": "../../resources/LiberationSerif-Regular.ttf"},
+				Family:    "serif",
+				Size:      10,
+				Fallbacks: map[string][]string{"Cyrillic": {FamilySans}},
+			},
+		},
+		{
+			name:    "too large size",
+			cfg:     FontConfig{Size: 100},
+			wantErr: ErrFontSize,
+		},
+		{
+			name:    "unknown family",
+			cfg:     FontConfig{Family: "comic"},
+			wantErr: ErrUnknownFamily,
+		},
+		{
+			name:    "unknown fallback family",
+			cfg:     FontConfig{Fallbacks: map[string][]string{AnyScript: {"comic"}}},
+			wantErr: ErrUnknownFamily,
+		},
+		{
+			name:    "unknown script",
+			cfg:     FontConfig{Fallbacks: map[string][]string{"Klingon": {FamilySans}}},
+			wantErr: ErrUnknownScript,
+		},
+		{
+			name:    "missing file",
+			cfg:     FontConfig{Families: map[string]string{"serif": "nope.ttf"}, Family: "serif"},
+			wantErr: os.ErrNotExist,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadFonts(tt.cfg)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("LoadFonts() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if _, err := LoadFonts(FontConfig{Families: map[string]string{"bad": "font_test.go"}, Family: "bad"}); err == nil {
+		t.Errorf("LoadFonts() with a bad font got no error")
+	}
+	if _, err := LoadFonts(FontConfig{Size: -1}); err == nil {
+		t.Errorf("LoadFonts() with a negative size got no error")
+	}
+}
+
+func TestFonts_runs(t *testing.T) {
+	fonts, err := DefaultFonts()
+	if err != nil {
+		t.Fatalf("DefaultFonts() error = %v", err)
+	}
+
+	type want struct {
+		family string
+		text   string
+	}
+	tests := []struct {
+		text string
+		want []want
+	}{
+		{text: "", want: nil},
+		{text: "Разморозка 1", want: []want{{FamilySerif, "Разморозка 1"}}},
+		{text: "ok ✓ done", want: []want{{FamilySerif, "ok "}, {FamilySans, "✓ "}, {FamilySerif, "done"}}},
+		{text: "✓", want: []want{{FamilySans, "✓"}}},
+	}
+	for _, tt := range tests {
+		var got []want
+		for _, r := range fonts.runs(tt.text) {
+			got = append(got, want{r.font.family, r.text})
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("runs(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+
+	noFallback, err := LoadFonts(FontConfig{Fallbacks: map[string][]string{}})
+	if err != nil {
+		t.Fatalf("LoadFonts() error = %v", err)
+	}
+	if runs := noFallback.runs("✓"); len(runs) != 1 || runs[0].font.family != FamilySerif {
+		t.Errorf("runs() without fallbacks = %v", runs)
+	}
+}
+
+func TestPDF_fonts(t *testing.T) {
+	fonts, err := LoadFonts(FontConfig{Size: 12})
+	if err != nil {
+		t.Fatalf("LoadFonts() error = %v", err)
+	}
+
+	evs := testEvents(60)
+	evs[0].MessageText = "✓ → ✗ ok"
+	tmpl := Template{Fonts: fonts}
+	pdf, err := PDF(Build(evs, tmpl, time.Now()), tmpl)
+	if err != nil {
+		t.Fatalf("PDF() error = %v", err)
+	}
+	defer pdf.Close()
+
+	// the bigger rows take more pages than the 3 of the default size
+	if got := pdf.GetNumberOfPages(); got <= 3 {
+		t.Errorf("PDF() got %d pages", got)
+	}
+}
@@ -0,0 +1,82 @@
+package report
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+)
+
+// htmlTemplate is a self-contained page of the report.
+var htmlTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"time": formatTime,
+	"join": strings.Join,
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}} - {{.Unit.GUID}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin-bottom: 1.5em; }
+th, td { border: 1px solid #ccc; padding: 0.25em 0.5em; text-align: left; vertical-align: top; }
+th { background: #eee; }
+td.number { text-align: right; }
+dt { font-weight: bold; }
+nav a { margin-right: 1em; }
+footer { color: #777; font-size: 0.9em; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<dl>
+<dt>Unit</dt><dd>{{.Unit.GUID}}</dd>
+<dt>Inventory ids</dt><dd>{{join .Unit.InventoryIDs ", "}}</dd>
+<dt>Events</dt><dd>{{.Unit.Events}}</dd>
+<dt>First event</dt><dd>{{time .Unit.FirstSeen}}</dd>
+<dt>Last event</dt><dd>{{time .Unit.LastSeen}}</dd>
+</dl>
+
+<h2>Summary</h2>
+<table>
+<tr><th>Message class</th><th>Events</th></tr>
+{{- range .ByClass}}
+<tr><td>{{.Key}}</td><td class="number">{{.Events}}</td></tr>
+{{- end}}
+</table>
+<table>
+<tr><th>Level</th><th>Events</th></tr>
+{{- range .ByLevel}}
+<tr><td>{{.Key}}</td><td class="number">{{.Events}}</td></tr>
+{{- end}}
+</table>
+
+<h2>Events</h2>
+<nav>
+{{- range $i, $s := .Sections}}
+<a href="#section-{{$i}}">{{$s.Class}}</a>
+{{- end}}
+</nav>
+{{- $columns := .Columns}}
+{{- range $i, $s := .Sections}}
+<h3 id="section-{{$i}}">{{$s.Class}}</h3>
+<table>
+<tr>{{range $columns}}<th>{{.Field}}</th>{{end}}</tr>
+{{- range $s.Rows}}
+<tr>{{range $j, $c := .}}<td{{if (index $columns $j).Numeric}} class="number"{{end}}>{{$c}}</td>{{end}}</tr>
+{{- end}}
+</table>
+{{- end}}
+
+<footer>Generated at {{time .GeneratedAt}}</footer>
+</body>
+</html>
+`))
+
+// HTML writes the report as an HTML page.
+func HTML(w io.Writer, d Data) error {
+	if err := htmlTemplate.Execute(w, d); err != nil {
+		return fmt.Errorf("failed to write HTML: %w", err)
+	}
+	return nil
+}
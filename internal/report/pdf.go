@@ -0,0 +1,428 @@
+package report
+
+import (
+	"fmt"
+	"github.com/signintech/gopdf"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PDF page layout in points, the heights are scaled with the font size.
+const (
+	margin       = 36.0
+	headerHeight = 26.0
+	footerHeight = 22.0
+	rowHeight    = 14.0
+	cellPadding  = 3.0
+)
+
+// item kinds of the paginated summary and table.
+const (
+	itemRow = iota
+	itemHeading
+	itemHeader
+	itemSection
+	itemBlank
+)
+
+// item is a line of the paginated summary or events table.
+type item struct {
+	kind  int
+	cells []string
+}
+
+// pdfWriter draws the report, the first error is kept and stops the drawing.
+type pdfWriter struct {
+	pdf   *gopdf.GoPdf
+	data  Data
+	fonts *Fonts
+	err   error
+
+	// scale of the font sizes and the heights, size is the current font size
+	scale float64
+	size  float64
+
+	width       float64
+	height      float64
+	rowHeight   float64
+	rowsPerPage int
+
+	summary [][]item
+	table   [][]item
+
+	page  int
+	pages int
+}
+
+// PDF renders the report as A4 pages: the cover, the summary and the events table
+// grouped by message class with a bookmark per class.
+func PDF(d Data, tmpl Template) (*gopdf.GoPdf, error) {
+	fonts := tmpl.Fonts
+	if fonts == nil {
+		var err error
+		if fonts, err = DefaultFonts(); err != nil {
+			return nil, err
+		}
+	}
+
+	w := &pdfWriter{pdf: &gopdf.GoPdf{}, data: d, fonts: fonts, scale: fonts.size / DefaultFontSize}
+	w.pdf.Start(gopdf.Config{PageSize: *gopdf.PageSizeA4})
+
+	for _, f := range fonts.fonts {
+		if err := w.pdf.AddTTFFontData(f.family, f.data); err != nil {
+			w.pdf.Close()
+			return nil, fmt.Errorf("failed to add font %q: %w", f.family, err)
+		}
+	}
+
+	w.layout()
+	w.pdf.SetTextColor(0, 0, 0)
+	w.pdf.AddHeader(w.header)
+	w.pdf.AddFooter(w.footer)
+
+	w.cover()
+	w.drawSummary()
+	w.drawTable()
+
+	if w.err != nil {
+		w.pdf.Close()
+		return nil, w.err
+	}
+	return w.pdf, nil
+}
+
+// layout paginates the summary and the events table, so the number of pages is known
+// when the footers are drawn.
+func (w *pdfWriter) layout() {
+	w.width = gopdf.PageSizeA4.W - 2*margin
+	w.height = gopdf.PageSizeA4.H
+	w.rowHeight = rowHeight * w.scale
+	w.rowsPerPage = rowsPerPage(w.scale)
+
+	var summary []item
+	for i, counts := range [][]Count{w.data.ByClass, w.data.ByLevel} {
+		title, key := "Events by message class", "Message class"
+		if i == 1 {
+			title, key = "Events by level", "Level"
+			summary = append(summary, item{kind: itemBlank})
+		}
+
+		summary = append(summary,
+			item{kind: itemHeading, cells: []string{title}},
+			item{kind: itemHeader, cells: []string{key, "Events", "Share"}},
+		)
+		for _, c := range counts {
+			share := 0.0
+			if w.data.Unit.Events != 0 {
+				share = float64(c.Events) * 100 / float64(w.data.Unit.Events)
+			}
+			summary = append(summary, item{cells: []string{c.Key, strconv.Itoa(c.Events), fmt.Sprintf("%.1f%%", share)}})
+		}
+	}
+	w.summary = paginate(summary, w.rowsPerPage)
+
+	var table []item
+	for _, s := range w.data.Sections {
+		table = append(table, item{kind: itemSection, cells: []string{s.Class, strconv.Itoa(len(s.Rows))}})
+		for _, r := range s.Rows {
+			table = append(table, item{cells: r})
+		}
+	}
+	// every table page starts with the column titles
+	w.table = paginate(table, w.rowsPerPage-1)
+
+	w.pages = 1 + len(w.summary) + len(w.table)
+}
+
+// rowsPerPage returns the number of the rows between the header and the footer of a page
+// with the heights scaled.
+func rowsPerPage(scale float64) int {
+	return int((gopdf.PageSizeA4.H - 2*margin - (headerHeight+footerHeight)*scale) / (rowHeight * scale))
+}
+
+// paginate splits the items into pages, at least one item per page.
+func paginate(items []item, perPage int) [][]item {
+	if perPage < 1 {
+		perPage = 1
+	}
+	var pages [][]item
+	for len(items) > 0 {
+		n := perPage
+		if n > len(items) {
+			n = len(items)
+		}
+		pages = append(pages, items[:n])
+		items = items[n:]
+	}
+	return pages
+}
+
+// addPage starts a new page, its header and footer are drawn by gopdf.
+func (w *pdfWriter) addPage() {
+	w.page++
+	w.pdf.AddPage()
+}
+
+// header draws the title and the unit on every page but the cover.
+func (w *pdfWriter) header() {
+	if w.page <= 1 {
+		return
+	}
+
+	w.setFont(9)
+	w.text(margin, margin, w.width/2, w.data.Title, gopdf.Left)
+	w.text(margin+w.width/2, margin, w.width/2, "Unit "+w.data.Unit.GUID, gopdf.Right)
+	w.pdf.SetLineWidth(0.5)
+	w.pdf.Line(margin, margin+w.rowHeight+4, margin+w.width, margin+w.rowHeight+4)
+}
+
+// footer draws the generation time and the page number on every page.
+func (w *pdfWriter) footer() {
+	y := w.height - margin - w.rowHeight
+
+	w.setFont(8)
+	w.text(margin, y, w.width/2, "Generated at "+w.data.GeneratedAt.UTC().Format(timeLayout)+" UTC", gopdf.Left)
+	w.text(margin+w.width/2, y, w.width/2, fmt.Sprintf("Page %d of %d", w.page, w.pages), gopdf.Right)
+}
+
+// cover draws the first page with the unit metadata.
+func (w *pdfWriter) cover() {
+	w.addPage()
+
+	y := margin + 140
+	w.setFont(24)
+	w.pdf.SetXY(margin, y)
+	w.cell(w.width, 30*w.scale, w.data.Title, gopdf.Center)
+
+	w.setFont(14)
+	w.pdf.SetXY(margin, y+40*w.scale)
+	w.cell(w.width, 20*w.scale, "Unit "+w.data.Unit.GUID, gopdf.Center)
+
+	u := w.data.Unit
+	lines := [][2]string{
+		{"Inventory IDs", strings.Join(u.InventoryIDs, ", ")},
+		{"Events", strconv.Itoa(u.Events)},
+		{"First seen", formatTime(u.FirstSeen)},
+		{"Last seen", formatTime(u.LastSeen)},
+		{"Latest level", strconv.Itoa(u.LatestLevel)},
+		{"Generated at", w.data.GeneratedAt.UTC().Format(timeLayout) + " UTC"},
+	}
+	if u.Events == 0 {
+		lines = [][2]string{{"Events", "no events"}, lines[len(lines)-1]}
+	}
+
+	w.setFont(12)
+	y += 110 * w.scale
+	for _, l := range lines {
+		w.text(margin+60, y, 130*w.scale, l[0], gopdf.Left)
+		w.text(margin+60+130*w.scale, y, w.width-120-130*w.scale, l[1], gopdf.Left)
+		y += 22 * w.scale
+	}
+}
+
+// drawSummary draws the counts by message class and level.
+func (w *pdfWriter) drawSummary() {
+	widths := []float64{w.width * 0.5, w.width * 0.25, w.width * 0.25}
+	for i, page := range w.summary {
+		w.addPage()
+		if i == 0 {
+			w.pdf.AddOutline("Summary")
+		}
+
+		y := margin + headerHeight*w.scale
+		for j, it := range page {
+			switch it.kind {
+			case itemHeading:
+				w.setFont(12)
+				w.text(margin, y, w.width, it.cells[0], gopdf.Left)
+			case itemHeader:
+				w.setFont(9)
+				w.fill(y, 220)
+				w.cells(y, widths, it.cells)
+			case itemRow:
+				w.setFont(9)
+				if j%2 == 0 {
+					w.fill(y, 245)
+				}
+				w.cells(y, widths, it.cells)
+			}
+			y += w.rowHeight
+		}
+	}
+}
+
+// drawTable draws the events grouped by message class.
+func (w *pdfWriter) drawTable() {
+	var total float64
+	for _, c := range w.data.Columns {
+		total += c.Weight
+	}
+
+	widths := make([]float64, len(w.data.Columns))
+	titles := make([]string, len(w.data.Columns))
+	for i, c := range w.data.Columns {
+		widths[i] = w.width * c.Weight / total
+		titles[i] = c.Field
+	}
+
+	for _, page := range w.table {
+		w.addPage()
+
+		y := margin + headerHeight*w.scale
+		w.setFont(8)
+		w.fill(y, 220)
+		w.cells(y, widths, titles)
+		y += w.rowHeight
+
+		for j, it := range page {
+			switch it.kind {
+			case itemSection:
+				w.pdf.AddOutline(it.cells[0])
+				w.setFont(10)
+				w.fill(y, 235)
+				w.text(margin, y, w.width, fmt.Sprintf("%s (%s events)", it.cells[0], it.cells[1]), gopdf.Left)
+			case itemRow:
+				w.setFont(8)
+				if j%2 == 0 {
+					w.fill(y, 248)
+				}
+				w.cells(y, widths, it.cells)
+			}
+			y += w.rowHeight
+		}
+	}
+}
+
+// cells draws a row of cells.
+func (w *pdfWriter) cells(y float64, widths []float64, cells []string) {
+	x := margin
+	for i, c := range cells {
+		w.text(x, y, widths[i], c, gopdf.Left)
+		x += widths[i]
+	}
+}
+
+// fill draws the gray background of a row.
+func (w *pdfWriter) fill(y float64, gray uint8) {
+	w.pdf.SetFillColor(gray, gray, gray)
+	w.pdf.RectFromUpperLeftWithStyle(margin, y, w.width, w.rowHeight, "F")
+	w.pdf.SetFillColor(0, 0, 0)
+}
+
+// text draws the text in a cell of the row height, cut to the cell width.
+func (w *pdfWriter) text(x, y, width float64, text string, align int) {
+	w.pdf.SetXY(x+cellPadding, y)
+	w.cell(width-2*cellPadding, w.rowHeight, w.fit(text, width-2*cellPadding), align)
+}
+
+// cell draws the text in a cell at the current position,
+// the glyphs missing from the main font are drawn with the fallbacks.
+func (w *pdfWriter) cell(width, height float64, text string, align int) {
+	if w.err != nil {
+		return
+	}
+
+	runs := w.fonts.runs(text)
+	if len(runs) == 0 || len(runs) == 1 && runs[0].font == w.fonts.main {
+		w.err = w.pdf.CellWithOption(&gopdf.Rect{W: width, H: height}, text, gopdf.CellOption{Align: align | gopdf.Middle})
+		return
+	}
+
+	widths := make([]float64, len(runs))
+	total := 0.0
+	for i, r := range runs {
+		widths[i] = w.runWidth(r)
+		total += widths[i]
+	}
+
+	x, y := w.pdf.GetX(), w.pdf.GetY()
+	switch {
+	case align&gopdf.Right != 0:
+		x += width - total
+	case align&gopdf.Center != 0:
+		x += (width - total) / 2
+	}
+
+	for i, r := range runs {
+		if w.err != nil {
+			return
+		}
+		w.useFont(r.font)
+		w.pdf.SetXY(x, y)
+		w.err = w.pdf.CellWithOption(&gopdf.Rect{W: widths[i], H: height}, r.text, gopdf.CellOption{Align: gopdf.Left | gopdf.Middle})
+		x += widths[i]
+	}
+	w.useFont(w.fonts.main)
+}
+
+// setFont sets the size of the main font, scaled with the configured size.
+func (w *pdfWriter) setFont(size float64) {
+	w.size = size * w.scale
+	w.useFont(w.fonts.main)
+}
+
+// useFont sets the font of the current size.
+func (w *pdfWriter) useFont(f *font) {
+	if w.err != nil {
+		return
+	}
+	w.err = w.pdf.SetFont(f.family, "", w.size)
+}
+
+// fit cuts the text to the width.
+func (w *pdfWriter) fit(text string, width float64) string {
+	if w.err != nil || w.measure(text) <= width {
+		return text
+	}
+
+	runes := []rune(text)
+	lo, hi := 0, len(runes)
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+		if w.measure(string(runes[:mid])+"...") <= width {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+	return string(runes[:lo]) + "..."
+}
+
+// measure returns the width of the text in the current size.
+func (w *pdfWriter) measure(text string) float64 {
+	runs := w.fonts.runs(text)
+	if len(runs) == 0 || len(runs) == 1 && runs[0].font == w.fonts.main {
+		return w.measureText(text)
+	}
+
+	total := 0.0
+	for _, r := range runs {
+		total += w.runWidth(r)
+	}
+	w.useFont(w.fonts.main)
+	return total
+}
+
+// runWidth returns the width of the run in its font, the font stays set.
+func (w *pdfWriter) runWidth(r run) float64 {
+	w.useFont(r.font)
+	return w.measureText(r.text)
+}
+
+// measureText returns the width of the text in the current font.
+func (w *pdfWriter) measureText(text string) float64 {
+	width, err := w.pdf.MeasureTextWidth(text)
+	if err != nil && w.err == nil {
+		w.err = err
+	}
+	return width
+}
+
+// formatTime formats the time of the cover.
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.UTC().Format(timeLayout) + " UTC"
+}
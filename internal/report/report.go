@@ -0,0 +1,184 @@
+// Package report builds the per-unit reports of events.
+package report
+
+import (
+	"errors"
+	"fmt"
+	"go-tsv-watcher/internal/events"
+	"go-tsv-watcher/internal/storage/service"
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// DefaultTitle is the title of the report unless set by the template.
+const DefaultTitle = "Unit events report"
+
+// DefaultColumns are the columns of the events table unless set by the template.
+var DefaultColumns = []string{"Number", "IngestedAt", "MessageClass", "Level", "Area", "MessageText"}
+
+// columnWeights are the relative widths of the wide columns, the others have 1.
+var columnWeights = map[string]float64{
+	"ID":           3,
+	"UnitGUID":     3,
+	"MessageText":  4,
+	"IngestedAt":   2.2,
+	"SourceFile":   1.6,
+	"MessageID":    1.5,
+	"MessageClass": 1.4,
+	"InventoryID":  1.4,
+}
+
+// timeLayout is the layout of the times in the report.
+const timeLayout = "2006-01-02 15:04:05"
+
+// noClass is the section title of the events without a message class.
+const noClass = "(none)"
+
+// ErrUnknownColumn error occurs when the column is not a field of the event
+var ErrUnknownColumn = errors.New("unknown report column")
+
+// Template selects the content and the look of the report.
+type Template struct {
+	Title string
+	// Columns are the event fields shown in the events table.
+	Columns []string
+	// Fonts of the PDF, the embedded fonts if nil.
+	Fonts *Fonts
+}
+
+// Column is a column of the events table.
+type Column struct {
+	Field  string
+	Weight float64
+	// Numeric columns are written as numbers to the spreadsheets.
+	Numeric bool
+}
+
+// Count is the number of events by a key.
+type Count struct {
+	Key    string
+	Events int
+}
+
+// Section is the events of one message class.
+type Section struct {
+	Class string
+	Rows  [][]string
+}
+
+// Data is the content of the report of a unit.
+type Data struct {
+	Title       string
+	Unit        service.Unit
+	GeneratedAt time.Time
+
+	ByClass []Count
+	ByLevel []Count
+
+	Columns  []Column
+	Sections []Section
+}
+
+// ValidateColumns checks that every column is a field of the event.
+func ValidateColumns(columns []string) error {
+	t := reflect.TypeOf(events.Event{})
+	for _, c := range columns {
+		if _, ok := t.FieldByName(c); !ok {
+			return fmt.Errorf("%w: %q", ErrUnknownColumn, c)
+		}
+	}
+	return nil
+}
+
+// Build builds the report of the events of a unit.
+func Build(evs []events.Event, tmpl Template, now time.Time) Data {
+	d := Data{Title: tmpl.Title, GeneratedAt: now}
+	if d.Title == "" {
+		d.Title = DefaultTitle
+	}
+
+	columns := tmpl.Columns
+	if len(columns) == 0 {
+		columns = DefaultColumns
+	}
+	t := reflect.TypeOf(events.Event{})
+	for _, c := range columns {
+		weight, ok := columnWeights[c]
+		if !ok {
+			weight = 1
+		}
+		f, ok := t.FieldByName(c)
+		d.Columns = append(d.Columns, Column{Field: c, Weight: weight, Numeric: ok && f.Type.Kind() == reflect.Int})
+	}
+
+	d.Unit.InventoryIDs = []string{}
+	byClass := make(map[string]int)
+	byLevel := make(map[int]int)
+	sections := make(map[string]int)
+	for _, e := range evs {
+		d.Unit.Add(e)
+		byLevel[e.Level]++
+
+		class := e.MessageClass
+		if class == "" {
+			class = noClass
+		}
+		byClass[class]++
+
+		i, ok := sections[class]
+		if !ok {
+			i = len(d.Sections)
+			sections[class] = i
+			d.Sections = append(d.Sections, Section{Class: class})
+		}
+		d.Sections[i].Rows = append(d.Sections[i].Rows, row(e, d.Columns))
+	}
+
+	sort.SliceStable(d.Sections, func(a, b int) bool { return d.Sections[a].Class < d.Sections[b].Class })
+
+	for class, n := range byClass {
+		d.ByClass = append(d.ByClass, Count{Key: class, Events: n})
+	}
+	sort.Slice(d.ByClass, func(a, b int) bool { return d.ByClass[a].Key < d.ByClass[b].Key })
+
+	levels := make([]int, 0, len(byLevel))
+	for level := range byLevel {
+		levels = append(levels, level)
+	}
+	sort.Ints(levels)
+	for _, level := range levels {
+		d.ByLevel = append(d.ByLevel, Count{Key: strconv.Itoa(level), Events: byLevel[level]})
+	}
+
+	return d
+}
+
+// row returns the cells of the event.
+func row(e events.Event, columns []Column) []string {
+	v := reflect.ValueOf(e)
+	cells := make([]string, len(columns))
+	for i, c := range columns {
+		cells[i] = cell(v.FieldByName(c.Field))
+	}
+	return cells
+}
+
+// cell formats the field value.
+func cell(f reflect.Value) string {
+	switch value := f.Interface().(type) {
+	case time.Time:
+		if value.IsZero() {
+			return ""
+		}
+		return value.UTC().Format(timeLayout)
+	case bool:
+		if value {
+			return "yes"
+		}
+		return "no"
+	default:
+		return fmt.Sprint(value)
+	}
+}
@@ -0,0 +1,239 @@
+package report
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"go-tsv-watcher/internal/events"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testEvents(n int) []events.Event {
+	now := time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)
+	classes := []string{"working", "alarm", "waiting"}
+
+	evs := make([]events.Event, 0, n)
+	for i := 0; i < n; i++ {
+		evs = append(evs, events.Event{
+			ID:           fmt.Sprint(i),
+			Number:       i,
+			UnitGUID:     "unit1",
+			InventoryID:  fmt.Sprintf("inv%d", i%2),
+			MessageClass: classes[i%3],
+			Level:        (i % 2) * 100,
+			MessageText:  "Разморозка " + fmt.Sprint(i),
+			IngestedAt:   now.Add(time.Duration(i) * time.Minute),
+		})
+	}
+	return evs
+}
+
+func TestBuild(t *testing.T) {
+	now := time.Date(2023, 5, 2, 0, 0, 0, 0, time.UTC)
+	d := Build(testEvents(6), Template{Columns: []string{"Number", "Level", "IngestedAt", "Block"}}, now)
+
+	if d.Title != DefaultTitle || d.Unit.GUID != "unit1" || d.Unit.Events != 6 {
+		t.Errorf("Build() got title %q, unit %+v", d.Title, d.Unit)
+	}
+	if !reflect.DeepEqual(d.Unit.InventoryIDs, []string{"inv0", "inv1"}) {
+		t.Errorf("Build() got inventory ids %v", d.Unit.InventoryIDs)
+	}
+
+	wantClass := []Count{{"alarm", 2}, {"waiting", 2}, {"working", 2}}
+	if !reflect.DeepEqual(d.ByClass, wantClass) {
+		t.Errorf("Build() got by class %v, want %v", d.ByClass, wantClass)
+	}
+
+	wantLevel := []Count{{"0", 3}, {"100", 3}}
+	if !reflect.DeepEqual(d.ByLevel, wantLevel) {
+		t.Errorf("Build() got by level %v, want %v", d.ByLevel, wantLevel)
+	}
+
+	wantSections := []Section{
+		{Class: "alarm", Rows: [][]string{{"1", "100", "2023-05-01 10:01:00", "no"}, {"4", "0", "2023-05-01 10:04:00", "no"}}},
+		{Class: "waiting", Rows: [][]string{{"2", "0", "2023-05-01 10:02:00", "no"}, {"5", "100", "2023-05-01 10:05:00", "no"}}},
+		{Class: "working", Rows: [][]string{{"0", "0", "2023-05-01 10:00:00", "no"}, {"3", "100", "2023-05-01 10:03:00", "no"}}},
+	}
+	if !reflect.DeepEqual(d.Sections, wantSections) {
+		t.Errorf("Build() got sections %v, want %v", d.Sections, wantSections)
+	}
+}
+
+func TestValidateColumns(t *testing.T) {
+	if err := ValidateColumns(DefaultColumns); err != nil {
+		t.Errorf("ValidateColumns() error = %v", err)
+	}
+	if err := ValidateColumns([]string{"Level", "Nope"}); !errors.Is(err, ErrUnknownColumn) {
+		t.Errorf("ValidateColumns() error = %v, want %v", err, ErrUnknownColumn)
+	}
+}
+
+func TestPDF(t *testing.T) {
+	tests := []struct {
+		name      string
+		events    int
+		wantPages int
+	}{
+		{name: "no events", events: 0, wantPages: 2},
+		{name: "one table page", events: 10, wantPages: 3},
+		// 3 sections and 300 rows on pages of 49 lines after the column titles
+		{name: "many events", events: 300, wantPages: 2 + 7},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl := Template{}
+			pdf, err := PDF(Build(testEvents(tt.events), tmpl, time.Now()), tmpl)
+			if err != nil {
+				t.Fatalf("PDF() error = %v", err)
+			}
+			defer pdf.Close()
+
+			if got := pdf.GetNumberOfPages(); got != tt.wantPages {
+				t.Errorf("PDF() got %d pages, want %d", got, tt.wantPages)
+			}
+
+			var buf bytes.Buffer
+			if err = pdf.Write(&buf); err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+			if !bytes.HasPrefix(buf.Bytes(), []byte("%PDF")) {
+				t.Errorf("PDF() is not a PDF")
+			}
+			if tt.events > 0 && !bytes.Contains(buf.Bytes(), []byte("/Outlines")) {
+				t.Errorf("PDF() has no bookmarks")
+			}
+		})
+	}
+}
+
+func TestHTML(t *testing.T) {
+	evs := testEvents(3)
+	evs[0].MessageText = "<script>alert(1)</script>"
+
+	var buf bytes.Buffer
+	if err := HTML(&buf, Build(evs, Template{}, time.Now())); err != nil {
+		t.Fatalf("HTML() error = %v", err)
+	}
+
+	page := buf.String()
+	for _, want := range []string{"<h1>" + DefaultTitle + "</h1>", "<dd>unit1</dd>", `<h3 id="section-0">alarm</h3>`, "&lt;script&gt;"} {
+		if !strings.Contains(page, want) {
+			t.Errorf("HTML() has no %q", want)
+		}
+	}
+	if strings.Contains(page, "<script>") {
+		t.Errorf("HTML() is not escaped")
+	}
+}
+
+func TestCSV(t *testing.T) {
+	var buf bytes.Buffer
+	d := Build(testEvents(3), Template{Columns: []string{"Number", "MessageClass", "MessageText"}}, time.Now())
+	if err := CSV(&buf, d); err != nil {
+		t.Fatalf("CSV() error = %v", err)
+	}
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("ReadAll() error = %v", err)
+	}
+	want := [][]string{
+		{"Number", "MessageClass", "MessageText"},
+		{"1", "alarm", "Разморозка 1"},
+		{"2", "waiting", "Разморозка 2"},
+		{"0", "working", "Разморозка 0"},
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("CSV() got %v, want %v", records, want)
+	}
+}
+
+func TestCSV_formulas(t *testing.T) {
+	evs := testEvents(6)
+	for i, text := range []string{"=HYPERLINK(\"http://x\")", "+1+2", "-2+3", "@SUM(A1)", "\tcmd", "-5"} {
+		evs[i].MessageText = text
+	}
+
+	var buf bytes.Buffer
+	d := Build(evs, Template{Columns: []string{"Number", "MessageText"}}, time.Now())
+	if err := CSV(&buf, d); err != nil {
+		t.Fatalf("CSV() error = %v", err)
+	}
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("ReadAll() error = %v", err)
+	}
+	got := make(map[string]string)
+	for _, r := range records[1:] {
+		got[r[0]] = r[1]
+	}
+	want := map[string]string{
+		"0": "'=HYPERLINK(\"http://x\")",
+		"1": "'+1+2",
+		"2": "'-2+3",
+		"3": "'@SUM(A1)",
+		"4": "'\tcmd",
+		"5": "-5",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("CSV() got %q, want %q", got, want)
+	}
+}
+
+func TestXLSX(t *testing.T) {
+	var buf bytes.Buffer
+	d := Build(testEvents(3), Template{Columns: []string{"Number", "MessageText"}}, time.Now())
+	if err := XLSX(&buf, d); err != nil {
+		t.Fatalf("XLSX() error = %v", err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("NewReader() error = %v", err)
+	}
+
+	parts := make(map[string]string)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("Open() error = %v", err)
+		}
+		content, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatalf("ReadAll() error = %v", err)
+		}
+		parts[f.Name] = string(content)
+	}
+
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels"} {
+		if _, ok := parts[name]; !ok {
+			t.Errorf("XLSX() has no %s", name)
+		}
+	}
+	if !strings.Contains(parts["xl/worksheets/sheet1.xml"], `<t xml:space="preserve">unit1</t>`) {
+		t.Errorf("XLSX() summary has no unit")
+	}
+
+	events := parts["xl/worksheets/sheet2.xml"]
+	for _, want := range []string{`<c r="A2"><v>1</v></c>`, `<c r="B2" t="inlineStr"><is><t xml:space="preserve">Разморозка 1</t></is></c>`} {
+		if !strings.Contains(events, want) {
+			t.Errorf("XLSX() events have no %q", want)
+		}
+	}
+}
+
+func TestColumnName(t *testing.T) {
+	for i, want := range map[int]string{0: "A", 25: "Z", 26: "AA", 51: "AZ", 52: "BA", 701: "ZZ", 702: "AAA"} {
+		if got := columnName(i); got != want {
+			t.Errorf("columnName(%d) = %s, want %s", i, got, want)
+		}
+	}
+}
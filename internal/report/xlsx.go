@@ -0,0 +1,165 @@
+package report
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// xlsxParts are the static parts of the workbook with the Summary and Events sheets.
+var xlsxParts = []struct {
+	name    string
+	content string
+}{
+	{"[Content_Types].xml", xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+		`<Override PartName="/xl/worksheets/sheet2.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+		`</Types>`},
+	{"_rels/.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+		`</Relationships>`},
+	{"xl/workbook.xml", xml.Header + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" ` +
+		`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets>` +
+		`<sheet name="Summary" sheetId="1" r:id="rId1"/>` +
+		`<sheet name="Events" sheetId="2" r:id="rId2"/>` +
+		`</sheets></workbook>`},
+	{"xl/_rels/workbook.xml.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+		`<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet2.xml"/>` +
+		`</Relationships>`},
+}
+
+// xlsxCell is a cell of a sheet, numbers are written as numbers.
+type xlsxCell struct {
+	value  string
+	number bool
+}
+
+// XLSX writes the report as a workbook with the Summary and Events sheets.
+func XLSX(w io.Writer, d Data) error {
+	zw := zip.NewWriter(w)
+
+	for _, p := range xlsxParts {
+		f, err := zw.Create(p.name)
+		if err == nil {
+			_, err = io.WriteString(f, p.content)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to write XLSX: %w", err)
+		}
+	}
+
+	for i, rows := range [][][]xlsxCell{summaryRows(d), eventRows(d)} {
+		f, err := zw.Create(fmt.Sprintf("xl/worksheets/sheet%d.xml", i+1))
+		if err == nil {
+			err = writeSheet(f, rows)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to write XLSX: %w", err)
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("failed to write XLSX: %w", err)
+	}
+	return nil
+}
+
+// summaryRows returns the rows of the Summary sheet.
+func summaryRows(d Data) [][]xlsxCell {
+	text := func(values ...string) []xlsxCell {
+		cells := make([]xlsxCell, len(values))
+		for i, v := range values {
+			cells[i] = xlsxCell{value: v}
+		}
+		return cells
+	}
+	count := func(c Count) []xlsxCell {
+		return []xlsxCell{{value: c.Key}, {value: strconv.Itoa(c.Events), number: true}}
+	}
+
+	rows := [][]xlsxCell{
+		text(d.Title),
+		text("Unit", d.Unit.GUID),
+		text("Inventory ids", strings.Join(d.Unit.InventoryIDs, ", ")),
+		{{value: "Events"}, {value: strconv.Itoa(d.Unit.Events), number: true}},
+		text("First event", formatTime(d.Unit.FirstSeen)),
+		text("Last event", formatTime(d.Unit.LastSeen)),
+		text("Generated at", formatTime(d.GeneratedAt)),
+		nil,
+		text("Message class", "Events"),
+	}
+	for _, c := range d.ByClass {
+		rows = append(rows, count(c))
+	}
+	rows = append(rows, nil, text("Level", "Events"))
+	for _, c := range d.ByLevel {
+		rows = append(rows, count(c))
+	}
+	return rows
+}
+
+// eventRows returns the rows of the Events sheet.
+func eventRows(d Data) [][]xlsxCell {
+	header := make([]xlsxCell, len(d.Columns))
+	for i, c := range d.Columns {
+		header[i] = xlsxCell{value: c.Field}
+	}
+
+	rows := [][]xlsxCell{header}
+	for _, s := range d.Sections {
+		for _, r := range s.Rows {
+			cells := make([]xlsxCell, len(r))
+			for i, v := range r {
+				cells[i] = xlsxCell{value: v, number: d.Columns[i].Numeric}
+			}
+			rows = append(rows, cells)
+		}
+	}
+	return rows
+}
+
+// writeSheet writes the worksheet with the rows, the strings are inline.
+func writeSheet(w io.Writer, rows [][]xlsxCell) error {
+	var b strings.Builder
+	b.WriteString(xml.Header)
+	b.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+
+	for i, r := range rows {
+		fmt.Fprintf(&b, `<row r="%d">`, i+1)
+		for j, c := range r {
+			ref := columnName(j) + strconv.Itoa(i+1)
+			if c.number {
+				if _, err := strconv.ParseFloat(c.value, 64); err == nil {
+					fmt.Fprintf(&b, `<c r="%s"><v>%s</v></c>`, ref, c.value)
+					continue
+				}
+			}
+			fmt.Fprintf(&b, `<c r="%s" t="inlineStr"><is><t xml:space="preserve">`, ref)
+			if err := xml.EscapeText(&b, []byte(c.value)); err != nil {
+				return err
+			}
+			b.WriteString(`</t></is></c>`)
+		}
+		b.WriteString(`</row>`)
+	}
+
+	b.WriteString(`</sheetData></worksheet>`)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// columnName returns the letters of the zero based column: A, B, ..., Z, AA, AB, ...
+func columnName(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
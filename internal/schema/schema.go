@@ -1,7 +1,84 @@
 package schema
 
+import (
+	"go-tsv-watcher/internal/usecase"
+	"time"
+)
+
 // EventRequest is the schema for the event request
 type EventRequest struct {
 	UnitGUID string `json:"unit_guid"`
 	Page     int    `json:"page"`
+	// Pipeline of the event, all pipelines of the main storage if empty.
+	Pipeline string `json:"pipeline,omitempty"`
+}
+
+// EventsResponse is the schema for the events list response
+type EventsResponse struct {
+	Events     []map[string]any `json:"events"`
+	NextCursor string           `json:"next_cursor,omitempty"`
+}
+
+// UnitsResponse is the schema for the units list response
+type UnitsResponse struct {
+	Units any `json:"units"`
+}
+
+// FilesResponse is the schema for the files list response
+type FilesResponse struct {
+	Files any `json:"files"`
+}
+
+// FileActionResponse is the schema for the reprocess and delete file responses
+type FileActionResponse struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Deleted int    `json:"deleted"`
+}
+
+// StreamMessage is the schema for the WebSocket stream messages
+type StreamMessage struct {
+	// Type is event or heartbeat
+	Type  string         `json:"type"`
+	ID    string         `json:"id,omitempty"`
+	Event map[string]any `json:"event,omitempty"`
+	Time  string         `json:"time,omitempty"`
+}
+
+// APIKeyRequest is the schema for the create API key request
+type APIKeyRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+}
+
+// APIKeysResponse is the schema for the API keys list response
+type APIKeysResponse struct {
+	Keys any `json:"keys"`
+}
+
+// HealthResponse is the schema for the liveness and readiness probe responses
+type HealthResponse struct {
+	// Status is ok, ready or not_ready
+	Status     string           `json:"status"`
+	Components []ComponentState `json:"components,omitempty"`
+}
+
+// ComponentState is the schema for a component of the readiness probe, the errors are in the status only
+type ComponentState struct {
+	Name  string `json:"name"`
+	State string `json:"state"`
+}
+
+// StatusResponse is the schema for the service status, Config is set if the config is reloaded
+type StatusResponse struct {
+	usecase.Status
+	Config *ConfigVersion `json:"config,omitempty"`
+}
+
+// ConfigVersion is the schema for the loaded config of the service status, the rest is in the admin config status
+type ConfigVersion struct {
+	Version  int       `json:"version"`
+	LoadedAt time.Time `json:"loaded_at"`
+	// Pending are the changed fields applied on the next restart.
+	Pending []string `json:"pending,omitempty"`
 }
@@ -0,0 +1,126 @@
+package itisadb
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"go-tsv-watcher/internal/storage/service"
+	"sort"
+)
+
+const (
+	// apiKeysIndex maps API key ids to the JSON of the keys.
+	apiKeysIndex = "api_keys"
+	// revokedValue replaces a deleted API key.
+	revokedValue = "revoked"
+)
+
+// storedAPIKey is the layout of an API key in the index, unlike service.APIKey it keeps the hash.
+type storedAPIKey struct {
+	service.APIKey
+	Hash string `json:"hash"`
+}
+
+// SaveAPIKey saves the API key.
+func (i *Itisadb) SaveAPIKey(ctx context.Context, key service.APIKey) error {
+	keys, err := i.client.Index(ctx, apiKeysIndex)
+	if err != nil {
+		return fmt.Errorf("failed to get api keys index: %w", err)
+	}
+
+	raw, err := json.Marshal(storedAPIKey{APIKey: key, Hash: key.Hash})
+	if err != nil {
+		return err
+	}
+
+	if err = keys.Set(ctx, key.ID, string(raw), true); err != nil {
+		return fmt.Errorf("failed to save api key %s: %w", key.ID, err)
+	}
+	return nil
+}
+
+// ListAPIKeys returns the API keys in the order of creation.
+func (i *Itisadb) ListAPIKeys(ctx context.Context) ([]service.APIKey, error) {
+	keysMap, err := i.apiKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]service.APIKey, 0, len(keysMap))
+	for _, key := range keysMap {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(a, b int) bool {
+		if !keys[a].CreatedAt.Equal(keys[b].CreatedAt) {
+			return keys[a].CreatedAt.Before(keys[b].CreatedAt)
+		}
+		return keys[a].ID < keys[b].ID
+	})
+
+	return keys, nil
+}
+
+// GetAPIKey returns the API key by the hash of its secret.
+func (i *Itisadb) GetAPIKey(ctx context.Context, hash string) (service.APIKey, error) {
+	keysMap, err := i.apiKeys(ctx)
+	if err != nil {
+		return service.APIKey{}, err
+	}
+
+	for _, key := range keysMap {
+		if key.Hash == hash {
+			return key, nil
+		}
+	}
+	return service.APIKey{}, service.ErrAPIKeyNotFound
+}
+
+// DeleteAPIKey marks the API key as revoked.
+func (i *Itisadb) DeleteAPIKey(ctx context.Context, id string) error {
+	keysMap, err := i.apiKeys(ctx)
+	if err != nil {
+		return err
+	}
+	if _, ok := keysMap[id]; !ok {
+		return service.ErrAPIKeyNotFound
+	}
+
+	keys, err := i.client.Index(ctx, apiKeysIndex)
+	if err != nil {
+		return fmt.Errorf("failed to get api keys index: %w", err)
+	}
+
+	if err = keys.Set(ctx, id, revokedValue, false); err != nil {
+		return fmt.Errorf("failed to revoke api key %s: %w", id, err)
+	}
+	return nil
+}
+
+// apiKeys returns the not revoked API keys by id.
+func (i *Itisadb) apiKeys(ctx context.Context) (map[string]service.APIKey, error) {
+	keys, err := i.client.Index(ctx, apiKeysIndex)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get api keys index: %w", err)
+	}
+
+	raw, err := keys.GetIndex(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get api keys: %w", err)
+	}
+
+	keysMap := make(map[string]service.APIKey, len(raw))
+	for id, value := range raw {
+		if value == revokedValue {
+			continue
+		}
+
+		var key storedAPIKey
+		if err = json.Unmarshal([]byte(value), &key); err != nil {
+			return nil, fmt.Errorf("failed to decode api key %s: %w", id, err)
+		}
+		key.APIKey.Hash = key.Hash
+		keysMap[id] = key.APIKey
+	}
+
+	return keysMap, nil
+}
@@ -0,0 +1,198 @@
+package itisadb
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/egorgasay/itisadb-go-sdk"
+	"go-tsv-watcher/internal/events"
+	"go-tsv-watcher/internal/storage/service"
+	"sort"
+	"time"
+)
+
+// ListFiles returns the files of the pipeline with the status,
+// all files if the status is empty, of all pipelines if the pipeline is empty.
+func (i *Itisadb) ListFiles(ctx context.Context, pipeline, status string) ([]service.File, error) {
+	filesMap, err := i.files.GetIndex(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get index: %w", err)
+	}
+
+	stats, err := i.client.Index(ctx, filesStatsIndex)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get files stats index: %w", err)
+	}
+
+	statsMap, err := stats.GetIndex(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get files stats: %w", err)
+	}
+
+	files := make([]service.File, 0, len(filesMap))
+	for key, errMsg := range filesMap {
+		p, name := splitFileKey(key)
+		if errMsg == resetValue || pipeline != "" && p != pipeline {
+			continue
+		}
+
+		f, err := newFile(p, name, errMsg, statsMap[key])
+		if err != nil {
+			return nil, err
+		}
+
+		if status == "" || f.Status == status {
+			files = append(files, f)
+		}
+	}
+	sort.Slice(files, func(a, b int) bool {
+		if files[a].Name != files[b].Name {
+			return files[a].Name < files[b].Name
+		}
+		return files[a].Pipeline < files[b].Pipeline
+	})
+
+	return files, nil
+}
+
+// GetFile returns the file of the pipeline with the units of its stored events.
+func (i *Itisadb) GetFile(ctx context.Context, pipeline, name string) (service.FileDetails, error) {
+	f, err := i.file(ctx, pipeline, name)
+	if err != nil {
+		return service.FileDetails{}, err
+	}
+
+	perUnit := make(map[string]int)
+	err = i.iterEvents(ctx, nil, func(e events.Event) bool {
+		if e.Pipeline == pipeline && e.SourceFile == name {
+			perUnit[e.UnitGUID]++
+		}
+		return false
+	})
+	if err != nil {
+		return service.FileDetails{}, err
+	}
+
+	details := service.FileDetails{File: f, Units: make([]string, 0, len(perUnit))}
+	for guid, n := range perUnit {
+		details.Units = append(details.Units, guid)
+		details.Stored += n
+	}
+	sort.Strings(details.Units)
+
+	return details, nil
+}
+
+// DeleteFile marks the events of the file of the pipeline as pruned and the file as deleted,
+// the record is kept so the file is not ingested again.
+func (i *Itisadb) DeleteFile(ctx context.Context, pipeline, name string) (int, error) {
+	if _, err := i.file(ctx, pipeline, name); err != nil {
+		return 0, err
+	}
+
+	deleted, err := i.purgeFile(ctx, pipeline, name)
+	if err != nil {
+		return deleted, err
+	}
+
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	err = i.updateFileStats(ctx, fileKey(pipeline, name), func(fs *fileStats) {
+		fs.DeletedAt = &now
+	})
+
+	return deleted, err
+}
+
+// ResetFile marks the events of the file of the pipeline as pruned and resets its record,
+// so it can be ingested again.
+func (i *Itisadb) ResetFile(ctx context.Context, pipeline, name string) error {
+	if _, err := i.file(ctx, pipeline, name); err != nil {
+		return err
+	}
+
+	if _, err := i.purgeFile(ctx, pipeline, name); err != nil {
+		return err
+	}
+
+	key := fileKey(pipeline, name)
+	err := i.updateFileStats(ctx, key, func(fs *fileStats) {
+		*fs = fileStats{}
+	})
+	if err != nil {
+		return err
+	}
+
+	if err = i.files.Set(ctx, key, resetValue, false); err != nil {
+		return fmt.Errorf("failed to reset %s: %w", name, err)
+	}
+
+	return nil
+}
+
+// file returns the record of the file of the pipeline.
+func (i *Itisadb) file(ctx context.Context, pipeline, name string) (service.File, error) {
+	filesMap, err := i.files.GetIndex(ctx)
+	if err != nil {
+		return service.File{}, fmt.Errorf("failed to get index: %w", err)
+	}
+
+	key := fileKey(pipeline, name)
+	errMsg, ok := filesMap[key]
+	if !ok || errMsg == resetValue {
+		return service.File{}, service.ErrFileNotFound
+	}
+
+	stats, err := i.client.Index(ctx, filesStatsIndex)
+	if err != nil {
+		return service.File{}, fmt.Errorf("failed to get files stats index: %w", err)
+	}
+
+	raw, err := stats.Get(ctx, key)
+	if err != nil {
+		raw = ""
+	}
+
+	return newFile(pipeline, name, errMsg, raw)
+}
+
+// purgeFile marks the events of the file of the pipeline as pruned and returns their number.
+func (i *Itisadb) purgeFile(ctx context.Context, pipeline, name string) (int, error) {
+	type position struct {
+		index *itisadb.Index
+		n     int
+	}
+
+	var positions []position
+	err := i.iterStored(ctx, nil, func(guidIndex *itisadb.Index, n int, e events.Event) bool {
+		if e.Pipeline == pipeline && e.SourceFile == name {
+			positions = append(positions, position{index: guidIndex, n: n})
+		}
+		return false
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for j, p := range positions {
+		if err = i.tombstone(ctx, p.index, p.n); err != nil {
+			return j, fmt.Errorf("failed to delete event of %s: %w", name, err)
+		}
+	}
+
+	return len(positions), nil
+}
+
+// newFile creates the file record of the pipeline from its error and encoded stats.
+func newFile(pipeline, name, errMsg, rawStats string) (service.File, error) {
+	var fs fileStats
+	if rawStats != "" {
+		if err := json.Unmarshal([]byte(rawStats), &fs); err != nil {
+			return service.File{}, fmt.Errorf("failed to decode stats of %s: %w", name, err)
+		}
+	}
+
+	f := service.NewFile(name, errMsg, fs.DeletedAt)
+	f.Pipeline, f.Events, f.Pruned, f.Archived = pipeline, fs.Events, fs.Pruned, fs.Archived
+
+	return f, nil
+}
@@ -2,21 +2,45 @@ package itisadb
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/egorgasay/itisadb-go-sdk"
 	"go-tsv-watcher/internal/events"
 	"go-tsv-watcher/internal/storage/service"
 	"go-tsv-watcher/pkg/logger"
-	"log"
 	"reflect"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// unitsIndex maps unit guids to the number of their first not pruned event.
+	unitsIndex = "units"
+	// filesStatsIndex maps filenames to their fileStats.
+	filesStatsIndex = "files_stats"
+	// prunedKey marks a pruned event stored in the legacy layout.
+	prunedKey = "Pruned"
+	// prunedValue replaces a pruned event, the key is kept to preserve numbering.
+	prunedValue = "pruned"
+	// resetValue replaces the error of a reset file, so the file can be ingested again.
+	resetValue = "\x00reset"
 )
 
 // Itisadb is a storage for events.
+//
+// Every event is stored as a JSON value in the index of its unit,
+// the key is the number of the event within the unit.
 type Itisadb struct {
 	files  *itisadb.Index
 	client *itisadb.Client
 	logger logger.ILogger
+
+	mu sync.Mutex
+	// next holds the next free number of every unit known to this process.
+	next map[string]int
 }
 
 // New creates a new Itisadb.
@@ -38,22 +62,54 @@ func New(ctx context.Context, creds string, logger logger.ILogger) (*Itisadb, er
 	}, nil
 }
 
-// LoadFilenames loads parsed filenames from the database.
-func (i *Itisadb) LoadFilenames(ctx context.Context, adder service.Adder) error {
+// Close does nothing, the client has no connection to close.
+func (i *Itisadb) Close() error {
+	return nil
+}
+
+// Ping checks the connection to itisadb.
+func (i *Itisadb) Ping(ctx context.Context) error {
+	_, err := i.client.IsIndex(ctx, "files")
+	return err
+}
+
+// fileKey returns the key of the file of the pipeline in the files indexes,
+// the files of the default pipeline are keyed by their names as before the pipelines.
+func fileKey(pipeline, name string) string {
+	if pipeline == events.DefaultPipeline {
+		return name
+	}
+	return pipeline + "/" + name
+}
+
+// splitFileKey returns the pipeline and the name of the file by its key.
+func splitFileKey(key string) (pipeline, name string) {
+	if pipeline, name, ok := strings.Cut(key, "/"); ok {
+		return pipeline, name
+	}
+	return events.DefaultPipeline, key
+}
+
+// LoadFilenames loads parsed filenames of the pipeline from the database.
+func (i *Itisadb) LoadFilenames(ctx context.Context, pipeline string, adder service.Adder) error {
 	filesMap, err := i.files.GetIndex(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to get index: %w", err)
 	}
 
-	for name := range filesMap {
+	for key, errMsg := range filesMap {
+		p, name := splitFileKey(key)
+		if errMsg == resetValue || p != pipeline {
+			continue
+		}
 		adder.AddFile(name)
 	}
 
 	return nil
 }
 
-// AddFilename adds parsed filename to the database.
-func (i *Itisadb) AddFilename(ctx context.Context, filename string, err error) error {
+// AddFilename adds parsed filename of the pipeline to the database.
+func (i *Itisadb) AddFilename(ctx context.Context, pipeline, filename string, err error) error {
 	if ctx.Err() != nil {
 		return ctx.Err()
 	}
@@ -63,7 +119,13 @@ func (i *Itisadb) AddFilename(ctx context.Context, filename string, err error) e
 		errMsg = err.Error()
 	}
 
-	err = i.files.Set(context.Background(), filename, errMsg, true)
+	key := fileKey(pipeline, filename)
+	err = i.files.Set(context.Background(), key, errMsg, true)
+	if errors.Is(err, itisadb.ErrUniqueConstraint) {
+		if prev, errGet := i.files.Get(ctx, key); errGet == nil && prev == resetValue {
+			err = i.files.Set(ctx, key, errMsg, false)
+		}
+	}
 	if err != nil {
 		return fmt.Errorf("failed to set: %w", err)
 	}
@@ -71,61 +133,172 @@ func (i *Itisadb) AddFilename(ctx context.Context, filename string, err error) e
 	return nil
 }
 
-// SaveEvents saves events to the database.
+// SaveEvents saves events to the database, one value per event.
 func (i *Itisadb) SaveEvents(ctx context.Context, evs service.IEvents) error {
+	units, err := i.client.Index(ctx, unitsIndex)
+	if err != nil {
+		return fmt.Errorf("failed to get units index: %w", err)
+	}
+
+	saved, err := i.saveEvents(ctx, units, evs)
+	if err != nil {
+		i.logger.Warn(err.Error())
+	}
+
+	i.countEvents(ctx, evs, len(saved))
+	return nil
+}
+
+// SaveFile saves the events and adds their file to the ledger after all of them are saved.
+// If an event fails or the context is done, the saved events are tombstoned and the file is not in the ledger,
+// so the file ingested again does not duplicate them.
+func (i *Itisadb) SaveFile(ctx context.Context, evs service.IEvents) error {
+	units, err := i.client.Index(ctx, unitsIndex)
+	if err != nil {
+		return fmt.Errorf("failed to get units index: %w", err)
+	}
+
+	saved, err := i.saveEvents(ctx, units, evs)
+	if err != nil {
+		i.discard(saved)
+		return err
+	}
+
+	if err = i.AddFilename(ctx, evs.Pipeline(), evs.Source(), nil); err != nil {
+		i.discard(saved)
+		return err
+	}
+	i.countEvents(ctx, evs, len(saved))
+	return nil
+}
+
+// savedEvent is the number of a saved event in the index of its unit.
+type savedEvent struct {
+	guidIndex *itisadb.Index
+	number    int
+}
+
+// discard tombstones the saved events of a file not added to the ledger, the errors are logged.
+// The events are discarded after the context of the file is done, so they use their own context.
+func (i *Itisadb) discard(saved []savedEvent) {
+	for _, e := range saved {
+		if err := i.tombstone(context.Background(), e.guidIndex, e.number); err != nil {
+			i.logger.Warn(fmt.Sprintf("failed to discard event %d: %v", e.number, err))
+		}
+	}
+}
+
+// saveEvents saves the events until one fails and returns the saved ones.
+func (i *Itisadb) saveEvents(ctx context.Context, units *itisadb.Index, evs service.IEvents) ([]savedEvent, error) {
+	source, pipeline := evs.Source(), evs.Pipeline()
+	ingestedAt := time.Now().UTC()
+	guidIndexes := make(map[string]*itisadb.Index)
+	var saved []savedEvent
+
+	var errSave error
 	save := func(e events.Event) (stop bool) {
 		if ctx.Err() != nil {
-			log.Println(ctx.Err())
+			errSave = ctx.Err()
 			return true
 		}
 
-		guidIndex, err := i.client.Index(ctx, e.UnitGUID)
-		if err != nil {
-			i.logger.Warn(fmt.Sprintf("failed to create or get guid index: %v", err))
-			return true
+		e.SourceFile, e.IngestedAt, e.Pipeline = source, ingestedAt, pipeline
+
+		guidIndex, ok := guidIndexes[e.UnitGUID]
+		if !ok {
+			var err error
+			guidIndex, err = i.client.Index(ctx, e.UnitGUID)
+			if err != nil {
+				errSave = fmt.Errorf("failed to create or get guid index: %w", err)
+				return true
+			}
+			guidIndexes[e.UnitGUID] = guidIndex
+
+			if _, err = units.Get(ctx, e.UnitGUID); err != nil {
+				if err = units.Set(ctx, e.UnitGUID, "1", false); err != nil {
+					i.logger.Warn(fmt.Sprintf("failed to register unit: %v", err))
+				}
+			}
 		}
 
-		num, err := guidIndex.Size(ctx)
+		value, err := json.Marshal(e)
 		if err != nil {
-			i.logger.Warn(fmt.Sprintf("failed to get size: %v", err))
+			errSave = fmt.Errorf("failed to encode event: %w", err)
 			return true
 		}
 
-		numIndex, err := guidIndex.Index(ctx, fmt.Sprintf("%d", num+1))
+		number, err := i.claim(ctx, e.UnitGUID, guidIndex, string(value))
 		if err != nil {
-			i.logger.Warn(fmt.Sprintf("failed to create or get index: %v", err))
+			errSave = fmt.Errorf("failed to save event: %w", err)
 			return true
 		}
 
-		ev := reflect.ValueOf(e)
-		for j := 0; j < ev.NumField(); j++ {
-			// get field name
-			field := ev.Type().Field(j)
-			// get field value
-			value := ev.Field(j)
-			switch field.Type.Kind() {
-			case reflect.String:
-				err = numIndex.Set(ctx, field.Name, value.String(), false)
-				if err != nil {
-					i.logger.Warn(fmt.Sprintf("failed to save %s: %s", field.Name, err))
-				}
-			case reflect.Int:
-				err = numIndex.Set(ctx, field.Name, fmt.Sprintf("%d", value.Int()), false)
-				if err != nil {
-					i.logger.Warn(fmt.Sprintf("failed to save %s: %s", field.Name, err))
-				}
-			}
-		}
+		saved = append(saved, savedEvent{guidIndex: guidIndex, number: number})
 		return false
 	}
 
 	evs.Iter(save)
 
-	return nil
+	return saved, errSave
 }
 
-// GetEventByNumber gets event by given number.
-func (i *Itisadb) GetEventByNumber(ctx context.Context, guid string, number int) (events.Event, error) {
+// countEvents adds the number of the saved events to the stats of their file, the errors are logged.
+func (i *Itisadb) countEvents(ctx context.Context, evs service.IEvents, saved int) {
+	if saved == 0 {
+		return
+	}
+
+	err := i.updateFileStats(ctx, fileKey(evs.Pipeline(), evs.Source()), func(fs *fileStats) { fs.Events += saved })
+	if err != nil {
+		i.logger.Warn(err.Error())
+	}
+}
+
+// claim stores the value under the next free number of the unit and returns the number.
+// The value is set only if the key does not exist, so concurrent writers never share a number.
+func (i *Itisadb) claim(ctx context.Context, guid string, guidIndex *itisadb.Index, value string) (int, error) {
+	i.mu.Lock()
+	next, ok := i.next[guid]
+	i.mu.Unlock()
+
+	if !ok {
+		size, err := guidIndex.Size(ctx)
+		if err != nil && !errors.Is(err, itisadb.ErrIndexNotFound) {
+			return 0, fmt.Errorf("failed to get size: %w", err)
+		}
+		next = int(size) + 1
+	}
+
+	for {
+		if ctx.Err() != nil {
+			return 0, ctx.Err()
+		}
+
+		err := guidIndex.Set(ctx, strconv.Itoa(next), value, true)
+		if errors.Is(err, itisadb.ErrUniqueConstraint) {
+			next++
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		i.mu.Lock()
+		if i.next == nil {
+			i.next = make(map[string]int)
+		}
+		if i.next[guid] <= next {
+			i.next[guid] = next + 1
+		}
+		i.mu.Unlock()
+
+		return next, nil
+	}
+}
+
+// GetEventByNumber gets event by given number, the numbers are shared by the pipelines of the unit,
+// so the event of another pipeline is not found.
+func (i *Itisadb) GetEventByNumber(ctx context.Context, pipeline, guid string, number int) (events.Event, error) {
 	if ctx.Err() != nil {
 		return events.Event{}, ctx.Err()
 	}
@@ -135,18 +308,59 @@ func (i *Itisadb) GetEventByNumber(ctx context.Context, guid string, number int)
 		return events.Event{}, err
 	}
 
-	numIndex, err := guidIndex.Index(ctx, fmt.Sprintf("%d", number))
+	event, pruned, err := i.readEvent(ctx, guidIndex, number)
 	if err != nil {
 		return events.Event{}, err
 	}
 
+	if pruned || pipeline != "" && event.Pipeline != pipeline {
+		return events.Event{}, service.ErrEventNotFound
+	}
+
+	return event, nil
+}
+
+// readEvent reads the event with the number from the unit index.
+func (i *Itisadb) readEvent(ctx context.Context, guidIndex *itisadb.Index, number int) (events.Event, bool, error) {
+	value, err := guidIndex.Get(ctx, strconv.Itoa(number))
+	if err != nil || value == "" {
+		return i.readLegacyEvent(ctx, guidIndex, number)
+	}
+
+	if value == prunedValue {
+		return events.Event{}, true, nil
+	}
+
+	var event events.Event
+	if err = json.Unmarshal([]byte(value), &event); err != nil {
+		return events.Event{}, false, fmt.Errorf("failed to decode event: %w", err)
+	}
+	// the events saved before the pipelines belong to the default one
+	if event.Pipeline == "" {
+		event.Pipeline = events.DefaultPipeline
+	}
+
+	return event, false, nil
+}
+
+// readLegacyEvent reads the event stored as an index of fields.
+func (i *Itisadb) readLegacyEvent(ctx context.Context, guidIndex *itisadb.Index, number int) (events.Event, bool, error) {
+	numIndex, err := guidIndex.Index(ctx, fmt.Sprintf("%d", number))
+	if err != nil {
+		return events.Event{}, false, err
+	}
+
 	numMap, err := numIndex.GetIndex(ctx)
 	if err != nil {
-		return events.Event{}, err
+		return events.Event{}, false, err
 	}
 
 	if len(numMap) == 0 {
-		return events.Event{}, service.ErrEventNotFound
+		return events.Event{}, false, service.ErrEventNotFound
+	}
+
+	if numMap[prunedKey] != "" {
+		return events.Event{}, true, nil
 	}
 
 	var event events.Event
@@ -158,17 +372,39 @@ func (i *Itisadb) GetEventByNumber(ctx context.Context, guid string, number int)
 		field := ev.Field(j)
 		tField := ev.Type().Field(j)
 
+		raw, ok := numMap[tField.Name]
+		if !ok {
+			continue
+		}
+
 		switch field.Type().Kind() {
 		case reflect.String:
-			field.SetString(numMap[tField.Name])
+			field.SetString(raw)
 		case reflect.Int:
-			num, err := strconv.Atoi(numMap[tField.Name])
+			num, err := strconv.Atoi(raw)
 			if err != nil {
 				continue
 			}
 			field.SetInt(int64(num))
+		case reflect.Bool:
+			b, err := strconv.ParseBool(raw)
+			if err != nil {
+				continue
+			}
+			field.SetBool(b)
+		case reflect.Struct:
+			if _, ok := field.Interface().(time.Time); ok {
+				t, err := time.Parse(time.RFC3339Nano, raw)
+				if err != nil {
+					continue
+				}
+				field.Set(reflect.ValueOf(t))
+			}
 		}
 	}
+	if event.Pipeline == "" {
+		event.Pipeline = events.DefaultPipeline
+	}
 
-	return event, nil
+	return event, false, nil
 }
@@ -7,6 +7,7 @@ import (
 	"github.com/go-chi/httplog"
 	"github.com/pkg/errors"
 	"go-tsv-watcher/internal/events"
+	"go-tsv-watcher/internal/storage/service"
 	"go-tsv-watcher/pkg/logger"
 	"reflect"
 	"testing"
@@ -70,7 +71,7 @@ func TestItisadb_AddFilename(t *testing.T) {
 				files:  files,
 				client: client,
 			}
-			if err = i.AddFilename(tt.args.ctx, tt.args.filename, tt.args.err); err != nil {
+			if err = i.AddFilename(tt.args.ctx, events.DefaultPipeline, tt.args.filename, tt.args.err); err != nil {
 				t.Errorf("AddFilename() error = %v", err)
 			}
 
@@ -204,7 +205,7 @@ func TestItisadb_GetEventByNumber(t *testing.T) {
 				t.Errorf("Can't set index %v", err)
 			}
 
-			got, err := i.GetEventByNumber(tt.args.ctx, tt.args.guid, tt.args.number)
+			got, err := i.GetEventByNumber(tt.args.ctx, "", tt.args.guid, tt.args.number)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("GetEventByNumber() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -275,7 +276,7 @@ func TestItisadb_LoadFilenames(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := st.AddFilename(context.Background(), tt.filename, tt.err)
+			err := st.AddFilename(context.Background(), events.DefaultPipeline, tt.filename, tt.err)
 			if (err != nil) != tt.wantError {
 				t.Errorf("error adding filename: %v", err)
 			}
@@ -284,7 +285,7 @@ func TestItisadb_LoadFilenames(t *testing.T) {
 
 	a := &addStub{}
 
-	err = st.LoadFilenames(context.Background(), a)
+	err = st.LoadFilenames(context.Background(), events.DefaultPipeline, a)
 
 	if err != nil {
 		t.Fatalf("error loading filenames: %v", err)
@@ -317,6 +318,14 @@ func (i ieventsStub) Print() {
 
 }
 
+func (i ieventsStub) Source() string {
+	return ""
+}
+
+func (i ieventsStub) Pipeline() string {
+	return events.DefaultPipeline
+}
+
 func (i ieventsStub) Iter(cb func(d events.Event) (stop bool)) {
 	for _, d := range i.events {
 		if stop := cb(d); stop {
@@ -364,6 +373,7 @@ func TestItisadb_SaveEvents(t *testing.T) {
 					{
 						ID:       "3",
 						UnitGUID: "9132dbdf-5991-4a56-bc0c-5b3e3d6777bf",
+						Block:    true,
 					},
 					{
 						ID:       "4",
@@ -390,23 +400,18 @@ func TestItisadb_SaveEvents(t *testing.T) {
 			}
 
 			for j, e := range tt.evs.events {
-				guidIndex, err := i.client.Index(ctx, e.UnitGUID)
+				got, err := i.GetEventByNumber(ctx, "", e.UnitGUID, 1)
 				if err != nil {
-					t.Fatalf("failed to create or get guid index: %v", err)
+					t.Fatalf("failed to get event: %v", err)
 				}
 
-				numIndex, err := guidIndex.Index(ctx, "1")
-				if err != nil {
-					t.Fatalf("failed to create or get index: %v", err)
+				if got.ID != tt.evs.events[j].ID || got.Block != tt.evs.events[j].Block {
+					t.Errorf("got = %v, want %v", got, tt.evs.events[j])
 				}
 
-				get, err := numIndex.Get(ctx, "ID")
+				guidIndex, err := i.client.Index(ctx, e.UnitGUID)
 				if err != nil {
-					t.Fatalf("failed to get ID: %v", err)
-				}
-
-				if get != tt.evs.events[j].ID {
-					t.Errorf("got = %v, want %v", get, tt.evs.events[j].ID)
+					t.Fatalf("failed to create or get guid index: %v", err)
 				}
 
 				guidIndex.Delete(ctx)
@@ -416,3 +421,135 @@ func TestItisadb_SaveEvents(t *testing.T) {
 	}
 
 }
+
+// cancelStub is the events of a file cancelling the context before the event at index after.
+type cancelStub struct {
+	ieventsStub
+	after  int
+	cancel context.CancelFunc
+}
+
+func (c cancelStub) Source() string {
+	return "cancelled.tsv"
+}
+
+func (c cancelStub) Iter(cb func(d events.Event) (stop bool)) {
+	for j, d := range c.events {
+		if j == c.after {
+			c.cancel()
+		}
+		if stop := cb(d); stop {
+			return
+		}
+	}
+}
+
+func TestItisadb_SaveFile_cancelled(t *testing.T) {
+	client, err := itisadb.New(":800")
+	if err != nil {
+		t.Logf("Can't create client %v", err)
+		t.Skip()
+	}
+
+	if !isWorking(client) {
+		t.Skip()
+	}
+
+	files, err := client.Index(context.Background(), "test_files")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	i := &Itisadb{
+		client: client,
+		files:  files,
+		logger: logger.New(httplog.NewLogger("watcher", httplog.Options{Concise: true})),
+	}
+
+	guid := "cancelled-unit"
+	ctx, cancel := context.WithCancel(context.Background())
+	evs := cancelStub{after: 2, cancel: cancel, ieventsStub: ieventsStub{events: []events.Event{
+		{ID: "1", UnitGUID: guid}, {ID: "2", UnitGUID: guid}, {ID: "3", UnitGUID: guid}, {ID: "4", UnitGUID: guid},
+	}}}
+	if err = i.SaveFile(ctx, evs); err == nil {
+		t.Fatal("SaveFile() error = nil, want the cancelled context")
+	}
+
+	// the events saved before the cancel are tombstoned and the file is not in the ledger
+	for number := 1; number <= 2; number++ {
+		if _, err = i.GetEventByNumber(context.Background(), "", guid, number); !errors.Is(err, service.ErrEventNotFound) {
+			t.Errorf("GetEventByNumber(%d) error = %v, want %v", number, err, service.ErrEventNotFound)
+		}
+	}
+	if _, err = files.Get(context.Background(), evs.Source()); err == nil {
+		t.Errorf("the cancelled file is in the ledger")
+	}
+
+	if guidIndex, err := client.Index(context.Background(), guid); err == nil {
+		guidIndex.Delete(context.Background())
+	}
+}
+
+func TestTopEvents(t *testing.T) {
+	evs := []events.Event{{ID: "3", Number: 3}, {ID: "1", Number: 1}, {ID: "5", Number: 5}, {ID: "2", Number: 2}, {ID: "4", Number: 4}}
+
+	tests := []struct {
+		name string
+		q    service.EventsQuery
+		want []int
+	}{
+		{name: "first", q: service.EventsQuery{SortBy: service.SortNumber, Limit: 2}, want: []int{1, 2}},
+		{name: "desc", q: service.EventsQuery{SortBy: service.SortNumber, Desc: true, Limit: 3}, want: []int{5, 4, 3}},
+		{name: "no limit", q: service.EventsQuery{SortBy: service.SortNumber}, want: []int{1, 2, 3, 4, 5}},
+		{name: "limit over events", q: service.EventsQuery{SortBy: service.SortNumber, Limit: 10}, want: []int{1, 2, 3, 4, 5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			top := &topEvents{q: tt.q}
+			for _, e := range evs {
+				top.add(e)
+			}
+
+			var got []int
+			for _, e := range top.sorted() {
+				got = append(got, e.Number)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("sorted() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestItisadb_ListEvents_maxScan(t *testing.T) {
+	client, err := itisadb.New(":800")
+	if err != nil {
+		t.Logf("Can't create client %v", err)
+		t.Skip()
+	}
+
+	if !isWorking(client) {
+		t.Skip()
+	}
+
+	defer func(n int) { maxScan = n }(maxScan)
+	maxScan = 2
+
+	i := &Itisadb{client: client, logger: logger.New(httplog.NewLogger("watcher", httplog.Options{Concise: true}))}
+
+	guid := "scanned-unit"
+	evs := ieventsStub{events: []events.Event{{ID: "1", UnitGUID: guid}, {ID: "2", UnitGUID: guid}, {ID: "3", UnitGUID: guid}}}
+	if err = i.SaveEvents(context.Background(), evs); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if guidIndex, err := client.Index(context.Background(), guid); err == nil {
+			guidIndex.Delete(context.Background())
+		}
+	}()
+
+	_, err = i.ListEvents(context.Background(), service.EventsQuery{Filter: service.EventFilter{UnitGUID: guid}, Limit: 1})
+	if !errors.Is(err, service.ErrInvalidQuery) {
+		t.Errorf("ListEvents() error = %v, want %v", err, service.ErrInvalidQuery)
+	}
+}
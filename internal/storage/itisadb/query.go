@@ -0,0 +1,177 @@
+package itisadb
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/egorgasay/itisadb-go-sdk"
+	"go-tsv-watcher/internal/events"
+	"go-tsv-watcher/internal/storage/service"
+	"sort"
+)
+
+// units returns the guids of all units.
+func (i *Itisadb) units(ctx context.Context) ([]string, error) {
+	units, err := i.client.Index(ctx, unitsIndex)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get units index: %w", err)
+	}
+
+	heads, err := units.GetIndex(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get units: %w", err)
+	}
+
+	guids := make([]string, 0, len(heads))
+	for guid := range heads {
+		guids = append(guids, guid)
+	}
+	sort.Strings(guids)
+
+	return guids, nil
+}
+
+// iterEvents calls cb for every not pruned event of the units, all units if guids is empty.
+// Itisadb has no secondary indexes, so every event is read.
+func (i *Itisadb) iterEvents(ctx context.Context, guids []string, cb func(e events.Event) (stop bool)) error {
+	return i.iterStored(ctx, guids, func(_ *itisadb.Index, _ int, e events.Event) bool {
+		return cb(e)
+	})
+}
+
+// iterStored is iterEvents that also passes the unit index and the number of the event in it.
+func (i *Itisadb) iterStored(ctx context.Context, guids []string,
+	cb func(guidIndex *itisadb.Index, n int, e events.Event) (stop bool)) error {
+	if len(guids) == 0 {
+		var err error
+		guids, err = i.units(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, guid := range guids {
+		guidIndex, err := i.client.Index(ctx, guid)
+		if err != nil {
+			return fmt.Errorf("failed to get unit index: %w", err)
+		}
+
+		size, err := guidIndex.Size(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get unit size: %w", err)
+		}
+
+		for n := 1; n <= int(size); n++ {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			e, pruned, err := i.readEvent(ctx, guidIndex, n)
+			if errors.Is(err, service.ErrEventNotFound) || pruned {
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("failed to read event %s/%d: %w", guid, n, err)
+			}
+
+			if cb(guidIndex, n, e) {
+				return nil
+			}
+		}
+	}
+
+	return nil
+}
+
+// maxScan is the most events read by a query, itisadb has no index to sort or filter the events,
+// so every query reads all events of the units it covers.
+var maxScan = 1000000
+
+// ListEvents returns the events matching the query. Without secondary indexes every event of the units
+// is read for every page, so a query reading more than maxScan events fails and asks for the unit filter.
+// Only the Limit first events are kept while reading.
+func (i *Itisadb) ListEvents(ctx context.Context, q service.EventsQuery) ([]events.Event, error) {
+	if _, err := q.Column(); err != nil {
+		return nil, err
+	}
+
+	if q.After != nil {
+		if _, err := service.CursorArg(q.After, q.SortBy); err != nil {
+			return nil, err
+		}
+	}
+
+	var guids []string
+	if q.Filter.UnitGUID != "" {
+		guids = []string{q.Filter.UnitGUID}
+	}
+
+	top := &topEvents{q: q}
+	var scanned int
+	var errScan error
+	err := i.iterEvents(ctx, guids, func(e events.Event) (stop bool) {
+		if scanned++; scanned > maxScan {
+			errScan = fmt.Errorf("%w: more than %d events to read, filter by unit_guid", service.ErrInvalidQuery, maxScan)
+			return true
+		}
+		if q.Filter.Match(e) && q.IsAfter(e) {
+			top.add(e)
+		}
+		return false
+	})
+	if err != nil {
+		return nil, err
+	}
+	if errScan != nil {
+		return nil, errScan
+	}
+
+	return top.sorted(), nil
+}
+
+// topEvents keeps the first q.Limit events in the order of the query, all events if Limit is not set.
+// It's a heap with the last kept event on top, so a following event replaces it.
+type topEvents struct {
+	q   service.EventsQuery
+	evs []events.Event
+}
+
+// add keeps the event if it's among the first ones.
+func (t *topEvents) add(e events.Event) {
+	if t.q.Limit <= 0 || len(t.evs) < t.q.Limit {
+		heap.Push(t, e)
+		return
+	}
+	if t.q.Less(e, t.evs[0]) {
+		t.evs[0] = e
+		heap.Fix(t, 0)
+	}
+}
+
+// sorted returns the kept events in the order of the query.
+func (t *topEvents) sorted() []events.Event {
+	sort.Slice(t.evs, func(a, b int) bool {
+		return t.q.Less(t.evs[a], t.evs[b])
+	})
+	return t.evs
+}
+
+// Len implements heap.Interface.
+func (t *topEvents) Len() int { return len(t.evs) }
+
+// Less implements heap.Interface, the later event goes first.
+func (t *topEvents) Less(a, b int) bool { return t.q.Less(t.evs[b], t.evs[a]) }
+
+// Swap implements heap.Interface.
+func (t *topEvents) Swap(a, b int) { t.evs[a], t.evs[b] = t.evs[b], t.evs[a] }
+
+// Push implements heap.Interface.
+func (t *topEvents) Push(x any) { t.evs = append(t.evs, x.(events.Event)) }
+
+// Pop implements heap.Interface.
+func (t *topEvents) Pop() any {
+	e := t.evs[len(t.evs)-1]
+	t.evs = t.evs[:len(t.evs)-1]
+	return e
+}
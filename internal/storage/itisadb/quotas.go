@@ -0,0 +1,55 @@
+package itisadb
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// quotasIndex maps <day>/<client> to the used quota, the index has no deletion
+// so the entries of the past days are kept.
+const quotasIndex = "quotas"
+
+// LoadQuotas returns the used quotas of the day by client.
+func (i *Itisadb) LoadQuotas(ctx context.Context, day string) (map[string]int, error) {
+	quotas, err := i.client.Index(ctx, quotasIndex)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get quotas index: %w", err)
+	}
+
+	raw, err := quotas.GetIndex(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get quotas: %w", err)
+	}
+
+	used := make(map[string]int)
+	for key, value := range raw {
+		d, client, ok := strings.Cut(key, "/")
+		if !ok || d != day {
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode quota %s: %w", key, err)
+		}
+		used[client] = n
+	}
+
+	return used, nil
+}
+
+// SaveQuotas saves the used quotas of the day by client.
+func (i *Itisadb) SaveQuotas(ctx context.Context, day string, used map[string]int) error {
+	quotas, err := i.client.Index(ctx, quotasIndex)
+	if err != nil {
+		return fmt.Errorf("failed to get quotas index: %w", err)
+	}
+
+	for client, n := range used {
+		if err = quotas.Set(ctx, day+"/"+client, strconv.Itoa(n), false); err != nil {
+			return fmt.Errorf("failed to save quota of %s: %w", client, err)
+		}
+	}
+	return nil
+}
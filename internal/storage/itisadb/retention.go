@@ -0,0 +1,285 @@
+package itisadb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/egorgasay/itisadb-go-sdk"
+	"go-tsv-watcher/internal/events"
+	"go-tsv-watcher/internal/storage/service"
+	"strconv"
+	"time"
+)
+
+// fileStats is the files ledger bookkeeping stored in the filesStatsIndex.
+type fileStats struct {
+	Events   int `json:"events"`
+	Pruned   int `json:"pruned"`
+	Archived int `json:"archived"`
+	// DeletedAt is set when the events of the file are deleted.
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+// updateFileStats applies modify to the stats of the file by its fileKey.
+func (i *Itisadb) updateFileStats(ctx context.Context, filename string, modify func(fs *fileStats)) error {
+	stats, err := i.client.Index(ctx, filesStatsIndex)
+	if err != nil {
+		return fmt.Errorf("failed to get files stats index: %w", err)
+	}
+
+	var fs fileStats
+	if raw, err := stats.Get(ctx, filename); err == nil && raw != "" {
+		if err = json.Unmarshal([]byte(raw), &fs); err != nil {
+			return fmt.Errorf("failed to decode stats of %s: %w", filename, err)
+		}
+	}
+
+	modify(&fs)
+
+	raw, err := json.Marshal(fs)
+	if err != nil {
+		return err
+	}
+
+	if err = stats.Set(ctx, filename, string(raw), false); err != nil {
+		return fmt.Errorf("failed to save stats of %s: %w", filename, err)
+	}
+
+	return nil
+}
+
+// unitCursor points to the oldest not pruned event of a unit.
+type unitCursor struct {
+	guid  string
+	index *itisadb.Index
+	head  int
+	size  int
+
+	// event is the head event, loaded lazily.
+	event  *events.Event
+	moved  bool
+	pruned int
+}
+
+// live returns the number of not pruned events of the unit.
+func (c *unitCursor) live() int {
+	return c.size - c.head + 1
+}
+
+// pruner collects pruned events and flushes them in batches.
+type pruner struct {
+	i       *Itisadb
+	policy  service.RetentionPolicy
+	units   *itisadb.Index
+	cursors []*unitCursor
+	batch   []events.Event
+	targets []target
+	deleted int
+}
+
+// target is the position of a batched event.
+type target struct {
+	cursor *unitCursor
+	number int
+}
+
+// Prune marks the events out of the retention policy as pruned in batches
+// and returns the number of pruned events.
+func (i *Itisadb) Prune(ctx context.Context, policy service.RetentionPolicy) (int, error) {
+	units, err := i.client.Index(ctx, unitsIndex)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get units index: %w", err)
+	}
+
+	heads, err := units.GetIndex(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get units: %w", err)
+	}
+
+	p := &pruner{i: i, policy: policy, units: units}
+	for guid, head := range heads {
+		idx, err := i.client.Index(ctx, guid)
+		if err != nil {
+			return 0, fmt.Errorf("failed to get unit index: %w", err)
+		}
+
+		size, err := idx.Size(ctx)
+		if err != nil {
+			return 0, fmt.Errorf("failed to get unit size: %w", err)
+		}
+
+		h, err := strconv.Atoi(head)
+		if err != nil || h < 1 {
+			h = 1
+		}
+
+		p.cursors = append(p.cursors, &unitCursor{guid: guid, index: idx, head: h, size: int(size)})
+	}
+
+	if err = p.run(ctx); err != nil {
+		return p.deleted, err
+	}
+
+	return p.deleted, nil
+}
+
+func (p *pruner) run(ctx context.Context) error {
+	if p.policy.MaxAge > 0 {
+		cutoff := time.Now().UTC().Add(-p.policy.MaxAge)
+		for _, c := range p.cursors {
+			for {
+				e, err := p.headEvent(ctx, c)
+				if err != nil {
+					return err
+				}
+				if e == nil || !e.IngestedAt.Before(cutoff) {
+					break
+				}
+				if err = p.prune(ctx, c); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if p.policy.MaxPerUnit > 0 {
+		for _, c := range p.cursors {
+			for c.live() > p.policy.MaxPerUnit {
+				if err := p.prune(ctx, c); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if p.policy.MaxEvents > 0 {
+		total := 0
+		for _, c := range p.cursors {
+			total += c.live()
+		}
+
+		for ; total > p.policy.MaxEvents; total-- {
+			oldest, err := p.oldest(ctx)
+			if err != nil {
+				return err
+			}
+			if oldest == nil {
+				break
+			}
+			if err = p.prune(ctx, oldest); err != nil {
+				return err
+			}
+		}
+	}
+
+	return p.flush(ctx)
+}
+
+// headEvent loads the oldest not pruned event of the unit, nil if there is none.
+func (p *pruner) headEvent(ctx context.Context, c *unitCursor) (*events.Event, error) {
+	for c.event == nil && c.head <= c.size {
+		e, pruned, err := p.i.readEvent(ctx, c.index, c.head)
+		if err != nil && !errors.Is(err, service.ErrEventNotFound) {
+			return nil, fmt.Errorf("failed to read event %s/%d: %w", c.guid, c.head, err)
+		}
+
+		if pruned || errors.Is(err, service.ErrEventNotFound) {
+			c.head++
+			c.moved = true
+			continue
+		}
+
+		c.event = &e
+	}
+
+	return c.event, nil
+}
+
+// oldest returns the unit whose head event was ingested first.
+func (p *pruner) oldest(ctx context.Context) (*unitCursor, error) {
+	var oldest *unitCursor
+	for _, c := range p.cursors {
+		e, err := p.headEvent(ctx, c)
+		if err != nil {
+			return nil, err
+		}
+		if e == nil {
+			continue
+		}
+		if oldest == nil || e.IngestedAt.Before(oldest.event.IngestedAt) {
+			oldest = c
+		}
+	}
+
+	return oldest, nil
+}
+
+// prune moves the head event of the unit into the batch.
+func (p *pruner) prune(ctx context.Context, c *unitCursor) error {
+	e, err := p.headEvent(ctx, c)
+	if err != nil || e == nil {
+		return err
+	}
+
+	p.batch = append(p.batch, *e)
+	p.targets = append(p.targets, target{cursor: c, number: c.head})
+	c.event = nil
+	c.head++
+	c.moved = true
+
+	if len(p.batch) >= p.policy.Batch() {
+		return p.flush(ctx)
+	}
+	return nil
+}
+
+// flush archives and marks the batch as pruned, then saves the heads and the files ledger.
+func (p *pruner) flush(ctx context.Context) error {
+	if len(p.batch) != 0 && p.policy.Archiver != nil {
+		if err := p.policy.Archiver.Archive(ctx, p.batch); err != nil {
+			return fmt.Errorf("failed to archive events: %w", err)
+		}
+	}
+
+	perFile := make(map[string]int)
+	for j, e := range p.batch {
+		t := p.targets[j]
+		if err := p.i.tombstone(ctx, t.cursor.index, t.number); err != nil {
+			return fmt.Errorf("failed to prune event %s/%d: %w", t.cursor.guid, t.number, err)
+		}
+		perFile[fileKey(e.Pipeline, e.SourceFile)]++
+		p.deleted++
+	}
+
+	for _, c := range p.cursors {
+		if !c.moved {
+			continue
+		}
+		if err := p.units.Set(ctx, c.guid, strconv.Itoa(c.head), false); err != nil {
+			return fmt.Errorf("failed to save head of %s: %w", c.guid, err)
+		}
+		c.moved = false
+	}
+
+	for file, n := range perFile {
+		n := n
+		err := p.i.updateFileStats(ctx, file, func(fs *fileStats) {
+			fs.Pruned += n
+			if p.policy.Archiver != nil {
+				fs.Archived += n
+			}
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	p.batch, p.targets = p.batch[:0], p.targets[:0]
+	return nil
+}
+
+// tombstone replaces the event with a pruned marker, so the numbering of the unit is kept.
+func (i *Itisadb) tombstone(ctx context.Context, guidIndex *itisadb.Index, number int) error {
+	return guidIndex.Set(ctx, strconv.Itoa(number), prunedValue, false)
+}
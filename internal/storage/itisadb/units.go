@@ -0,0 +1,67 @@
+package itisadb
+
+import (
+	"context"
+	"go-tsv-watcher/internal/events"
+	"go-tsv-watcher/internal/storage/service"
+	"sort"
+)
+
+// ListUnits returns the units of the pipeline sorted by guid, of all pipelines if the pipeline is empty.
+func (i *Itisadb) ListUnits(ctx context.Context, pipeline string) ([]service.Unit, error) {
+	index := make(map[string]*service.Unit)
+	err := i.iterEvents(ctx, nil, func(e events.Event) bool {
+		if pipeline != "" && e.Pipeline != pipeline {
+			return false
+		}
+		u, ok := index[e.UnitGUID]
+		if !ok {
+			u = &service.Unit{InventoryIDs: []string{}}
+			index[e.UnitGUID] = u
+		}
+		u.Add(e)
+		return false
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	units := make([]service.Unit, 0, len(index))
+	for _, u := range index {
+		units = append(units, *u)
+	}
+	sort.Slice(units, func(a, b int) bool { return units[a].GUID < units[b].GUID })
+
+	return units, nil
+}
+
+// GetUnit returns the unit with its events of the pipeline broken down by class and area,
+// the events of all pipelines are counted if the pipeline is empty.
+func (i *Itisadb) GetUnit(ctx context.Context, pipeline, guid string) (service.UnitSummary, error) {
+	guids, err := i.units(ctx)
+	if err != nil {
+		return service.UnitSummary{}, err
+	}
+
+	n := sort.SearchStrings(guids, guid)
+	if n == len(guids) || guids[n] != guid {
+		return service.UnitSummary{}, service.ErrUnitNotFound
+	}
+
+	summary := service.UnitSummary{Unit: service.Unit{InventoryIDs: []string{}}}
+	err = i.iterEvents(ctx, []string{guid}, func(e events.Event) bool {
+		if pipeline == "" || e.Pipeline == pipeline {
+			summary.Add(e)
+		}
+		return false
+	})
+	if err != nil {
+		return service.UnitSummary{}, err
+	}
+
+	if summary.Events == 0 {
+		return service.UnitSummary{}, service.ErrUnitNotFound
+	}
+
+	return summary, nil
+}
@@ -0,0 +1,180 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"go-tsv-watcher/internal/storage/postgres"
+	"go-tsv-watcher/internal/storage/sqlite"
+	"go-tsv-watcher/migrations"
+	"io/fs"
+)
+
+// ErrMigrationsUnsupported occurs when the storage has no schema to migrate.
+var ErrMigrationsUnsupported = errors.New("migrations are not supported by this storage")
+
+// ErrSchemaOutdated occurs when the schema is behind the embedded migrations.
+var ErrSchemaOutdated = errors.New("database schema is not up-to-date")
+
+// MigrationStatus describes the schema version of a storage.
+type MigrationStatus struct {
+	// Version is the currently applied version, 0 if none.
+	Version uint
+	// Latest is the newest version embedded into the binary.
+	Latest uint
+	// Dirty is true when the last migration failed halfway.
+	Dirty bool
+}
+
+// Migrator applies the embedded migrations to a sql like storage.
+type Migrator struct {
+	m      *migrate.Migrate
+	vendor string
+}
+
+// NewMigrator creates a Migrator for the storage described by cfg.
+func NewMigrator(cfg *Config) (*Migrator, error) {
+	if cfg.Type == "itisadb" {
+		return nil, ErrMigrationsUnsupported
+	}
+
+	db, err := open(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return newMigrator(cfg.Type, db)
+}
+
+func newMigrator(vendor string, db *sql.DB) (*Migrator, error) {
+	var m *migrate.Migrate
+	var err error
+
+	switch vendor {
+	case "postgres":
+		m, err = postgres.NewMigrate(db)
+	case "sqlite3":
+		m, err = sqlite.NewMigrate(db)
+	default:
+		return nil, ErrUnknownType
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare migrations: %w", err)
+	}
+
+	return &Migrator{m: m, vendor: vendor}, nil
+}
+
+// Up applies all pending migrations.
+func (m *Migrator) Up() error {
+	err := m.m.Up()
+	if err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return fmt.Errorf("failed to apply migrations: %w", err)
+	}
+	return nil
+}
+
+// Down rolls back the given number of migrations.
+func (m *Migrator) Down(steps int) error {
+	if steps <= 0 {
+		return fmt.Errorf("steps must be positive, got %d", steps)
+	}
+
+	err := m.m.Steps(-steps)
+	if err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return fmt.Errorf("failed to roll back migrations: %w", err)
+	}
+	return nil
+}
+
+// Force sets the schema version without running migrations and clears the dirty flag.
+func (m *Migrator) Force(version int) error {
+	if err := m.m.Force(version); err != nil {
+		return fmt.Errorf("failed to force version %d: %w", version, err)
+	}
+	return nil
+}
+
+// Status returns the applied and the latest available schema versions.
+func (m *Migrator) Status() (MigrationStatus, error) {
+	var status MigrationStatus
+
+	version, dirty, err := m.m.Version()
+	if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
+		return status, fmt.Errorf("failed to get version: %w", err)
+	}
+	status.Version, status.Dirty = version, dirty
+
+	status.Latest, err = latestVersion(m.vendor)
+	if err != nil {
+		return status, err
+	}
+
+	return status, nil
+}
+
+// Close closes the migrator and its database connection.
+func (m *Migrator) Close() error {
+	srcErr, dbErr := m.m.Close()
+	if srcErr != nil {
+		return srcErr
+	}
+	return dbErr
+}
+
+// versioned is the storage reporting the applied migration version.
+type versioned interface {
+	SchemaVersion(ctx context.Context) (version uint, dirty bool, err error)
+}
+
+// CheckSchema returns ErrSchemaOutdated if the embedded migrations of the vendor are not all applied
+// to the storage, nil for the storages without migrations.
+func CheckSchema(ctx context.Context, st Storage, vendor string) error {
+	v, ok := st.(versioned)
+	if !ok {
+		return nil
+	}
+
+	version, dirty, err := v.SchemaVersion(ctx)
+	if err != nil {
+		return err
+	}
+
+	latest, err := latestVersion(vendor)
+	if err != nil {
+		return err
+	}
+
+	if dirty || version != latest {
+		return fmt.Errorf("%w: version %d (dirty: %v), latest %d", ErrSchemaOutdated, version, dirty, latest)
+	}
+	return nil
+}
+
+// latestVersion returns the newest embedded migration version for vendor.
+func latestVersion(vendor string) (uint, error) {
+	src, err := iofs.New(migrations.FS, vendor)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open migrations: %w", err)
+	}
+	defer src.Close()
+
+	version, err := src.First()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	for {
+		next, err := src.Next(version)
+		if errors.Is(err, fs.ErrNotExist) {
+			return version, nil
+		}
+		if err != nil {
+			return 0, fmt.Errorf("failed to read migrations: %w", err)
+		}
+		version = next
+	}
+}
@@ -37,46 +37,266 @@ func (m *MockStorage) EXPECT() *MockStorageMockRecorder {
 }
 
 // AddFilename mocks base method.
-func (m *MockStorage) AddFilename(arg0 context.Context, arg1 string, arg2 error) error {
+func (m *MockStorage) AddFilename(arg0 context.Context, arg1, arg2 string, arg3 error) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "AddFilename", arg0, arg1, arg2)
+	ret := m.ctrl.Call(m, "AddFilename", arg0, arg1, arg2, arg3)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // AddFilename indicates an expected call of AddFilename.
-func (mr *MockStorageMockRecorder) AddFilename(arg0, arg1, arg2 interface{}) *gomock.Call {
+func (mr *MockStorageMockRecorder) AddFilename(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddFilename", reflect.TypeOf((*MockStorage)(nil).AddFilename), arg0, arg1, arg2)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddFilename", reflect.TypeOf((*MockStorage)(nil).AddFilename), arg0, arg1, arg2, arg3)
+}
+
+// Close mocks base method.
+func (m *MockStorage) Close() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Close")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Close indicates an expected call of Close.
+func (mr *MockStorageMockRecorder) Close() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MockStorage)(nil).Close))
+}
+
+// DeleteAPIKey mocks base method.
+func (m *MockStorage) DeleteAPIKey(arg0 context.Context, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteAPIKey", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteAPIKey indicates an expected call of DeleteAPIKey.
+func (mr *MockStorageMockRecorder) DeleteAPIKey(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteAPIKey", reflect.TypeOf((*MockStorage)(nil).DeleteAPIKey), arg0, arg1)
+}
+
+// DeleteFile mocks base method.
+func (m *MockStorage) DeleteFile(arg0 context.Context, arg1, arg2 string) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteFile", arg0, arg1, arg2)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteFile indicates an expected call of DeleteFile.
+func (mr *MockStorageMockRecorder) DeleteFile(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteFile", reflect.TypeOf((*MockStorage)(nil).DeleteFile), arg0, arg1, arg2)
+}
+
+// GetAPIKey mocks base method.
+func (m *MockStorage) GetAPIKey(arg0 context.Context, arg1 string) (service.APIKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAPIKey", arg0, arg1)
+	ret0, _ := ret[0].(service.APIKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAPIKey indicates an expected call of GetAPIKey.
+func (mr *MockStorageMockRecorder) GetAPIKey(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAPIKey", reflect.TypeOf((*MockStorage)(nil).GetAPIKey), arg0, arg1)
 }
 
 // GetEventByNumber mocks base method.
-func (m *MockStorage) GetEventByNumber(arg0 context.Context, arg1 string, arg2 int) (events.Event, error) {
+func (m *MockStorage) GetEventByNumber(arg0 context.Context, arg1, arg2 string, arg3 int) (events.Event, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetEventByNumber", arg0, arg1, arg2)
+	ret := m.ctrl.Call(m, "GetEventByNumber", arg0, arg1, arg2, arg3)
 	ret0, _ := ret[0].(events.Event)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetEventByNumber indicates an expected call of GetEventByNumber.
-func (mr *MockStorageMockRecorder) GetEventByNumber(arg0, arg1, arg2 interface{}) *gomock.Call {
+func (mr *MockStorageMockRecorder) GetEventByNumber(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetEventByNumber", reflect.TypeOf((*MockStorage)(nil).GetEventByNumber), arg0, arg1, arg2, arg3)
+}
+
+// GetFile mocks base method.
+func (m *MockStorage) GetFile(arg0 context.Context, arg1, arg2 string) (service.FileDetails, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetFile", arg0, arg1, arg2)
+	ret0, _ := ret[0].(service.FileDetails)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetFile indicates an expected call of GetFile.
+func (mr *MockStorageMockRecorder) GetFile(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetFile", reflect.TypeOf((*MockStorage)(nil).GetFile), arg0, arg1, arg2)
+}
+
+// GetUnit mocks base method.
+func (m *MockStorage) GetUnit(arg0 context.Context, arg1, arg2 string) (service.UnitSummary, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUnit", arg0, arg1, arg2)
+	ret0, _ := ret[0].(service.UnitSummary)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUnit indicates an expected call of GetUnit.
+func (mr *MockStorageMockRecorder) GetUnit(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUnit", reflect.TypeOf((*MockStorage)(nil).GetUnit), arg0, arg1, arg2)
+}
+
+// ListAPIKeys mocks base method.
+func (m *MockStorage) ListAPIKeys(arg0 context.Context) ([]service.APIKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListAPIKeys", arg0)
+	ret0, _ := ret[0].([]service.APIKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListAPIKeys indicates an expected call of ListAPIKeys.
+func (mr *MockStorageMockRecorder) ListAPIKeys(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListAPIKeys", reflect.TypeOf((*MockStorage)(nil).ListAPIKeys), arg0)
+}
+
+// ListEvents mocks base method.
+func (m *MockStorage) ListEvents(arg0 context.Context, arg1 service.EventsQuery) ([]events.Event, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListEvents", arg0, arg1)
+	ret0, _ := ret[0].([]events.Event)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListEvents indicates an expected call of ListEvents.
+func (mr *MockStorageMockRecorder) ListEvents(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListEvents", reflect.TypeOf((*MockStorage)(nil).ListEvents), arg0, arg1)
+}
+
+// ListFiles mocks base method.
+func (m *MockStorage) ListFiles(arg0 context.Context, arg1, arg2 string) ([]service.File, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListFiles", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]service.File)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListFiles indicates an expected call of ListFiles.
+func (mr *MockStorageMockRecorder) ListFiles(arg0, arg1, arg2 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetEventByNumber", reflect.TypeOf((*MockStorage)(nil).GetEventByNumber), arg0, arg1, arg2)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListFiles", reflect.TypeOf((*MockStorage)(nil).ListFiles), arg0, arg1, arg2)
+}
+
+// ListUnits mocks base method.
+func (m *MockStorage) ListUnits(arg0 context.Context, arg1 string) ([]service.Unit, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListUnits", arg0, arg1)
+	ret0, _ := ret[0].([]service.Unit)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListUnits indicates an expected call of ListUnits.
+func (mr *MockStorageMockRecorder) ListUnits(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListUnits", reflect.TypeOf((*MockStorage)(nil).ListUnits), arg0, arg1)
 }
 
 // LoadFilenames mocks base method.
-func (m *MockStorage) LoadFilenames(arg0 context.Context, arg1 service.Adder) error {
+func (m *MockStorage) LoadFilenames(arg0 context.Context, arg1 string, arg2 service.Adder) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "LoadFilenames", arg0, arg1)
+	ret := m.ctrl.Call(m, "LoadFilenames", arg0, arg1, arg2)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // LoadFilenames indicates an expected call of LoadFilenames.
-func (mr *MockStorageMockRecorder) LoadFilenames(arg0, arg1 interface{}) *gomock.Call {
+func (mr *MockStorageMockRecorder) LoadFilenames(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LoadFilenames", reflect.TypeOf((*MockStorage)(nil).LoadFilenames), arg0, arg1, arg2)
+}
+
+// LoadQuotas mocks base method.
+func (m *MockStorage) LoadQuotas(arg0 context.Context, arg1 string) (map[string]int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LoadQuotas", arg0, arg1)
+	ret0, _ := ret[0].(map[string]int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LoadQuotas indicates an expected call of LoadQuotas.
+func (mr *MockStorageMockRecorder) LoadQuotas(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LoadQuotas", reflect.TypeOf((*MockStorage)(nil).LoadQuotas), arg0, arg1)
+}
+
+// Ping mocks base method.
+func (m *MockStorage) Ping(arg0 context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Ping", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Ping indicates an expected call of Ping.
+func (mr *MockStorageMockRecorder) Ping(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Ping", reflect.TypeOf((*MockStorage)(nil).Ping), arg0)
+}
+
+// Prune mocks base method.
+func (m *MockStorage) Prune(arg0 context.Context, arg1 service.RetentionPolicy) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Prune", arg0, arg1)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Prune indicates an expected call of Prune.
+func (mr *MockStorageMockRecorder) Prune(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Prune", reflect.TypeOf((*MockStorage)(nil).Prune), arg0, arg1)
+}
+
+// ResetFile mocks base method.
+func (m *MockStorage) ResetFile(arg0 context.Context, arg1, arg2 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ResetFile", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ResetFile indicates an expected call of ResetFile.
+func (mr *MockStorageMockRecorder) ResetFile(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResetFile", reflect.TypeOf((*MockStorage)(nil).ResetFile), arg0, arg1, arg2)
+}
+
+// SaveAPIKey mocks base method.
+func (m *MockStorage) SaveAPIKey(arg0 context.Context, arg1 service.APIKey) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveAPIKey", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveAPIKey indicates an expected call of SaveAPIKey.
+func (mr *MockStorageMockRecorder) SaveAPIKey(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LoadFilenames", reflect.TypeOf((*MockStorage)(nil).LoadFilenames), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveAPIKey", reflect.TypeOf((*MockStorage)(nil).SaveAPIKey), arg0, arg1)
 }
 
 // SaveEvents mocks base method.
@@ -92,3 +312,31 @@ func (mr *MockStorageMockRecorder) SaveEvents(arg0, arg1 interface{}) *gomock.Ca
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveEvents", reflect.TypeOf((*MockStorage)(nil).SaveEvents), arg0, arg1)
 }
+
+// SaveFile mocks base method.
+func (m *MockStorage) SaveFile(arg0 context.Context, arg1 service.IEvents) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveFile", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveFile indicates an expected call of SaveFile.
+func (mr *MockStorageMockRecorder) SaveFile(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveFile", reflect.TypeOf((*MockStorage)(nil).SaveFile), arg0, arg1)
+}
+
+// SaveQuotas mocks base method.
+func (m *MockStorage) SaveQuotas(arg0 context.Context, arg1 string, arg2 map[string]int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveQuotas", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveQuotas indicates an expected call of SaveQuotas.
+func (mr *MockStorageMockRecorder) SaveQuotas(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveQuotas", reflect.TypeOf((*MockStorage)(nil).SaveQuotas), arg0, arg1, arg2)
+}
@@ -2,14 +2,13 @@ package postgres
 
 import (
 	"database/sql"
-	"errors"
+	"fmt"
+	"go-tsv-watcher/migrations"
 	"go-tsv-watcher/pkg/logger"
-	"log"
 
 	"github.com/golang-migrate/migrate/v4"
 	"github.com/golang-migrate/migrate/v4/database/postgres"
-	// File driver
-	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
 	// Postgres driver
 	_ "github.com/jackc/pgx"
 	"go-tsv-watcher/internal/storage/sqllike"
@@ -20,28 +19,27 @@ type Postgres struct {
 	sqllike.DB
 }
 
-// New Postgres constructor.
-func New(db *sql.DB, path string, logger logger.ILogger) *Postgres {
-	driver, err := postgres.WithInstance(db, &postgres.Config{})
+// New Postgres constructor, the queries are prepared for the db.
+func New(db *sql.DB, logger logger.ILogger) (*Postgres, error) {
+	bdb, err := sqllike.New(db, logger, sqllike.Dollar, "postgres")
 	if err != nil {
-		log.Fatal(err)
-		return nil
+		return nil, err
 	}
 
-	m, err := migrate.NewWithDatabaseInstance(
-		path,
-		"postgres", driver)
+	return &Postgres{DB: *bdb}, nil
+}
+
+// NewMigrate creates a migrate instance with the embedded postgres migrations.
+func NewMigrate(db *sql.DB) (*migrate.Migrate, error) {
+	driver, err := postgres.WithInstance(db, &postgres.Config{})
 	if err != nil {
-		log.Fatal(err)
-		return nil
+		return nil, fmt.Errorf("failed to create migrate driver: %w", err)
 	}
 
-	err = m.Up()
-	if err != nil && !errors.Is(err, migrate.ErrNoChange) {
-		log.Fatal(err)
+	src, err := iofs.New(migrations.FS, "postgres")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open migrations: %w", err)
 	}
 
-	bdb := sqllike.New(db, logger)
-
-	return &Postgres{DB: *bdb}
+	return migrate.NewWithInstance("iofs", src, "postgres", driver)
 }
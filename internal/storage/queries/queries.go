@@ -14,34 +14,189 @@ type Name int
 
 // AddFilename query for  adding filename.
 // SaveEvent query for saving event.
-// GetEvent query for getting event.
+// GetEvent query for getting event by its number within the unit.
+// ClaimNumbers query for claiming the next numbers of the unit.
+// AddFileEvents query for counting saved events of the file.
+// DeleteEvent query for deleting event by id.
+// AddFilePruned query for counting pruned and archived events of the file.
+// PruneByAge query for selecting events ingested before the time.
+// PruneUnits query for selecting units with too many events.
+// PruneUnit query for selecting the oldest events of the unit.
+// CountEvents query for counting all events.
+// PruneOldest query for selecting the oldest events.
+// UnitsStats query for counting events of every unit.
+// UnitsInventory query for selecting inventory ids of every unit.
+// UnitsLatestLevel query for selecting the level of the last event of every unit.
+// UnitStats query for counting events of the unit.
+// UnitInventory query for selecting inventory ids of the unit.
+// UnitLatestLevel query for selecting the level of the last event of the unit.
+// UnitBreakdown query for counting events of the unit by class and area.
+// ListFiles query for selecting all files.
+// GetFile query for selecting the file.
+// FileUnits query for counting stored events of the file by unit.
+// DeleteFileEvents query for deleting events of the file.
+// MarkFileDeleted query for marking the file as deleted.
+// RemoveFile query for removing the file record.
+// SaveAPIKey query for saving the API key.
+// ListAPIKeys query for selecting all API keys.
+// GetAPIKey query for selecting the API key by the hash of its secret.
+// DeleteAPIKey query for deleting the API key.
+// LoadQuotas query for selecting the used quotas of the day.
+// SaveQuota query for saving the used quota of the client for the day.
+// PruneQuotas query for deleting the used quotas of the other days.
+// SchemaVersion query for selecting the applied migration version.
 // Query names.
 const (
 	AddFilename = iota
 	SaveEvent
 	GetEvent
+	ClaimNumbers
+	AddFileEvents
+	DeleteEvent
+	AddFilePruned
+	PruneByAge
+	PruneUnits
+	PruneUnit
+	CountEvents
+	PruneOldest
+	UnitsStats
+	UnitsInventory
+	UnitsLatestLevel
+	UnitStats
+	UnitInventory
+	UnitLatestLevel
+	UnitBreakdown
+	ListFiles
+	GetFile
+	FileUnits
+	DeleteFileEvents
+	MarkFileDeleted
+	RemoveFile
+	SaveAPIKey
+	ListAPIKeys
+	GetAPIKey
+	DeleteAPIKey
+	LoadQuotas
+	SaveQuota
+	PruneQuotas
+	SchemaVersion
 )
 
+// EventColumns is the list of events columns in the order they are scanned.
+const EventColumns = `ID, Number, MQTT, InventoryID, UnitGUID, MessageID, MessageText,
+       Context, MessageClass, Level, Area, Address, Block, Type, Bit, InvertBit,
+       SourceFile, IngestedAt, Pipeline`
+
 var queriesSqlite3 = map[Name]Query{
-	AddFilename: "INSERT INTO files (name, error) VALUES (?, ?)",
+	AddFilename: "INSERT INTO files (pipeline, name, error) VALUES (?, ?, ?)",
 	SaveEvent: `INSERT INTO events (ID,
                      Number, MQTT ,InventoryID, 
                      UnitGUID, MessageID, MessageText,
                      Context  ,MessageClass, Level, 
                      Area, Address , Block, Type, Bit, 
-                     InvertBit) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
-	GetEvent: "SELECT * FROM events WHERE UnitGUID = ? LIMIT 1 OFFSET ?",
+                     InvertBit, SourceFile, IngestedAt, Pipeline, Seq) 
+                     VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+	GetEvent: "SELECT " + EventColumns + " FROM events WHERE UnitGUID = ?1 AND (?2 = '' OR Pipeline = ?2) AND Seq = ?3",
+	ClaimNumbers: "INSERT INTO unit_numbers (unit_guid, last_number) VALUES (?1, ?2) " +
+		"ON CONFLICT (unit_guid) DO UPDATE SET last_number = unit_numbers.last_number + ?2 RETURNING last_number",
+	AddFileEvents: "UPDATE files SET events = events + ? WHERE pipeline = ? AND name = ?",
+	DeleteEvent:   "DELETE FROM events WHERE ID = ?",
+	AddFilePruned: "UPDATE files SET pruned = pruned + ?, archived = archived + ? WHERE pipeline = ? AND name = ?",
+	PruneByAge: "SELECT " + EventColumns + " FROM events WHERE IngestedAt < ? " +
+		"ORDER BY IngestedAt, Number LIMIT ?",
+	PruneUnits: "SELECT UnitGUID, COUNT(*) FROM events GROUP BY UnitGUID HAVING COUNT(*) > ?",
+	PruneUnit: "SELECT " + EventColumns + " FROM events WHERE UnitGUID = ? " +
+		"ORDER BY IngestedAt, Number LIMIT ?",
+	CountEvents: "SELECT COUNT(*) FROM events",
+	PruneOldest: "SELECT " + EventColumns + " FROM events ORDER BY IngestedAt, Number LIMIT ?",
+	UnitsStats: "SELECT UnitGUID, COUNT(*), MIN(IngestedAt), MAX(IngestedAt) FROM events " +
+		"WHERE (?1 = '' OR Pipeline = ?1) GROUP BY UnitGUID ORDER BY UnitGUID",
+	UnitsInventory: "SELECT DISTINCT UnitGUID, InventoryID FROM events WHERE (?1 = '' OR Pipeline = ?1)",
+	UnitsLatestLevel: `SELECT UnitGUID, Level FROM (
+                     SELECT UnitGUID, Level, ROW_NUMBER() OVER (
+                         PARTITION BY UnitGUID ORDER BY IngestedAt DESC, Number DESC) AS n
+                     FROM events WHERE (?1 = '' OR Pipeline = ?1)) latest WHERE n = 1`,
+	UnitStats: "SELECT UnitGUID, COUNT(*), MIN(IngestedAt), MAX(IngestedAt) FROM events " +
+		"WHERE UnitGUID = ?1 AND (?2 = '' OR Pipeline = ?2) GROUP BY UnitGUID",
+	UnitInventory: "SELECT DISTINCT UnitGUID, InventoryID FROM events " +
+		"WHERE UnitGUID = ?1 AND (?2 = '' OR Pipeline = ?2)",
+	UnitLatestLevel: "SELECT UnitGUID, Level FROM events WHERE UnitGUID = ?1 AND (?2 = '' OR Pipeline = ?2) " +
+		"ORDER BY IngestedAt DESC, Number DESC LIMIT 1",
+	UnitBreakdown: "SELECT MessageClass, Area, COUNT(*) FROM events " +
+		"WHERE UnitGUID = ?1 AND (?2 = '' OR Pipeline = ?2) GROUP BY MessageClass, Area",
+	ListFiles: "SELECT pipeline, name, error, events, pruned, archived, deleted_at FROM files " +
+		"WHERE (?1 = '' OR pipeline = ?1) ORDER BY name, pipeline",
+	GetFile: "SELECT pipeline, name, error, events, pruned, archived, deleted_at FROM files " +
+		"WHERE pipeline = ?1 AND name = ?2",
+	FileUnits: "SELECT UnitGUID, COUNT(*) FROM events WHERE Pipeline = ?1 AND SourceFile = ?2 " +
+		"GROUP BY UnitGUID ORDER BY UnitGUID",
+	DeleteFileEvents: "DELETE FROM events WHERE Pipeline = ?1 AND SourceFile = ?2",
+	MarkFileDeleted:  "UPDATE files SET deleted_at = ?1 WHERE pipeline = ?2 AND name = ?3",
+	RemoveFile:       "DELETE FROM files WHERE pipeline = ?1 AND name = ?2",
+	SaveAPIKey:       "INSERT INTO api_keys (id, name, hash, scopes, created_at) VALUES (?, ?, ?, ?, ?)",
+	ListAPIKeys:      "SELECT id, name, hash, scopes, created_at FROM api_keys ORDER BY created_at, id",
+	GetAPIKey:        "SELECT id, name, hash, scopes, created_at FROM api_keys WHERE hash = ?",
+	DeleteAPIKey:     "DELETE FROM api_keys WHERE id = ?",
+	LoadQuotas:       "SELECT client, used FROM quotas WHERE day = ?",
+	SaveQuota:        "INSERT INTO quotas (client, day, used) VALUES (?, ?, ?) ON CONFLICT (client, day) DO UPDATE SET used = excluded.used",
+	PruneQuotas:      "DELETE FROM quotas WHERE day <> ?",
+	SchemaVersion:    "SELECT version, dirty FROM schema_migrations LIMIT 1",
 }
 
 var queriesPostgres = map[Name]Query{
-	AddFilename: "INSERT INTO files (name,error) VALUES ($1,$2)",
+	AddFilename: "INSERT INTO files (pipeline, name, error) VALUES ($1, $2, $3)",
 	SaveEvent: `INSERT INTO events (ID,
                      Number, MQTT ,InventoryID, 
                      UnitGUID, MessageID, MessageText,
                      Context  ,MessageClass, Level, 
                      Area, Address , Block, Type, Bit, 
-                     InvertBit) VALUES ($1::uuid, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)`,
-	GetEvent: "SELECT * FROM events WHERE UnitGUID = $1 LIMIT 1 OFFSET $2",
+                     InvertBit, SourceFile, IngestedAt, Pipeline, Seq) 
+                     VALUES ($1::uuid, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20)`,
+	GetEvent: "SELECT " + EventColumns + " FROM events WHERE UnitGUID = $1 AND ($2::text = '' OR Pipeline = $2) AND Seq = $3",
+	ClaimNumbers: "INSERT INTO unit_numbers (unit_guid, last_number) VALUES ($1, $2) " +
+		"ON CONFLICT (unit_guid) DO UPDATE SET last_number = unit_numbers.last_number + $2 RETURNING last_number",
+	AddFileEvents: "UPDATE files SET events = events + $1 WHERE pipeline = $2 AND name = $3",
+	DeleteEvent:   "DELETE FROM events WHERE ID = $1::uuid",
+	AddFilePruned: "UPDATE files SET pruned = pruned + $1, archived = archived + $2 WHERE pipeline = $3 AND name = $4",
+	PruneByAge: "SELECT " + EventColumns + " FROM events WHERE IngestedAt < $1 " +
+		"ORDER BY IngestedAt, Number LIMIT $2",
+	PruneUnits: "SELECT UnitGUID, COUNT(*) FROM events GROUP BY UnitGUID HAVING COUNT(*) > $1",
+	PruneUnit: "SELECT " + EventColumns + " FROM events WHERE UnitGUID = $1 " +
+		"ORDER BY IngestedAt, Number LIMIT $2",
+	CountEvents: "SELECT COUNT(*) FROM events",
+	PruneOldest: "SELECT " + EventColumns + " FROM events ORDER BY IngestedAt, Number LIMIT $1",
+	UnitsStats: "SELECT UnitGUID, COUNT(*), MIN(IngestedAt), MAX(IngestedAt) FROM events " +
+		"WHERE ($1::text = '' OR Pipeline = $1) GROUP BY UnitGUID ORDER BY UnitGUID",
+	UnitsInventory: "SELECT DISTINCT UnitGUID, InventoryID FROM events WHERE ($1::text = '' OR Pipeline = $1)",
+	UnitsLatestLevel: `SELECT UnitGUID, Level FROM (
+                     SELECT UnitGUID, Level, ROW_NUMBER() OVER (
+                         PARTITION BY UnitGUID ORDER BY IngestedAt DESC, Number DESC) AS n
+                     FROM events WHERE ($1::text = '' OR Pipeline = $1)) latest WHERE n = 1`,
+	UnitStats: "SELECT UnitGUID, COUNT(*), MIN(IngestedAt), MAX(IngestedAt) FROM events " +
+		"WHERE UnitGUID = $1 AND ($2::text = '' OR Pipeline = $2) GROUP BY UnitGUID",
+	UnitInventory: "SELECT DISTINCT UnitGUID, InventoryID FROM events " +
+		"WHERE UnitGUID = $1 AND ($2::text = '' OR Pipeline = $2)",
+	UnitLatestLevel: "SELECT UnitGUID, Level FROM events WHERE UnitGUID = $1 AND ($2::text = '' OR Pipeline = $2) " +
+		"ORDER BY IngestedAt DESC, Number DESC LIMIT 1",
+	UnitBreakdown: "SELECT MessageClass, Area, COUNT(*) FROM events " +
+		"WHERE UnitGUID = $1 AND ($2::text = '' OR Pipeline = $2) GROUP BY MessageClass, Area",
+	ListFiles: "SELECT pipeline, name, error, events, pruned, archived, deleted_at FROM files " +
+		"WHERE ($1::text = '' OR pipeline = $1) ORDER BY name, pipeline",
+	GetFile: "SELECT pipeline, name, error, events, pruned, archived, deleted_at FROM files " +
+		"WHERE pipeline = $1 AND name = $2",
+	FileUnits: "SELECT UnitGUID, COUNT(*) FROM events WHERE Pipeline = $1 AND SourceFile = $2 " +
+		"GROUP BY UnitGUID ORDER BY UnitGUID",
+	DeleteFileEvents: "DELETE FROM events WHERE Pipeline = $1 AND SourceFile = $2",
+	MarkFileDeleted:  "UPDATE files SET deleted_at = $1 WHERE pipeline = $2 AND name = $3",
+	RemoveFile:       "DELETE FROM files WHERE pipeline = $1 AND name = $2",
+	SaveAPIKey:       "INSERT INTO api_keys (id, name, hash, scopes, created_at) VALUES ($1, $2, $3, $4, $5)",
+	ListAPIKeys:      "SELECT id, name, hash, scopes, created_at FROM api_keys ORDER BY created_at, id",
+	GetAPIKey:        "SELECT id, name, hash, scopes, created_at FROM api_keys WHERE hash = $1",
+	DeleteAPIKey:     "DELETE FROM api_keys WHERE id = $1",
+	LoadQuotas:       "SELECT client, used FROM quotas WHERE day = $1",
+	SaveQuota:        "INSERT INTO quotas (client, day, used) VALUES ($1, $2, $3) ON CONFLICT (client, day) DO UPDATE SET used = excluded.used",
+	PruneQuotas:      "DELETE FROM quotas WHERE day <> $1",
+	SchemaVersion:    "SELECT version, dirty FROM schema_migrations LIMIT 1",
 }
 
 // ErrNotFound occurs when query was not found.
@@ -50,10 +205,13 @@ var ErrNotFound = errors.New("the query was not found")
 // ErrNilStatement occurs query statement is nil.
 var ErrNilStatement = errors.New("query statement is nil")
 
-var statements = make(map[Name]*sql.Stmt, 10)
+// Statements are the prepared queries of a database.
+type Statements struct {
+	statements map[Name]*sql.Stmt
+}
 
 // Prepare prepares all queries for db instance.
-func Prepare(DB *sql.DB, vendor string) error {
+func Prepare(DB *sql.DB, vendor string) (*Statements, error) {
 	var queries map[Name]Query
 	switch vendor {
 	case "sqlite3":
@@ -62,19 +220,21 @@ func Prepare(DB *sql.DB, vendor string) error {
 		queries = queriesPostgres
 	}
 
+	s := &Statements{statements: make(map[Name]*sql.Stmt, len(queries))}
 	for n, q := range queries {
 		prep, err := DB.Prepare(string(q))
 		if err != nil {
-			return err
+			s.Close()
+			return nil, err
 		}
-		statements[n] = prep
+		s.statements[n] = prep
 	}
-	return nil
+	return s, nil
 }
 
-// GetPreparedStatement returns *sql.Stmt by name of query.
-func GetPreparedStatement(name int) (*sql.Stmt, error) {
-	stmt, ok := statements[Name(name)]
+// Get returns *sql.Stmt by name of query.
+func (s *Statements) Get(name int) (*sql.Stmt, error) {
+	stmt, ok := s.statements[Name(name)]
 	if !ok {
 		return nil, ErrNotFound
 	}
@@ -87,8 +247,8 @@ func GetPreparedStatement(name int) (*sql.Stmt, error) {
 }
 
 // Close closes all prepared statements.
-func Close() error {
-	for _, stmt := range statements {
+func (s *Statements) Close() error {
+	for _, stmt := range s.statements {
 		err := stmt.Close()
 		if err != nil {
 			return fmt.Errorf("error closing statement: %w", err)
@@ -0,0 +1,19 @@
+package service
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrAPIKeyNotFound error for not found API key
+var ErrAPIKeyNotFound = errors.New("api key not found")
+
+// APIKey is a stored API key, only the hash of its secret is kept.
+type APIKey struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// Hash is the hex SHA-256 of the secret.
+	Hash      string    `json:"-"`
+	Scopes    []string  `json:"scopes"`
+	CreatedAt time.Time `json:"created_at"`
+}
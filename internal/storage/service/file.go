@@ -0,0 +1,60 @@
+package service
+
+import (
+	"errors"
+	"time"
+)
+
+// File statuses.
+const (
+	FileOK      = "ok"
+	FileFailed  = "failed"
+	FileDeleted = "deleted"
+)
+
+// ErrFileNotFound error for not found file
+var ErrFileNotFound = errors.New("file not found")
+
+// File is the ingestion record of a file.
+type File struct {
+	// Pipeline ingested the file, the same name may be ingested by several pipelines.
+	Pipeline string `json:"pipeline"`
+	Name     string `json:"name"`
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	// Events is the number of saved events, Pruned and Archived count the removed ones.
+	Events    int        `json:"events"`
+	Pruned    int        `json:"pruned"`
+	Archived  int        `json:"archived"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+// FileDetails is the file with its stored events and produced reports.
+type FileDetails struct {
+	File
+	// Stored is the number of events of the file still in the storage.
+	Stored int      `json:"stored"`
+	Units  []string `json:"units"`
+	PDFs   []string `json:"pdfs"`
+}
+
+// NewFile creates the file record and sets its status.
+func NewFile(name, errMsg string, deletedAt *time.Time) File {
+	f := File{Name: name, Error: errMsg, DeletedAt: deletedAt, Status: FileOK}
+	switch {
+	case deletedAt != nil:
+		f.Status = FileDeleted
+	case errMsg != "":
+		f.Status = FileFailed
+	}
+	return f
+}
+
+// ValidFileStatus reports whether the status filter is known, empty matches all files.
+func ValidFileStatus(status string) bool {
+	switch status {
+	case "", FileOK, FileFailed, FileDeleted:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,244 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"go-tsv-watcher/internal/events"
+	"strconv"
+	"time"
+)
+
+// Sort fields of events.
+const (
+	SortIngestedAt   = "ingested_at"
+	SortNumber       = "number"
+	SortLevel        = "level"
+	SortUnitGUID     = "unit_guid"
+	SortMessageClass = "message_class"
+)
+
+// SortFields maps sort fields to their event columns.
+var SortFields = map[string]string{
+	SortIngestedAt:   "IngestedAt",
+	SortNumber:       "Number",
+	SortLevel:        "Level",
+	SortUnitGUID:     "UnitGUID",
+	SortMessageClass: "MessageClass",
+}
+
+// ErrInvalidQuery occurs when the query can't be executed.
+var ErrInvalidQuery = errors.New("invalid query")
+
+// EventFilter describes which events are selected.
+// Zero fields are not used.
+type EventFilter struct {
+	UnitGUID     string
+	InventoryID  string
+	MessageClass string
+	MessageID    string
+	Area         string
+	SourceFile   string
+	Pipeline     string
+	MinLevel     *int
+	MaxLevel     *int
+	// From and To limit the ingestion time to [From, To).
+	From time.Time
+	To   time.Time
+}
+
+// Match reports whether the event satisfies the filter.
+func (f EventFilter) Match(e events.Event) bool {
+	switch {
+	case f.UnitGUID != "" && e.UnitGUID != f.UnitGUID,
+		f.InventoryID != "" && e.InventoryID != f.InventoryID,
+		f.MessageClass != "" && e.MessageClass != f.MessageClass,
+		f.MessageID != "" && e.MessageID != f.MessageID,
+		f.Area != "" && e.Area != f.Area,
+		f.SourceFile != "" && e.SourceFile != f.SourceFile,
+		f.Pipeline != "" && e.Pipeline != f.Pipeline,
+		f.MinLevel != nil && e.Level < *f.MinLevel,
+		f.MaxLevel != nil && e.Level > *f.MaxLevel,
+		!f.From.IsZero() && e.IngestedAt.Before(f.From),
+		!f.To.IsZero() && !e.IngestedAt.Before(f.To):
+		return false
+	}
+	return true
+}
+
+// Cursor points to the last event of a page.
+type Cursor struct {
+	// Value of the sort field.
+	Value string `json:"v"`
+	// Number and then ID break ties between equal values,
+	// so the events of a file ingested at once keep the order of the file.
+	Number int    `json:"n,omitempty"`
+	ID     string `json:"id"`
+}
+
+// CursorOf returns the cursor pointing to the event in the order of the sort field.
+func CursorOf(e events.Event, sortBy string) *Cursor {
+	return &Cursor{Value: SortValue(e, sortBy), Number: e.Number, ID: e.ID}
+}
+
+// EventsQuery describes a page of events.
+type EventsQuery struct {
+	Filter EventFilter
+	// SortBy is one of SortFields, SortIngestedAt by default.
+	SortBy string
+	Desc   bool
+	Limit  int
+	// After is the cursor of the previous page, nil for the first one.
+	After *Cursor
+}
+
+// EventsPage is a page of events.
+type EventsPage struct {
+	Events []events.Event
+	// Next is the cursor of the next page, nil for the last one.
+	Next *Cursor
+}
+
+// Column returns the event column the query is sorted by.
+func (q EventsQuery) Column() (string, error) {
+	if q.SortBy == "" {
+		return SortFields[SortIngestedAt], nil
+	}
+
+	column, ok := SortFields[q.SortBy]
+	if !ok {
+		return "", ErrInvalidQuery
+	}
+	return column, nil
+}
+
+// SortValue returns the value of the sort field of the event.
+func SortValue(e events.Event, sortBy string) string {
+	switch sortBy {
+	case SortNumber:
+		return strconv.Itoa(e.Number)
+	case SortLevel:
+		return strconv.Itoa(e.Level)
+	case SortUnitGUID:
+		return e.UnitGUID
+	case SortMessageClass:
+		return e.MessageClass
+	default:
+		return e.IngestedAt.UTC().Format(time.RFC3339Nano)
+	}
+}
+
+// CursorArg converts the cursor value to the type of the sort field.
+func CursorArg(c *Cursor, sortBy string) (any, error) {
+	switch sortBy {
+	case SortNumber, SortLevel:
+		n, err := strconv.Atoi(c.Value)
+		if err != nil {
+			return nil, ErrInvalidQuery
+		}
+		return n, nil
+	case SortUnitGUID, SortMessageClass:
+		return c.Value, nil
+	default:
+		t, err := time.Parse(time.RFC3339Nano, c.Value)
+		if err != nil {
+			return nil, ErrInvalidQuery
+		}
+		return t.UTC(), nil
+	}
+}
+
+// Less reports whether a goes before b in the order of the query.
+func (q EventsQuery) Less(a, b events.Event) bool {
+	var cmp int
+	switch q.SortBy {
+	case SortNumber:
+		cmp = a.Number - b.Number
+	case SortLevel:
+		cmp = a.Level - b.Level
+	case SortUnitGUID:
+		cmp = compareStrings(a.UnitGUID, b.UnitGUID)
+	case SortMessageClass:
+		cmp = compareStrings(a.MessageClass, b.MessageClass)
+	default:
+		switch {
+		case a.IngestedAt.Before(b.IngestedAt):
+			cmp = -1
+		case a.IngestedAt.After(b.IngestedAt):
+			cmp = 1
+		}
+	}
+
+	if cmp == 0 {
+		cmp = a.Number - b.Number
+	}
+	if cmp == 0 {
+		cmp = compareStrings(a.ID, b.ID)
+	}
+
+	if q.Desc {
+		return cmp > 0
+	}
+	return cmp < 0
+}
+
+// IsAfter reports whether the event goes after the cursor of the query.
+func (q EventsQuery) IsAfter(e events.Event) bool {
+	if q.After == nil {
+		return true
+	}
+
+	arg, err := CursorArg(q.After, q.SortBy)
+	if err != nil {
+		return false
+	}
+
+	last := events.Event{Number: q.After.Number, ID: q.After.ID}
+	switch v := arg.(type) {
+	case int:
+		if q.SortBy == SortNumber {
+			last.Number = v
+		}
+		last.Level = v
+	case string:
+		last.UnitGUID, last.MessageClass = v, v
+	case time.Time:
+		last.IngestedAt = v
+	}
+
+	return q.Less(last, e)
+}
+
+func compareStrings(a, b string) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// EncodeCursor returns an opaque representation of the cursor.
+func EncodeCursor(c *Cursor) string {
+	if c == nil {
+		return ""
+	}
+
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// DecodeCursor parses the cursor returned by EncodeCursor.
+func DecodeCursor(s string) (*Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidQuery
+	}
+
+	var c Cursor
+	if err = json.Unmarshal(raw, &c); err != nil || c.ID == "" {
+		return nil, ErrInvalidQuery
+	}
+	return &c, nil
+}
@@ -1,8 +1,10 @@
 package service
 
 import (
+	"context"
 	"errors"
 	"go-tsv-watcher/internal/events"
+	"time"
 )
 
 // Adder common interface for adding files
@@ -14,8 +16,42 @@ type Adder interface {
 type IEvents interface {
 	Fill() error
 	Print()
+	Source() string
+	Pipeline() string
 	Iter(cb func(d events.Event) (stop bool))
 }
 
+// Archiver common interface for exporting events before they are pruned
+type Archiver interface {
+	Archive(ctx context.Context, evs []events.Event) error
+}
+
+// RetentionPolicy describes which events are pruned.
+// Zero limits are disabled.
+type RetentionPolicy struct {
+	// MaxAge of an event since it was ingested.
+	MaxAge time.Duration
+	// MaxPerUnit events kept for every unit, the oldest are pruned first.
+	MaxPerUnit int
+	// MaxEvents kept in total, the oldest are pruned first. The total size is counted in events
+	// rather than bytes, the backends don't share a way to measure the stored bytes.
+	MaxEvents int
+	// BatchSize is the number of events deleted at once.
+	BatchSize int
+	// Archiver exports events before deletion, nil to skip.
+	Archiver Archiver
+}
+
+// DefaultPruneBatchSize is used when RetentionPolicy.BatchSize is not set.
+const DefaultPruneBatchSize = 500
+
+// Batch returns the batch size of the policy.
+func (p RetentionPolicy) Batch() int {
+	if p.BatchSize <= 0 {
+		return DefaultPruneBatchSize
+	}
+	return p.BatchSize
+}
+
 // ErrEventNotFound error for not found event
 var ErrEventNotFound = errors.New("event not found")
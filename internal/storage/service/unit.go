@@ -0,0 +1,80 @@
+package service
+
+import (
+	"errors"
+	"go-tsv-watcher/internal/events"
+	"sort"
+	"time"
+)
+
+// ErrUnitNotFound error for not found unit
+var ErrUnitNotFound = errors.New("unit not found")
+
+// Unit is the registry entry of a unit derived from its events.
+type Unit struct {
+	GUID         string    `json:"unit_guid"`
+	InventoryIDs []string  `json:"inventory_ids"`
+	Events       int       `json:"events"`
+	FirstSeen    time.Time `json:"first_seen"`
+	LastSeen     time.Time `json:"last_seen"`
+	// LatestLevel is the level of the last ingested event.
+	LatestLevel int `json:"latest_level"`
+
+	// latest is the last ingested event, used by Add.
+	latest *events.Event
+}
+
+// UnitSummary is the unit with its events broken down by class and area.
+type UnitSummary struct {
+	Unit
+	ByMessageClass map[string]int `json:"by_message_class"`
+	ByArea         map[string]int `json:"by_area"`
+}
+
+// Add accounts the event in the unit.
+func (u *Unit) Add(e events.Event) {
+	if u.Events == 0 || e.IngestedAt.Before(u.FirstSeen) {
+		u.FirstSeen = e.IngestedAt
+	}
+	if u.Events == 0 || e.IngestedAt.After(u.LastSeen) {
+		u.LastSeen = e.IngestedAt
+	}
+
+	if u.latest == nil || e.IngestedAt.After(u.latest.IngestedAt) ||
+		e.IngestedAt.Equal(u.latest.IngestedAt) && e.Number > u.latest.Number {
+		u.latest = &e
+		u.LatestLevel = e.Level
+	}
+
+	u.GUID = e.UnitGUID
+	u.Events++
+	u.AddInventoryID(e.InventoryID)
+}
+
+// AddInventoryID adds the inventory id keeping the list sorted and unique.
+func (u *Unit) AddInventoryID(id string) {
+	if id == "" {
+		return
+	}
+
+	i := sort.SearchStrings(u.InventoryIDs, id)
+	if i < len(u.InventoryIDs) && u.InventoryIDs[i] == id {
+		return
+	}
+
+	u.InventoryIDs = append(u.InventoryIDs, "")
+	copy(u.InventoryIDs[i+1:], u.InventoryIDs[i:])
+	u.InventoryIDs[i] = id
+}
+
+// Add accounts the event in the summary.
+func (s *UnitSummary) Add(e events.Event) {
+	if s.ByMessageClass == nil {
+		s.ByMessageClass = make(map[string]int)
+		s.ByArea = make(map[string]int)
+	}
+
+	s.Unit.Add(e)
+	s.ByMessageClass[e.MessageClass]++
+	s.ByArea[e.Area]++
+}
@@ -2,19 +2,17 @@ package sqlite
 
 import (
 	"database/sql"
-	"github.com/pkg/errors"
+	"fmt"
 	"go-tsv-watcher/internal/storage/sqllike"
+	"go-tsv-watcher/migrations"
 	"go-tsv-watcher/pkg/logger"
-	"log"
 
 	// SQLite driver
 	_ "modernc.org/sqlite"
 
 	"github.com/golang-migrate/migrate/v4"
 	"github.com/golang-migrate/migrate/v4/database/sqlite"
-
-	// file driver
-	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
 )
 
 // Sqlite3 struct for the sqlite3 database.
@@ -22,28 +20,27 @@ type Sqlite3 struct {
 	sqllike.DB
 }
 
-// New Sqlite3 constructor.
-func New(db *sql.DB, path string, logger logger.ILogger) *Sqlite3 {
-	driver, err := sqlite.WithInstance(db, &sqlite.Config{})
+// New Sqlite3 constructor, the queries are prepared for the db.
+func New(db *sql.DB, logger logger.ILogger) (*Sqlite3, error) {
+	bdb, err := sqllike.New(db, logger, sqllike.Question, "sqlite3")
 	if err != nil {
-		log.Fatal(err)
-		return nil
+		return nil, err
 	}
 
-	m, err := migrate.NewWithDatabaseInstance(
-		path,
-		"sqlite", driver)
+	return &Sqlite3{DB: *bdb}, nil
+}
+
+// NewMigrate creates a migrate instance with the embedded sqlite3 migrations.
+func NewMigrate(db *sql.DB) (*migrate.Migrate, error) {
+	driver, err := sqlite.WithInstance(db, &sqlite.Config{})
 	if err != nil {
-		log.Fatal(err)
-		return nil
+		return nil, fmt.Errorf("failed to create migrate driver: %w", err)
 	}
 
-	err = m.Up()
-	if err != nil && !errors.Is(err, migrate.ErrNoChange) {
-		log.Fatal(err)
+	src, err := iofs.New(migrations.FS, "sqlite3")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open migrations: %w", err)
 	}
 
-	bdb := sqllike.New(db, logger)
-
-	return &Sqlite3{DB: *bdb}
+	return migrate.NewWithInstance("iofs", src, "sqlite", driver)
 }
@@ -4,15 +4,19 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"github.com/docker/distribution/uuid"
 	"github.com/go-chi/httplog"
 	"go-tsv-watcher/internal/events"
-	"go-tsv-watcher/internal/storage/queries"
+	"go-tsv-watcher/internal/storage"
+	"go-tsv-watcher/internal/storage/service"
 	"go-tsv-watcher/internal/storage/sqlite"
 	"go-tsv-watcher/pkg/logger"
 	"log"
 	"os"
+	"reflect"
 	"testing"
+	"time"
 )
 
 var st *sqlite.Sqlite3
@@ -30,9 +34,17 @@ func TestMain(m *testing.M) {
 		Concise: true,
 	})
 
-	st = sqlite.New(db, "file://..//..//..//migrations/sqlite3", logger.New(loggerInstance))
+	mig, err := sqlite.NewMigrate(db)
+	if err != nil {
+		log.Fatalf("can't create migrate: %v", err)
+	}
+
+	err = mig.Up()
+	if err != nil {
+		log.Fatalf("can't apply migrations: %v", err)
+	}
 
-	err = queries.Prepare(db, "sqlite3")
+	st, err = sqlite.New(db, logger.New(loggerInstance))
 	if err != nil {
 		log.Fatalf("error preparing db: %v", err)
 	}
@@ -92,7 +104,7 @@ func TestAddFilename(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := st.AddFilename(context.Background(), tt.filename, tt.err)
+			err := st.AddFilename(context.Background(), events.DefaultPipeline, tt.filename, tt.err)
 			if (err != nil) != tt.wantError {
 				t.Errorf("error adding filename: %v", err)
 			}
@@ -104,7 +116,7 @@ func TestAddFilename(t *testing.T) {
 
 		a := &addStub{}
 
-		err := st.LoadFilenames(context.Background(), a)
+		err := st.LoadFilenames(context.Background(), events.DefaultPipeline, a)
 		if err != nil {
 			t.Fatalf("error loading filenames: %v", err)
 		}
@@ -156,7 +168,7 @@ func TestLoadFilenames(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := st.AddFilename(context.Background(), tt.filename, tt.err)
+			err := st.AddFilename(context.Background(), events.DefaultPipeline, tt.filename, tt.err)
 			if (err != nil) != tt.wantError {
 				t.Errorf("error adding filename: %v", err)
 			}
@@ -165,7 +177,7 @@ func TestLoadFilenames(t *testing.T) {
 
 	a := &addStub{}
 
-	err = st.LoadFilenames(context.Background(), a)
+	err = st.LoadFilenames(context.Background(), events.DefaultPipeline, a)
 
 	if err != nil {
 		t.Fatalf("error loading filenames: %v", err)
@@ -201,6 +213,16 @@ func (i ieventsStub) Print() {
 
 }
 
+// Source unused
+func (i ieventsStub) Source() string {
+	return ""
+}
+
+// Pipeline returns the default pipeline.
+func (i ieventsStub) Pipeline() string {
+	return events.DefaultPipeline
+}
+
 // Iter iterates over all the events.
 func (i ieventsStub) Iter(cb func(d events.Event) (stop bool)) {
 	for _, d := range i.events {
@@ -271,6 +293,52 @@ func TestDB_SaveEvents(t *testing.T) {
 	}
 }
 
+// fileStub is the events of a named file.
+type fileStub struct {
+	ieventsStub
+	source string
+}
+
+// Source returns the name of the file.
+func (f fileStub) Source() string {
+	return f.source
+}
+
+func TestDB_SaveFile(t *testing.T) {
+	ctx := context.Background()
+
+	ok := fileStub{source: "save-ok.tsv", ieventsStub: ieventsStub{events: []events.Event{
+		{ID: "save-1", UnitGUID: "save-unit", Number: 1},
+		{ID: "save-2", UnitGUID: "save-unit", Number: 2},
+	}}}
+	if err := st.SaveFile(ctx, ok); err != nil {
+		t.Fatalf("SaveFile() error = %v", err)
+	}
+	file, err := st.GetFile(ctx, events.DefaultPipeline, ok.source)
+	if err != nil {
+		t.Fatalf("GetFile() error = %v", err)
+	}
+	if file.Events != 2 {
+		t.Errorf("GetFile() events = %d, want 2", file.Events)
+	}
+
+	// the duplicated id fails the second event, so neither the first one nor the file is saved
+	failed := fileStub{source: "save-failed.tsv", ieventsStub: ieventsStub{events: []events.Event{
+		{ID: "save-3", UnitGUID: "save-unit", Number: 3},
+		{ID: "save-1", UnitGUID: "save-unit", Number: 4},
+	}}}
+	if err = st.SaveFile(ctx, failed); err == nil {
+		t.Fatal("SaveFile() error = nil, want the duplicated id")
+	}
+	if _, err = st.GetFile(ctx, events.DefaultPipeline, failed.source); !errors.Is(err, service.ErrFileNotFound) {
+		t.Errorf("GetFile() error = %v, want %v", err, service.ErrFileNotFound)
+	}
+	var n int
+	if err = st.DB.QueryRow("SELECT COUNT(*) FROM events WHERE SourceFile = ?", failed.source).Scan(&n); err != nil || n != 0 {
+		t.Errorf("events of the failed file = %d, %v", n, err)
+	}
+}
+
 func TestDB_GetEventByNumber(t *testing.T) {
 	type args struct {
 		ctx    context.Context
@@ -329,17 +397,17 @@ func TestDB_GetEventByNumber(t *testing.T) {
 INSERT INTO events (
                     ID, UnitGUID, MessageText, Number, Level, Bit, 
                     InvertBit, MQTT, InventoryID, MessageID, MessageClass, 
-                    Context, Area, Address, Block, Type) 
-VALUES (?, ?, ?, 0, 0, 0, 0, '', '', '', '', '','', '', false, '')`
+                    Context, Area, Address, Block, Type, Seq) 
+VALUES (?, ?, ?, 0, 0, 0, 0, '', '', '', '', '','', '', false, '', ?)`
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			_, err := st.DB.Exec(query, uuid.Generate().String(), tt.want.UnitGUID, tt.want.MessageText)
+			_, err := st.DB.Exec(query, uuid.Generate().String(), tt.want.UnitGUID, tt.want.MessageText, tt.args.number)
 			if err != nil {
 				t.Fatalf("error adding mock event: %v", err)
 			}
 
-			got, err := st.GetEventByNumber(tt.args.ctx, tt.args.guid, tt.args.number)
+			got, err := st.GetEventByNumber(tt.args.ctx, "", tt.args.guid, tt.args.number)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("GetEventByNumber() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -352,3 +420,647 @@ VALUES (?, ?, ?, 0, 0, 0, 0, '', '', '', '', '','', '', false, '')`
 		})
 	}
 }
+
+// archiveStub collects archived events.
+type archiveStub struct {
+	events []events.Event
+}
+
+// Archive saves the events in memory.
+func (a *archiveStub) Archive(_ context.Context, evs []events.Event) error {
+	a.events = append(a.events, evs...)
+	return nil
+}
+
+func TestDB_Prune(t *testing.T) {
+	_, err := st.DB.Exec("DELETE FROM events")
+	if err != nil {
+		t.Fatalf("error deleting events: %v", err)
+	}
+
+	_, err = st.DB.Exec("DELETE FROM files")
+	if err != nil {
+		t.Fatalf("error deleting files: %v", err)
+	}
+
+	err = st.AddFilename(context.Background(), events.DefaultPipeline, "prune.tsv", nil)
+	if err != nil {
+		t.Fatalf("error adding filename: %v", err)
+	}
+
+	query := `
+INSERT INTO events (
+                    ID, UnitGUID, MessageText, Number, Level, Bit, 
+                    InvertBit, MQTT, InventoryID, MessageID, MessageClass, 
+                    Context, Area, Address, Block, Type, SourceFile, IngestedAt) 
+VALUES (?, ?, '', ?, 0, 0, 0, '', '', '', '', '','', '', false, '', 'prune.tsv', ?)`
+
+	now := time.Now().UTC()
+	insert := []struct {
+		unit string
+		age  time.Duration
+	}{
+		{"unit1", 48 * time.Hour},
+		{"unit1", 3 * time.Hour},
+		{"unit1", 2 * time.Hour},
+		{"unit1", time.Hour},
+		{"unit2", 2 * time.Hour},
+		{"unit2", time.Hour},
+	}
+
+	for n, e := range insert {
+		_, err = st.DB.Exec(query, uuid.Generate().String(), e.unit, n, now.Add(-e.age))
+		if err != nil {
+			t.Fatalf("error adding event: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name        string
+		policy      service.RetentionPolicy
+		wantDeleted int
+		wantLeft    int
+	}{
+		{
+			name:        "by age",
+			policy:      service.RetentionPolicy{MaxAge: 24 * time.Hour},
+			wantDeleted: 1,
+			wantLeft:    5,
+		},
+		{
+			name:        "per unit",
+			policy:      service.RetentionPolicy{MaxPerUnit: 2, BatchSize: 1},
+			wantDeleted: 1,
+			wantLeft:    4,
+		},
+		{
+			name:        "total",
+			policy:      service.RetentionPolicy{MaxEvents: 2},
+			wantDeleted: 2,
+			wantLeft:    2,
+		},
+		{
+			name:        "nothing to prune",
+			policy:      service.RetentionPolicy{MaxAge: 24 * time.Hour, MaxPerUnit: 2, MaxEvents: 2},
+			wantDeleted: 0,
+			wantLeft:    2,
+		},
+	}
+
+	archiver := &archiveStub{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.policy.Archiver = archiver
+
+			deleted, err := st.Prune(context.Background(), tt.policy)
+			if err != nil {
+				t.Fatalf("Prune() error = %v", err)
+			}
+
+			if deleted != tt.wantDeleted {
+				t.Errorf("Prune() deleted = %d, want %d", deleted, tt.wantDeleted)
+			}
+
+			var left int
+			if err = st.DB.QueryRow("SELECT COUNT(*) FROM events").Scan(&left); err != nil {
+				t.Fatalf("error counting events: %v", err)
+			}
+
+			if left != tt.wantLeft {
+				t.Errorf("Prune() left = %d, want %d", left, tt.wantLeft)
+			}
+		})
+	}
+
+	var pruned, archived int
+	err = st.DB.QueryRow("SELECT pruned, archived FROM files WHERE name = 'prune.tsv'").Scan(&pruned, &archived)
+	if err != nil {
+		t.Fatalf("error getting file: %v", err)
+	}
+
+	if pruned != 4 || archived != 4 || len(archiver.events) != 4 {
+		t.Errorf("unexpected bookkeeping: pruned %d, archived %d, exported %d", pruned, archived, len(archiver.events))
+	}
+
+	var oldest time.Time
+	if err = st.DB.QueryRow("SELECT IngestedAt FROM events ORDER BY IngestedAt LIMIT 1").Scan(&oldest); err != nil {
+		t.Fatalf("error getting oldest event: %v", err)
+	}
+
+	if now.Sub(oldest) > 90*time.Minute {
+		t.Errorf("the newest events must be kept, the oldest left is %v", oldest)
+	}
+}
+
+func TestDB_GetEventByNumber_pruned(t *testing.T) {
+	for _, table := range []string{"events", "files", "unit_numbers"} {
+		if _, err := st.DB.Exec("DELETE FROM " + table); err != nil {
+			t.Fatalf("error deleting %s: %v", table, err)
+		}
+	}
+
+	ctx := context.Background()
+	first := fileStub{source: "seq-1.tsv", ieventsStub: ieventsStub{events: []events.Event{
+		{ID: "seq-1", UnitGUID: "seq-unit", Number: 1},
+		{ID: "seq-2", UnitGUID: "seq-unit", Number: 2},
+		{ID: "seq-3", UnitGUID: "seq-unit", Number: 3},
+	}}}
+	if err := st.SaveFile(ctx, first); err != nil {
+		t.Fatalf("SaveFile() error = %v", err)
+	}
+
+	deleted, err := st.Prune(ctx, service.RetentionPolicy{MaxPerUnit: 1})
+	if err != nil || deleted != 2 {
+		t.Fatalf("Prune() got = %d, error = %v", deleted, err)
+	}
+
+	// the pruned numbers are gone and the kept event keeps its number
+	if _, err = st.GetEventByNumber(ctx, "", "seq-unit", 1); !errors.Is(err, service.ErrEventNotFound) {
+		t.Errorf("GetEventByNumber(1) error = %v, want %v", err, service.ErrEventNotFound)
+	}
+	got, err := st.GetEventByNumber(ctx, "", "seq-unit", 3)
+	if err != nil || got.ID != "seq-3" {
+		t.Errorf("GetEventByNumber(3) got = %+v, error = %v", got, err)
+	}
+
+	// the numbers go on after the pruned ones
+	second := fileStub{source: "seq-2.tsv", ieventsStub: ieventsStub{events: []events.Event{
+		{ID: "seq-4", UnitGUID: "seq-unit", Number: 1},
+	}}}
+	if err = st.SaveFile(ctx, second); err != nil {
+		t.Fatalf("SaveFile() error = %v", err)
+	}
+	got, err = st.GetEventByNumber(ctx, "", "seq-unit", 4)
+	if err != nil || got.ID != "seq-4" {
+		t.Errorf("GetEventByNumber(4) got = %+v, error = %v", got, err)
+	}
+}
+
+func TestDB_ListEvents(t *testing.T) {
+	_, err := st.DB.Exec("DELETE FROM events")
+	if err != nil {
+		t.Fatalf("error deleting events: %v", err)
+	}
+
+	query := `
+INSERT INTO events (
+                    ID, UnitGUID, MessageText, Number, Level, Bit, 
+                    InvertBit, MQTT, InventoryID, MessageID, MessageClass, 
+                    Context, Area, Address, Block, Type, SourceFile, IngestedAt) 
+VALUES (?, ?, '', ?, ?, 0, 0, '', '', '', ?, '','', '', false, '', ?, ?)`
+
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	for n := 0; n < 10; n++ {
+		unit, class := "unit1", "waiting"
+		if n%2 == 1 {
+			unit, class = "unit2", "working"
+		}
+
+		_, err = st.DB.Exec(query, uuid.Generate().String(), unit, n, n*10, class,
+			fmt.Sprintf("%d.tsv", n/5), now.Add(time.Duration(n/3)*time.Minute))
+		if err != nil {
+			t.Fatalf("error adding event: %v", err)
+		}
+	}
+
+	minLevel, maxLevel := 20, 70
+	tests := []struct {
+		name       string
+		query      service.EventsQuery
+		wantNumber []int
+	}{
+		{
+			name:       "all",
+			query:      service.EventsQuery{SortBy: service.SortNumber},
+			wantNumber: []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9},
+		},
+		{
+			name:       "ingestion order",
+			query:      service.EventsQuery{SortBy: service.SortIngestedAt},
+			wantNumber: []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9},
+		},
+		{
+			name: "filters",
+			query: service.EventsQuery{
+				SortBy: service.SortLevel,
+				Desc:   true,
+				Filter: service.EventFilter{UnitGUID: "unit1", MessageClass: "waiting", MinLevel: &minLevel, MaxLevel: &maxLevel},
+			},
+			wantNumber: []int{6, 4, 2},
+		},
+		{
+			name: "source file and time range",
+			query: service.EventsQuery{
+				SortBy: service.SortNumber,
+				Filter: service.EventFilter{SourceFile: "0.tsv", From: now.Add(time.Minute), To: now.Add(time.Hour)},
+			},
+			wantNumber: []int{3, 4},
+		},
+	}
+
+	for _, tt := range tests {
+		for _, limit := range []int{0, 1, 3} {
+			t.Run(fmt.Sprintf("%s limit %d", tt.name, limit), func(t *testing.T) {
+				q := tt.query
+				q.Limit = limit
+
+				var got []int
+				for page := 0; page < len(tt.wantNumber)+1; page++ {
+					evs, err := st.ListEvents(context.Background(), q)
+					if err != nil {
+						t.Fatalf("ListEvents() error = %v", err)
+					}
+
+					for _, e := range evs {
+						got = append(got, e.Number)
+					}
+
+					if limit == 0 || len(evs) < limit {
+						break
+					}
+
+					last := evs[len(evs)-1]
+					q.After = service.CursorOf(last, q.SortBy)
+				}
+
+				if !reflect.DeepEqual(got, tt.wantNumber) {
+					t.Errorf("ListEvents() got = %v, want %v", got, tt.wantNumber)
+				}
+			})
+		}
+	}
+
+	t.Run("sorted by ingestion time", func(t *testing.T) {
+		q := service.EventsQuery{Desc: true, Limit: 4}
+
+		var got []time.Time
+		for {
+			evs, err := st.ListEvents(context.Background(), q)
+			if err != nil {
+				t.Fatalf("ListEvents() error = %v", err)
+			}
+
+			for _, e := range evs {
+				got = append(got, e.IngestedAt)
+			}
+
+			if len(evs) < q.Limit {
+				break
+			}
+
+			last := evs[len(evs)-1]
+			q.After = service.CursorOf(last, q.SortBy)
+		}
+
+		if len(got) != 10 {
+			t.Fatalf("ListEvents() got %d events, want 10", len(got))
+		}
+
+		for i := 1; i < len(got); i++ {
+			if got[i].After(got[i-1]) {
+				t.Errorf("ListEvents() events are not sorted: %v", got)
+			}
+		}
+	})
+}
+
+func TestDB_Units(t *testing.T) {
+	_, err := st.DB.Exec("DELETE FROM events")
+	if err != nil {
+		t.Fatalf("error deleting events: %v", err)
+	}
+
+	query := `
+INSERT INTO events (
+                    ID, UnitGUID, MessageText, Number, Level, Bit, 
+                    InvertBit, MQTT, InventoryID, MessageID, MessageClass, 
+                    Context, Area, Address, Block, Type, SourceFile, IngestedAt) 
+VALUES (?, ?, '', ?, ?, 0, 0, '', ?, '', ?, '', ?, '', false, '', '', ?)`
+
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	rows := []struct {
+		unit, inventory, class, area string
+		number, level                int
+		ingested                     time.Time
+	}{
+		{"unit1", "inv2", "alarm", "LOCAL", 1, 10, now},
+		{"unit1", "inv1", "alarm", "NET", 2, 20, now.Add(time.Minute)},
+		{"unit1", "inv1", "waiting", "LOCAL", 3, 30, now.Add(time.Minute)},
+		{"unit2", "", "working", "LOCAL", 1, 40, now.Add(time.Hour)},
+	}
+	for _, r := range rows {
+		_, err = st.DB.Exec(query, uuid.Generate().String(), r.unit, r.number, r.level,
+			r.inventory, r.class, r.area, r.ingested)
+		if err != nil {
+			t.Fatalf("error adding event: %v", err)
+		}
+	}
+
+	units, err := st.ListUnits(context.Background(), "")
+	if err != nil {
+		t.Fatalf("ListUnits() error = %v", err)
+	}
+
+	want := []service.Unit{
+		{GUID: "unit1", InventoryIDs: []string{"inv1", "inv2"}, Events: 3,
+			FirstSeen: now, LastSeen: now.Add(time.Minute), LatestLevel: 30},
+		{GUID: "unit2", InventoryIDs: []string{}, Events: 1,
+			FirstSeen: now.Add(time.Hour), LastSeen: now.Add(time.Hour), LatestLevel: 40},
+	}
+	if !reflect.DeepEqual(units, want) {
+		t.Errorf("ListUnits() got = %v, want %v", units, want)
+	}
+
+	unit, err := st.GetUnit(context.Background(), "", "unit1")
+	if err != nil {
+		t.Fatalf("GetUnit() error = %v", err)
+	}
+
+	wantSummary := service.UnitSummary{
+		Unit:           want[0],
+		ByMessageClass: map[string]int{"alarm": 2, "waiting": 1},
+		ByArea:         map[string]int{"LOCAL": 2, "NET": 1},
+	}
+	if !reflect.DeepEqual(unit, wantSummary) {
+		t.Errorf("GetUnit() got = %v, want %v", unit, wantSummary)
+	}
+
+	_, err = st.GetUnit(context.Background(), "", "unit3")
+	if !errors.Is(err, service.ErrUnitNotFound) {
+		t.Errorf("GetUnit() error = %v, want %v", err, service.ErrUnitNotFound)
+	}
+}
+
+func TestDB_Files(t *testing.T) {
+	for _, table := range []string{"events", "files"} {
+		if _, err := st.DB.Exec("DELETE FROM " + table); err != nil {
+			t.Fatalf("error deleting %s: %v", table, err)
+		}
+	}
+
+	ctx := context.Background()
+	for _, name := range []string{"a.tsv", "b.tsv", "c.tsv"} {
+		var errFill error
+		if name == "c.tsv" {
+			errFill = errors.New("bad file")
+		}
+		if err := st.AddFilename(ctx, events.DefaultPipeline, name, errFill); err != nil {
+			t.Fatalf("error adding filename: %v", err)
+		}
+	}
+
+	query := `
+INSERT INTO events (
+                    ID, UnitGUID, MessageText, Number, Level, Bit, 
+                    InvertBit, MQTT, InventoryID, MessageID, MessageClass, 
+                    Context, Area, Address, Block, Type, SourceFile, IngestedAt) 
+VALUES (?, ?, '', ?, 0, 0, 0, '', '', '', '', '', '', '', false, '', ?, ?)`
+
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	for n, source := range []string{"a.tsv", "a.tsv", "a.tsv", "b.tsv"} {
+		unit := "unit1"
+		if n == 1 {
+			unit = "unit2"
+		}
+		_, err := st.DB.Exec(query, uuid.Generate().String(), unit, n, source, now)
+		if err != nil {
+			t.Fatalf("error adding event: %v", err)
+		}
+	}
+
+	names := func(status string) []string {
+		files, err := st.ListFiles(ctx, "", status)
+		if err != nil {
+			t.Fatalf("ListFiles() error = %v", err)
+		}
+
+		got := []string{}
+		for _, f := range files {
+			got = append(got, f.Name)
+		}
+		return got
+	}
+
+	if got := names(""); !reflect.DeepEqual(got, []string{"a.tsv", "b.tsv", "c.tsv"}) {
+		t.Errorf("ListFiles() got = %v", got)
+	}
+	if got := names(service.FileFailed); !reflect.DeepEqual(got, []string{"c.tsv"}) {
+		t.Errorf("ListFiles(failed) got = %v", got)
+	}
+
+	file, err := st.GetFile(ctx, events.DefaultPipeline, "a.tsv")
+	if err != nil {
+		t.Fatalf("GetFile() error = %v", err)
+	}
+	if file.Stored != 3 || !reflect.DeepEqual(file.Units, []string{"unit1", "unit2"}) || file.Status != service.FileOK {
+		t.Errorf("GetFile() got = %+v", file)
+	}
+
+	deleted, err := st.DeleteFile(ctx, events.DefaultPipeline, "a.tsv")
+	if err != nil || deleted != 3 {
+		t.Fatalf("DeleteFile() got = %d, error = %v", deleted, err)
+	}
+
+	file, err = st.GetFile(ctx, events.DefaultPipeline, "a.tsv")
+	if err != nil {
+		t.Fatalf("GetFile() error = %v", err)
+	}
+	if file.Stored != 0 || file.Status != service.FileDeleted || file.DeletedAt == nil {
+		t.Errorf("GetFile() after delete got = %+v", file)
+	}
+	if got := names(service.FileDeleted); !reflect.DeepEqual(got, []string{"a.tsv"}) {
+		t.Errorf("ListFiles(deleted) got = %v", got)
+	}
+
+	if err = st.ResetFile(ctx, events.DefaultPipeline, "b.tsv"); err != nil {
+		t.Fatalf("ResetFile() error = %v", err)
+	}
+	if _, err = st.GetFile(ctx, events.DefaultPipeline, "b.tsv"); !errors.Is(err, service.ErrFileNotFound) {
+		t.Errorf("GetFile() after reset error = %v, want %v", err, service.ErrFileNotFound)
+	}
+	if err = st.AddFilename(ctx, events.DefaultPipeline, "b.tsv", nil); err != nil {
+		t.Errorf("AddFilename() after reset error = %v", err)
+	}
+
+	if _, err = st.DeleteFile(ctx, events.DefaultPipeline, "d.tsv"); !errors.Is(err, service.ErrFileNotFound) {
+		t.Errorf("DeleteFile() error = %v, want %v", err, service.ErrFileNotFound)
+	}
+}
+
+// pipelineEvents are the events of a.tsv of a pipeline.
+type pipelineEvents struct {
+	ieventsStub
+	pipeline string
+}
+
+// Source returns a.tsv.
+func (p pipelineEvents) Source() string {
+	return "a.tsv"
+}
+
+// Pipeline returns the pipeline of the events.
+func (p pipelineEvents) Pipeline() string {
+	return p.pipeline
+}
+
+func TestDB_Pipelines(t *testing.T) {
+	for _, table := range []string{"events", "files"} {
+		if _, err := st.DB.Exec("DELETE FROM " + table); err != nil {
+			t.Fatalf("error deleting %s: %v", table, err)
+		}
+	}
+
+	ctx := context.Background()
+	for _, pipeline := range []string{events.DefaultPipeline, "line2"} {
+		// the same name is ingested by both pipelines
+		if err := st.AddFilename(ctx, pipeline, "a.tsv", nil); err != nil {
+			t.Fatalf("AddFilename(%s) error = %v", pipeline, err)
+		}
+
+		evs := pipelineEvents{pipeline: pipeline, ieventsStub: ieventsStub{events: []events.Event{
+			{ID: uuid.Generate().String(), UnitGUID: "unit-" + pipeline, Number: 1},
+			{ID: uuid.Generate().String(), UnitGUID: "shared", Number: 2},
+		}}}
+		if err := st.SaveEvents(ctx, evs); err != nil {
+			t.Fatalf("SaveEvents(%s) error = %v", pipeline, err)
+		}
+	}
+
+	a := &addStub{}
+	if err := st.LoadFilenames(ctx, "line2", a); err != nil || len(*a) != 1 {
+		t.Errorf("LoadFilenames(line2) got = %v, error = %v", *a, err)
+	}
+
+	evs, err := st.ListEvents(ctx, service.EventsQuery{Filter: service.EventFilter{Pipeline: "line2"}})
+	if err != nil {
+		t.Fatalf("ListEvents() error = %v", err)
+	}
+	if len(evs) != 2 || evs[0].Pipeline != "line2" || evs[1].Pipeline != "line2" {
+		t.Errorf("ListEvents(line2) got = %+v", evs)
+	}
+
+	units, err := st.ListUnits(ctx, "line2")
+	if err != nil || len(units) != 2 || units[0].GUID != "shared" || units[1].GUID != "unit-line2" {
+		t.Errorf("ListUnits(line2) got = %+v, error = %v", units, err)
+	}
+	unit, err := st.GetUnit(ctx, "", "shared")
+	if err != nil || unit.Events != 2 {
+		t.Errorf("GetUnit() of all pipelines got = %+v, error = %v", unit, err)
+	}
+	if _, err = st.GetUnit(ctx, "line2", "unit-"+events.DefaultPipeline); !errors.Is(err, service.ErrUnitNotFound) {
+		t.Errorf("GetUnit(line2) error = %v, want %v", err, service.ErrUnitNotFound)
+	}
+	if _, err = st.GetEventByNumber(ctx, "line2", "unit-"+events.DefaultPipeline, 1); !errors.Is(err, service.ErrEventNotFound) {
+		t.Errorf("GetEventByNumber(line2) error = %v, want %v", err, service.ErrEventNotFound)
+	}
+
+	files, err := st.ListFiles(ctx, "", "")
+	if err != nil || len(files) != 2 || files[0].Pipeline != events.DefaultPipeline || files[1].Pipeline != "line2" {
+		t.Errorf("ListFiles() got = %+v, error = %v", files, err)
+	}
+	if files, err = st.ListFiles(ctx, "line2", ""); err != nil || len(files) != 1 || files[0].Events != 2 {
+		t.Errorf("ListFiles(line2) got = %+v, error = %v", files, err)
+	}
+
+	deleted, err := st.DeleteFile(ctx, "line2", "a.tsv")
+	if err != nil || deleted != 2 {
+		t.Fatalf("DeleteFile(line2) got = %d, error = %v", deleted, err)
+	}
+	file, err := st.GetFile(ctx, events.DefaultPipeline, "a.tsv")
+	if err != nil || file.Stored != 2 || file.Status != service.FileOK {
+		t.Errorf("GetFile() of the other pipeline got = %+v, error = %v", file, err)
+	}
+}
+
+func TestDB_APIKeys(t *testing.T) {
+	if _, err := st.DB.Exec("DELETE FROM api_keys"); err != nil {
+		t.Fatalf("error deleting api_keys: %v", err)
+	}
+
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+	keys := []service.APIKey{
+		{ID: "k1", Name: "reader", Hash: "hash1", Scopes: []string{"events:read"}, CreatedAt: now},
+		{ID: "k2", Name: "admin", Hash: "hash2", Scopes: []string{"admin", "files:upload"}, CreatedAt: now.Add(time.Second)},
+	}
+	for _, key := range keys {
+		if err := st.SaveAPIKey(ctx, key); err != nil {
+			t.Fatalf("SaveAPIKey() error = %v", err)
+		}
+	}
+
+	got, err := st.ListAPIKeys(ctx)
+	if err != nil {
+		t.Fatalf("ListAPIKeys() error = %v", err)
+	}
+	if len(got) != 2 || got[0].ID != "k1" || !reflect.DeepEqual(got[1].Scopes, keys[1].Scopes) || !got[1].CreatedAt.Equal(keys[1].CreatedAt) {
+		t.Errorf("ListAPIKeys() got = %+v", got)
+	}
+
+	key, err := st.GetAPIKey(ctx, "hash2")
+	if err != nil || key.Name != "admin" || key.Hash != "hash2" {
+		t.Errorf("GetAPIKey() got = %+v, error = %v", key, err)
+	}
+	if _, err = st.GetAPIKey(ctx, "hash3"); !errors.Is(err, service.ErrAPIKeyNotFound) {
+		t.Errorf("GetAPIKey() error = %v, want %v", err, service.ErrAPIKeyNotFound)
+	}
+
+	if err = st.DeleteAPIKey(ctx, "k2"); err != nil {
+		t.Fatalf("DeleteAPIKey() error = %v", err)
+	}
+	if _, err = st.GetAPIKey(ctx, "hash2"); !errors.Is(err, service.ErrAPIKeyNotFound) {
+		t.Errorf("GetAPIKey() after delete error = %v, want %v", err, service.ErrAPIKeyNotFound)
+	}
+	if err = st.DeleteAPIKey(ctx, "k2"); !errors.Is(err, service.ErrAPIKeyNotFound) {
+		t.Errorf("DeleteAPIKey() error = %v, want %v", err, service.ErrAPIKeyNotFound)
+	}
+}
+
+func TestDB_Quotas(t *testing.T) {
+	if _, err := st.DB.Exec("DELETE FROM quotas"); err != nil {
+		t.Fatalf("error deleting quotas: %v", err)
+	}
+
+	ctx := context.Background()
+	if err := st.SaveQuotas(ctx, "2023-05-01", map[string]int{"key:a": 1, "ip:10.0.0.1": 5}); err != nil {
+		t.Fatalf("SaveQuotas() error = %v", err)
+	}
+	if err := st.SaveQuotas(ctx, "2023-05-01", map[string]int{"key:a": 3}); err != nil {
+		t.Fatalf("SaveQuotas() error = %v", err)
+	}
+
+	used, err := st.LoadQuotas(ctx, "2023-05-01")
+	if err != nil {
+		t.Fatalf("LoadQuotas() error = %v", err)
+	}
+	if want := map[string]int{"key:a": 3, "ip:10.0.0.1": 5}; !reflect.DeepEqual(used, want) {
+		t.Errorf("LoadQuotas() got = %v, want %v", used, want)
+	}
+
+	// the quotas of the previous days are deleted
+	if err = st.SaveQuotas(ctx, "2023-05-02", map[string]int{"key:a": 1}); err != nil {
+		t.Fatalf("SaveQuotas() error = %v", err)
+	}
+	if used, err = st.LoadQuotas(ctx, "2023-05-01"); err != nil || len(used) != 0 {
+		t.Errorf("LoadQuotas() of the previous day got = %v, error = %v", used, err)
+	}
+}
+
+func TestDB_Health(t *testing.T) {
+	ctx := context.Background()
+	if err := st.Ping(ctx); err != nil {
+		t.Fatalf("Ping() error = %v", err)
+	}
+
+	version, dirty, err := st.SchemaVersion(ctx)
+	if err != nil {
+		t.Fatalf("SchemaVersion() error = %v", err)
+	}
+	if version == 0 || dirty {
+		t.Errorf("SchemaVersion() got = %d (dirty: %v), want the applied version", version, dirty)
+	}
+
+	if err = storage.CheckSchema(ctx, st, "sqlite3"); err != nil {
+		t.Errorf("CheckSchema() error = %v", err)
+	}
+}
@@ -0,0 +1,104 @@
+package sqllike
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"go-tsv-watcher/internal/storage/queries"
+	"go-tsv-watcher/internal/storage/service"
+	"strings"
+)
+
+// SaveAPIKey saves the API key.
+func (db *DB) SaveAPIKey(ctx context.Context, key service.APIKey) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	statement, err := db.statements.Get(queries.SaveAPIKey)
+	if err != nil {
+		return err
+	}
+
+	_, err = statement.ExecContext(ctx, key.ID, key.Name, key.Hash, strings.Join(key.Scopes, " "), key.CreatedAt)
+	return err
+}
+
+// ListAPIKeys returns the API keys in the order of creation.
+func (db *DB) ListAPIKeys(ctx context.Context) ([]service.APIKey, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	rows, err := db.query(ctx, queries.ListAPIKeys)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := make([]service.APIKey, 0)
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, rows.Err()
+}
+
+// GetAPIKey returns the API key by the hash of its secret.
+func (db *DB) GetAPIKey(ctx context.Context, hash string) (service.APIKey, error) {
+	if ctx.Err() != nil {
+		return service.APIKey{}, ctx.Err()
+	}
+
+	statement, err := db.statements.Get(queries.GetAPIKey)
+	if err != nil {
+		return service.APIKey{}, err
+	}
+
+	key, err := scanAPIKey(statement.QueryRowContext(ctx, hash))
+	if errors.Is(err, sql.ErrNoRows) {
+		return service.APIKey{}, service.ErrAPIKeyNotFound
+	}
+	return key, err
+}
+
+// DeleteAPIKey deletes the API key.
+func (db *DB) DeleteAPIKey(ctx context.Context, id string) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	statement, err := db.statements.Get(queries.DeleteAPIKey)
+	if err != nil {
+		return err
+	}
+
+	res, err := statement.ExecContext(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return service.ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+// scanAPIKey scans the api_keys row.
+func scanAPIKey(row scanner) (service.APIKey, error) {
+	var key service.APIKey
+	var scopes string
+	var createdAt timeValue
+
+	if err := row.Scan(&key.ID, &key.Name, &key.Hash, &scopes, &createdAt); err != nil {
+		return service.APIKey{}, err
+	}
+
+	key.Scopes = strings.Fields(scopes)
+	key.CreatedAt = createdAt.Time
+	return key, nil
+}
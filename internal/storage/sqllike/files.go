@@ -0,0 +1,154 @@
+package sqllike
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"go-tsv-watcher/internal/storage/queries"
+	"go-tsv-watcher/internal/storage/service"
+	"time"
+)
+
+// ListFiles returns the files of the pipeline with the status,
+// all files if the status is empty, of all pipelines if the pipeline is empty.
+func (db *DB) ListFiles(ctx context.Context, pipeline, status string) ([]service.File, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	rows, err := db.query(ctx, queries.ListFiles, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	files := make([]service.File, 0)
+	for rows.Next() {
+		f, err := scanFile(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		if status == "" || f.Status == status {
+			files = append(files, f)
+		}
+	}
+
+	return files, rows.Err()
+}
+
+// GetFile returns the file of the pipeline with the units of its stored events.
+func (db *DB) GetFile(ctx context.Context, pipeline, name string) (service.FileDetails, error) {
+	if ctx.Err() != nil {
+		return service.FileDetails{}, ctx.Err()
+	}
+
+	statement, err := db.statements.Get(queries.GetFile)
+	if err != nil {
+		return service.FileDetails{}, err
+	}
+
+	f, err := scanFile(statement.QueryRowContext(ctx, pipeline, name))
+	if errors.Is(err, sql.ErrNoRows) {
+		return service.FileDetails{}, service.ErrFileNotFound
+	}
+	if err != nil {
+		return service.FileDetails{}, err
+	}
+
+	details := service.FileDetails{File: f, Units: []string{}}
+	err = db.eachPair(ctx, queries.FileUnits, []any{pipeline, name}, func(guid string, value any) error {
+		details.Units = append(details.Units, guid)
+		details.Stored += *value.(*int)
+		return nil
+	}, new(int))
+	if err != nil {
+		return service.FileDetails{}, err
+	}
+
+	return details, nil
+}
+
+// DeleteFile deletes the events of the file of the pipeline and marks it as deleted,
+// the record is kept so the file is not ingested again.
+func (db *DB) DeleteFile(ctx context.Context, pipeline, name string) (int, error) {
+	if ctx.Err() != nil {
+		return 0, ctx.Err()
+	}
+
+	return db.purgeFile(ctx, pipeline, name, queries.MarkFileDeleted,
+		time.Now().UTC().Truncate(time.Microsecond), pipeline, name)
+}
+
+// ResetFile deletes the events and the record of the file of the pipeline, so it can be ingested again.
+func (db *DB) ResetFile(ctx context.Context, pipeline, name string) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	_, err := db.purgeFile(ctx, pipeline, name, queries.RemoveFile, pipeline, name)
+	return err
+}
+
+// purgeFile deletes the events of the file and runs the record query in one transaction.
+func (db *DB) purgeFile(ctx context.Context, pipeline, name string, record int, args ...any) (int, error) {
+	del, err := db.statements.Get(queries.DeleteFileEvents)
+	if err != nil {
+		return 0, err
+	}
+
+	update, err := db.statements.Get(record)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.StmtContext(ctx, update).ExecContext(ctx, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to update file %s: %w", name, err)
+	}
+
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return 0, service.ErrFileNotFound
+	}
+
+	res, err = tx.StmtContext(ctx, del).ExecContext(ctx, pipeline, name)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete events of %s: %w", name, err)
+	}
+
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(deleted), tx.Commit()
+}
+
+// scanFile scans the files row.
+func scanFile(row scanner) (service.File, error) {
+	var pipeline, name, errMsg string
+	var events, pruned, archived int
+	var deletedAt timeValue
+
+	err := row.Scan(&pipeline, &name, &errMsg, &events, &pruned, &archived, &deletedAt)
+	if err != nil {
+		return service.File{}, err
+	}
+
+	var deleted *time.Time
+	if !deletedAt.IsZero() {
+		deleted = &deletedAt.Time
+	}
+
+	f := service.NewFile(name, errMsg, deleted)
+	f.Pipeline, f.Events, f.Pruned, f.Archived = pipeline, events, pruned, archived
+
+	return f, nil
+}
@@ -0,0 +1,113 @@
+package sqllike
+
+import (
+	"context"
+	"fmt"
+	"go-tsv-watcher/internal/events"
+	"go-tsv-watcher/internal/storage/queries"
+	"go-tsv-watcher/internal/storage/service"
+	"strings"
+)
+
+// builder builds the WHERE clause of a query.
+type builder struct {
+	placeholder Placeholder
+	conditions  []string
+	args        []any
+}
+
+// arg adds the argument and returns its placeholder.
+func (b *builder) arg(v any) string {
+	b.args = append(b.args, v)
+	return b.placeholder(len(b.args))
+}
+
+// where adds the condition, %s in it are replaced with placeholders of args.
+func (b *builder) where(condition string, args ...any) {
+	placeholders := make([]any, len(args))
+	for i, a := range args {
+		placeholders[i] = b.arg(a)
+	}
+	b.conditions = append(b.conditions, fmt.Sprintf(condition, placeholders...))
+}
+
+// filter adds the conditions of the filter.
+func (b *builder) filter(f service.EventFilter) {
+	for _, c := range [...]struct{ column, value string }{
+		{"UnitGUID", f.UnitGUID},
+		{"InventoryID", f.InventoryID},
+		{"MessageClass", f.MessageClass},
+		{"MessageID", f.MessageID},
+		{"Area", f.Area},
+		{"SourceFile", f.SourceFile},
+		{"Pipeline", f.Pipeline},
+	} {
+		if c.value != "" {
+			b.where(c.column+" = %s", c.value)
+		}
+	}
+
+	if f.MinLevel != nil {
+		b.where("Level >= %s", *f.MinLevel)
+	}
+	if f.MaxLevel != nil {
+		b.where("Level <= %s", *f.MaxLevel)
+	}
+	if !f.From.IsZero() {
+		b.where("IngestedAt >= %s", f.From.UTC())
+	}
+	if !f.To.IsZero() {
+		b.where("IngestedAt < %s", f.To.UTC())
+	}
+}
+
+// String returns the WHERE clause, empty if there are no conditions.
+func (b *builder) String() string {
+	if len(b.conditions) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(b.conditions, " AND ")
+}
+
+// ListEvents returns the events matching the query.
+func (db *DB) ListEvents(ctx context.Context, q service.EventsQuery) ([]events.Event, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	column, err := q.Column()
+	if err != nil {
+		return nil, err
+	}
+
+	b := &builder{placeholder: db.placeholder}
+	b.filter(q.Filter)
+
+	op, order := ">", "ASC"
+	if q.Desc {
+		op, order = "<", "DESC"
+	}
+
+	if q.After != nil {
+		value, err := service.CursorArg(q.After, q.SortBy)
+		if err != nil {
+			return nil, err
+		}
+
+		b.where(fmt.Sprintf("(%[1]s %[2]s %%s OR (%[1]s = %%s AND (Number %[2]s %%s OR (Number = %%s AND ID %[2]s %%s))))",
+			column, op), value, value, q.After.Number, q.After.Number, q.After.ID)
+	}
+
+	query := "SELECT " + queries.EventColumns + " FROM events" + b.String() +
+		fmt.Sprintf(" ORDER BY %[1]s %[2]s, Number %[2]s, ID %[2]s", column, order)
+	if q.Limit > 0 {
+		query += " LIMIT " + b.arg(q.Limit)
+	}
+
+	rows, err := db.QueryContext(ctx, query, b.args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanEvents(rows)
+}
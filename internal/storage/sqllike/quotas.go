@@ -0,0 +1,57 @@
+package sqllike
+
+import (
+	"context"
+	"go-tsv-watcher/internal/storage/queries"
+)
+
+// LoadQuotas returns the used quotas of the day by client.
+func (db *DB) LoadQuotas(ctx context.Context, day string) (map[string]int, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	rows, err := db.query(ctx, queries.LoadQuotas, day)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	used := make(map[string]int)
+	for rows.Next() {
+		var client string
+		var n int
+		if err = rows.Scan(&client, &n); err != nil {
+			return nil, err
+		}
+		used[client] = n
+	}
+
+	return used, rows.Err()
+}
+
+// SaveQuotas saves the used quotas of the day by client, the quotas of the other days are deleted.
+func (db *DB) SaveQuotas(ctx context.Context, day string, used map[string]int) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	statement, err := db.statements.Get(queries.SaveQuota)
+	if err != nil {
+		return err
+	}
+
+	for client, n := range used {
+		if _, err = statement.ExecContext(ctx, client, day, n); err != nil {
+			return err
+		}
+	}
+
+	statement, err = db.statements.Get(queries.PruneQuotas)
+	if err != nil {
+		return err
+	}
+
+	_, err = statement.ExecContext(ctx, day)
+	return err
+}
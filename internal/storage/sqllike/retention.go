@@ -0,0 +1,221 @@
+package sqllike
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"go-tsv-watcher/internal/events"
+	"go-tsv-watcher/internal/storage/queries"
+	"go-tsv-watcher/internal/storage/service"
+	"time"
+)
+
+// Prune deletes the events out of the retention policy in batches
+// and returns the number of deleted events.
+func (db *DB) Prune(ctx context.Context, policy service.RetentionPolicy) (int, error) {
+	var deleted int
+
+	if policy.MaxAge > 0 {
+		cutoff := time.Now().UTC().Add(-policy.MaxAge)
+		n, err := db.pruneBatches(ctx, policy, -1, func(limit int) (*sql.Rows, error) {
+			return db.query(ctx, queries.PruneByAge, cutoff, limit)
+		})
+		deleted += n
+		if err != nil {
+			return deleted, fmt.Errorf("failed to prune by age: %w", err)
+		}
+	}
+
+	if policy.MaxPerUnit > 0 {
+		n, err := db.pruneUnits(ctx, policy)
+		deleted += n
+		if err != nil {
+			return deleted, fmt.Errorf("failed to prune units: %w", err)
+		}
+	}
+
+	if policy.MaxEvents > 0 {
+		var count int
+		statement, err := db.statements.Get(queries.CountEvents)
+		if err != nil {
+			return deleted, err
+		}
+
+		if err = statement.QueryRowContext(ctx).Scan(&count); err != nil {
+			return deleted, fmt.Errorf("failed to count events: %w", err)
+		}
+
+		if count > policy.MaxEvents {
+			n, err := db.pruneBatches(ctx, policy, count-policy.MaxEvents, func(limit int) (*sql.Rows, error) {
+				return db.query(ctx, queries.PruneOldest, limit)
+			})
+			deleted += n
+			if err != nil {
+				return deleted, fmt.Errorf("failed to prune oldest: %w", err)
+			}
+		}
+	}
+
+	return deleted, nil
+}
+
+// pruneUnits deletes the oldest events of every unit that has more than policy.MaxPerUnit.
+func (db *DB) pruneUnits(ctx context.Context, policy service.RetentionPolicy) (int, error) {
+	rows, err := db.query(ctx, queries.PruneUnits, policy.MaxPerUnit)
+	if err != nil {
+		return 0, err
+	}
+
+	excess := make(map[string]int)
+	for rows.Next() {
+		var guid string
+		var count int
+		if err = rows.Scan(&guid, &count); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		excess[guid] = count - policy.MaxPerUnit
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+
+	var deleted int
+	for guid, n := range excess {
+		guid := guid
+		pruned, err := db.pruneBatches(ctx, policy, n, func(limit int) (*sql.Rows, error) {
+			return db.query(ctx, queries.PruneUnit, guid, limit)
+		})
+		deleted += pruned
+		if err != nil {
+			return deleted, err
+		}
+	}
+
+	return deleted, nil
+}
+
+// pruneBatches selects events with selectBatch and deletes them batch by batch
+// until limit events are deleted (-1 for no limit) or nothing is selected.
+func (db *DB) pruneBatches(ctx context.Context, policy service.RetentionPolicy, limit int,
+	selectBatch func(limit int) (*sql.Rows, error)) (int, error) {
+	var deleted int
+
+	for limit < 0 || deleted < limit {
+		if ctx.Err() != nil {
+			return deleted, ctx.Err()
+		}
+
+		size := policy.Batch()
+		if limit >= 0 && limit-deleted < size {
+			size = limit - deleted
+		}
+
+		rows, err := selectBatch(size)
+		if err != nil {
+			return deleted, err
+		}
+
+		batch, err := scanEvents(rows)
+		if err != nil {
+			return deleted, err
+		}
+
+		if len(batch) == 0 {
+			break
+		}
+
+		if err = db.deleteBatch(ctx, batch, policy.Archiver); err != nil {
+			return deleted, err
+		}
+		deleted += len(batch)
+
+		if len(batch) < size {
+			break
+		}
+	}
+
+	return deleted, nil
+}
+
+// deleteBatch archives the events if needed and deletes them in one transaction
+// together with the files ledger update. The archive of a batch is named by its events,
+// so the batch selected again after a failed transaction replaces it instead of being archived twice.
+func (db *DB) deleteBatch(ctx context.Context, batch []events.Event, archiver service.Archiver) error {
+	if archiver != nil {
+		if err := archiver.Archive(ctx, batch); err != nil {
+			return fmt.Errorf("failed to archive events: %w", err)
+		}
+	}
+
+	del, err := db.statements.Get(queries.DeleteEvent)
+	if err != nil {
+		return err
+	}
+
+	ledger, err := db.statements.Get(queries.AddFilePruned)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	del, ledger = tx.StmtContext(ctx, del), tx.StmtContext(ctx, ledger)
+
+	perFile := make(map[fileKey]int)
+	for _, e := range batch {
+		if _, err = del.ExecContext(ctx, e.ID); err != nil {
+			return fmt.Errorf("failed to delete event %s: %w", e.ID, err)
+		}
+		perFile[fileKey{pipeline: e.Pipeline, name: e.SourceFile}]++
+	}
+
+	for file, n := range perFile {
+		archived := 0
+		if archiver != nil {
+			archived = n
+		}
+
+		if _, err = ledger.ExecContext(ctx, n, archived, file.pipeline, file.name); err != nil {
+			return fmt.Errorf("failed to update file %s: %w", file.name, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// fileKey identifies a file, the same name may be ingested by several pipelines.
+type fileKey struct {
+	pipeline, name string
+}
+
+// query runs the prepared query by name.
+func (db *DB) query(ctx context.Context, name int, args ...any) (*sql.Rows, error) {
+	statement, err := db.statements.Get(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return statement.QueryContext(ctx, args...)
+}
+
+// scanEvents scans all rows into events and closes them.
+func scanEvents(rows *sql.Rows) ([]events.Event, error) {
+	defer rows.Close()
+
+	var evs []events.Event
+	for rows.Next() {
+		e, err := scanEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		evs = append(evs, e)
+	}
+
+	return evs, rows.Err()
+}
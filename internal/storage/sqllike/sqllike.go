@@ -3,44 +3,94 @@ package sqllike
 import (
 	"context"
 	"database/sql"
+	"fmt"
 	"github.com/pkg/errors"
 	"go-tsv-watcher/internal/events"
 	"go-tsv-watcher/internal/storage/queries"
 	"go-tsv-watcher/internal/storage/service"
 	"go-tsv-watcher/pkg/logger"
+	"sort"
+	"strconv"
+	"time"
 )
 
 // DB is an abstract implementation of the storage.Database interface for sql like databases.
 type DB struct {
 	*sql.DB
-	logger logger.ILogger
+	logger      logger.ILogger
+	placeholder Placeholder
+	statements  *queries.Statements
 }
 
-// New creates a new DB instance.
-func New(db *sql.DB, logger logger.ILogger) *DB {
-	return &DB{
-		DB:     db,
-		logger: logger,
+// Placeholder returns the query parameter placeholder by its number (from 1).
+type Placeholder func(n int) string
+
+// Question is the placeholder of sqlite.
+func Question(int) string {
+	return "?"
+}
+
+// Dollar is the placeholder of postgres.
+func Dollar(n int) string {
+	return "$" + strconv.Itoa(n)
+}
+
+// New creates a new DB instance and prepares the queries of the vendor.
+func New(db *sql.DB, logger logger.ILogger, placeholder Placeholder, vendor string) (*DB, error) {
+	statements, err := queries.Prepare(db, vendor)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare queries: %w", err)
 	}
+
+	return &DB{
+		DB:          db,
+		logger:      logger,
+		placeholder: placeholder,
+		statements:  statements,
+	}, nil
 }
 
-// Close closes the database connection.
+// Close closes the prepared queries and the database connection.
 func (db *DB) Close() error {
+	if err := db.statements.Close(); err != nil {
+		return err
+	}
 	return db.DB.Close()
 }
 
 // Ping checks the database connection.
-func (db *DB) Ping() error {
-	return db.DB.Ping()
+func (db *DB) Ping(ctx context.Context) error {
+	return db.DB.PingContext(ctx)
 }
 
-// AddFilename adds a filename and error to the database.
-func (db *DB) AddFilename(ctx context.Context, filename string, errFill error) error {
+// SchemaVersion returns the applied migration version and whether the last migration failed halfway.
+func (db *DB) SchemaVersion(ctx context.Context) (uint, bool, error) {
+	stmt, err := db.statements.Get(queries.SchemaVersion)
+	if err != nil {
+		return 0, false, err
+	}
+
+	var (
+		version uint
+		dirty   bool
+	)
+	err = stmt.QueryRowContext(ctx).Scan(&version, &dirty)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to get schema version: %w", err)
+	}
+	return version, dirty, nil
+}
+
+// AddFilename adds a filename of the pipeline and error to the database.
+func (db *DB) AddFilename(ctx context.Context, pipeline, filename string, errFill error) error {
 	if ctx.Err() != nil {
 		return ctx.Err()
 	}
 
-	statement, err := queries.GetPreparedStatement(queries.AddFilename)
+	statement, err := db.statements.Get(queries.AddFilename)
 	if err != nil {
 		return err
 	}
@@ -50,17 +100,17 @@ func (db *DB) AddFilename(ctx context.Context, filename string, errFill error) e
 		errMsg = errFill.Error()
 	}
 
-	_, err = statement.ExecContext(ctx, filename, errMsg)
+	_, err = statement.ExecContext(ctx, pipeline, filename, errMsg)
 	return err
 }
 
-// LoadFilenames loads filenames from the database into the RAM.
-func (db *DB) LoadFilenames(ctx context.Context, storage service.Adder) error {
+// LoadFilenames loads filenames of the pipeline from the database into the RAM.
+func (db *DB) LoadFilenames(ctx context.Context, pipeline string, storage service.Adder) error {
 	if ctx.Err() != nil {
 		return ctx.Err()
 	}
 
-	rows, err := db.QueryContext(ctx, "SELECT name FROM files")
+	rows, err := db.QueryContext(ctx, "SELECT name FROM files WHERE pipeline = "+db.placeholder(1), pipeline)
 	if err != nil {
 		return err
 	}
@@ -84,11 +134,24 @@ func (db *DB) SaveEvents(ctx context.Context, evs service.IEvents) error {
 		return ctx.Err()
 	}
 
-	statement, err := queries.GetPreparedStatement(queries.SaveEvent)
+	statement, err := db.statements.Get(queries.SaveEvent)
+	if err != nil {
+		return err
+	}
+	claim, err := db.statements.Get(queries.ClaimNumbers)
+	if err != nil {
+		return err
+	}
+
+	next, err := claimNumbers(ctx, claim, evs)
 	if err != nil {
 		return err
 	}
 
+	source, pipeline := evs.Source(), evs.Pipeline()
+	ingestedAt := time.Now().UTC().Truncate(time.Microsecond)
+	saved := 0
+
 	save := func(d events.Event) (stop bool) {
 		if ctx.Err() != nil {
 			db.logger.Warn(ctx.Err().Error())
@@ -96,35 +159,138 @@ func (db *DB) SaveEvents(ctx context.Context, evs service.IEvents) error {
 		}
 		_, err = statement.Exec(d.ID, d.Number, d.MQTT, d.InventoryID, d.UnitGUID,
 			d.MessageID, d.MessageText, d.Context, d.MessageClass,
-			d.Level, d.Area, d.Address, d.Block, d.Type, d.Bit, d.InvertBit)
+			d.Level, d.Area, d.Address, d.Block, d.Type, d.Bit, d.InvertBit,
+			source, ingestedAt, pipeline, next[d.UnitGUID])
+		next[d.UnitGUID]++
 		if err != nil {
 			db.logger.Warn(err.Error())
 			return true
 		}
+		saved++
 		return false
 	}
 
 	evs.Iter(save)
 
+	if saved == 0 {
+		return nil
+	}
+
+	statement, err = db.statements.Get(queries.AddFileEvents)
+	if err != nil {
+		return err
+	}
+
+	_, err = statement.ExecContext(ctx, saved, pipeline, source)
+	if err != nil {
+		db.logger.Warn(err.Error())
+	}
+
 	return nil
 }
 
-// GetEventByNumber returns the event by number.
-func (db *DB) GetEventByNumber(ctx context.Context, guid string, number int) (events.Event, error) {
+// SaveFile saves the events and adds their file to the ledger in one transaction,
+// nothing is saved if an event fails or the context is done.
+func (db *DB) SaveFile(ctx context.Context, evs service.IEvents) error {
+	add, err := db.statements.Get(queries.AddFilename)
+	if err != nil {
+		return err
+	}
+	save, err := db.statements.Get(queries.SaveEvent)
+	if err != nil {
+		return err
+	}
+	count, err := db.statements.Get(queries.AddFileEvents)
+	if err != nil {
+		return err
+	}
+	claim, err := db.statements.Get(queries.ClaimNumbers)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	source, pipeline := evs.Source(), evs.Pipeline()
+	if _, err = tx.StmtContext(ctx, add).ExecContext(ctx, pipeline, source, ""); err != nil {
+		return fmt.Errorf("failed to add file %s: %w", source, err)
+	}
+
+	// the numbers are claimed in the transaction, so a failed file leaves no gaps
+	next, err := claimNumbers(ctx, tx.StmtContext(ctx, claim), evs)
+	if err != nil {
+		return err
+	}
+
+	ingestedAt := time.Now().UTC().Truncate(time.Microsecond)
+	saveTx := tx.StmtContext(ctx, save)
+	saved := 0
+	evs.Iter(func(d events.Event) (stop bool) {
+		_, err = saveTx.ExecContext(ctx, d.ID, d.Number, d.MQTT, d.InventoryID, d.UnitGUID,
+			d.MessageID, d.MessageText, d.Context, d.MessageClass,
+			d.Level, d.Area, d.Address, d.Block, d.Type, d.Bit, d.InvertBit,
+			source, ingestedAt, pipeline, next[d.UnitGUID])
+		next[d.UnitGUID]++
+		if err != nil {
+			return true
+		}
+		saved++
+		return false
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save events of %s: %w", source, err)
+	}
+
+	if _, err = tx.StmtContext(ctx, count).ExecContext(ctx, saved, pipeline, source); err != nil {
+		return fmt.Errorf("failed to count events of %s: %w", source, err)
+	}
+
+	return tx.Commit()
+}
+
+// claimNumbers claims the numbers of the events within their units and returns the first one of every unit.
+// The units are claimed in order, so the concurrent files never wait for each other in a cycle.
+func claimNumbers(ctx context.Context, claim *sql.Stmt, evs service.IEvents) (map[string]int, error) {
+	counts := make(map[string]int)
+	evs.Iter(func(d events.Event) (stop bool) {
+		counts[d.UnitGUID]++
+		return false
+	})
+
+	guids := make([]string, 0, len(counts))
+	for guid := range counts {
+		guids = append(guids, guid)
+	}
+	sort.Strings(guids)
+
+	next := make(map[string]int, len(counts))
+	for _, guid := range guids {
+		var last int
+		if err := claim.QueryRowContext(ctx, guid, counts[guid]).Scan(&last); err != nil {
+			return nil, fmt.Errorf("failed to number the events of unit %s: %w", guid, err)
+		}
+		next[guid] = last - counts[guid] + 1
+	}
+	return next, nil
+}
+
+// GetEventByNumber returns the event by its number within the unit, the numbers are shared by the pipelines
+// of the unit and kept when the older events are pruned, so the event of another pipeline is not found.
+func (db *DB) GetEventByNumber(ctx context.Context, pipeline, guid string, number int) (events.Event, error) {
 	if ctx.Err() != nil {
 		return events.Event{}, ctx.Err()
 	}
 
-	number--
-	statement, err := queries.GetPreparedStatement(queries.GetEvent)
+	statement, err := db.statements.Get(queries.GetEvent)
 	if err != nil {
 		return events.Event{}, err
 	}
 
-	var d events.Event
-	err = statement.QueryRowContext(ctx, guid, number).Scan(&d.ID, &d.Number, &d.MQTT, &d.InventoryID, &d.UnitGUID,
-		&d.MessageID, &d.MessageText, &d.Context, &d.MessageClass, &d.Level, &d.Area, &d.Address, &d.Block, &d.Type,
-		&d.Bit, &d.InvertBit)
+	d, err := scanEvent(statement.QueryRowContext(ctx, guid, pipeline, number))
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return events.Event{}, service.ErrEventNotFound
@@ -134,3 +300,17 @@ func (db *DB) GetEventByNumber(ctx context.Context, guid string, number int) (ev
 
 	return d, nil
 }
+
+// scanner is implemented by *sql.Row and *sql.Rows.
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// scanEvent scans a row with queries.EventColumns into the event.
+func scanEvent(row scanner) (events.Event, error) {
+	var d events.Event
+	err := row.Scan(&d.ID, &d.Number, &d.MQTT, &d.InventoryID, &d.UnitGUID,
+		&d.MessageID, &d.MessageText, &d.Context, &d.MessageClass, &d.Level, &d.Area, &d.Address, &d.Block, &d.Type,
+		&d.Bit, &d.InvertBit, &d.SourceFile, &d.IngestedAt, &d.Pipeline)
+	return d, err
+}
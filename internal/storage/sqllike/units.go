@@ -0,0 +1,172 @@
+package sqllike
+
+import (
+	"context"
+	"fmt"
+	"go-tsv-watcher/internal/storage/queries"
+	"go-tsv-watcher/internal/storage/service"
+	"time"
+)
+
+// timeLayouts are the formats of timestamps returned as text.
+var timeLayouts = []string{
+	"2006-01-02 15:04:05.999999999 -0700 MST",
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999",
+}
+
+// timeValue scans timestamps returned as time.Time or as text (sqlite aggregates).
+type timeValue struct {
+	time.Time
+}
+
+// Scan implements sql.Scanner.
+func (t *timeValue) Scan(src any) error {
+	var raw string
+	switch v := src.(type) {
+	case nil:
+		t.Time = time.Time{}
+		return nil
+	case time.Time:
+		t.Time = v.UTC()
+		return nil
+	case string:
+		raw = v
+	case []byte:
+		raw = string(v)
+	default:
+		return fmt.Errorf("can't scan %T into time", src)
+	}
+
+	for _, layout := range timeLayouts {
+		parsed, err := time.Parse(layout, raw)
+		if err == nil {
+			t.Time = parsed.UTC()
+			return nil
+		}
+	}
+
+	return fmt.Errorf("can't parse time %q", raw)
+}
+
+// ListUnits returns the units of the pipeline sorted by guid, of all pipelines if the pipeline is empty.
+func (db *DB) ListUnits(ctx context.Context, pipeline string) ([]service.Unit, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	return db.units(ctx, queries.UnitsStats, queries.UnitsInventory, queries.UnitsLatestLevel, pipeline)
+}
+
+// GetUnit returns the unit with its events of the pipeline broken down by class and area,
+// the events of all pipelines are counted if the pipeline is empty.
+func (db *DB) GetUnit(ctx context.Context, pipeline, guid string) (service.UnitSummary, error) {
+	if ctx.Err() != nil {
+		return service.UnitSummary{}, ctx.Err()
+	}
+
+	units, err := db.units(ctx, queries.UnitStats, queries.UnitInventory, queries.UnitLatestLevel, guid, pipeline)
+	if err != nil {
+		return service.UnitSummary{}, err
+	}
+
+	if len(units) == 0 {
+		return service.UnitSummary{}, service.ErrUnitNotFound
+	}
+
+	summary := service.UnitSummary{
+		Unit:           units[0],
+		ByMessageClass: make(map[string]int),
+		ByArea:         make(map[string]int),
+	}
+
+	rows, err := db.query(ctx, queries.UnitBreakdown, guid, pipeline)
+	if err != nil {
+		return service.UnitSummary{}, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var class, area string
+		var count int
+		if err = rows.Scan(&class, &area, &count); err != nil {
+			return service.UnitSummary{}, err
+		}
+		summary.ByMessageClass[class] += count
+		summary.ByArea[area] += count
+	}
+
+	return summary, rows.Err()
+}
+
+// units runs the stats, inventory and latest level queries and merges their results.
+func (db *DB) units(ctx context.Context, stats, inventory, latest int, args ...any) ([]service.Unit, error) {
+	rows, err := db.query(ctx, stats, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	var units []service.Unit
+	index := make(map[string]int)
+	for rows.Next() {
+		var u service.Unit
+		var first, last timeValue
+		if err = rows.Scan(&u.GUID, &u.Events, &first, &last); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		u.FirstSeen, u.LastSeen = first.Time, last.Time
+		u.InventoryIDs = []string{}
+
+		index[u.GUID] = len(units)
+		units = append(units, u)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	err = db.eachPair(ctx, inventory, args, func(guid string, value any) error {
+		if i, ok := index[guid]; ok {
+			units[i].AddInventoryID(*value.(*string))
+		}
+		return nil
+	}, new(string))
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.eachPair(ctx, latest, args, func(guid string, value any) error {
+		if i, ok := index[guid]; ok {
+			units[i].LatestLevel = *value.(*int)
+		}
+		return nil
+	}, new(int))
+	if err != nil {
+		return nil, err
+	}
+
+	return units, nil
+}
+
+// eachPair runs the query returning (UnitGUID, value) rows and calls cb for every row.
+func (db *DB) eachPair(ctx context.Context, name int, args []any, cb func(guid string, value any) error, value any) error {
+	rows, err := db.query(ctx, name, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var guid string
+		if err = rows.Scan(&guid, value); err != nil {
+			return err
+		}
+		if err = cb(guid, value); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
@@ -8,7 +8,6 @@ import (
 	"go-tsv-watcher/internal/events"
 	"go-tsv-watcher/internal/storage/itisadb"
 	"go-tsv-watcher/internal/storage/postgres"
-	"go-tsv-watcher/internal/storage/queries"
 	"go-tsv-watcher/internal/storage/service"
 	"go-tsv-watcher/internal/storage/sqlite"
 	"go-tsv-watcher/pkg/logger"
@@ -16,11 +15,35 @@ import (
 
 // Database interface
 type Database interface {
-	LoadFilenames(ctx context.Context, putter service.Adder) error
-	AddFilename(ctx context.Context, filename string, err error) error
+	LoadFilenames(ctx context.Context, pipeline string, putter service.Adder) error
+	AddFilename(ctx context.Context, pipeline, filename string, err error) error
 
 	SaveEvents(ctx context.Context, evs service.IEvents) error
-	GetEventByNumber(ctx context.Context, guid string, number int) (events.Event, error)
+	// SaveFile saves the events and adds their file to the ledger only if all of them are saved.
+	SaveFile(ctx context.Context, evs service.IEvents) error
+	GetEventByNumber(ctx context.Context, pipeline, guid string, number int) (events.Event, error)
+	ListEvents(ctx context.Context, query service.EventsQuery) ([]events.Event, error)
+
+	ListUnits(ctx context.Context, pipeline string) ([]service.Unit, error)
+	GetUnit(ctx context.Context, pipeline, guid string) (service.UnitSummary, error)
+
+	ListFiles(ctx context.Context, pipeline, status string) ([]service.File, error)
+	GetFile(ctx context.Context, pipeline, name string) (service.FileDetails, error)
+	DeleteFile(ctx context.Context, pipeline, name string) (int, error)
+	ResetFile(ctx context.Context, pipeline, name string) error
+
+	Prune(ctx context.Context, policy service.RetentionPolicy) (int, error)
+
+	SaveAPIKey(ctx context.Context, key service.APIKey) error
+	ListAPIKeys(ctx context.Context) ([]service.APIKey, error)
+	GetAPIKey(ctx context.Context, hash string) (service.APIKey, error)
+	DeleteAPIKey(ctx context.Context, id string) error
+
+	LoadQuotas(ctx context.Context, day string) (map[string]int, error)
+	SaveQuotas(ctx context.Context, day string, used map[string]int) error
+
+	Ping(ctx context.Context) error
+	Close() error
 }
 
 // Storage interface for storage
@@ -32,43 +55,92 @@ type Storage Database
 type Config struct {
 	Type           string
 	DataSourceCred string
+	// AutoMigrate applies pending migrations on start.
+	AutoMigrate bool
 }
 
+// Types are the supported storage types.
+var Types = []string{"postgres", "sqlite3", "itisadb"}
+
+// ErrUnknownType occurs when the storage type is not supported.
+var ErrUnknownType = errors.New("unknown database type")
+
 // New storage
 func New(cfg *Config, logger logger.ILogger) (Storage, error) {
 	var st Storage
-	var err error
-	var db *sql.DB
-
-	switch cfg.Type {
-	case "postgres":
-		db, err = sql.Open("postgres", cfg.DataSourceCred)
-		if err != nil {
-			panic(err)
-		}
-
-		st = postgres.New(db, "file://migrations/sqlite3", logger)
-	case "sqlite3":
-		db, err = sql.Open("sqlite", cfg.DataSourceCred)
-		if err != nil {
-			panic(err)
-		}
 
-		st = sqlite.New(db, "file://migrations/sqlite3", logger)
-	case "itisadb":
+	if cfg.Type == "itisadb" {
 		nosql, err := itisadb.New(context.Background(), cfg.DataSourceCred, logger)
 		if err != nil {
 			return nil, fmt.Errorf("failed to connect to itisadb: %w", err)
 		}
 
 		return nosql, nil
-	default:
-		return nil, errors.New("unknown database type")
 	}
 
-	if err = queries.Prepare(db, cfg.Type); err != nil {
+	db, err := open(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = checkSchema(cfg, db); err != nil {
+		return nil, err
+	}
+
+	switch cfg.Type {
+	case "postgres":
+		st, err = postgres.New(db, logger)
+	case "sqlite3":
+		st, err = sqlite.New(db, logger)
+	}
+	if err != nil {
 		return nil, err
 	}
 
 	return st, nil
 }
+
+// open opens a connection to the sql like storage.
+func open(cfg *Config) (*sql.DB, error) {
+	var driver string
+	switch cfg.Type {
+	case "postgres":
+		driver = "postgres"
+	case "sqlite3":
+		driver = "sqlite"
+	default:
+		return nil, ErrUnknownType
+	}
+
+	db, err := sql.Open(driver, cfg.DataSourceCred)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", cfg.Type, err)
+	}
+
+	return db, nil
+}
+
+// checkSchema applies pending migrations when AutoMigrate is set,
+// otherwise it makes sure the schema is up-to-date.
+func checkSchema(cfg *Config, db *sql.DB) error {
+	m, err := newMigrator(cfg.Type, db)
+	if err != nil {
+		return err
+	}
+
+	if cfg.AutoMigrate {
+		return m.Up()
+	}
+
+	status, err := m.Status()
+	if err != nil {
+		return err
+	}
+
+	if status.Dirty || status.Version != status.Latest {
+		return fmt.Errorf("%w: version %d (dirty: %v), latest %d, run `watcher migrate up`",
+			ErrSchemaOutdated, status.Version, status.Dirty, status.Latest)
+	}
+
+	return nil
+}
@@ -0,0 +1,83 @@
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"fmt"
+	"go-tsv-watcher/pkg/logger"
+	"os"
+	"sync"
+	"time"
+)
+
+// reloader serves the certificate of the files and loads it again when they change.
+type reloader struct {
+	certFile, keyFile string
+	interval          time.Duration
+	logger            logger.ILogger
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	version string
+	checked time.Time
+}
+
+// newReloader loads the certificate of the files.
+func newReloader(certFile, keyFile string, interval time.Duration, loggerInstance logger.ILogger) (*reloader, error) {
+	r := &reloader{certFile: certFile, keyFile: keyFile, interval: interval, logger: loggerInstance}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	r.checked = time.Now()
+	return r, nil
+}
+
+// GetCertificate implements tls.Config.GetCertificate,
+// the files are checked at most once per interval.
+func (r *reloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if now := time.Now(); now.Sub(r.checked) >= r.interval {
+		r.checked = now
+		if version, err := r.fileVersion(); err != nil {
+			r.logger.Warn(fmt.Sprintf("Failed to check TLS certificate: %v", err))
+		} else if version != r.version {
+			if err := r.load(); err != nil {
+				r.logger.Warn(fmt.Sprintf("Failed to reload TLS certificate, the previous one is kept: %v", err))
+			} else {
+				r.logger.Info("TLS certificate reloaded")
+			}
+		}
+	}
+
+	return r.cert, nil
+}
+
+// load reads the certificate and remembers the version of the files.
+func (r *reloader) load() error {
+	version, err := r.fileVersion()
+	if err != nil {
+		return err
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+
+	r.cert, r.version = &cert, version
+	return nil
+}
+
+// fileVersion returns the modification times and sizes of the files.
+func (r *reloader) fileVersion() (string, error) {
+	version := ""
+	for _, path := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to stat TLS file: %w", err)
+		}
+		version += fmt.Sprintf("%d/%d;", info.ModTime().UnixNano(), info.Size())
+	}
+	return version, nil
+}
@@ -0,0 +1,153 @@
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"go-tsv-watcher/pkg/logger"
+	"os"
+	"strings"
+	"time"
+)
+
+// DefaultReloadInterval is how often the certificate files are checked for rotation by default.
+const DefaultReloadInterval = time.Minute
+
+// ErrNoCertificate error occurs when neither the certificate files nor the self-signed mode are set
+var ErrNoCertificate = errors.New("cert_file and key_file are required unless self_signed is set")
+
+// Config is the TLS config of the servers.
+type Config struct {
+	// CertFile and KeyFile are the PEM certificate chain and private key,
+	// they are reloaded when the files change.
+	CertFile string
+	KeyFile  string
+	// ReloadInterval is how often the files are checked for rotation, DefaultReloadInterval if zero.
+	ReloadInterval time.Duration
+
+	// MinVersion is tls.VersionTLS12 if zero.
+	MinVersion uint16
+	// CipherSuites of TLS 1.2 and older, the Go defaults if nil.
+	CipherSuites []uint16
+
+	// ClientCAFile is the PEM bundle of the client CAs, the client certificates are not requested if empty.
+	ClientCAFile string
+	// ClientAuth is tls.RequireAndVerifyClientCert if zero and ClientCAFile is set.
+	ClientAuth tls.ClientAuthType
+
+	// SelfSigned serves a certificate generated on start instead of the files, for development only.
+	SelfSigned bool
+}
+
+// versions are the TLS versions by name.
+var versions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// ParseVersion returns the TLS version by name, e.g. 1.2.
+func ParseVersion(name string) (uint16, error) {
+	v, ok := versions[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown TLS version %q", name)
+	}
+	return v, nil
+}
+
+// ParseCipherSuites returns the IDs of the cipher suites by name, the insecure ones are rejected.
+func ParseCipherSuites(names []string) ([]uint16, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	suites := make(map[string]uint16)
+	for _, s := range tls.CipherSuites() {
+		suites[s.Name] = s.ID
+	}
+
+	ids := make([]uint16, 0, len(names))
+	for _, name := range names {
+		id, ok := suites[strings.TrimSpace(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown or insecure cipher suite %q", name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// ParseClientAuth returns the client authentication policy by name: require or verify_if_given.
+func ParseClientAuth(name string) (tls.ClientAuthType, error) {
+	switch name {
+	case "", "require":
+		return tls.RequireAndVerifyClientCert, nil
+	case "verify_if_given":
+		return tls.VerifyClientCertIfGiven, nil
+	default:
+		return 0, fmt.Errorf("unknown client_auth %q", name)
+	}
+}
+
+// New returns the TLS config of a server, the failed reloads are logged and the previous certificate is kept.
+func New(cfg Config, loggerInstance logger.ILogger) (*tls.Config, error) {
+	tc := &tls.Config{
+		MinVersion:   cfg.MinVersion,
+		CipherSuites: cfg.CipherSuites,
+	}
+	if tc.MinVersion == 0 {
+		tc.MinVersion = tls.VersionTLS12
+	}
+
+	switch {
+	case cfg.CertFile != "" && cfg.KeyFile != "":
+		interval := cfg.ReloadInterval
+		if interval == 0 {
+			interval = DefaultReloadInterval
+		}
+		r, err := newReloader(cfg.CertFile, cfg.KeyFile, interval, loggerInstance)
+		if err != nil {
+			return nil, err
+		}
+		tc.GetCertificate = r.GetCertificate
+	case cfg.SelfSigned:
+		cert, err := selfSigned(time.Now())
+		if err != nil {
+			return nil, err
+		}
+		loggerInstance.Warn("TLS uses a self-signed certificate, do not use it in production")
+		tc.Certificates = []tls.Certificate{cert}
+	default:
+		return nil, ErrNoCertificate
+	}
+
+	if cfg.ClientCAFile != "" {
+		pool, err := loadCAs(cfg.ClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		tc.ClientCAs = pool
+		tc.ClientAuth = cfg.ClientAuth
+		if tc.ClientAuth == tls.NoClientCert {
+			tc.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+	}
+
+	return tc, nil
+}
+
+// loadCAs reads the PEM bundle of the CAs.
+func loadCAs(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client CA file: %w", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates in client CA file %s", path)
+	}
+	return pool, nil
+}
@@ -0,0 +1,256 @@
+package tlsconfig
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"github.com/go-chi/httplog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go-tsv-watcher/pkg/logger"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testLogger returns the logger of the tests.
+func testLogger() logger.ILogger {
+	return logger.New(httplog.NewLogger("watcher", httplog.Options{Concise: true}))
+}
+
+// issued is a generated certificate with its key.
+type issued struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	der  []byte
+}
+
+// issue generates a certificate signed by the parent, self-signed if nil.
+func issue(t *testing.T, name string, parent *issued, isCA bool) *issued {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+	}
+
+	signer, signerKey := template, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return &issued{cert: cert, key: key, der: der}
+}
+
+// write writes the certificate and the key as PEM files, returns their paths.
+func (i *issued) write(t *testing.T, dir, name string) (string, string) {
+	certFile, keyFile := filepath.Join(dir, name+".crt"), filepath.Join(dir, name+".key")
+
+	keyDER, err := x509.MarshalECPrivateKey(i.key)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: i.der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	return certFile, keyFile
+}
+
+// tlsCert returns the certificate for a tls.Config.
+func (i *issued) tlsCert() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{i.der}, PrivateKey: i.key}
+}
+
+func TestNew(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := issue(t, "server", nil, false).write(t, dir, "server")
+
+	badCA := filepath.Join(dir, "bad-ca.pem")
+	require.NoError(t, os.WriteFile(badCA, []byte("not a certificate"), 0o600))
+
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr bool
+		check   func(t *testing.T, tc *tls.Config)
+	}{
+		{
+			name: "Files",
+			cfg:  Config{CertFile: certFile, KeyFile: keyFile},
+			check: func(t *testing.T, tc *tls.Config) {
+				assert.Equal(t, uint16(tls.VersionTLS12), tc.MinVersion)
+				assert.Equal(t, tls.NoClientCert, tc.ClientAuth)
+				cert, err := tc.GetCertificate(nil)
+				require.NoError(t, err)
+				assert.NotEmpty(t, cert.Certificate)
+			},
+		},
+		{
+			name: "Settings",
+			cfg: Config{
+				CertFile:     certFile,
+				KeyFile:      keyFile,
+				MinVersion:   tls.VersionTLS13,
+				CipherSuites: []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256},
+				ClientCAFile: certFile,
+				ClientAuth:   tls.VerifyClientCertIfGiven,
+			},
+			check: func(t *testing.T, tc *tls.Config) {
+				assert.Equal(t, uint16(tls.VersionTLS13), tc.MinVersion)
+				assert.Equal(t, []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256}, tc.CipherSuites)
+				assert.Equal(t, tls.VerifyClientCertIfGiven, tc.ClientAuth)
+				assert.NotNil(t, tc.ClientCAs)
+			},
+		},
+		{
+			name: "Self-signed",
+			cfg:  Config{SelfSigned: true},
+			check: func(t *testing.T, tc *tls.Config) {
+				require.Len(t, tc.Certificates, 1)
+				cert, err := x509.ParseCertificate(tc.Certificates[0].Certificate[0])
+				require.NoError(t, err)
+				assert.NoError(t, cert.VerifyHostname("localhost"))
+			},
+		},
+		{
+			name:    "No certificate",
+			cfg:     Config{},
+			wantErr: true,
+		},
+		{
+			name:    "Missing files",
+			cfg:     Config{CertFile: filepath.Join(dir, "none.crt"), KeyFile: keyFile},
+			wantErr: true,
+		},
+		{
+			name:    "Bad client CA",
+			cfg:     Config{CertFile: certFile, KeyFile: keyFile, ClientCAFile: badCA},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tc, err := New(tt.cfg, testLogger())
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			tt.check(t, tc)
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	v, err := ParseVersion("1.3")
+	require.NoError(t, err)
+	assert.Equal(t, uint16(tls.VersionTLS13), v)
+	_, err = ParseVersion("1.4")
+	assert.Error(t, err)
+
+	suites, err := ParseCipherSuites([]string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"})
+	require.NoError(t, err)
+	assert.Equal(t, []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256}, suites)
+	_, err = ParseCipherSuites([]string{"TLS_RSA_WITH_RC4_128_SHA"})
+	assert.Error(t, err)
+
+	auth, err := ParseClientAuth("")
+	require.NoError(t, err)
+	assert.Equal(t, tls.RequireAndVerifyClientCert, auth)
+	_, err = ParseClientAuth("maybe")
+	assert.Error(t, err)
+}
+
+func TestNew_mutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := issue(t, "ca", nil, true)
+	caFile, _ := ca.write(t, dir, "ca")
+	certFile, keyFile := issue(t, "server", ca, false).write(t, dir, "server")
+
+	tc, err := New(Config{CertFile: certFile, KeyFile: keyFile, ClientCAFile: caFile}, testLogger())
+	require.NoError(t, err)
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), ErrorLog: log.New(io.Discard, "", 0)}
+	go server.Serve(tls.NewListener(lis, tc))
+	defer server.Close()
+	url := "https://" + lis.Addr().String()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	client := func(certs ...tls.Certificate) *http.Client {
+		return &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots, Certificates: certs}}}
+	}
+
+	_, err = client().Get(url)
+	assert.Error(t, err, "client without a certificate")
+
+	_, err = client(issue(t, "stranger", nil, false).tlsCert()).Get(url)
+	assert.Error(t, err, "client certificate of another CA")
+
+	resp, err := client(issue(t, "client", ca, false).tlsCert()).Get(url)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestReloader(t *testing.T) {
+	dir := t.TempDir()
+	first := issue(t, "first", nil, false)
+	certFile, keyFile := first.write(t, dir, "server")
+
+	r, err := newReloader(certFile, keyFile, time.Millisecond, testLogger())
+	require.NoError(t, err)
+
+	name := func() string {
+		cert, err := r.GetCertificate(nil)
+		require.NoError(t, err)
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		require.NoError(t, err)
+		return leaf.Subject.CommonName
+	}
+	touch := func() {
+		later := time.Now().Add(time.Minute)
+		require.NoError(t, os.Chtimes(certFile, later, later))
+		require.NoError(t, os.Chtimes(keyFile, later, later))
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	assert.Equal(t, "first", name())
+
+	// rotated certificate
+	issue(t, "second", nil, false).write(t, dir, "server")
+	touch()
+	assert.Equal(t, "second", name())
+
+	// a broken rotation keeps the previous certificate
+	require.NoError(t, os.WriteFile(keyFile, []byte("broken"), 0o600))
+	touch()
+	assert.Equal(t, "second", name())
+
+	// not checked again before the interval
+	r.interval = time.Hour
+	issue(t, "third", nil, false).write(t, dir, "server")
+	touch()
+	assert.Equal(t, "second", name())
+}
@@ -0,0 +1,92 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"go-tsv-watcher/internal/auth"
+	"go-tsv-watcher/internal/storage/service"
+	"time"
+)
+
+// ErrInvalidAPIKey error occurs when the API key to create has no name or unknown scopes
+var ErrInvalidAPIKey = errors.New("invalid api key")
+
+// CreatedAPIKey is the created API key with its secret, the secret is not stored.
+type CreatedAPIKey struct {
+	service.APIKey
+	Key string `json:"key"`
+}
+
+// CreateAPIKey creates an API key with the scopes, the secret is returned only once.
+func (u *UseCase) CreateAPIKey(ctx context.Context, name string, scopes []string) (CreatedAPIKey, error) {
+	if name == "" {
+		return CreatedAPIKey{}, fmt.Errorf("%w: name is required", ErrInvalidAPIKey)
+	}
+	if len(scopes) == 0 {
+		return CreatedAPIKey{}, fmt.Errorf("%w: scopes are required", ErrInvalidAPIKey)
+	}
+	if err := auth.ValidateScopes(scopes); err != nil {
+		return CreatedAPIKey{}, fmt.Errorf("%w: %v", ErrInvalidAPIKey, err)
+	}
+
+	secret, err := auth.GenerateKey()
+	if err != nil {
+		return CreatedAPIKey{}, err
+	}
+
+	key := service.APIKey{
+		ID:        uuid.NewString(),
+		Name:      name,
+		Hash:      auth.HashKey(secret),
+		Scopes:    scopes,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err = u.storage.SaveAPIKey(ctx, key); err != nil {
+		u.logger.Warn(err.Error())
+		return CreatedAPIKey{}, ErrStorageIsUnavailable
+	}
+
+	return CreatedAPIKey{APIKey: key, Key: secret}, nil
+}
+
+// ListAPIKeys returns the stored API keys without their secrets.
+func (u *UseCase) ListAPIKeys(ctx context.Context) ([]service.APIKey, error) {
+	keys, err := u.storage.ListAPIKeys(ctx)
+	if err != nil {
+		u.logger.Warn(err.Error())
+		return nil, ErrStorageIsUnavailable
+	}
+
+	if keys == nil {
+		keys = []service.APIKey{}
+	}
+	return keys, nil
+}
+
+// GetAPIKey returns the stored API key by the hash of its secret.
+func (u *UseCase) GetAPIKey(ctx context.Context, hash string) (service.APIKey, error) {
+	key, err := u.storage.GetAPIKey(ctx, hash)
+	if err != nil {
+		if errors.Is(err, service.ErrAPIKeyNotFound) {
+			return key, err
+		}
+		u.logger.Warn(err.Error())
+		return key, ErrStorageIsUnavailable
+	}
+	return key, nil
+}
+
+// DeleteAPIKey revokes the stored API key.
+func (u *UseCase) DeleteAPIKey(ctx context.Context, id string) error {
+	err := u.storage.DeleteAPIKey(ctx, id)
+	if err != nil {
+		if errors.Is(err, service.ErrAPIKeyNotFound) {
+			return err
+		}
+		u.logger.Warn(err.Error())
+		return ErrStorageIsUnavailable
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"github.com/go-chi/httplog"
+	"github.com/golang/mock/gomock"
+	"go-tsv-watcher/internal/auth"
+	"go-tsv-watcher/internal/storage/mocks"
+	"go-tsv-watcher/internal/storage/service"
+	"go-tsv-watcher/pkg/logger"
+	"testing"
+)
+
+func TestUseCase_CreateAPIKey(t *testing.T) {
+	tests := []struct {
+		name         string
+		keyName      string
+		scopes       []string
+		wantErr      error
+		mockBehavior func(r *mocks.MockStorage)
+	}{
+		{
+			name:    "ok",
+			keyName: "ci",
+			scopes:  []string{auth.ScopeUpload},
+			mockBehavior: func(r *mocks.MockStorage) {
+				r.EXPECT().SaveAPIKey(gomock.Any(), gomock.Any()).Return(nil)
+			},
+		},
+		{
+			name:         "no name",
+			scopes:       []string{auth.ScopeUpload},
+			wantErr:      ErrInvalidAPIKey,
+			mockBehavior: func(r *mocks.MockStorage) {},
+		},
+		{
+			name:         "unknown scope",
+			keyName:      "ci",
+			scopes:       []string{"root"},
+			wantErr:      ErrInvalidAPIKey,
+			mockBehavior: func(r *mocks.MockStorage) {},
+		},
+		{
+			name:    "storage error",
+			keyName: "ci",
+			scopes:  []string{auth.ScopeAdmin},
+			wantErr: ErrStorageIsUnavailable,
+			mockBehavior: func(r *mocks.MockStorage) {
+				r.EXPECT().SaveAPIKey(gomock.Any(), gomock.Any()).Return(errors.New("closed"))
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+			st := mocks.NewMockStorage(c)
+			tt.mockBehavior(st)
+
+			loggerInstance := httplog.NewLogger("watcher", httplog.Options{
+				Concise: true,
+			})
+			u := &UseCase{storage: st, logger: logger.New(loggerInstance)}
+
+			key, err := u.CreateAPIKey(context.Background(), tt.keyName, tt.scopes)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CreateAPIKey() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			// only the hash of the returned secret is stored
+			if key.Key == "" || key.Hash != auth.HashKey(key.Key) || key.ID == "" {
+				t.Errorf("CreateAPIKey() got = %+v", key)
+			}
+		})
+	}
+}
+
+func TestUseCase_DeleteAPIKey(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+	st := mocks.NewMockStorage(c)
+
+	loggerInstance := httplog.NewLogger("watcher", httplog.Options{
+		Concise: true,
+	})
+	u := &UseCase{storage: st, logger: logger.New(loggerInstance)}
+
+	st.EXPECT().DeleteAPIKey(gomock.Any(), "k1").Return(service.ErrAPIKeyNotFound)
+	if err := u.DeleteAPIKey(context.Background(), "k1"); !errors.Is(err, service.ErrAPIKeyNotFound) {
+		t.Errorf("DeleteAPIKey() error = %v, want %v", err, service.ErrAPIKeyNotFound)
+	}
+
+	st.EXPECT().DeleteAPIKey(gomock.Any(), "k2").Return(errors.New("closed"))
+	if err := u.DeleteAPIKey(context.Background(), "k2"); !errors.Is(err, ErrStorageIsUnavailable) {
+		t.Errorf("DeleteAPIKey() error = %v, want %v", err, ErrStorageIsUnavailable)
+	}
+}
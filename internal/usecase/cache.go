@@ -0,0 +1,212 @@
+package usecase
+
+import (
+	"container/list"
+	"go-tsv-watcher/internal/events"
+	"go-tsv-watcher/internal/storage/service"
+	"sync"
+	"time"
+)
+
+// CacheStats are the statistics of the event cache.
+type CacheStats struct {
+	Enabled  bool   `json:"enabled"`
+	Size     int    `json:"size"`
+	Capacity int    `json:"capacity"`
+	TTL      string `json:"ttl"`
+	Hits     uint64 `json:"hits"`
+	Misses   uint64 `json:"misses"`
+	// Evictions are the entries dropped for the capacity or the TTL.
+	Evictions uint64 `json:"evictions"`
+	// Invalidations are the entries dropped because the events of their unit changed.
+	Invalidations uint64  `json:"invalidations"`
+	HitRatio      float64 `json:"hit_ratio"`
+}
+
+// eventKey is the cached lookup of GetEventByNumber.
+type eventKey struct {
+	pipeline string
+	unit     string
+	number   int
+}
+
+// cacheEntry is a cached event with its expiration.
+type cacheEntry struct {
+	key     eventKey
+	event   events.Event
+	expires time.Time
+}
+
+// eventCache is a bounded LRU of the events by unit and number with a TTL.
+type eventCache struct {
+	capacity int
+	ttl      time.Duration
+
+	mu    sync.Mutex
+	lru   *list.List
+	items map[eventKey]*list.Element
+	units map[string]map[eventKey]*list.Element
+	// version changes on every invalidation, so the lookups started before it are not cached
+	version uint64
+	stats   CacheStats
+	now     func() time.Time
+}
+
+// newEventCache eventCache constructor.
+func newEventCache(capacity int, ttl time.Duration) *eventCache {
+	return &eventCache{
+		capacity: capacity,
+		ttl:      ttl,
+		lru:      list.New(),
+		items:    make(map[eventKey]*list.Element),
+		units:    make(map[string]map[eventKey]*list.Element),
+		now:      time.Now,
+	}
+}
+
+// SetEventCache caches up to size events found by GetEventByNumber for the TTL,
+// the cache is disabled if the size is not positive.
+func (u *UseCase) SetEventCache(size int, ttl time.Duration) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.cache = nil
+	if size > 0 {
+		u.cache = newEventCache(size, ttl)
+	}
+}
+
+// eventCache returns the event cache, nil if disabled.
+func (u *UseCase) eventCache() *eventCache {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+	return u.cache
+}
+
+// CacheStats returns the statistics of the event cache.
+func (u *UseCase) CacheStats() CacheStats {
+	c := u.eventCache()
+	if c == nil {
+		return CacheStats{}
+	}
+	return c.snapshot()
+}
+
+// invalidateUnits drops the cached events of the units of the events.
+func (u *UseCase) invalidateUnits(evs service.IEvents) {
+	c := u.eventCache()
+	if c == nil {
+		return
+	}
+
+	units := make(map[string]struct{})
+	evs.Iter(func(e events.Event) (stop bool) {
+		units[e.UnitGUID] = struct{}{}
+		return false
+	})
+	for unit := range units {
+		c.invalidateUnit(unit)
+	}
+}
+
+// invalidateAll drops all cached events, e.g. after the events of a file are deleted.
+func (u *UseCase) invalidateAll() {
+	if c := u.eventCache(); c != nil {
+		c.purge()
+	}
+}
+
+// get returns the cached event and the version to put the missed event with.
+func (c *eventCache) get(key eventKey) (events.Event, uint64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if ok && c.ttl > 0 && c.now().After(el.Value.(*cacheEntry).expires) {
+		c.remove(el)
+		c.stats.Evictions++
+		ok = false
+	}
+	if !ok {
+		c.stats.Misses++
+		return events.Event{}, c.version, false
+	}
+
+	c.stats.Hits++
+	c.lru.MoveToFront(el)
+	return el.Value.(*cacheEntry).event, c.version, true
+}
+
+// put caches the event unless the cache was invalidated since the version.
+func (c *eventCache) put(key eventKey, e events.Event, version uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if version != c.version {
+		return
+	}
+
+	if el, ok := c.items[key]; ok {
+		c.remove(el)
+	}
+	for c.lru.Len() >= c.capacity {
+		c.remove(c.lru.Back())
+		c.stats.Evictions++
+	}
+
+	el := c.lru.PushFront(&cacheEntry{key: key, event: e, expires: c.now().Add(c.ttl)})
+	c.items[key] = el
+	if c.units[key.unit] == nil {
+		c.units[key.unit] = make(map[eventKey]*list.Element)
+	}
+	c.units[key.unit][key] = el
+}
+
+// invalidateUnit drops the cached events of the unit.
+func (c *eventCache) invalidateUnit(unit string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.version++
+	for _, el := range c.units[unit] {
+		c.remove(el)
+		c.stats.Invalidations++
+	}
+}
+
+// purge drops all cached events.
+func (c *eventCache) purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.version++
+	c.stats.Invalidations += uint64(c.lru.Len())
+	c.lru.Init()
+	c.items = make(map[eventKey]*list.Element)
+	c.units = make(map[string]map[eventKey]*list.Element)
+}
+
+// remove drops the entry, the caller holds the lock.
+func (c *eventCache) remove(el *list.Element) {
+	key := el.Value.(*cacheEntry).key
+	c.lru.Remove(el)
+	delete(c.items, key)
+	delete(c.units[key.unit], key)
+	if len(c.units[key.unit]) == 0 {
+		delete(c.units, key.unit)
+	}
+}
+
+// snapshot returns the statistics.
+func (c *eventCache) snapshot() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	stats.Enabled, stats.Size, stats.Capacity = true, c.lru.Len(), c.capacity
+	stats.TTL = c.ttl.String()
+	if total := stats.Hits + stats.Misses; total > 0 {
+		stats.HitRatio = float64(stats.Hits) / float64(total)
+	}
+	return stats
+}
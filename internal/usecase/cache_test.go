@@ -0,0 +1,122 @@
+package usecase
+
+import (
+	"context"
+	"github.com/go-chi/httplog"
+	"github.com/golang/mock/gomock"
+	"go-tsv-watcher/internal/events"
+	"go-tsv-watcher/internal/storage/mocks"
+	"go-tsv-watcher/internal/storage/service"
+	"go-tsv-watcher/pkg/logger"
+	"testing"
+	"time"
+)
+
+func TestEventCache(t *testing.T) {
+	now := time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)
+	c := newEventCache(2, time.Minute)
+	c.now = func() time.Time { return now }
+
+	put := func(unit string, number int) {
+		_, version, _ := c.get(eventKey{unit: unit, number: number})
+		c.put(eventKey{unit: unit, number: number}, events.Event{UnitGUID: unit, Number: number}, version)
+	}
+	cached := func(unit string, number int) bool {
+		_, _, ok := c.get(eventKey{unit: unit, number: number})
+		return ok
+	}
+
+	put("a", 1)
+	put("a", 2)
+	if !cached("a", 1) {
+		t.Fatal("a/1 is not cached")
+	}
+
+	// a/2 is the least recently used
+	put("b", 1)
+	if cached("a", 2) || !cached("a", 1) || !cached("b", 1) {
+		t.Errorf("a/2 is not evicted: %+v", c.snapshot())
+	}
+
+	c.invalidateUnit("a")
+	if cached("a", 1) || !cached("b", 1) {
+		t.Errorf("a is not invalidated: %+v", c.snapshot())
+	}
+
+	now = now.Add(2 * time.Minute)
+	if cached("b", 1) {
+		t.Error("b/1 is not expired")
+	}
+
+	// a lookup started before an invalidation is not cached
+	_, version, _ := c.get(eventKey{unit: "a", number: 3})
+	c.invalidateUnit("b")
+	c.put(eventKey{unit: "a", number: 3}, events.Event{}, version)
+	if cached("a", 3) {
+		t.Error("stale a/3 is cached")
+	}
+
+	stats := c.snapshot()
+	want := CacheStats{Enabled: true, Size: 0, Capacity: 2, TTL: "1m0s", Hits: 4, Misses: 8, Evictions: 2, Invalidations: 1}
+	want.HitRatio = 4.0 / 12
+	if stats != want {
+		t.Errorf("snapshot() got = %+v, want %+v", stats, want)
+	}
+}
+
+func TestUseCase_GetEventByNumber_cache(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+	st := mocks.NewMockStorage(c)
+
+	loggerInstance := httplog.NewLogger("watcher", httplog.Options{
+		Concise: true,
+	})
+	u := New(st, t.TempDir(), logger.New(loggerInstance))
+	if stats := u.CacheStats(); stats.Enabled {
+		t.Errorf("CacheStats() of the disabled cache got = %+v", stats)
+	}
+	u.SetEventCache(10, time.Minute)
+
+	ctx := context.Background()
+	ev := events.Event{ID: "1", UnitGUID: "unit1", Number: 1}
+
+	// read through once, then from the cache
+	st.EXPECT().GetEventByNumber(gomock.Any(), "", "unit1", 1).Return(ev, nil)
+	for i := 0; i < 3; i++ {
+		got, err := u.GetEventByNumber(ctx, "", "unit1", 1)
+		if err != nil || got != ev {
+			t.Fatalf("GetEventByNumber() got = %+v, error = %v", got, err)
+		}
+	}
+
+	// the not found events are not cached
+	st.EXPECT().GetEventByNumber(gomock.Any(), "", "unit1", 2).Return(events.Event{}, service.ErrEventNotFound).Times(2)
+	for i := 0; i < 2; i++ {
+		if _, err := u.GetEventByNumber(ctx, "", "unit1", 2); err != service.ErrEventNotFound {
+			t.Fatalf("GetEventByNumber() error = %v", err)
+		}
+	}
+
+	// new events of the unit are read again
+	u.invalidateUnits(eventStub{events: []events.Event{{UnitGUID: "unit1", Number: 2}}})
+	st.EXPECT().GetEventByNumber(gomock.Any(), "", "unit1", 1).Return(ev, nil)
+	if _, err := u.GetEventByNumber(ctx, "", "unit1", 1); err != nil {
+		t.Fatalf("GetEventByNumber() error = %v", err)
+	}
+
+	// deleted events are read again
+	st.EXPECT().DeleteFile(gomock.Any(), events.DefaultPipeline, "a.tsv").Return(1, nil)
+	if _, err := u.DeleteFile(ctx, "", "a.tsv"); err != nil {
+		t.Fatalf("DeleteFile() error = %v", err)
+	}
+	st.EXPECT().GetEventByNumber(gomock.Any(), "", "unit1", 1).Return(events.Event{}, service.ErrEventNotFound)
+	if _, err := u.GetEventByNumber(ctx, "", "unit1", 1); err != service.ErrEventNotFound {
+		t.Fatalf("GetEventByNumber() error = %v", err)
+	}
+
+	stats := u.CacheStats()
+	if stats.Hits != 2 || stats.Misses != 5 || stats.Invalidations != 2 {
+		t.Errorf("CacheStats() got = %+v", stats)
+	}
+}
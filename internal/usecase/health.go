@@ -0,0 +1,215 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"go-tsv-watcher/internal/report"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Component states.
+const (
+	StateOK     = "ok"
+	StateFailed = "failed"
+)
+
+// CheckTimeout limits every readiness check, the slow component is failed.
+const CheckTimeout = 2 * time.Second
+
+// ErrWatcherStopped error occurs when the watcher of the pipeline is not running
+var ErrWatcherStopped = errors.New("watcher is stopped")
+
+// Check returns the problem of a component, nil if it is ready.
+type Check func(ctx context.Context) error
+
+// namedCheck is the readiness check added for a component.
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+// Component is the state of a part of the service checked by the readiness probe.
+type Component struct {
+	Name  string `json:"name"`
+	State string `json:"state"`
+	Error string `json:"error,omitempty"`
+}
+
+// PipelineStatus is the state of the watcher of a pipeline.
+type PipelineStatus struct {
+	Name      string `json:"name"`
+	Directory string `json:"directory"`
+	Watching  bool   `json:"watching"`
+	// LastScan is the time of the last scan of the directory, nil before the first one.
+	LastScan *time.Time `json:"last_scan,omitempty"`
+	// Queued is the number of the found files waiting to be ingested.
+	Queued      int        `json:"queued"`
+	LastFile    string     `json:"last_file,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+	LastErrorAt *time.Time `json:"last_error_at,omitempty"`
+}
+
+// Status is the detailed state of the service.
+type Status struct {
+	Ready      bool             `json:"ready"`
+	StartedAt  time.Time        `json:"started_at"`
+	Components []Component      `json:"components"`
+	Pipelines  []PipelineStatus `json:"pipelines"`
+}
+
+// AddCheck adds the readiness check of a component the use case doesn't know about (e.g. the migrations).
+func (u *UseCase) AddCheck(name string, check Check) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.checks = append(u.checks, namedCheck{name: name, check: check})
+}
+
+// Ready checks the storages and the watched directories of the pipelines, the fonts of the reports
+// and the added checks. The service is ready if all components are ok.
+func (u *UseCase) Ready(ctx context.Context) (bool, []Component) {
+	u.mu.RLock()
+	pipelines := make([]*pipeline, 0, len(u.names))
+	for _, name := range u.names {
+		pipelines = append(pipelines, u.pipelines[name])
+	}
+	checks := append([]namedCheck(nil), u.checks...)
+	u.mu.RUnlock()
+
+	list := []namedCheck{{name: "storage", check: u.storage.Ping}}
+	for _, p := range pipelines {
+		if p.storage != u.storage {
+			list = append(list, namedCheck{name: "storage:" + p.Name, check: p.storage.Ping})
+		}
+	}
+	for _, p := range pipelines {
+		p := p
+		list = append(list, namedCheck{name: "directory:" + p.Name, check: func(context.Context) error {
+			return u.checkDirectory(p)
+		}})
+	}
+	list = append(list, namedCheck{name: "fonts", check: func(context.Context) error {
+		return u.checkFonts(pipelines)
+	}})
+	list = append(list, checks...)
+
+	// the checks run at once, so the probe takes the timeout at most
+	components := make([]Component, len(list))
+	var wg sync.WaitGroup
+	for i, c := range list {
+		wg.Add(1)
+		go func(i int, c namedCheck) {
+			defer wg.Done()
+			components[i] = Component{Name: c.name, State: StateOK}
+			if err := u.runCheck(ctx, c.check); err != nil {
+				components[i].State, components[i].Error = StateFailed, err.Error()
+			}
+		}(i, c)
+	}
+	wg.Wait()
+
+	ready := true
+	for _, c := range components {
+		ready = ready && c.State == StateOK
+	}
+	return ready, components
+}
+
+// runCheck returns the problem of the check or the timeout, the check not done in time is left running.
+func (u *UseCase) runCheck(ctx context.Context, check Check) error {
+	ctx, cancel := context.WithTimeout(ctx, u.checkTimeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- check(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Status returns the components checked by Ready with the state of the watchers of the pipelines.
+func (u *UseCase) Status(ctx context.Context) Status {
+	ready, components := u.Ready(ctx)
+	status := Status{Ready: ready, StartedAt: u.startedAt, Components: components}
+
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+	for _, name := range u.names {
+		p := u.pipelines[name]
+		ps := PipelineStatus{Name: p.Name, Directory: p.dir, LastFile: p.lastFile, LastError: p.lastError}
+		if !p.lastErrorAt.IsZero() {
+			at := p.lastErrorAt
+			ps.LastErrorAt = &at
+		}
+		if p.fileWatcher != nil {
+			stats := p.fileWatcher.Stats()
+			ps.Watching, ps.Queued = stats.Running, stats.Queued
+			if !stats.LastScan.IsZero() {
+				ps.LastScan = &stats.LastScan
+			}
+		}
+		status.Pipelines = append(status.Pipelines, ps)
+	}
+	return status
+}
+
+// checkDirectory returns the problem of the watcher of the pipeline or of its directory.
+func (u *UseCase) checkDirectory(p *pipeline) error {
+	u.mu.RLock()
+	fileWatcher, dir := p.fileWatcher, p.dir
+	u.mu.RUnlock()
+
+	if fileWatcher == nil {
+		return ErrNotWatching
+	}
+	if stats := fileWatcher.Stats(); !stats.Running {
+		if stats.Err != nil {
+			return stats.Err
+		}
+		return ErrWatcherStopped
+	}
+
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	if _, err = d.Readdirnames(1); err != nil && err != io.EOF {
+		return err
+	}
+	return nil
+}
+
+// checkFonts returns the problem of the embedded fonts used by the PDF reports without configured fonts.
+func (u *UseCase) checkFonts(pipelines []*pipeline) error {
+	for _, p := range pipelines {
+		if u.reportConfig(p).Fonts == nil {
+			_, err := report.DefaultFonts()
+			return err
+		}
+	}
+	return nil
+}
+
+// setLastFile records the file last ingested by the pipeline.
+func (u *UseCase) setLastFile(p *pipeline, name string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	p.lastFile = name
+}
+
+// setLastError records the last problem of the pipeline.
+func (u *UseCase) setLastError(p *pipeline, msg string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	p.lastError, p.lastErrorAt = msg, time.Now()
+}
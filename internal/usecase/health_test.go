@@ -0,0 +1,91 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"github.com/go-chi/httplog"
+	"github.com/golang/mock/gomock"
+	"go-tsv-watcher/internal/events"
+	"go-tsv-watcher/internal/storage/mocks"
+	"go-tsv-watcher/internal/watcher"
+	"go-tsv-watcher/pkg/logger"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestUseCase_Ready(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+	st := mocks.NewMockStorage(c)
+	st.EXPECT().Ping(gomock.Any()).Return(nil).AnyTimes()
+
+	loggerInstance := httplog.NewLogger("watcher", httplog.Options{
+		Concise: true,
+	})
+	u := New(st, t.TempDir(), logger.New(loggerInstance))
+
+	states := func(components []Component) map[string]string {
+		m := make(map[string]string, len(components))
+		for _, c := range components {
+			m[c.Name] = c.State
+		}
+		return m
+	}
+
+	// the directory is not watched before Process
+	ready, components := u.Ready(context.Background())
+	want := map[string]string{"storage": StateOK, "directory:default": StateFailed, "fonts": StateOK}
+	if ready || !reflect.DeepEqual(states(components), want) {
+		t.Errorf("Ready() = %v, %v, want not ready with %v", ready, components, want)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	dir := t.TempDir()
+	fileWatcher := watcher.New(time.Hour, dir, make(chan string, 1))
+	go fileWatcher.Run(ctx)
+	for !fileWatcher.Stats().Running {
+		time.Sleep(time.Millisecond)
+	}
+	u.pipelines[events.DefaultPipeline].fileWatcher, u.pipelines[events.DefaultPipeline].dir = fileWatcher, dir
+
+	if ready, components = u.Ready(context.Background()); !ready {
+		t.Errorf("Ready() = %v, %v, want ready", ready, components)
+	}
+
+	u.AddCheck("migrations", func(context.Context) error {
+		return errors.New("test error")
+	})
+	ready, components = u.Ready(context.Background())
+	want = map[string]string{"storage": StateOK, "directory:default": StateOK, "fonts": StateOK, "migrations": StateFailed}
+	if ready || !reflect.DeepEqual(states(components), want) {
+		t.Errorf("Ready() = %v, %v, want not ready with %v", ready, components, want)
+	}
+
+	// the slow check is failed by the timeout
+	u.checkTimeout = 10 * time.Millisecond
+	u.AddCheck("slow", func(ctx context.Context) error {
+		<-ctx.Done()
+		time.Sleep(time.Second)
+		return nil
+	})
+	start := time.Now()
+	_, components = u.Ready(context.Background())
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Ready() took %v, want the timeout", elapsed)
+	}
+	if last := components[len(components)-1]; last.Name != "slow" || last.State != StateFailed {
+		t.Errorf("Ready() slow component = %+v, want failed", last)
+	}
+
+	u.setLastError(u.pipelines[events.DefaultPipeline], "Failed to fill gadgets: test error")
+	status := u.Status(context.Background())
+	if len(status.Pipelines) != 1 {
+		t.Fatalf("Status() pipelines = %v, want the default one", status.Pipelines)
+	}
+	got := status.Pipelines[0]
+	if !got.Watching || got.Directory != dir || got.LastError != "Failed to fill gadgets: test error" || got.LastErrorAt == nil {
+		t.Errorf("Status() pipeline = %+v", got)
+	}
+}
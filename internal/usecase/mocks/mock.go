@@ -7,6 +7,9 @@ package mock_usecase
 import (
 	context "context"
 	events "go-tsv-watcher/internal/events"
+	service "go-tsv-watcher/internal/storage/service"
+	usecase "go-tsv-watcher/internal/usecase"
+	io "io"
 	reflect "reflect"
 	time "time"
 
@@ -36,31 +39,325 @@ func (m *MockIUseCase) EXPECT() *MockIUseCaseMockRecorder {
 	return m.recorder
 }
 
+// CacheStats mocks base method.
+func (m *MockIUseCase) CacheStats() usecase.CacheStats {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CacheStats")
+	ret0, _ := ret[0].(usecase.CacheStats)
+	return ret0
+}
+
+// CacheStats indicates an expected call of CacheStats.
+func (mr *MockIUseCaseMockRecorder) CacheStats() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CacheStats", reflect.TypeOf((*MockIUseCase)(nil).CacheStats))
+}
+
+// CreateAPIKey mocks base method.
+func (m *MockIUseCase) CreateAPIKey(ctx context.Context, name string, scopes []string) (usecase.CreatedAPIKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateAPIKey", ctx, name, scopes)
+	ret0, _ := ret[0].(usecase.CreatedAPIKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateAPIKey indicates an expected call of CreateAPIKey.
+func (mr *MockIUseCaseMockRecorder) CreateAPIKey(ctx, name, scopes interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateAPIKey", reflect.TypeOf((*MockIUseCase)(nil).CreateAPIKey), ctx, name, scopes)
+}
+
+// DeleteAPIKey mocks base method.
+func (m *MockIUseCase) DeleteAPIKey(ctx context.Context, id string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteAPIKey", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteAPIKey indicates an expected call of DeleteAPIKey.
+func (mr *MockIUseCaseMockRecorder) DeleteAPIKey(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteAPIKey", reflect.TypeOf((*MockIUseCase)(nil).DeleteAPIKey), ctx, id)
+}
+
+// DeleteFile mocks base method.
+func (m *MockIUseCase) DeleteFile(ctx context.Context, pipeline, name string) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteFile", ctx, pipeline, name)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteFile indicates an expected call of DeleteFile.
+func (mr *MockIUseCaseMockRecorder) DeleteFile(ctx, pipeline, name interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteFile", reflect.TypeOf((*MockIUseCase)(nil).DeleteFile), ctx, pipeline, name)
+}
+
+// GetAPIKey mocks base method.
+func (m *MockIUseCase) GetAPIKey(ctx context.Context, hash string) (service.APIKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAPIKey", ctx, hash)
+	ret0, _ := ret[0].(service.APIKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAPIKey indicates an expected call of GetAPIKey.
+func (mr *MockIUseCaseMockRecorder) GetAPIKey(ctx, hash interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAPIKey", reflect.TypeOf((*MockIUseCase)(nil).GetAPIKey), ctx, hash)
+}
+
 // GetEventByNumber mocks base method.
-func (m *MockIUseCase) GetEventByNumber(ctx context.Context, unitGUID string, number int) (events.Event, error) {
+func (m *MockIUseCase) GetEventByNumber(ctx context.Context, pipeline, unitGUID string, number int) (events.Event, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetEventByNumber", ctx, unitGUID, number)
+	ret := m.ctrl.Call(m, "GetEventByNumber", ctx, pipeline, unitGUID, number)
 	ret0, _ := ret[0].(events.Event)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetEventByNumber indicates an expected call of GetEventByNumber.
-func (mr *MockIUseCaseMockRecorder) GetEventByNumber(ctx, unitGUID, number interface{}) *gomock.Call {
+func (mr *MockIUseCaseMockRecorder) GetEventByNumber(ctx, pipeline, unitGUID, number interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetEventByNumber", reflect.TypeOf((*MockIUseCase)(nil).GetEventByNumber), ctx, pipeline, unitGUID, number)
+}
+
+// GetFile mocks base method.
+func (m *MockIUseCase) GetFile(ctx context.Context, pipeline, name string) (service.FileDetails, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetFile", ctx, pipeline, name)
+	ret0, _ := ret[0].(service.FileDetails)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetFile indicates an expected call of GetFile.
+func (mr *MockIUseCaseMockRecorder) GetFile(ctx, pipeline, name interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetFile", reflect.TypeOf((*MockIUseCase)(nil).GetFile), ctx, pipeline, name)
+}
+
+// GetUnit mocks base method.
+func (m *MockIUseCase) GetUnit(ctx context.Context, pipeline, guid string) (service.UnitSummary, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUnit", ctx, pipeline, guid)
+	ret0, _ := ret[0].(service.UnitSummary)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUnit indicates an expected call of GetUnit.
+func (mr *MockIUseCaseMockRecorder) GetUnit(ctx, pipeline, guid interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUnit", reflect.TypeOf((*MockIUseCase)(nil).GetUnit), ctx, pipeline, guid)
+}
+
+// GetUpload mocks base method.
+func (m *MockIUseCase) GetUpload(ctx context.Context, pipeline, id string) (usecase.Upload, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUpload", ctx, pipeline, id)
+	ret0, _ := ret[0].(usecase.Upload)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUpload indicates an expected call of GetUpload.
+func (mr *MockIUseCaseMockRecorder) GetUpload(ctx, pipeline, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUpload", reflect.TypeOf((*MockIUseCase)(nil).GetUpload), ctx, pipeline, id)
+}
+
+// ListAPIKeys mocks base method.
+func (m *MockIUseCase) ListAPIKeys(ctx context.Context) ([]service.APIKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListAPIKeys", ctx)
+	ret0, _ := ret[0].([]service.APIKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListAPIKeys indicates an expected call of ListAPIKeys.
+func (mr *MockIUseCaseMockRecorder) ListAPIKeys(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListAPIKeys", reflect.TypeOf((*MockIUseCase)(nil).ListAPIKeys), ctx)
+}
+
+// ListEvents mocks base method.
+func (m *MockIUseCase) ListEvents(ctx context.Context, query service.EventsQuery) (service.EventsPage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListEvents", ctx, query)
+	ret0, _ := ret[0].(service.EventsPage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListEvents indicates an expected call of ListEvents.
+func (mr *MockIUseCaseMockRecorder) ListEvents(ctx, query interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListEvents", reflect.TypeOf((*MockIUseCase)(nil).ListEvents), ctx, query)
+}
+
+// ListFiles mocks base method.
+func (m *MockIUseCase) ListFiles(ctx context.Context, pipeline, status string) ([]service.File, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListFiles", ctx, pipeline, status)
+	ret0, _ := ret[0].([]service.File)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListFiles indicates an expected call of ListFiles.
+func (mr *MockIUseCaseMockRecorder) ListFiles(ctx, pipeline, status interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListFiles", reflect.TypeOf((*MockIUseCase)(nil).ListFiles), ctx, pipeline, status)
+}
+
+// ListUnits mocks base method.
+func (m *MockIUseCase) ListUnits(ctx context.Context, pipeline string) ([]service.Unit, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListUnits", ctx, pipeline)
+	ret0, _ := ret[0].([]service.Unit)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListUnits indicates an expected call of ListUnits.
+func (mr *MockIUseCaseMockRecorder) ListUnits(ctx, pipeline interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetEventByNumber", reflect.TypeOf((*MockIUseCase)(nil).GetEventByNumber), ctx, unitGUID, number)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListUnits", reflect.TypeOf((*MockIUseCase)(nil).ListUnits), ctx, pipeline)
+}
+
+// Pipelines mocks base method.
+func (m *MockIUseCase) Pipelines() []string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Pipelines")
+	ret0, _ := ret[0].([]string)
+	return ret0
+}
+
+// Pipelines indicates an expected call of Pipelines.
+func (mr *MockIUseCaseMockRecorder) Pipelines() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Pipelines", reflect.TypeOf((*MockIUseCase)(nil).Pipelines))
 }
 
 // Process mocks base method.
-func (m *MockIUseCase) Process(ctx context.Context, refresh time.Duration, dir string) error {
+func (m *MockIUseCase) Process(ctx context.Context, pipeline string, refresh time.Duration, dir string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Process", ctx, refresh, dir)
+	ret := m.ctrl.Call(m, "Process", ctx, pipeline, refresh, dir)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // Process indicates an expected call of Process.
-func (mr *MockIUseCaseMockRecorder) Process(ctx, refresh, dir interface{}) *gomock.Call {
+func (mr *MockIUseCaseMockRecorder) Process(ctx, pipeline, refresh, dir interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Process", reflect.TypeOf((*MockIUseCase)(nil).Process), ctx, pipeline, refresh, dir)
+}
+
+// Prune mocks base method.
+func (m *MockIUseCase) Prune(ctx context.Context, interval time.Duration, policy service.RetentionPolicy) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Prune", ctx, interval, policy)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Prune indicates an expected call of Prune.
+func (mr *MockIUseCaseMockRecorder) Prune(ctx, interval, policy interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Prune", reflect.TypeOf((*MockIUseCase)(nil).Prune), ctx, interval, policy)
+}
+
+// Ready mocks base method.
+func (m *MockIUseCase) Ready(ctx context.Context) (bool, []usecase.Component) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Ready", ctx)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].([]usecase.Component)
+	return ret0, ret1
+}
+
+// Ready indicates an expected call of Ready.
+func (mr *MockIUseCaseMockRecorder) Ready(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Ready", reflect.TypeOf((*MockIUseCase)(nil).Ready), ctx)
+}
+
+// Report mocks base method.
+func (m *MockIUseCase) Report(ctx context.Context, unitGUID string, opts usecase.ReportOptions) (usecase.Report, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Report", ctx, unitGUID, opts)
+	ret0, _ := ret[0].(usecase.Report)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Report indicates an expected call of Report.
+func (mr *MockIUseCaseMockRecorder) Report(ctx, unitGUID, opts interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Report", reflect.TypeOf((*MockIUseCase)(nil).Report), ctx, unitGUID, opts)
+}
+
+// ReprocessFile mocks base method.
+func (m *MockIUseCase) ReprocessFile(ctx context.Context, pipeline, name string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReprocessFile", ctx, pipeline, name)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReprocessFile indicates an expected call of ReprocessFile.
+func (mr *MockIUseCaseMockRecorder) ReprocessFile(ctx, pipeline, name interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReprocessFile", reflect.TypeOf((*MockIUseCase)(nil).ReprocessFile), ctx, pipeline, name)
+}
+
+// Status mocks base method.
+func (m *MockIUseCase) Status(ctx context.Context) usecase.Status {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Status", ctx)
+	ret0, _ := ret[0].(usecase.Status)
+	return ret0
+}
+
+// Status indicates an expected call of Status.
+func (mr *MockIUseCaseMockRecorder) Status(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Status", reflect.TypeOf((*MockIUseCase)(nil).Status), ctx)
+}
+
+// Stream mocks base method.
+func (m *MockIUseCase) Stream(ctx context.Context, filter service.EventFilter, lastEventID string) (<-chan usecase.StreamEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Stream", ctx, filter, lastEventID)
+	ret0, _ := ret[0].(<-chan usecase.StreamEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Stream indicates an expected call of Stream.
+func (mr *MockIUseCaseMockRecorder) Stream(ctx, filter, lastEventID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Stream", reflect.TypeOf((*MockIUseCase)(nil).Stream), ctx, filter, lastEventID)
+}
+
+// Upload mocks base method.
+func (m *MockIUseCase) Upload(ctx context.Context, pipeline string, body io.Reader) (usecase.Upload, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Upload", ctx, pipeline, body)
+	ret0, _ := ret[0].(usecase.Upload)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Upload indicates an expected call of Upload.
+func (mr *MockIUseCaseMockRecorder) Upload(ctx, pipeline, body interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Process", reflect.TypeOf((*MockIUseCase)(nil).Process), ctx, refresh, dir)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Upload", reflect.TypeOf((*MockIUseCase)(nil).Upload), ctx, pipeline, body)
 }
@@ -0,0 +1,129 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+	"go-tsv-watcher/internal/events"
+	"go-tsv-watcher/internal/storage"
+	"go-tsv-watcher/internal/watcher"
+	"time"
+)
+
+// ErrPipelineNotFound error occurs when the pipeline is not configured
+var ErrPipelineNotFound = errors.New("pipeline not found")
+
+// Pipeline is a watched directory with its own parser settings, storage and report outputs.
+type Pipeline struct {
+	// Name is recorded on the events and the files of the pipeline.
+	Name string
+	// Columns renames the columns of the file header to the event columns.
+	Columns map[string]string
+	// Storage of the events and the files, the storage of the use case if nil.
+	Storage storage.Storage
+	// DirOut is the directory of the reports.
+	DirOut string
+	// Reports of the pipeline, the reports of the use case if nil.
+	Reports *ReportConfig
+}
+
+// pipeline is the state of a Pipeline, its watcher and directory are set by Process.
+type pipeline struct {
+	Pipeline
+	storage     storage.Storage
+	dirOut      string
+	fileWatcher *watcher.Watcher
+	dir         string
+
+	// the last ingested file and the last problem of the pipeline, reported by Status
+	lastFile    string
+	lastError   string
+	lastErrorAt time.Time
+}
+
+// newPipeline creates the state of the pipeline with the storage of the use case as the fallback.
+func newPipeline(p Pipeline, st storage.Storage) *pipeline {
+	if p.Storage != nil {
+		st = p.Storage
+	}
+	return &pipeline{Pipeline: p, storage: st, dirOut: p.DirOut + "/"}
+}
+
+// SetPipelines replaces the pipelines, the first one serves the requests naming no pipeline.
+// It is called before Process.
+func (u *UseCase) SetPipelines(pipelines []Pipeline) error {
+	if len(pipelines) == 0 {
+		return fmt.Errorf("%w: no pipelines", ErrPipelineNotFound)
+	}
+
+	byName := make(map[string]*pipeline, len(pipelines))
+	names := make([]string, 0, len(pipelines))
+	for _, p := range pipelines {
+		if p.Name == "" {
+			return fmt.Errorf("pipeline name is required")
+		}
+		if _, ok := byName[p.Name]; ok {
+			return fmt.Errorf("pipeline %q is duplicated", p.Name)
+		}
+		byName[p.Name] = newPipeline(p, u.storage)
+		names = append(names, p.Name)
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.pipelines, u.names = byName, names
+	return nil
+}
+
+// Pipelines returns the names of the pipelines in order.
+func (u *UseCase) Pipelines() []string {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+	return append([]string(nil), u.names...)
+}
+
+// pipeline returns the pipeline by its name, the first one if the name is empty.
+func (u *UseCase) pipeline(name string) (*pipeline, error) {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+
+	if name == "" && len(u.names) != 0 {
+		name = u.names[0]
+	}
+	p, ok := u.pipelines[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrPipelineNotFound, name)
+	}
+	return p, nil
+}
+
+// storageOf returns the storage of the pipeline, the storage of the use case if the name is empty.
+// The storage of the use case keeps the events of all pipelines without their own storage.
+func (u *UseCase) storageOf(name string) (storage.Storage, error) {
+	if name == "" {
+		return u.storage, nil
+	}
+	p, err := u.pipeline(name)
+	if err != nil {
+		return nil, err
+	}
+	return p.storage, nil
+}
+
+// storages returns the storage of the use case and the own storages of the pipelines.
+func (u *UseCase) storages() []storage.Storage {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+
+	all := []storage.Storage{u.storage}
+	for _, name := range u.names {
+		if p := u.pipelines[name]; p.Storage != nil {
+			all = append(all, p.Storage)
+		}
+	}
+	return all
+}
+
+// parserOptions returns the parser settings of the pipeline.
+func (p *pipeline) parserOptions() events.Options {
+	return events.Options{Pipeline: p.Name, Columns: p.Columns}
+}
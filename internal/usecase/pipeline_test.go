@@ -0,0 +1,97 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"github.com/go-chi/httplog"
+	"github.com/golang/mock/gomock"
+	"go-tsv-watcher/internal/events"
+	"go-tsv-watcher/internal/storage/mocks"
+	"go-tsv-watcher/internal/storage/service"
+	"go-tsv-watcher/pkg/logger"
+	"reflect"
+	"testing"
+)
+
+func TestUseCase_SetPipelines(t *testing.T) {
+	loggerInstance := httplog.NewLogger("watcher", httplog.Options{
+		Concise: true,
+	})
+	u := New(nil, t.TempDir(), logger.New(loggerInstance))
+
+	if got := u.Pipelines(); !reflect.DeepEqual(got, []string{events.DefaultPipeline}) {
+		t.Errorf("Pipelines() = %v, want the default pipeline", got)
+	}
+
+	tests := []struct {
+		name      string
+		pipelines []Pipeline
+	}{
+		{name: "none"},
+		{name: "no name", pipelines: []Pipeline{{Name: "line1"}, {}}},
+		{name: "duplicated", pipelines: []Pipeline{{Name: "line1"}, {Name: "line1"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := u.SetPipelines(tt.pipelines); err == nil {
+				t.Error("SetPipelines() error = nil")
+			}
+		})
+	}
+
+	if err := u.SetPipelines([]Pipeline{{Name: "line1"}, {Name: "line2"}}); err != nil {
+		t.Fatalf("SetPipelines() error = %v", err)
+	}
+	if got := u.Pipelines(); !reflect.DeepEqual(got, []string{"line1", "line2"}) {
+		t.Errorf("Pipelines() = %v, want [line1 line2]", got)
+	}
+}
+
+func TestUseCase_pipelineStorages(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+	shared, own := mocks.NewMockStorage(c), mocks.NewMockStorage(c)
+
+	loggerInstance := httplog.NewLogger("watcher", httplog.Options{
+		Concise: true,
+	})
+	u := New(shared, t.TempDir(), logger.New(loggerInstance))
+	err := u.SetPipelines([]Pipeline{{Name: "line1"}, {Name: "line2", Storage: own}})
+	if err != nil {
+		t.Fatalf("SetPipelines() error = %v", err)
+	}
+	ctx := context.Background()
+
+	// the first pipeline serves the requests naming no pipeline
+	shared.EXPECT().GetFile(gomock.Any(), "line1", "a.tsv").Return(service.FileDetails{}, nil)
+	if _, err = u.GetFile(ctx, "", "a.tsv"); err != nil {
+		t.Errorf("GetFile() error = %v", err)
+	}
+
+	own.EXPECT().GetFile(gomock.Any(), "line2", "a.tsv").Return(service.FileDetails{}, nil)
+	if _, err = u.GetFile(ctx, "line2", "a.tsv"); err != nil {
+		t.Errorf("GetFile() error = %v", err)
+	}
+
+	// the filters without a pipeline cover the pipelines of the main storage
+	shared.EXPECT().ListUnits(gomock.Any(), "").Return(nil, nil)
+	if _, err = u.ListUnits(ctx, ""); err != nil {
+		t.Errorf("ListUnits() error = %v", err)
+	}
+
+	own.EXPECT().ListUnits(gomock.Any(), "line2").Return(nil, nil)
+	if _, err = u.ListUnits(ctx, "line2"); err != nil {
+		t.Errorf("ListUnits() error = %v", err)
+	}
+
+	if _, err = u.ListUnits(ctx, "nope"); !errors.Is(err, ErrPipelineNotFound) {
+		t.Errorf("ListUnits() error = %v, want %v", err, ErrPipelineNotFound)
+	}
+	if _, err = u.DeleteFile(ctx, "nope", "a.tsv"); !errors.Is(err, ErrPipelineNotFound) {
+		t.Errorf("DeleteFile() error = %v, want %v", err, ErrPipelineNotFound)
+	}
+
+	if got := u.storages(); len(got) != 2 {
+		t.Errorf("storages() got %d storages, want 2", len(got))
+	}
+}
@@ -0,0 +1,96 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+	"go-tsv-watcher/internal/report"
+	"io"
+)
+
+// Report formats.
+const (
+	FormatPDF  = "pdf"
+	FormatHTML = "html"
+	FormatCSV  = "csv"
+	FormatXLSX = "xlsx"
+)
+
+// ReportFormats are the supported report formats in the order of preference.
+var ReportFormats = []string{FormatPDF, FormatHTML, FormatCSV, FormatXLSX}
+
+// ErrUnknownFormat error occurs when the report format is not supported
+var ErrUnknownFormat = errors.New("unknown report format")
+
+// Renderer renders the report of a unit in one format.
+type Renderer interface {
+	// Render writes the report to w.
+	Render(w io.Writer, d report.Data) error
+	// ContentType is the media type of the rendered report.
+	ContentType() string
+}
+
+// renderers are the renderers by the report format, the format is the file extension.
+var renderers = map[string]Renderer{
+	FormatPDF:  pdfRenderer{},
+	FormatHTML: funcRenderer{render: report.HTML, contentType: "text/html; charset=utf-8"},
+	FormatCSV:  funcRenderer{render: report.CSV, contentType: "text/csv; charset=utf-8"},
+	FormatXLSX: funcRenderer{render: report.XLSX, contentType: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"},
+}
+
+// ReportRenderer returns the renderer of the format.
+func ReportRenderer(format string) (Renderer, error) {
+	r, ok := renderers[format]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownFormat, format)
+	}
+	return r, nil
+}
+
+// renderer returns the renderer of the format with the configured fonts of the pipeline.
+func (u *UseCase) renderer(p *pipeline, format string) (Renderer, error) {
+	r, err := ReportRenderer(format)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := r.(pdfRenderer); ok {
+		return pdfRenderer{fonts: u.reportConfig(p).Fonts}, nil
+	}
+	return r, nil
+}
+
+// pdfRenderer renders the PDF reports with the fonts, the embedded ones if nil.
+type pdfRenderer struct {
+	fonts *report.Fonts
+}
+
+// Render implements Renderer.
+func (r pdfRenderer) Render(w io.Writer, d report.Data) error {
+	pdf, err := report.PDF(d, report.Template{Fonts: r.fonts})
+	if err != nil {
+		return err
+	}
+	defer pdf.Close()
+
+	return pdf.Write(w)
+}
+
+// ContentType implements Renderer.
+func (pdfRenderer) ContentType() string {
+	return "application/pdf"
+}
+
+// funcRenderer renders the reports with a function of the report package.
+type funcRenderer struct {
+	render      func(w io.Writer, d report.Data) error
+	contentType string
+}
+
+// Render implements Renderer.
+func (r funcRenderer) Render(w io.Writer, d report.Data) error {
+	return r.render(w, d)
+}
+
+// ContentType implements Renderer.
+func (r funcRenderer) ContentType() string {
+	return r.contentType
+}
@@ -0,0 +1,312 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"go-tsv-watcher/internal/events"
+	"go-tsv-watcher/internal/report"
+	"go-tsv-watcher/internal/storage"
+	"go-tsv-watcher/internal/storage/service"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ErrReportNotFound error occurs when the unit has no report or no events to render
+var ErrReportNotFound = errors.New("report not found")
+
+// Report modes.
+const (
+	// ReportModeFile renders the events of the processed file.
+	ReportModeFile = "file"
+	// ReportModeHistory renders all stored events of the unit.
+	ReportModeHistory = "history"
+	// ReportModeWindow renders the stored events of the unit ingested within the window.
+	ReportModeWindow = "window"
+)
+
+// Report naming schemes.
+const (
+	// ReportNamingUnit saves one report per unit as <unit>.<format>.
+	ReportNamingUnit = "unit"
+	// ReportNamingFile saves one report per unit and file as <unit>/<file>.<format>.
+	ReportNamingFile = "file"
+)
+
+// ReportConfig selects how the reports are built when files are processed.
+type ReportConfig struct {
+	Mode   string
+	Window time.Duration
+	Naming string
+
+	// Title and Columns of the report, the defaults of the report package if empty.
+	Title   string
+	Columns []string
+
+	// Formats are saved when the files are processed, only PDF if empty.
+	Formats []string
+
+	// Fonts of the PDF reports, the embedded fonts if nil.
+	Fonts *report.Fonts
+}
+
+// SetReportConfig sets how the reports are built.
+func (u *UseCase) SetReportConfig(cfg ReportConfig) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.reports = cfg
+}
+
+// reportConfig returns the report config of the pipeline with the defaults applied.
+func (u *UseCase) reportConfig(p *pipeline) ReportConfig {
+	u.mu.RLock()
+	cfg := u.reports
+	if p.Reports != nil {
+		cfg = *p.Reports
+	}
+	u.mu.RUnlock()
+
+	if cfg.Mode == "" {
+		cfg.Mode = ReportModeFile
+	}
+	if cfg.Naming == "" {
+		cfg.Naming = ReportNamingUnit
+	}
+	if len(cfg.Formats) == 0 {
+		cfg.Formats = []string{FormatPDF}
+	}
+	return cfg
+}
+
+// reportName returns the name of the saved report relative to the output directory,
+// empty if the unit or the file can't name a report under the naming scheme.
+func (u *UseCase) reportName(p *pipeline, unitGUID, source, format string) string {
+	if !validName(unitGUID) {
+		return ""
+	}
+	if u.reportConfig(p).Naming != ReportNamingFile {
+		return unitGUID + "." + format
+	}
+
+	if !validName(source) {
+		return ""
+	}
+	return unitGUID + "/" + strings.TrimSuffix(source, ".tsv") + "." + format
+}
+
+// validName reports whether the unit guid or the file name is a single path element,
+// the guids come from the ingested files and the requests, so they must not leave the output directory.
+func validName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, `/\`)
+}
+
+// reportPath returns the path of the report in the output directory of the pipeline,
+// false if the name is empty or the path leaves the directory.
+func (p *pipeline) reportPath(name string) (string, bool) {
+	if name == "" {
+		return "", false
+	}
+
+	path := filepath.Join(p.dirOut, filepath.FromSlash(name))
+	rel, err := filepath.Rel(p.dirOut, path)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return path, true
+}
+
+// savesFormat reports whether the reports of the format are saved when the files are processed.
+func (u *UseCase) savesFormat(p *pipeline, format string) bool {
+	for _, f := range u.reportConfig(p).Formats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// reportFilter returns the filter of the stored events of the saved report of the pipeline.
+func (u *UseCase) reportFilter(p *pipeline, unitGUID, source string) service.EventFilter {
+	filter := service.EventFilter{UnitGUID: unitGUID, Pipeline: p.Name}
+
+	cfg := u.reportConfig(p)
+	switch {
+	case cfg.Naming == ReportNamingFile:
+		filter.SourceFile = source
+	case cfg.Mode == ReportModeWindow:
+		filter.From = time.Now().UTC().Add(-cfg.Window)
+	}
+
+	return filter
+}
+
+// ReportOptions selects how the report of a unit is produced.
+type ReportOptions struct {
+	// Regenerate renders the report from the storage and saves it.
+	Regenerate bool
+
+	// From, To and SourceFile render the report of a part of the events, it is not saved.
+	// With the file naming scheme SourceFile selects the saved report of the file.
+	From       time.Time
+	To         time.Time
+	SourceFile string
+
+	// Format of the report, PDF if empty.
+	// The formats which are not saved are rendered from the storage.
+	Format string
+
+	// Pipeline of the report, the first pipeline if empty.
+	Pipeline string
+}
+
+// Report is a report ready to be served.
+type Report struct {
+	io.ReadSeekCloser
+
+	Name        string
+	ContentType string
+	ModTime     time.Time
+	ETag        string
+}
+
+// memReport is the content of a report rendered on demand.
+type memReport struct {
+	*bytes.Reader
+}
+
+// Close implements io.Closer.
+func (memReport) Close() error {
+	return nil
+}
+
+// Report returns the saved report of the unit or renders it from the storage of the pipeline.
+func (u *UseCase) Report(ctx context.Context, unitGUID string, opts ReportOptions) (Report, error) {
+	if !validName(unitGUID) {
+		return Report{}, ErrReportNotFound
+	}
+
+	p, err := u.pipeline(opts.Pipeline)
+	if err != nil {
+		return Report{}, err
+	}
+
+	if opts.Format == "" {
+		opts.Format = FormatPDF
+	}
+	renderer, err := u.renderer(p, opts.Format)
+	if err != nil {
+		return Report{}, err
+	}
+
+	perFile := u.reportConfig(p).Naming == ReportNamingFile
+	name := u.reportName(p, unitGUID, opts.SourceFile, opts.Format)
+	filtered := !opts.From.IsZero() || !opts.To.IsZero() || (!perFile && opts.SourceFile != "")
+	saved := !filtered && name != "" && u.savesFormat(p, opts.Format)
+
+	if !opts.Regenerate && !filtered {
+		if name == "" {
+			return Report{}, ErrReportNotFound
+		}
+		if saved {
+			return u.openReport(p, name, renderer.ContentType())
+		}
+	}
+
+	filter := service.EventFilter{
+		UnitGUID:   unitGUID,
+		From:       opts.From,
+		To:         opts.To,
+		SourceFile: opts.SourceFile,
+		Pipeline:   p.Name,
+	}
+	if !filtered {
+		// the saved report is regenerated the same way as when the file is processed
+		filter = u.reportFilter(p, unitGUID, opts.SourceFile)
+	}
+
+	evs, err := u.unitEvents(ctx, p.storage, filter)
+	if err != nil {
+		return Report{}, err
+	}
+	if len(evs) == 0 {
+		return Report{}, ErrReportNotFound
+	}
+
+	d := u.build(p, evs)
+	if saved {
+		if err = u.saveReport(p, renderer, d, name); err != nil {
+			return Report{}, err
+		}
+		return u.openReport(p, name, renderer.ContentType())
+	}
+
+	var buf bytes.Buffer
+	if err = u.render(&buf, renderer, d); err != nil {
+		return Report{}, err
+	}
+
+	return Report{
+		ReadSeekCloser: memReport{bytes.NewReader(buf.Bytes())},
+		Name:           unitGUID + "." + opts.Format,
+		ContentType:    renderer.ContentType(),
+		ModTime:        time.Now().UTC(),
+		ETag:           fmt.Sprintf(`"%x"`, sha256.Sum256(buf.Bytes())),
+	}, nil
+}
+
+// openReport opens the saved report of the pipeline by its name.
+func (u *UseCase) openReport(p *pipeline, name, contentType string) (Report, error) {
+	path, ok := p.reportPath(name)
+	if !ok {
+		return Report{}, ErrReportNotFound
+	}
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return Report{}, ErrReportNotFound
+	}
+	if err != nil {
+		return Report{}, fmt.Errorf("failed to open report: %w", err)
+	}
+
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return Report{}, fmt.Errorf("failed to stat report: %w", err)
+	}
+
+	return Report{
+		ReadSeekCloser: f,
+		Name:           fi.Name(),
+		ContentType:    contentType,
+		ModTime:        fi.ModTime(),
+		ETag:           fmt.Sprintf(`"%x-%x"`, fi.ModTime().UnixNano(), fi.Size()),
+	}, nil
+}
+
+// unitEvents reads all events of the storage matching the filter in the ingestion order.
+func (u *UseCase) unitEvents(ctx context.Context, st storage.Storage, filter service.EventFilter) ([]events.Event, error) {
+	query := service.EventsQuery{Filter: filter, SortBy: service.SortIngestedAt, Limit: MaxEventsLimit}
+
+	var all []events.Event
+	for {
+		evs, err := st.ListEvents(ctx, query)
+		if err != nil {
+			u.logger.Warn(err.Error())
+			return nil, ErrStorageIsUnavailable
+		}
+
+		all = append(all, evs...)
+		if len(evs) < query.Limit {
+			return all, nil
+		}
+
+		last := evs[len(evs)-1]
+		query.After = service.CursorOf(last, query.SortBy)
+	}
+}
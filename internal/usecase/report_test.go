@@ -0,0 +1,317 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"github.com/go-chi/httplog"
+	"github.com/golang/mock/gomock"
+	"go-tsv-watcher/internal/events"
+	"go-tsv-watcher/internal/storage/mocks"
+	"go-tsv-watcher/internal/storage/service"
+	"go-tsv-watcher/pkg/logger"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUseCase_Report(t *testing.T) {
+	from := time.Date(2023, 5, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name         string
+		guid         string
+		cfg          ReportConfig
+		opts         ReportOptions
+		saved        bool
+		wantErr      error
+		wantSaved    bool
+		wantPrefix   string
+		mockBehavior func(r *mocks.MockStorage)
+	}{
+		{
+			name:         "saved",
+			guid:         "unit1",
+			saved:        true,
+			wantSaved:    true,
+			mockBehavior: func(r *mocks.MockStorage) {},
+		},
+		{
+			name:         "not saved",
+			guid:         "unit1",
+			wantErr:      ErrReportNotFound,
+			mockBehavior: func(r *mocks.MockStorage) {},
+		},
+		{
+			name:         "path",
+			guid:         "../unit1",
+			wantErr:      ErrReportNotFound,
+			mockBehavior: func(r *mocks.MockStorage) {},
+		},
+		{
+			name:         "parent per file",
+			guid:         "..",
+			cfg:          ReportConfig{Naming: ReportNamingFile},
+			opts:         ReportOptions{SourceFile: "x.tsv"},
+			wantErr:      ErrReportNotFound,
+			mockBehavior: func(r *mocks.MockStorage) {},
+		},
+		{
+			name: "filtered",
+			guid: "unit1",
+			opts: ReportOptions{From: from, SourceFile: "a.tsv"},
+			mockBehavior: func(r *mocks.MockStorage) {
+				r.EXPECT().ListEvents(gomock.Any(), service.EventsQuery{
+					Filter: service.EventFilter{UnitGUID: "unit1", From: from, SourceFile: "a.tsv", Pipeline: events.DefaultPipeline},
+					SortBy: service.SortIngestedAt,
+					Limit:  MaxEventsLimit,
+				}).Return([]events.Event{{ID: "1", UnitGUID: "unit1"}, {ID: "2", UnitGUID: "unit1"}}, nil)
+			},
+		},
+		{
+			name:      "regenerate",
+			guid:      "unit1",
+			opts:      ReportOptions{Regenerate: true},
+			wantSaved: true,
+			mockBehavior: func(r *mocks.MockStorage) {
+				r.EXPECT().ListEvents(gomock.Any(), gomock.Any()).
+					Return([]events.Event{{ID: "1", UnitGUID: "unit1"}}, nil)
+			},
+		},
+		{
+			name:    "no events",
+			guid:    "unit1",
+			opts:    ReportOptions{Regenerate: true},
+			wantErr: ErrReportNotFound,
+			mockBehavior: func(r *mocks.MockStorage) {
+				r.EXPECT().ListEvents(gomock.Any(), gomock.Any()).Return(nil, nil)
+			},
+		},
+		{
+			name:    "storage error",
+			guid:    "unit1",
+			opts:    ReportOptions{Regenerate: true},
+			wantErr: ErrStorageIsUnavailable,
+			mockBehavior: func(r *mocks.MockStorage) {
+				r.EXPECT().ListEvents(gomock.Any(), gomock.Any()).Return(nil, errors.New("test error"))
+			},
+		},
+		{
+			name:       "format not saved",
+			guid:       "unit1",
+			opts:       ReportOptions{Format: FormatCSV},
+			wantPrefix: "Number,IngestedAt",
+			mockBehavior: func(r *mocks.MockStorage) {
+				r.EXPECT().ListEvents(gomock.Any(), service.EventsQuery{
+					Filter: service.EventFilter{UnitGUID: "unit1", Pipeline: events.DefaultPipeline},
+					SortBy: service.SortIngestedAt,
+					Limit:  MaxEventsLimit,
+				}).Return([]events.Event{{ID: "1", UnitGUID: "unit1"}}, nil)
+			},
+		},
+		{
+			name:       "saved format regenerate",
+			guid:       "unit1",
+			cfg:        ReportConfig{Formats: []string{FormatPDF, FormatHTML}},
+			opts:       ReportOptions{Format: FormatHTML, Regenerate: true},
+			wantSaved:  true,
+			wantPrefix: "<!DOCTYPE html>",
+			mockBehavior: func(r *mocks.MockStorage) {
+				r.EXPECT().ListEvents(gomock.Any(), gomock.Any()).
+					Return([]events.Event{{ID: "1", UnitGUID: "unit1"}}, nil)
+			},
+		},
+		{
+			name:         "unknown format",
+			guid:         "unit1",
+			opts:         ReportOptions{Format: "doc"},
+			wantErr:      ErrUnknownFormat,
+			mockBehavior: func(r *mocks.MockStorage) {},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+			st := mocks.NewMockStorage(c)
+			tt.mockBehavior(st)
+
+			loggerInstance := httplog.NewLogger("watcher", httplog.Options{
+				Concise: true,
+			})
+
+			dirOut := t.TempDir()
+			u := New(st, dirOut, logger.New(loggerInstance))
+			u.SetReportConfig(tt.cfg)
+			if tt.saved {
+				if err := os.WriteFile(dirOut+"/unit1.pdf", []byte("%PDF-saved"), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			report, err := u.Report(context.Background(), tt.guid, tt.opts)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Report() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			defer report.Close()
+
+			format, prefix := tt.opts.Format, tt.wantPrefix
+			if format == "" {
+				format, prefix = FormatPDF, "%PDF"
+			}
+
+			content, err := io.ReadAll(report)
+			if err != nil || !strings.HasPrefix(string(content), prefix) {
+				t.Errorf("Report() content = %.20q, error = %v", content, err)
+			}
+			if report.ETag == "" || report.Name != "unit1."+format || report.ContentType == "" {
+				t.Errorf("Report() got = %+v", report)
+			}
+
+			_, err = os.Stat(dirOut + "/" + report.Name)
+			if saved := err == nil; saved != tt.wantSaved {
+				t.Errorf("Report() saved = %v, want %v", saved, tt.wantSaved)
+			}
+		})
+	}
+}
+
+func TestUseCase_saveReportsModes(t *testing.T) {
+	stored := []events.Event{{ID: "1", UnitGUID: "unit1", SourceFile: "a.tsv"}, {ID: "2", UnitGUID: "unit1", SourceFile: "b.tsv"}}
+	tests := []struct {
+		name         string
+		cfg          ReportConfig
+		wantFiles    []string
+		mockBehavior func(r *mocks.MockStorage)
+	}{
+		{
+			name:         "file",
+			cfg:          ReportConfig{},
+			wantFiles:    []string{"unit1.pdf"},
+			mockBehavior: func(r *mocks.MockStorage) {},
+		},
+		{
+			name:         "formats",
+			cfg:          ReportConfig{Formats: []string{FormatPDF, FormatHTML, FormatCSV, FormatXLSX}},
+			wantFiles:    []string{"unit1.pdf", "unit1.html", "unit1.csv", "unit1.xlsx"},
+			mockBehavior: func(r *mocks.MockStorage) {},
+		},
+		{
+			name:      "history",
+			cfg:       ReportConfig{Mode: ReportModeHistory},
+			wantFiles: []string{"unit1.pdf"},
+			mockBehavior: func(r *mocks.MockStorage) {
+				r.EXPECT().ListEvents(gomock.Any(), service.EventsQuery{
+					Filter: service.EventFilter{UnitGUID: "unit1", Pipeline: events.DefaultPipeline},
+					SortBy: service.SortIngestedAt,
+					Limit:  MaxEventsLimit,
+				}).Return(stored, nil)
+			},
+		},
+		{
+			name:      "window",
+			cfg:       ReportConfig{Mode: ReportModeWindow, Window: time.Hour},
+			wantFiles: []string{"unit1.pdf"},
+			mockBehavior: func(r *mocks.MockStorage) {
+				r.EXPECT().ListEvents(gomock.Any(), gomock.Any()).DoAndReturn(
+					func(_ context.Context, q service.EventsQuery) ([]events.Event, error) {
+						if since := time.Since(q.Filter.From); since < time.Hour || since > time.Hour+time.Minute {
+							t.Errorf("ListEvents() from = %v", q.Filter.From)
+						}
+						return stored, nil
+					})
+			},
+		},
+		{
+			name:         "per file",
+			cfg:          ReportConfig{Naming: ReportNamingFile, Formats: []string{FormatPDF, FormatCSV}},
+			wantFiles:    []string{"unit1/b.pdf", "unit1/b.csv"},
+			mockBehavior: func(r *mocks.MockStorage) {},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+			st := mocks.NewMockStorage(c)
+			tt.mockBehavior(st)
+
+			loggerInstance := httplog.NewLogger("watcher", httplog.Options{
+				Concise: true,
+			})
+
+			dirOut := t.TempDir()
+			u := New(st, dirOut, logger.New(loggerInstance))
+			u.SetReportConfig(tt.cfg)
+
+			es := sourceStub{eventStub: eventStub{events: stored[1:]}, source: "b.tsv"}
+			if err := u.saveReports(context.Background(), u.pipelines[events.DefaultPipeline], es); err != nil {
+				t.Fatalf("saveReports() error = %v", err)
+			}
+
+			for _, file := range tt.wantFiles {
+				if _, err := os.Stat(dirOut + "/" + file); err != nil {
+					t.Errorf("saveReports() missing %s: %v", file, err)
+				}
+			}
+
+			if tt.cfg.Naming == ReportNamingFile {
+				// the source file selects the saved report of the file
+				report, err := u.Report(context.Background(), "unit1", ReportOptions{SourceFile: "b.tsv"})
+				if err != nil {
+					t.Fatalf("Report() error = %v", err)
+				}
+				report.Close()
+			}
+		})
+	}
+}
+
+func TestUseCase_saveReports_unitPath(t *testing.T) {
+	loggerInstance := httplog.NewLogger("watcher", httplog.Options{
+		Concise: true,
+	})
+
+	root := t.TempDir()
+	dirOut := filepath.Join(root, "out")
+	u := New(nil, dirOut, logger.New(loggerInstance))
+	u.SetReportConfig(ReportConfig{Naming: ReportNamingFile})
+
+	// the unit guids of the ingested file can't place the reports outside the output directory
+	es := sourceStub{source: "b.tsv", eventStub: eventStub{events: []events.Event{
+		{ID: "1", UnitGUID: "../../escape"},
+		{ID: "2", UnitGUID: ".."},
+		{ID: "3", UnitGUID: "."},
+		{ID: "4", UnitGUID: ""},
+		{ID: "5", UnitGUID: "unit1"},
+	}}}
+	if err := u.saveReports(context.Background(), u.pipelines[events.DefaultPipeline], es); err != nil {
+		t.Fatalf("saveReports() error = %v", err)
+	}
+
+	var files []string
+	filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			rel, _ := filepath.Rel(root, path)
+			files = append(files, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	if len(files) != 1 || files[0] != "out/unit1/b.pdf" {
+		t.Errorf("saveReports() files = %v, want [out/unit1/b.pdf]", files)
+	}
+}
+
+// sourceStub is an eventStub parsed from the source file.
+type sourceStub struct {
+	eventStub
+	source string
+}
+
+func (s sourceStub) Source() string {
+	return s.source
+}
@@ -0,0 +1,312 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"go-tsv-watcher/internal/events"
+	"go-tsv-watcher/internal/storage"
+	"go-tsv-watcher/internal/storage/service"
+	"sync"
+	"time"
+)
+
+// streamBuffer is the number of events buffered for a subscriber.
+const streamBuffer = 1024
+
+// streamWait is how long publishing waits for the subscribers with a full buffer before dropping them.
+var streamWait = 5 * time.Second
+
+// StreamEvent is a stored event pushed to a stream.
+type StreamEvent struct {
+	// ID resumes the stream after the event.
+	ID    string
+	Event events.Event
+}
+
+// subscriber receives the published events matching its filter.
+type subscriber struct {
+	filter service.EventFilter
+	events chan events.Event
+	// done is closed when the subscriber is removed, so the publishers stop waiting for it
+	done chan struct{}
+	// mu is held by the publishers while sending, so events is closed after them
+	mu sync.Mutex
+}
+
+// stop ends the subscriber once it's removed from the broker.
+func (s *subscriber) stop() {
+	close(s.done)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	close(s.events)
+}
+
+// send sends the events matching the filter, false if the subscriber is still lagging behind when expired is closed.
+func (s *subscriber) send(evs []events.Event, expired <-chan struct{}) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// events is open while the lock is held unless the subscriber was stopped before
+	select {
+	case <-s.done:
+		return true
+	default:
+	}
+
+	for _, e := range evs {
+		if !s.filter.Match(e) {
+			continue
+		}
+		select {
+		case <-s.done:
+			return true
+		case s.events <- e:
+			continue
+		default:
+		}
+		select {
+		case <-s.done:
+			return true
+		case s.events <- e:
+		case <-expired:
+			return false
+		}
+	}
+	return true
+}
+
+// broker fans out the stored events to the subscribers.
+type broker struct {
+	mu          sync.Mutex
+	subscribers map[*subscriber]struct{}
+	// closed brokers end the new subscribers at once
+	closed bool
+}
+
+// subscribe adds a subscriber of the events matching the filter.
+func (b *broker) subscribe(filter service.EventFilter) *subscriber {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.subscribers == nil {
+		b.subscribers = make(map[*subscriber]struct{})
+	}
+	s := &subscriber{filter: filter, events: make(chan events.Event, streamBuffer), done: make(chan struct{})}
+	if b.closed {
+		close(s.done)
+		close(s.events)
+		return s
+	}
+	b.subscribers[s] = struct{}{}
+	return s
+}
+
+// close removes the subscribers and closes their channels, the later subscribers are closed at once.
+func (b *broker) close() {
+	b.mu.Lock()
+	b.closed = true
+	subscribers := b.subscribers
+	b.subscribers = nil
+	b.mu.Unlock()
+
+	for s := range subscribers {
+		s.stop()
+	}
+}
+
+// unsubscribe removes the subscriber and closes its channel.
+func (b *broker) unsubscribe(s *subscriber) {
+	b.mu.Lock()
+	_, ok := b.subscribers[s]
+	delete(b.subscribers, s)
+	b.mu.Unlock()
+
+	if ok {
+		s.stop()
+	}
+}
+
+// active reports whether anyone is subscribed.
+func (b *broker) active() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subscribers) != 0
+}
+
+// publish sends the events to the subscribers. The events past a full buffer wait for the subscriber
+// up to streamWait in total, the subscribers still lagging behind are dropped
+// and resume from the storage when they reconnect. The broker isn't locked while waiting,
+// so the streams are opened and closed meanwhile.
+func (b *broker) publish(evs []events.Event) {
+	b.mu.Lock()
+	subscribers := make([]*subscriber, 0, len(b.subscribers))
+	for s := range b.subscribers {
+		subscribers = append(subscribers, s)
+	}
+	b.mu.Unlock()
+
+	expired := make(chan struct{})
+	timeout := time.AfterFunc(streamWait, func() { close(expired) })
+	defer timeout.Stop()
+
+	for _, s := range subscribers {
+		if !s.send(evs, expired) {
+			b.unsubscribe(s)
+		}
+	}
+}
+
+// streamID returns the ID of the event in the stream.
+func streamID(e events.Event) string {
+	return service.EncodeCursor(service.CursorOf(e, service.SortIngestedAt))
+}
+
+// publishStored publishes the stored events of the file of the pipeline to the streams.
+func (u *UseCase) publishStored(ctx context.Context, p *pipeline, source string) {
+	if !u.streams.active() {
+		return
+	}
+
+	// the storage sets the ingestion time, so the events are read back to get the stream IDs
+	evs, err := u.unitEvents(ctx, p.storage, service.EventFilter{SourceFile: source, Pipeline: p.Name})
+	if err != nil {
+		return
+	}
+	u.streams.publish(evs)
+}
+
+// CloseStreams ends the streams and the ones opened later, so the servers shut down without waiting for them.
+// The clients resume from the storage when they reconnect.
+func (u *UseCase) CloseStreams() {
+	u.streams.close()
+}
+
+// Stream pushes the events matching the filter as they are stored until the context is done.
+// With lastEventID the events stored after it are replayed first from the storage of the pipeline of the filter,
+// from all storages in the order of ingestion if the filter has no pipeline, like the live events.
+// The channel is closed when the context is done, the storage fails or the reader lags behind.
+func (u *UseCase) Stream(ctx context.Context, filter service.EventFilter, lastEventID string) (<-chan StreamEvent, error) {
+	var after *service.Cursor
+	if lastEventID != "" {
+		var err error
+		if after, err = service.DecodeCursor(lastEventID); err != nil {
+			return nil, fmt.Errorf("%w: bad last event id", err)
+		}
+		if _, err = service.CursorArg(after, service.SortIngestedAt); err != nil {
+			return nil, fmt.Errorf("%w: bad last event id", err)
+		}
+	}
+
+	var sts []storage.Storage
+	if filter.Pipeline == "" {
+		sts = u.storages()
+	} else {
+		st, err := u.storageOf(filter.Pipeline)
+		if err != nil {
+			return nil, err
+		}
+		sts = []storage.Storage{st}
+	}
+
+	// the subscriber buffers the events stored during the replay
+	sub := u.streams.subscribe(filter)
+	out := make(chan StreamEvent)
+
+	go func() {
+		defer close(out)
+		defer u.streams.unsubscribe(sub)
+
+		send := func(e events.Event) bool {
+			select {
+			case out <- StreamEvent{ID: streamID(e), Event: e}:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		query := service.EventsQuery{Filter: filter, SortBy: service.SortIngestedAt, Limit: MaxEventsLimit, After: after}
+		if after != nil && !u.replay(ctx, sts, &query, send) {
+			return
+		}
+
+		for {
+			select {
+			case e, ok := <-sub.events:
+				if !ok {
+					return
+				}
+				// skip the events already replayed
+				if !query.IsAfter(e) {
+					continue
+				}
+				if !send(e) {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+// replay sends the events of the storages after the cursor of the query in its order and moves the cursor
+// to the last one, false if the stream is over.
+func (u *UseCase) replay(ctx context.Context, sts []storage.Storage, query *service.EventsQuery,
+	send func(e events.Event) bool) bool {
+	sources := make([]*replaySource, len(sts))
+	for i, st := range sts {
+		sources[i] = &replaySource{st: st, query: *query}
+	}
+
+	for {
+		var next *replaySource
+		for _, src := range sources {
+			if err := src.fill(ctx); err != nil {
+				u.logger.Warn(fmt.Sprintf("Failed to replay events: %v", err))
+				return false
+			}
+			if len(src.evs) != 0 && (next == nil || query.Less(src.evs[0], next.evs[0])) {
+				next = src
+			}
+		}
+		if next == nil {
+			return true
+		}
+
+		e := next.evs[0]
+		next.evs = next.evs[1:]
+		if !send(e) {
+			return false
+		}
+		query.After = service.CursorOf(e, query.SortBy)
+	}
+}
+
+// replaySource reads the events of a storage page by page.
+type replaySource struct {
+	st    storage.Storage
+	query service.EventsQuery
+	evs   []events.Event
+	// last is set once the last page is read
+	last bool
+}
+
+// fill reads the next page once the previous one is sent.
+func (s *replaySource) fill(ctx context.Context) error {
+	if len(s.evs) != 0 || s.last {
+		return nil
+	}
+
+	evs, err := s.st.ListEvents(ctx, s.query)
+	if err != nil {
+		return err
+	}
+	if len(evs) != 0 {
+		s.query.After = service.CursorOf(evs[len(evs)-1], s.query.SortBy)
+	}
+	s.evs, s.last = evs, len(evs) < s.query.Limit
+	return nil
+}
@@ -0,0 +1,304 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/go-chi/httplog"
+	"github.com/golang/mock/gomock"
+	"go-tsv-watcher/internal/events"
+	"go-tsv-watcher/internal/storage/mocks"
+	"go-tsv-watcher/internal/storage/service"
+	"go-tsv-watcher/pkg/logger"
+	"testing"
+	"time"
+)
+
+func streamEvents(n int) []events.Event {
+	start := time.Date(2023, 5, 1, 0, 0, 0, 0, time.UTC)
+	evs := make([]events.Event, n)
+	for i := range evs {
+		evs[i] = events.Event{
+			ID:         fmt.Sprintf("e%d", i),
+			UnitGUID:   "unit1",
+			Level:      i,
+			SourceFile: "a.tsv",
+			IngestedAt: start.Add(time.Duration(i) * time.Second),
+		}
+	}
+	return evs
+}
+
+func TestUseCase_Stream(t *testing.T) {
+	evs := streamEvents(4)
+	minLevel := 1
+
+	tests := []struct {
+		name         string
+		filter       service.EventFilter
+		lastEventID  string
+		live         []events.Event
+		want         []string
+		wantErr      error
+		mockBehavior func(r *mocks.MockStorage)
+	}{
+		{
+			name:         "live",
+			filter:       service.EventFilter{MinLevel: &minLevel},
+			live:         evs,
+			want:         []string{"e1", "e2", "e3"},
+			mockBehavior: func(r *mocks.MockStorage) {},
+		},
+		{
+			name:        "resume",
+			filter:      service.EventFilter{UnitGUID: "unit1"},
+			lastEventID: streamID(evs[0]),
+			live:        evs[2:],
+			want:        []string{"e1", "e2", "e3"},
+			mockBehavior: func(r *mocks.MockStorage) {
+				r.EXPECT().ListEvents(gomock.Any(), service.EventsQuery{
+					Filter: service.EventFilter{UnitGUID: "unit1"},
+					SortBy: service.SortIngestedAt,
+					Limit:  MaxEventsLimit,
+					After:  &service.Cursor{Value: "2023-05-01T00:00:00Z", ID: "e0"},
+				}).Return(evs[1:3], nil)
+			},
+		},
+		{
+			name:         "bad last event id",
+			lastEventID:  "nope",
+			wantErr:      service.ErrInvalidQuery,
+			mockBehavior: func(r *mocks.MockStorage) {},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+			st := mocks.NewMockStorage(c)
+			tt.mockBehavior(st)
+
+			loggerInstance := httplog.NewLogger("watcher", httplog.Options{
+				Concise: true,
+			})
+			u := New(st, t.TempDir(), logger.New(loggerInstance))
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			stream, err := u.Stream(ctx, tt.filter, tt.lastEventID)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Stream() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			u.streams.publish(tt.live)
+
+			var got []string
+			for len(got) < len(tt.want) {
+				select {
+				case e := <-stream:
+					if e.ID != streamID(e.Event) {
+						t.Errorf("Stream() id = %s", e.ID)
+					}
+					got = append(got, e.Event.ID)
+				case <-time.After(time.Second):
+					t.Fatalf("Stream() got %v, want %v", got, tt.want)
+				}
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("Stream() got %v, want %v", got, tt.want)
+			}
+
+			cancel()
+			if _, ok := <-stream; ok {
+				t.Errorf("Stream() is not closed")
+			}
+		})
+	}
+}
+
+func TestUseCase_publishStored(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+	st := mocks.NewMockStorage(c)
+
+	loggerInstance := httplog.NewLogger("watcher", httplog.Options{
+		Concise: true,
+	})
+	u := New(st, t.TempDir(), logger.New(loggerInstance))
+
+	// nothing is read without subscribers
+	u.publishStored(context.Background(), u.pipelines[events.DefaultPipeline], "a.tsv")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream, err := u.Stream(ctx, service.EventFilter{}, "")
+	if err != nil {
+		t.Fatalf("Stream() error = %v", err)
+	}
+
+	st.EXPECT().ListEvents(gomock.Any(), service.EventsQuery{
+		Filter: service.EventFilter{SourceFile: "a.tsv", Pipeline: events.DefaultPipeline},
+		SortBy: service.SortIngestedAt,
+		Limit:  MaxEventsLimit,
+	}).Return(streamEvents(1), nil)
+	u.publishStored(context.Background(), u.pipelines[events.DefaultPipeline], "a.tsv")
+
+	select {
+	case e := <-stream:
+		if e.Event.ID != "e0" {
+			t.Errorf("Stream() got %v", e.Event)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Stream() got no events")
+	}
+}
+
+func TestBroker_lagging(t *testing.T) {
+	defer func(wait time.Duration) { streamWait = wait }(streamWait)
+	streamWait = 10 * time.Millisecond
+
+	var b broker
+	s := b.subscribe(service.EventFilter{})
+
+	b.publish(streamEvents(streamBuffer + 1))
+	if b.active() {
+		t.Errorf("publish() kept the lagging subscriber")
+	}
+
+	n := 0
+	for range s.events {
+		n++
+	}
+	if n != streamBuffer {
+		t.Errorf("publish() sent %d events, want %d", n, streamBuffer)
+	}
+
+	// unsubscribing the dropped subscriber is a no-op
+	b.unsubscribe(s)
+}
+
+func TestBroker_bigFile(t *testing.T) {
+	var b broker
+	s := b.subscribe(service.EventFilter{})
+
+	received := make(chan int)
+	go func() {
+		n := 0
+		for range s.events {
+			n++
+		}
+		received <- n
+	}()
+
+	// the reading subscriber keeps up with the files bigger than its buffer
+	b.publish(streamEvents(3 * streamBuffer))
+	if !b.active() {
+		t.Errorf("publish() dropped the reading subscriber")
+	}
+
+	b.unsubscribe(s)
+	if n := <-received; n != 3*streamBuffer {
+		t.Errorf("publish() sent %d events, want %d", n, 3*streamBuffer)
+	}
+}
+
+func TestUseCase_CloseStreams(t *testing.T) {
+	u := New(nil, t.TempDir(), nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stream, err := u.Stream(ctx, service.EventFilter{}, "")
+	if err != nil {
+		t.Fatalf("Stream() error = %v", err)
+	}
+
+	u.CloseStreams()
+	if _, ok := <-stream; ok {
+		t.Errorf("CloseStreams() kept the stream open")
+	}
+
+	later, err := u.Stream(ctx, service.EventFilter{}, "")
+	if err != nil {
+		t.Fatalf("Stream() error = %v", err)
+	}
+	if _, ok := <-later; ok {
+		t.Errorf("Stream() opened a stream after CloseStreams()")
+	}
+}
+
+func TestBroker_publishUnlocked(t *testing.T) {
+	defer func(wait time.Duration) { streamWait = wait }(streamWait)
+	streamWait = time.Second
+
+	var b broker
+	lagging := b.subscribe(service.EventFilter{})
+
+	published := make(chan struct{})
+	go func() {
+		b.publish(streamEvents(streamBuffer + 1))
+		close(published)
+	}()
+
+	// the streams are opened and closed while the publisher waits for the lagging one
+	start := time.Now()
+	s := b.subscribe(service.EventFilter{})
+	b.unsubscribe(s)
+	if elapsed := time.Since(start); elapsed > streamWait/2 {
+		t.Errorf("subscribe() waited %v for the publisher", elapsed)
+	}
+
+	// the removed subscriber is not waited for
+	b.unsubscribe(lagging)
+	select {
+	case <-published:
+	case <-time.After(streamWait / 2):
+		t.Errorf("publish() kept waiting for the removed subscriber")
+	}
+}
+
+func TestUseCase_Stream_replayStorages(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+
+	evs := streamEvents(5)
+	evs[2].Pipeline, evs[4].Pipeline = "line2", "line2"
+	main, own := mocks.NewMockStorage(c), mocks.NewMockStorage(c)
+	query := service.EventsQuery{
+		SortBy: service.SortIngestedAt,
+		Limit:  MaxEventsLimit,
+		After:  service.CursorOf(evs[0], service.SortIngestedAt),
+	}
+	main.EXPECT().ListEvents(gomock.Any(), query).Return([]events.Event{evs[1], evs[3]}, nil)
+	own.EXPECT().ListEvents(gomock.Any(), query).Return([]events.Event{evs[2], evs[4]}, nil)
+
+	u := New(main, t.TempDir(), logger.New(httplog.NewLogger("watcher", httplog.Options{Concise: true})))
+	if err := u.SetPipelines([]Pipeline{{Name: "default"}, {Name: "line2", Storage: own}}); err != nil {
+		t.Fatalf("SetPipelines() error = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// the stream of all pipelines replays the events of the pipeline with its own storage too
+	stream, err := u.Stream(ctx, service.EventFilter{}, streamID(evs[0]))
+	if err != nil {
+		t.Fatalf("Stream() error = %v", err)
+	}
+
+	var got []string
+	for len(got) < 4 {
+		select {
+		case e := <-stream:
+			got = append(got, e.Event.ID)
+		case <-time.After(time.Second):
+			t.Fatalf("Stream() got %v", got)
+		}
+	}
+	if want := []string{"e1", "e2", "e3", "e4"}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Stream() got %v, want %v", got, want)
+	}
+}
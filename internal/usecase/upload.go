@@ -0,0 +1,132 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"go-tsv-watcher/internal/storage/service"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// DefaultMaxUploadSize is the biggest upload in bytes unless set by SetMaxUploadSize.
+const DefaultMaxUploadSize int64 = 32 << 20
+
+// UploadPending is the status of an upload not ingested yet,
+// ingested uploads have the status of their file.
+const UploadPending = "pending"
+
+// uploadPrefix starts the names of uploaded files in the watched directory.
+const uploadPrefix = "upload-"
+
+// ErrUploadTooLarge error occurs when the upload exceeds the max upload size
+var ErrUploadTooLarge = errors.New("upload is too large")
+
+// ErrUploadNotFound error for not found upload
+var ErrUploadNotFound = errors.New("upload not found")
+
+// Upload is the ingestion state of an uploaded file.
+type Upload struct {
+	ID       string `json:"id"`
+	Pipeline string `json:"pipeline"`
+	File     string `json:"file"`
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	// Events is the number of saved rows, Stored is the number still in the storage.
+	Events int `json:"events"`
+	Stored int `json:"stored"`
+}
+
+// SetMaxUploadSize sets the biggest upload in bytes.
+func (u *UseCase) SetMaxUploadSize(size int64) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.maxUploadSize = size
+}
+
+// Upload streams the body into the watched directory of the pipeline, so Process ingests it
+// on the next refresh the same way as dropped files.
+func (u *UseCase) Upload(ctx context.Context, pipeline string, body io.Reader) (Upload, error) {
+	p, err := u.pipeline(pipeline)
+	if err != nil {
+		return Upload{}, err
+	}
+
+	u.mu.RLock()
+	watching, dir, limit := p.fileWatcher != nil, p.dir, u.maxUploadSize
+	u.mu.RUnlock()
+
+	if !watching {
+		return Upload{}, ErrNotWatching
+	}
+	if limit <= 0 {
+		limit = DefaultMaxUploadSize
+	}
+
+	// the temporary name has no .tsv suffix, so the watcher skips the incomplete file
+	tmp, err := os.CreateTemp(dir, "."+uploadPrefix+"*")
+	if err != nil {
+		return Upload{}, fmt.Errorf("failed to create upload: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	n, err := io.Copy(tmp, io.LimitReader(body, limit+1))
+	if errClose := tmp.Close(); err == nil {
+		err = errClose
+	}
+	if err != nil {
+		return Upload{}, fmt.Errorf("failed to write upload: %w", err)
+	}
+	if n > limit {
+		return Upload{}, ErrUploadTooLarge
+	}
+	if ctx.Err() != nil {
+		return Upload{}, ctx.Err()
+	}
+
+	id := uuid.NewString()
+	upload := Upload{ID: id, Pipeline: p.Name, File: uploadPrefix + id + ".tsv", Status: UploadPending}
+	if err = os.Rename(tmp.Name(), filepath.Join(dir, upload.File)); err != nil {
+		return Upload{}, fmt.Errorf("failed to save upload: %w", err)
+	}
+
+	return upload, nil
+}
+
+// GetUpload returns the ingestion state of the upload of the pipeline.
+func (u *UseCase) GetUpload(ctx context.Context, pipeline, id string) (Upload, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return Upload{}, ErrUploadNotFound
+	}
+
+	p, err := u.pipeline(pipeline)
+	if err != nil {
+		return Upload{}, err
+	}
+
+	u.mu.RLock()
+	dir := p.dir
+	u.mu.RUnlock()
+
+	upload := Upload{ID: id, Pipeline: p.Name, File: uploadPrefix + id + ".tsv"}
+
+	file, err := p.storage.GetFile(ctx, p.Name, upload.File)
+	if errors.Is(err, service.ErrFileNotFound) {
+		if _, errStat := os.Stat(filepath.Join(dir, upload.File)); dir == "" || errStat != nil {
+			return Upload{}, ErrUploadNotFound
+		}
+		upload.Status = UploadPending
+		return upload, nil
+	}
+	if err != nil {
+		u.logger.Warn(err.Error())
+		return Upload{}, ErrStorageIsUnavailable
+	}
+
+	upload.Status, upload.Error = file.Status, file.Error
+	upload.Events, upload.Stored = file.Events, file.Stored
+
+	return upload, nil
+}
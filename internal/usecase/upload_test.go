@@ -0,0 +1,109 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"github.com/go-chi/httplog"
+	"github.com/golang/mock/gomock"
+	"go-tsv-watcher/internal/events"
+	"go-tsv-watcher/internal/storage/mocks"
+	"go-tsv-watcher/internal/storage/service"
+	"go-tsv-watcher/internal/watcher"
+	"go-tsv-watcher/pkg/logger"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUseCase_Upload(t *testing.T) {
+	tests := []struct {
+		name         string
+		body         string
+		watching     bool
+		wantStatus   string
+		wantErr      error
+		mockBehavior func(r *mocks.MockStorage, file string)
+	}{
+		{
+			name:       "pending",
+			body:       "n\tunit_guid\n1\tunit1\n",
+			watching:   true,
+			wantStatus: UploadPending,
+			mockBehavior: func(r *mocks.MockStorage, file string) {
+				r.EXPECT().GetFile(gomock.Any(), events.DefaultPipeline, file).Return(service.FileDetails{}, service.ErrFileNotFound)
+			},
+		},
+		{
+			name:       "ingested",
+			body:       "n\tunit_guid\n1\tunit1\n",
+			watching:   true,
+			wantStatus: service.FileOK,
+			mockBehavior: func(r *mocks.MockStorage, file string) {
+				r.EXPECT().GetFile(gomock.Any(), events.DefaultPipeline, file).Return(service.FileDetails{
+					File:   service.File{Name: file, Status: service.FileOK, Events: 1},
+					Stored: 1,
+				}, nil)
+			},
+		},
+		{
+			name:         "too large",
+			body:         strings.Repeat("a", 65),
+			watching:     true,
+			wantErr:      ErrUploadTooLarge,
+			mockBehavior: func(r *mocks.MockStorage, file string) {},
+		},
+		{
+			name:         "not watching",
+			body:         "n\n",
+			wantErr:      ErrNotWatching,
+			mockBehavior: func(r *mocks.MockStorage, file string) {},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+			st := mocks.NewMockStorage(c)
+
+			loggerInstance := httplog.NewLogger("watcher", httplog.Options{
+				Concise: true,
+			})
+
+			dir := t.TempDir()
+			u := New(st, t.TempDir(), logger.New(loggerInstance))
+			u.SetMaxUploadSize(64)
+			if tt.watching {
+				u.pipelines[events.DefaultPipeline].fileWatcher, u.pipelines[events.DefaultPipeline].dir = watcher.New(time.Second, dir, nil), dir
+			}
+
+			upload, err := u.Upload(context.Background(), "", strings.NewReader(tt.body))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Upload() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			entries, _ := os.ReadDir(dir)
+			if err != nil {
+				if len(entries) != 0 {
+					t.Errorf("Upload() left %d files", len(entries))
+				}
+				return
+			}
+
+			content, err := os.ReadFile(filepath.Join(dir, upload.File))
+			if err != nil || string(content) != tt.body {
+				t.Fatalf("Upload() saved %q, error = %v", content, err)
+			}
+
+			tt.mockBehavior(st, upload.File)
+			got, err := u.GetUpload(context.Background(), "", upload.ID)
+			if err != nil {
+				t.Fatalf("GetUpload() error = %v", err)
+			}
+			if got.Status != tt.wantStatus {
+				t.Errorf("GetUpload() status = %v, want %v", got.Status, tt.wantStatus)
+			}
+		})
+	}
+}
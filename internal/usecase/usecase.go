@@ -4,106 +4,316 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"github.com/signintech/gopdf"
 	"go-tsv-watcher/internal/events"
+	"go-tsv-watcher/internal/report"
 	"go-tsv-watcher/internal/storage"
 	"go-tsv-watcher/internal/storage/service"
 	"go-tsv-watcher/internal/watcher"
 	"go-tsv-watcher/pkg/logger"
-	"reflect"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
 	"time"
 )
 
 // UseCase struct for the logic layer.
 type UseCase struct {
-	storage     storage.Storage
-	fileWatcher *watcher.Watcher
-	dirOut      string
-	logger      logger.ILogger
+	storage storage.Storage
+
+	// mu guards the pipelines with their watchers and directories set by Process,
+	// the upload, report and cache settings.
+	mu            sync.RWMutex
+	pipelines     map[string]*pipeline
+	names         []string
+	maxUploadSize int64
+	reports       ReportConfig
+	cache         *eventCache
+
+	// streams of the stored events
+	streams broker
+
+	// checks are the readiness checks added besides the ones of the use case
+	checks       []namedCheck
+	checkTimeout time.Duration
+	startedAt    time.Time
+
+	// ingesting is the context of the files ingested by Process, it is done by AbortIngestion only
+	ingesting context.Context
+	abort     context.CancelFunc
+
+	logger logger.ILogger
 }
 
 // ErrStorageIsUnavailable error occurs when is unavailable
 var ErrStorageIsUnavailable = errors.New("storage is unavailable")
 
+// ErrNotWatching error occurs when files are managed before Process started
+var ErrNotWatching = errors.New("files are not watched yet")
+
+// ErrFileIngested error occurs when the file to ingest is already in the ledger
+var ErrFileIngested = errors.New("file is already ingested")
+
 // IUseCase interface for mock testing.
 //
 //go:generate mockgen -source=usecase.go -destination=mocks/mock.go
 type IUseCase interface {
-	Process(ctx context.Context, refresh time.Duration, dir string) error
-	GetEventByNumber(ctx context.Context, unitGUID string, number int) (events.Event, error)
+	Process(ctx context.Context, pipeline string, refresh time.Duration, dir string) error
+	GetEventByNumber(ctx context.Context, pipeline, unitGUID string, number int) (events.Event, error)
+	ListEvents(ctx context.Context, query service.EventsQuery) (service.EventsPage, error)
+	Stream(ctx context.Context, filter service.EventFilter, lastEventID string) (<-chan StreamEvent, error)
+	ListUnits(ctx context.Context, pipeline string) ([]service.Unit, error)
+	GetUnit(ctx context.Context, pipeline, guid string) (service.UnitSummary, error)
+	ListFiles(ctx context.Context, pipeline, status string) ([]service.File, error)
+	GetFile(ctx context.Context, pipeline, name string) (service.FileDetails, error)
+	ReprocessFile(ctx context.Context, pipeline, name string) error
+	DeleteFile(ctx context.Context, pipeline, name string) (int, error)
+	Upload(ctx context.Context, pipeline string, body io.Reader) (Upload, error)
+	GetUpload(ctx context.Context, pipeline, id string) (Upload, error)
+	Report(ctx context.Context, unitGUID string, opts ReportOptions) (Report, error)
+	Prune(ctx context.Context, interval time.Duration, policy service.RetentionPolicy) error
+	CreateAPIKey(ctx context.Context, name string, scopes []string) (CreatedAPIKey, error)
+	ListAPIKeys(ctx context.Context) ([]service.APIKey, error)
+	GetAPIKey(ctx context.Context, hash string) (service.APIKey, error)
+	DeleteAPIKey(ctx context.Context, id string) error
+	CacheStats() CacheStats
+	Pipelines() []string
+	Ready(ctx context.Context) (bool, []Component)
+	Status(ctx context.Context) Status
 }
 
-// New UseCase constructor
+// New UseCase constructor with the default pipeline writing the reports to dirOut.
 func New(storage storage.Storage, dirOut string, loggerInstance logger.ILogger) *UseCase {
+	p := newPipeline(Pipeline{Name: events.DefaultPipeline, DirOut: dirOut}, storage)
+	ingesting, abort := context.WithCancel(context.Background())
 	return &UseCase{
-		storage: storage,
-		dirOut:  dirOut + "/",
-		logger:  loggerInstance,
+		storage:      storage,
+		pipelines:    map[string]*pipeline{p.Name: p},
+		names:        []string{p.Name},
+		startedAt:    time.Now(),
+		checkTimeout: CheckTimeout,
+		ingesting:    ingesting,
+		abort:        abort,
+		logger:       loggerInstance,
 	}
 }
 
-// Process the files in the directory
-func (u *UseCase) Process(ctx context.Context, refresh time.Duration, dir string) error {
+// Process the files of the pipeline in the directory until the context is done, the current file is finished first
+// unless AbortIngestion is called.
+func (u *UseCase) Process(ctx context.Context, name string, refresh time.Duration, dir string) error {
+	p, err := u.pipeline(name)
+	if err != nil {
+		return err
+	}
+
 	files := make(chan string, 100)
 
-	u.fileWatcher = watcher.New(refresh, dir, files)
-	err := u.storage.LoadFilenames(ctx, u.fileWatcher)
+	fileWatcher := watcher.New(refresh, dir, files)
+	err = p.storage.LoadFilenames(ctx, p.Name, fileWatcher)
 	if err != nil {
-		return fmt.Errorf("failed to load filenames: %w", err)
+		return fmt.Errorf("failed to load filenames of %s: %w", p.Name, err)
 	}
 
+	u.mu.Lock()
+	p.fileWatcher, p.dir = fileWatcher, dir
+	u.mu.Unlock()
+
 	go func() {
-		err = u.fileWatcher.Run()
-		if err != nil {
+		err := fileWatcher.Run(ctx)
+		if err != nil && ctx.Err() == nil {
 			u.logger.Warn(err.Error())
+			u.setLastError(p, err.Error())
 		}
 	}()
 
-	for ctx.Err() == nil {
+	for {
 		fmt.Println("Waiting for new file...")
-		filename := <-files
+		var path string
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case path = <-files:
+		}
+		// the select picks at random when both are ready, the file stays out of the ledger for the next start
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		filename := filepath.Base(path)
 		fmt.Println("New file:", filename)
-		gadgets, err := events.New(dir + "/" + filename)
+		gadgets, err := events.New(path, p.parserOptions())
 		if err != nil {
 			return fmt.Errorf("failed to create events: %w", err)
 		}
 
-		errFill := gadgets.Fill()
-		errAdd := u.storage.AddFilename(ctx, filename, errFill)
-		if errAdd != nil {
-			u.logger.Warn(fmt.Sprintf("Failed to add filename: %v", errAdd))
-		}
+		// the current file is finished when the context is done
+		_ = u.ingest(u.ingesting, p, gadgets)
+	}
+}
 
-		if errFill != nil {
-			u.logger.Warn(fmt.Sprintf("Failed to fill gadgets: %v", errFill))
-			continue
-		} else {
-			gadgets.Print()
-		}
+// AbortIngestion cancels the files being ingested by Process, they are not saved and stay out of the ledger.
+func (u *UseCase) AbortIngestion() {
+	u.abort()
+}
 
-		err = u.storage.SaveEvents(ctx, gadgets)
-		if err != nil {
-			u.logger.Warn(fmt.Sprintf("Failed to save devices: %v", err))
+// ingest saves the events with their file in the ledger of the pipeline and their reports,
+// the file is recorded as failed if it can't be parsed. The problems are logged.
+func (u *UseCase) ingest(ctx context.Context, p *pipeline, gadgets *events.Events) error {
+	warn := func(msg string) {
+		u.logger.Warn(msg)
+		u.setLastError(p, msg)
+	}
+	defer u.setLastFile(p, gadgets.Source())
+
+	if errFill := gadgets.Fill(); errFill != nil {
+		warn(fmt.Sprintf("Failed to fill gadgets: %v", errFill))
+		if err := p.storage.AddFilename(ctx, p.Name, gadgets.Source(), errFill); err != nil {
+			warn(fmt.Sprintf("Failed to add filename: %v", err))
+			return ErrStorageIsUnavailable
 		}
+		return nil
+	}
+	gadgets.Print()
 
-		err = u.savePDF(gadgets)
-		if err != nil {
-			u.logger.Warn(err.Error())
+	// the file is in the ledger only with all its events, so a file cut off is ingested again
+	err := p.storage.SaveFile(ctx, gadgets)
+	if err != nil {
+		warn(fmt.Sprintf("Failed to save devices: %v", err))
+		return ErrStorageIsUnavailable
+	}
+	u.invalidateUnits(gadgets)
+	u.publishStored(ctx, p, gadgets.Source())
+
+	err = u.saveReports(ctx, p, gadgets)
+	if err != nil {
+		warn(err.Error())
+	}
+	return nil
+}
+
+// IngestFile processes the file of the pipeline at once without the watcher and returns its ledger record,
+// the file of the first pipeline if the pipeline is empty. The files already in the ledger are not ingested again.
+func (u *UseCase) IngestFile(ctx context.Context, pipeline, path string) (service.FileDetails, error) {
+	p, err := u.pipeline(pipeline)
+	if err != nil {
+		return service.FileDetails{}, err
+	}
+
+	name := filepath.Base(path)
+	_, err = p.storage.GetFile(ctx, p.Name, name)
+	if err == nil {
+		return service.FileDetails{}, fmt.Errorf("%w: %s", ErrFileIngested, name)
+	}
+	if !errors.Is(err, service.ErrFileNotFound) {
+		u.logger.Warn(err.Error())
+		return service.FileDetails{}, ErrStorageIsUnavailable
+	}
+
+	return u.ingestFile(ctx, p, path)
+}
+
+// ReplayFile deletes the events of the stored file of the pipeline and ingests it again from the directory
+// without the watcher, the file of the first pipeline if the pipeline is empty.
+func (u *UseCase) ReplayFile(ctx context.Context, pipeline, dir, name string) (service.FileDetails, error) {
+	if filepath.Base(name) != name {
+		return service.FileDetails{}, service.ErrFileNotFound
+	}
+
+	p, err := u.pipeline(pipeline)
+	if err != nil {
+		return service.FileDetails{}, err
+	}
+
+	path := filepath.Join(dir, name)
+	if _, err = os.Stat(path); err != nil {
+		return service.FileDetails{}, service.ErrFileNotFound
+	}
+
+	err = p.storage.ResetFile(ctx, p.Name, name)
+	if err != nil {
+		if errors.Is(err, service.ErrFileNotFound) {
+			return service.FileDetails{}, err
 		}
+		u.logger.Warn(err.Error())
+		return service.FileDetails{}, ErrStorageIsUnavailable
+	}
+	u.invalidateAll()
+
+	return u.ingestFile(ctx, p, path)
+}
+
+// ingestFile ingests the file of the pipeline and returns its ledger record.
+func (u *UseCase) ingestFile(ctx context.Context, p *pipeline, path string) (service.FileDetails, error) {
+	gadgets, err := events.New(path, p.parserOptions())
+	if err != nil {
+		return service.FileDetails{}, fmt.Errorf("failed to create events: %w", err)
+	}
+
+	if err = u.ingest(ctx, p, gadgets); err != nil {
+		return service.FileDetails{}, err
+	}
+
+	return u.GetFile(ctx, p.Name, gadgets.Source())
+}
+
+// Watching returns ErrNotWatching until Process of the pipeline is started, so SetWatch can't fail for the pipeline.
+func (u *UseCase) Watching(name string) error {
+	p, err := u.pipeline(name)
+	if err != nil {
+		return err
+	}
+
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+	if p.fileWatcher == nil {
+		return ErrNotWatching
+	}
+	return nil
+}
+
+// SetWatch changes the refresh interval and the directory of the running Process of the pipeline,
+// the files found before are still processed.
+func (u *UseCase) SetWatch(name string, refresh time.Duration, dir string) error {
+	p, err := u.pipeline(name)
+	if err != nil {
+		return err
 	}
-	close(files)
-	return ctx.Err()
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if p.fileWatcher == nil {
+		return ErrNotWatching
+	}
+	p.fileWatcher.SetRefreshInterval(refresh)
+	p.fileWatcher.SetDir(dir)
+	p.dir = dir
+	return nil
 }
 
-func (u *UseCase) savePDF(devs service.IEvents) error {
+// saveReports saves the reports of the units of the events in the configured formats of the pipeline.
+func (u *UseCase) saveReports(ctx context.Context, p *pipeline, devs service.IEvents) error {
 	var devicesGroups = make(map[string][]events.Event, 20)
 	devs.Iter(func(d events.Event) (stop bool) {
 		devicesGroups[d.UnitGUID] = append(devicesGroups[d.UnitGUID], d)
 		return false
 	})
 
+	cfg := u.reportConfig(p)
 	for unitGUID, group := range devicesGroups {
-		err := u.process(group, unitGUID)
+		// the report of the whole history or window is rendered from the storage,
+		// so the events of the previous files of the unit are kept
+		if cfg.Naming != ReportNamingFile && cfg.Mode != ReportModeFile {
+			evs, err := u.unitEvents(ctx, p.storage, u.reportFilter(p, unitGUID, devs.Source()))
+			if err != nil {
+				return err
+			}
+			if len(evs) != 0 {
+				group = evs
+			}
+		}
+
+		err := u.process(p, group, unitGUID, devs.Source())
 		if err != nil {
 			return err
 		}
@@ -112,66 +322,111 @@ func (u *UseCase) savePDF(devs service.IEvents) error {
 	return nil
 }
 
-func (u *UseCase) process(group []events.Event, unitGUID string) error {
-	pdf := gopdf.GoPdf{}
-	defer pdf.Close()
+func (u *UseCase) process(p *pipeline, group []events.Event, unitGUID, source string) error {
+	cfg := u.reportConfig(p)
+	d := u.build(p, group)
 
-	pdf.Start(gopdf.Config{PageSize: *gopdf.PageSizeA4})
+	for _, format := range cfg.Formats {
+		renderer, err := u.renderer(p, format)
+		if err != nil {
+			return err
+		}
 
-	err := pdf.AddTTFFont("LiberationSerif-Regular", "resources/LiberationSerif-Regular.ttf")
-	if err != nil {
-		u.logger.Warn(fmt.Sprintf("failed to add font: %v", err.Error()))
-		return fmt.Errorf("failed to add font: %w", err)
+		name := u.reportName(p, unitGUID, source, format)
+		if name == "" {
+			u.logger.Warn(fmt.Sprintf("Skipped the report of unit %q of %s: the unit can't name a report", unitGUID, source))
+			return nil
+		}
+
+		err = u.saveReport(p, renderer, d, name)
+		if err != nil {
+			return err
+		}
 	}
 
-	err = pdf.SetFont("LiberationSerif-Regular", "", 14)
+	return nil
+}
+
+// build builds the report data of the events of a unit with the configured template of the pipeline.
+func (u *UseCase) build(p *pipeline, group []events.Event) report.Data {
+	cfg := u.reportConfig(p)
+	tmpl := report.Template{Title: cfg.Title, Columns: cfg.Columns}
+
+	return report.Build(group, tmpl, time.Now().UTC())
+}
+
+// render renders the report into w.
+func (u *UseCase) render(w io.Writer, renderer Renderer, d report.Data) error {
+	if err := renderer.Render(w, d); err != nil {
+		u.logger.Warn(fmt.Sprintf("Failed to render report: %v", err))
+		return fmt.Errorf("failed to render report: %w", err)
+	}
+	return nil
+}
+
+// saveReport renders the report to the output directory of the pipeline by its report name,
+// the file is replaced at once so it is never served half written.
+func (u *UseCase) saveReport(p *pipeline, renderer Renderer, d report.Data, name string) error {
+	finalName, ok := p.reportPath(name)
+	if !ok {
+		return fmt.Errorf("failed to save report: %q leaves the output directory", name)
+	}
+	tmpName := filepath.Join(filepath.Dir(finalName), "."+filepath.Base(finalName)+".tmp")
+
+	err := os.MkdirAll(filepath.Dir(finalName), 0755)
 	if err != nil {
-		u.logger.Warn(fmt.Sprintf("failed to set font: %v", err.Error()))
-		return fmt.Errorf("failed to set font: %w", err)
+		u.logger.Warn(fmt.Sprintf("Failed to save report: %v", err))
+		return fmt.Errorf("failed to save report: %w", err)
 	}
 
-	for _, d := range group {
-		pdf.AddPage()
+	f, err := os.Create(tmpName)
+	if err != nil {
+		u.logger.Warn(fmt.Sprintf("Failed to save report: %v", err))
+		return fmt.Errorf("failed to save report: %w", err)
+	}
 
-		// for reflection over devices.Device
-		dv := reflect.ValueOf(d)
-		for i := 0; i < dv.NumField(); i++ {
-			f := dv.Field(i)
-			switch f.Kind() {
-			case reflect.String:
-				err = pdf.Cell(nil, fmt.Sprintf("%s:  %v", dv.Type().Field(i).Name, f.String()))
-			case reflect.Int:
-				err = pdf.Cell(nil, fmt.Sprintf("%s:  %v", dv.Type().Field(i).Name, f.Int()))
-			case reflect.Bool:
-				err = pdf.Cell(nil, fmt.Sprintf("%s:  %v", dv.Type().Field(i).Name, f.Bool()))
-			default:
-				err = pdf.Cell(nil, fmt.Sprintf("unknown type:  %v", f.Kind()))
-			}
-			if err != nil {
-				u.logger.Warn(fmt.Sprintf("Failed to add text: %v", err))
-				return fmt.Errorf("failed to add text: %w", err)
-			}
-			pdf.Br(20)
+	err = u.render(f, renderer, d)
+	if errClose := f.Close(); err == nil && errClose != nil {
+		err = fmt.Errorf("failed to save report: %w", errClose)
+		u.logger.Warn(err.Error())
+	}
+	if err == nil {
+		if err = os.Rename(tmpName, finalName); err != nil {
+			err = fmt.Errorf("failed to save report: %w", err)
+			u.logger.Warn(err.Error())
 		}
 	}
-
-	finalName := u.dirOut + unitGUID + ".pdf"
-	err = pdf.WritePdf(finalName)
 	if err != nil {
-		u.logger.Warn(fmt.Sprintf("Failed to save PDF: %v", err))
-		return fmt.Errorf("failed to save PDF: %w", err)
+		os.Remove(tmpName)
+		return err
 	}
 
 	return nil
 }
 
-// GetEventByNumber gets an event by number
-func (u *UseCase) GetEventByNumber(ctx context.Context, unitGUID string, number int) (events.Event, error) {
+// GetEventByNumber gets an event of the pipeline by number, through the event cache if enabled,
+// the events of all pipelines of the storage of the use case are numbered if the pipeline is empty.
+func (u *UseCase) GetEventByNumber(ctx context.Context, pipeline, unitGUID string, number int) (events.Event, error) {
 	if number <= 0 {
 		return events.Event{}, service.ErrEventNotFound
 	}
 
-	ev, err := u.storage.GetEventByNumber(ctx, unitGUID, number)
+	st, err := u.storageOf(pipeline)
+	if err != nil {
+		return events.Event{}, err
+	}
+
+	cache, key := u.eventCache(), eventKey{pipeline: pipeline, unit: unitGUID, number: number}
+	var version uint64
+	if cache != nil {
+		ev, v, ok := cache.get(key)
+		if ok {
+			return ev, nil
+		}
+		version = v
+	}
+
+	ev, err := st.GetEventByNumber(ctx, pipeline, unitGUID, number)
 	if err != nil {
 		if errors.Is(err, service.ErrEventNotFound) {
 			return ev, err
@@ -179,5 +434,231 @@ func (u *UseCase) GetEventByNumber(ctx context.Context, unitGUID string, number
 		u.logger.Warn(err.Error())
 		return ev, ErrStorageIsUnavailable
 	}
+
+	if cache != nil {
+		cache.put(key, ev, version)
+	}
 	return ev, nil
 }
+
+// DefaultEventsLimit is the page size used when the query has no limit.
+const DefaultEventsLimit = 100
+
+// MaxEventsLimit is the biggest page size.
+const MaxEventsLimit = 1000
+
+// ListEvents returns a page of events matching the query,
+// the storage of the pipeline of the filter is queried.
+func (u *UseCase) ListEvents(ctx context.Context, query service.EventsQuery) (service.EventsPage, error) {
+	if _, err := query.Column(); err != nil {
+		return service.EventsPage{}, err
+	}
+
+	st, err := u.storageOf(query.Filter.Pipeline)
+	if err != nil {
+		return service.EventsPage{}, err
+	}
+
+	switch {
+	case query.Limit <= 0:
+		query.Limit = DefaultEventsLimit
+	case query.Limit > MaxEventsLimit:
+		query.Limit = MaxEventsLimit
+	}
+
+	// one more event to find out whether there is a next page
+	limit := query.Limit
+	query.Limit++
+
+	evs, err := st.ListEvents(ctx, query)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidQuery) {
+			return service.EventsPage{}, err
+		}
+		u.logger.Warn(err.Error())
+		return service.EventsPage{}, ErrStorageIsUnavailable
+	}
+
+	page := service.EventsPage{Events: evs}
+	if len(evs) > limit {
+		page.Events = evs[:limit]
+		last := page.Events[limit-1]
+		page.Next = service.CursorOf(last, query.SortBy)
+	}
+
+	return page, nil
+}
+
+// ListUnits returns the units of the pipeline, all known units of the storage of the use case
+// if the pipeline is empty.
+func (u *UseCase) ListUnits(ctx context.Context, pipeline string) ([]service.Unit, error) {
+	st, err := u.storageOf(pipeline)
+	if err != nil {
+		return nil, err
+	}
+
+	units, err := st.ListUnits(ctx, pipeline)
+	if err != nil {
+		u.logger.Warn(err.Error())
+		return nil, ErrStorageIsUnavailable
+	}
+
+	if units == nil {
+		units = []service.Unit{}
+	}
+	return units, nil
+}
+
+// GetUnit returns the unit with its events of the pipeline broken down by class and area.
+func (u *UseCase) GetUnit(ctx context.Context, pipeline, guid string) (service.UnitSummary, error) {
+	st, err := u.storageOf(pipeline)
+	if err != nil {
+		return service.UnitSummary{}, err
+	}
+
+	unit, err := st.GetUnit(ctx, pipeline, guid)
+	if err != nil {
+		if errors.Is(err, service.ErrUnitNotFound) {
+			return unit, err
+		}
+		u.logger.Warn(err.Error())
+		return unit, ErrStorageIsUnavailable
+	}
+	return unit, nil
+}
+
+// ListFiles returns the ingested files of the pipeline with the status, all files if the status is empty,
+// the files of all pipelines of the storage of the use case if the pipeline is empty.
+func (u *UseCase) ListFiles(ctx context.Context, pipeline, status string) ([]service.File, error) {
+	if !service.ValidFileStatus(status) {
+		return nil, fmt.Errorf("%w: unknown file status %q", service.ErrInvalidQuery, status)
+	}
+
+	st, err := u.storageOf(pipeline)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := st.ListFiles(ctx, pipeline, status)
+	if err != nil {
+		u.logger.Warn(err.Error())
+		return nil, ErrStorageIsUnavailable
+	}
+
+	if files == nil {
+		files = []service.File{}
+	}
+	return files, nil
+}
+
+// GetFile returns the file of the pipeline with the units of its events and the PDFs produced for them,
+// the file of the first pipeline if the pipeline is empty.
+func (u *UseCase) GetFile(ctx context.Context, pipeline, name string) (service.FileDetails, error) {
+	p, err := u.pipeline(pipeline)
+	if err != nil {
+		return service.FileDetails{}, err
+	}
+
+	file, err := p.storage.GetFile(ctx, p.Name, name)
+	if err != nil {
+		if errors.Is(err, service.ErrFileNotFound) {
+			return file, err
+		}
+		u.logger.Warn(err.Error())
+		return file, ErrStorageIsUnavailable
+	}
+
+	file.PDFs = []string{}
+	for _, guid := range file.Units {
+		pdf := u.reportName(p, guid, name, FormatPDF)
+		if path, ok := p.reportPath(pdf); ok {
+			if _, err = os.Stat(path); err == nil {
+				file.PDFs = append(file.PDFs, pdf)
+			}
+		}
+	}
+
+	return file, nil
+}
+
+// ReprocessFile deletes the events of the file of the pipeline and hands it back to its watcher,
+// so Process ingests it again on the next refresh.
+func (u *UseCase) ReprocessFile(ctx context.Context, pipeline, name string) error {
+	if filepath.Base(name) != name {
+		return service.ErrFileNotFound
+	}
+
+	p, err := u.pipeline(pipeline)
+	if err != nil {
+		return err
+	}
+
+	u.mu.RLock()
+	fileWatcher, dir := p.fileWatcher, p.dir
+	u.mu.RUnlock()
+
+	if fileWatcher == nil {
+		return ErrNotWatching
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+		return service.ErrFileNotFound
+	}
+
+	err = p.storage.ResetFile(ctx, p.Name, name)
+	if err != nil {
+		if errors.Is(err, service.ErrFileNotFound) {
+			return err
+		}
+		u.logger.Warn(err.Error())
+		return ErrStorageIsUnavailable
+	}
+
+	u.invalidateAll()
+	fileWatcher.Forget(name)
+	return nil
+}
+
+// DeleteFile deletes the events of the file of the pipeline and returns their number.
+func (u *UseCase) DeleteFile(ctx context.Context, pipeline, name string) (int, error) {
+	p, err := u.pipeline(pipeline)
+	if err != nil {
+		return 0, err
+	}
+
+	deleted, err := p.storage.DeleteFile(ctx, p.Name, name)
+	if err != nil {
+		if errors.Is(err, service.ErrFileNotFound) {
+			return 0, err
+		}
+		u.logger.Warn(err.Error())
+		return 0, ErrStorageIsUnavailable
+	}
+
+	u.invalidateAll()
+	return deleted, nil
+}
+
+// Prune applies the retention policy to the storages every interval.
+func (u *UseCase) Prune(ctx context.Context, interval time.Duration, policy service.RetentionPolicy) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		for _, st := range u.storages() {
+			n, err := st.Prune(ctx, policy)
+			if err != nil {
+				u.logger.Warn(fmt.Sprintf("Failed to prune events: %v", err))
+			} else if n > 0 {
+				u.invalidateAll()
+				u.logger.Info(fmt.Sprintf("Pruned %d events", n))
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -8,11 +8,14 @@ import (
 	"go-tsv-watcher/internal/events"
 	"go-tsv-watcher/internal/storage/mocks"
 	"go-tsv-watcher/internal/storage/service"
+	"go-tsv-watcher/internal/watcher"
 	"go-tsv-watcher/pkg/logger"
 	"os"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestUseCase_GetEventByNumber(t *testing.T) {
@@ -41,7 +44,7 @@ func TestUseCase_GetEventByNumber(t *testing.T) {
 				ID:       "testID",
 			},
 			mockBehavior: func(r *mocks.MockStorage) {
-				r.EXPECT().GetEventByNumber(gomock.Any(), gomock.Any(), gomock.Any()).Return(events.Event{
+				r.EXPECT().GetEventByNumber(gomock.Any(), "", gomock.Any(), gomock.Any()).Return(events.Event{
 					Number:   3,
 					UnitGUID: "testUseCase",
 					ID:       "testID",
@@ -57,7 +60,7 @@ func TestUseCase_GetEventByNumber(t *testing.T) {
 			},
 			want: events.Event{},
 			mockBehavior: func(r *mocks.MockStorage) {
-				r.EXPECT().GetEventByNumber(gomock.Any(), gomock.Any(), gomock.Any()).Return(events.Event{}, service.ErrEventNotFound)
+				r.EXPECT().GetEventByNumber(gomock.Any(), "", gomock.Any(), gomock.Any()).Return(events.Event{}, service.ErrEventNotFound)
 			},
 			wantErr: true,
 		},
@@ -70,7 +73,7 @@ func TestUseCase_GetEventByNumber(t *testing.T) {
 			},
 			want: events.Event{},
 			mockBehavior: func(r *mocks.MockStorage) {
-				r.EXPECT().GetEventByNumber(gomock.Any(), gomock.Any(), gomock.Any()).Return(events.Event{}, errors.New("test error"))
+				r.EXPECT().GetEventByNumber(gomock.Any(), "", gomock.Any(), gomock.Any()).Return(events.Event{}, errors.New("test error"))
 			},
 			wantErr: true,
 		},
@@ -87,7 +90,7 @@ func TestUseCase_GetEventByNumber(t *testing.T) {
 			})
 
 			u := &UseCase{storage: st, logger: logger.New(loggerInstance)}
-			got, err := u.GetEventByNumber(tt.args.ctx, tt.args.unitGUID, tt.args.number)
+			got, err := u.GetEventByNumber(tt.args.ctx, "", tt.args.unitGUID, tt.args.number)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("GetEventByNumber() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -99,6 +102,380 @@ func TestUseCase_GetEventByNumber(t *testing.T) {
 	}
 }
 
+func TestUseCase_ListEvents(t *testing.T) {
+	tests := []struct {
+		name         string
+		query        service.EventsQuery
+		want         service.EventsPage
+		wantErr      error
+		mockBehavior func(r *mocks.MockStorage)
+	}{
+		{
+			name:  "next page",
+			query: service.EventsQuery{SortBy: service.SortLevel, Limit: 2},
+			want: service.EventsPage{
+				Events: []events.Event{{ID: "1", Level: 1}, {ID: "2", Level: 2}},
+				Next:   &service.Cursor{Value: "2", ID: "2"},
+			},
+			mockBehavior: func(r *mocks.MockStorage) {
+				r.EXPECT().ListEvents(gomock.Any(), service.EventsQuery{SortBy: service.SortLevel, Limit: 3}).
+					Return([]events.Event{{ID: "1", Level: 1}, {ID: "2", Level: 2}, {ID: "3", Level: 3}}, nil)
+			},
+		},
+		{
+			name:  "last page with default limit",
+			query: service.EventsQuery{},
+			want: service.EventsPage{
+				Events: []events.Event{{ID: "1"}},
+			},
+			mockBehavior: func(r *mocks.MockStorage) {
+				r.EXPECT().ListEvents(gomock.Any(), service.EventsQuery{Limit: DefaultEventsLimit + 1}).
+					Return([]events.Event{{ID: "1"}}, nil)
+			},
+		},
+		{
+			name:         "unknown sort",
+			query:        service.EventsQuery{SortBy: "nope"},
+			wantErr:      service.ErrInvalidQuery,
+			mockBehavior: func(r *mocks.MockStorage) {},
+		},
+		{
+			name:    "storage error",
+			query:   service.EventsQuery{Limit: MaxEventsLimit * 2},
+			wantErr: ErrStorageIsUnavailable,
+			mockBehavior: func(r *mocks.MockStorage) {
+				r.EXPECT().ListEvents(gomock.Any(), service.EventsQuery{Limit: MaxEventsLimit + 1}).
+					Return(nil, errors.New("test error"))
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+			st := mocks.NewMockStorage(c)
+			tt.mockBehavior(st)
+
+			loggerInstance := httplog.NewLogger("watcher", httplog.Options{
+				Concise: true,
+			})
+
+			u := &UseCase{storage: st, logger: logger.New(loggerInstance)}
+			got, err := u.ListEvents(context.Background(), tt.query)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("ListEvents() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ListEvents() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUseCase_GetUnit(t *testing.T) {
+	tests := []struct {
+		name         string
+		guid         string
+		want         service.UnitSummary
+		wantErr      error
+		mockBehavior func(r *mocks.MockStorage)
+	}{
+		{
+			name: "ok",
+			guid: "unit1",
+			want: service.UnitSummary{Unit: service.Unit{GUID: "unit1", Events: 1}},
+			mockBehavior: func(r *mocks.MockStorage) {
+				r.EXPECT().GetUnit(gomock.Any(), "", "unit1").
+					Return(service.UnitSummary{Unit: service.Unit{GUID: "unit1", Events: 1}}, nil)
+			},
+		},
+		{
+			name:    "not found",
+			guid:    "unit2",
+			wantErr: service.ErrUnitNotFound,
+			mockBehavior: func(r *mocks.MockStorage) {
+				r.EXPECT().GetUnit(gomock.Any(), "", "unit2").
+					Return(service.UnitSummary{}, service.ErrUnitNotFound)
+			},
+		},
+		{
+			name:    "storage error",
+			guid:    "unit3",
+			wantErr: ErrStorageIsUnavailable,
+			mockBehavior: func(r *mocks.MockStorage) {
+				r.EXPECT().GetUnit(gomock.Any(), "", "unit3").
+					Return(service.UnitSummary{}, errors.New("test error"))
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+			st := mocks.NewMockStorage(c)
+			tt.mockBehavior(st)
+
+			loggerInstance := httplog.NewLogger("watcher", httplog.Options{
+				Concise: true,
+			})
+
+			u := &UseCase{storage: st, logger: logger.New(loggerInstance)}
+			got, err := u.GetUnit(context.Background(), "", tt.guid)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("GetUnit() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetUnit() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUseCase_ReprocessFile(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.tsv"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		filename     string
+		watching     bool
+		wantErr      error
+		mockBehavior func(r *mocks.MockStorage)
+	}{
+		{
+			name:     "ok",
+			filename: "a.tsv",
+			watching: true,
+			mockBehavior: func(r *mocks.MockStorage) {
+				r.EXPECT().ResetFile(gomock.Any(), events.DefaultPipeline, "a.tsv").Return(nil)
+			},
+		},
+		{
+			name:         "not watching",
+			filename:     "a.tsv",
+			wantErr:      ErrNotWatching,
+			mockBehavior: func(r *mocks.MockStorage) {},
+		},
+		{
+			name:         "not on disk",
+			filename:     "b.tsv",
+			watching:     true,
+			wantErr:      service.ErrFileNotFound,
+			mockBehavior: func(r *mocks.MockStorage) {},
+		},
+		{
+			name:         "path",
+			filename:     "../a.tsv",
+			watching:     true,
+			wantErr:      service.ErrFileNotFound,
+			mockBehavior: func(r *mocks.MockStorage) {},
+		},
+		{
+			name:     "storage error",
+			filename: "a.tsv",
+			watching: true,
+			wantErr:  ErrStorageIsUnavailable,
+			mockBehavior: func(r *mocks.MockStorage) {
+				r.EXPECT().ResetFile(gomock.Any(), events.DefaultPipeline, "a.tsv").Return(errors.New("test error"))
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+			st := mocks.NewMockStorage(c)
+			tt.mockBehavior(st)
+
+			loggerInstance := httplog.NewLogger("watcher", httplog.Options{
+				Concise: true,
+			})
+
+			u := New(st, t.TempDir(), logger.New(loggerInstance))
+			if tt.watching {
+				u.pipelines[events.DefaultPipeline].fileWatcher, u.pipelines[events.DefaultPipeline].dir = watcher.New(time.Second, dir, nil), dir
+			}
+
+			err := u.ReprocessFile(context.Background(), "", tt.filename)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("ReprocessFile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUseCase_Process(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+	st := mocks.NewMockStorage(c)
+	st.EXPECT().LoadFilenames(gomock.Any(), events.DefaultPipeline, gomock.Any()).Return(nil)
+
+	loggerInstance := httplog.NewLogger("watcher", httplog.Options{
+		Concise: true,
+	})
+	u := New(st, t.TempDir(), logger.New(loggerInstance))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- u.Process(ctx, "", time.Hour, t.TempDir())
+	}()
+
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Process() error = %v, want %v", err, context.Canceled)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Process() didn't stop when the context is done")
+	}
+}
+
+func TestUseCase_IngestFile(t *testing.T) {
+	dir := t.TempDir()
+	data := "n\tunit_guid\tlevel\n1\tunit-1\t100\n"
+	if err := os.WriteFile(filepath.Join(dir, "a.tsv"), []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	stored := service.FileDetails{File: service.File{Pipeline: events.DefaultPipeline, Name: "a.tsv", Status: service.FileOK, Events: 1}}
+
+	tests := []struct {
+		name         string
+		path         string
+		want         service.FileDetails
+		wantErr      error
+		mockBehavior func(r *mocks.MockStorage)
+	}{
+		{
+			name: "ok",
+			path: filepath.Join(dir, "a.tsv"),
+			want: stored,
+			mockBehavior: func(r *mocks.MockStorage) {
+				gomock.InOrder(
+					r.EXPECT().GetFile(gomock.Any(), events.DefaultPipeline, "a.tsv").Return(service.FileDetails{}, service.ErrFileNotFound),
+					r.EXPECT().SaveFile(gomock.Any(), gomock.Any()).Return(nil),
+					r.EXPECT().GetFile(gomock.Any(), events.DefaultPipeline, "a.tsv").Return(stored, nil),
+				)
+			},
+		},
+		{
+			name:    "ingested",
+			path:    filepath.Join(dir, "a.tsv"),
+			wantErr: ErrFileIngested,
+			mockBehavior: func(r *mocks.MockStorage) {
+				r.EXPECT().GetFile(gomock.Any(), events.DefaultPipeline, "a.tsv").Return(stored, nil)
+			},
+		},
+		{
+			name:    "storage error",
+			path:    filepath.Join(dir, "a.tsv"),
+			wantErr: ErrStorageIsUnavailable,
+			mockBehavior: func(r *mocks.MockStorage) {
+				gomock.InOrder(
+					r.EXPECT().GetFile(gomock.Any(), events.DefaultPipeline, "a.tsv").Return(service.FileDetails{}, service.ErrFileNotFound),
+					r.EXPECT().SaveFile(gomock.Any(), gomock.Any()).Return(errors.New("test error")),
+				)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+			st := mocks.NewMockStorage(c)
+			tt.mockBehavior(st)
+
+			loggerInstance := httplog.NewLogger("watcher", httplog.Options{
+				Concise: true,
+			})
+
+			u := New(st, t.TempDir(), logger.New(loggerInstance))
+			u.SetReportConfig(ReportConfig{Formats: []string{FormatCSV}})
+
+			got, err := u.IngestFile(context.Background(), "", tt.path)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("IngestFile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && got.Status != tt.want.Status {
+				t.Errorf("IngestFile() status = %q, want %q", got.Status, tt.want.Status)
+			}
+		})
+	}
+}
+
+func TestUseCase_ReplayFile(t *testing.T) {
+	dir := t.TempDir()
+	data := "n\tunit_guid\tlevel\n1\tunit-1\t100\n"
+	if err := os.WriteFile(filepath.Join(dir, "a.tsv"), []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	stored := service.FileDetails{File: service.File{Pipeline: events.DefaultPipeline, Name: "a.tsv", Status: service.FileOK, Events: 1}}
+
+	tests := []struct {
+		name         string
+		filename     string
+		wantErr      error
+		mockBehavior func(r *mocks.MockStorage)
+	}{
+		{
+			name:     "ok",
+			filename: "a.tsv",
+			mockBehavior: func(r *mocks.MockStorage) {
+				gomock.InOrder(
+					r.EXPECT().ResetFile(gomock.Any(), events.DefaultPipeline, "a.tsv").Return(nil),
+					r.EXPECT().SaveFile(gomock.Any(), gomock.Any()).Return(nil),
+					r.EXPECT().GetFile(gomock.Any(), events.DefaultPipeline, "a.tsv").Return(stored, nil),
+				)
+			},
+		},
+		{
+			name:         "not on disk",
+			filename:     "b.tsv",
+			wantErr:      service.ErrFileNotFound,
+			mockBehavior: func(r *mocks.MockStorage) {},
+		},
+		{
+			name:         "path",
+			filename:     "../a.tsv",
+			wantErr:      service.ErrFileNotFound,
+			mockBehavior: func(r *mocks.MockStorage) {},
+		},
+		{
+			name:     "not stored",
+			filename: "a.tsv",
+			wantErr:  service.ErrFileNotFound,
+			mockBehavior: func(r *mocks.MockStorage) {
+				r.EXPECT().ResetFile(gomock.Any(), events.DefaultPipeline, "a.tsv").Return(service.ErrFileNotFound)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+			st := mocks.NewMockStorage(c)
+			tt.mockBehavior(st)
+
+			loggerInstance := httplog.NewLogger("watcher", httplog.Options{
+				Concise: true,
+			})
+
+			u := New(st, t.TempDir(), logger.New(loggerInstance))
+			u.SetReportConfig(ReportConfig{Formats: []string{FormatCSV}})
+
+			_, err := u.ReplayFile(context.Background(), "", dir, tt.filename)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("ReplayFile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
 type eventStub struct {
 	events []events.Event
 }
@@ -111,6 +488,14 @@ func (i eventStub) Print() {
 
 }
 
+func (i eventStub) Source() string {
+	return ""
+}
+
+func (i eventStub) Pipeline() string {
+	return events.DefaultPipeline
+}
+
 func (i eventStub) Iter(cb func(d events.Event) (stop bool)) {
 	for _, d := range i.events {
 		if stop := cb(d); stop {
@@ -119,7 +504,7 @@ func (i eventStub) Iter(cb func(d events.Event) (stop bool)) {
 	}
 }
 
-func TestUseCase_savePDF(t *testing.T) {
+func TestUseCase_saveReports(t *testing.T) {
 	loggerInstance := httplog.NewLogger("watcher", httplog.Options{
 		Concise: true,
 	})
@@ -144,9 +529,9 @@ func TestUseCase_savePDF(t *testing.T) {
 		{UnitGUID: "1"}, {UnitGUID: "2"}, {UnitGUID: "3"}, {UnitGUID: "4"}, {UnitGUID: "5"},
 	}}
 
-	err = uc.savePDF(es)
+	err = uc.saveReports(context.Background(), uc.pipelines[events.DefaultPipeline], es)
 	if err != nil {
-		t.Fatalf("savePDF() error = %v", err)
+		t.Fatalf("saveReports() error = %v", err)
 	}
 
 	directory, err := os.Open(dir)
@@ -175,7 +560,7 @@ loop:
 	}
 
 	if len(es.events) != 0 {
-		t.Fatalf("savePDF() missing files")
+		t.Fatalf("saveReports() missing files")
 	}
 
 	err = os.RemoveAll(dir)
@@ -1,18 +1,42 @@
 package watcher
 
 import (
+	"context"
 	"fmt"
 	"github.com/dolthub/swiss"
 	"os"
+	"path/filepath"
+	"sync"
 	"time"
 )
 
-// Watcher watches a directory for new files
+// Watcher watches a directory for new files and sends their paths
 type Watcher struct {
+	// mu guards the refresh interval, the directory and the processed files
+	mu              sync.Mutex
 	refreshInterval time.Duration
 	dir             string
 	processed       *swiss.Map[string, struct{}]
 	files           chan string
+	// reset wakes Run up to apply a new refresh interval
+	reset chan struct{}
+
+	// running, lastScan and err are reported by Stats
+	running  bool
+	lastScan time.Time
+	err      error
+}
+
+// Stats is the state of the watcher
+type Stats struct {
+	// Running is false before Run and after it returned
+	Running bool
+	// LastScan is the time of the last scan of the directory, zero before the first one
+	LastScan time.Time
+	// Queued is the number of the found files not taken yet
+	Queued int
+	// Err is the error Run returned, nil if it is running or stopped by the context
+	Err error
 }
 
 // New creates a new watcher
@@ -22,47 +46,146 @@ func New(refreshInterval time.Duration, dir string, files chan string) *Watcher
 		dir:             dir,
 		processed:       swiss.NewMap[string, struct{}](100),
 		files:           files,
+		reset:           make(chan struct{}, 1),
+	}
+}
+
+// SetRefreshInterval changes the refresh interval of the running watcher
+func (w *Watcher) SetRefreshInterval(refreshInterval time.Duration) {
+	w.mu.Lock()
+	w.refreshInterval = refreshInterval
+	w.mu.Unlock()
+
+	select {
+	case w.reset <- struct{}{}:
+	default:
 	}
 }
 
+// SetDir changes the watched directory from the next refresh,
+// the files of the previous directory already sent are still processed
+func (w *Watcher) SetDir(dir string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.dir = dir
+}
+
+// settings returns the refresh interval and the directory
+func (w *Watcher) settings() (time.Duration, string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.refreshInterval, w.dir
+}
+
+// Stats returns the state of the watcher
+func (w *Watcher) Stats() Stats {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return Stats{Running: w.running, LastScan: w.lastScan, Queued: len(w.files), Err: w.err}
+}
+
+// setRunning records whether Run is running and the error it returned
+func (w *Watcher) setRunning(running bool, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.running, w.err = running, err
+}
+
+// scanned records the time of the scan
+func (w *Watcher) scanned(t time.Time) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.lastScan = t
+}
+
 // AddFile adds a file to the list of processed files
 func (w *Watcher) AddFile(filename string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.processed.Put(filename, struct{}{})
+}
+
+// Forget removes a file from the list of processed files, so it is sent again on the next refresh
+func (w *Watcher) Forget(filename string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.processed.Delete(filename)
+}
+
+// seen reports whether the file is processed and marks it as processed
+func (w *Watcher) seen(filename string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.processed.Has(filename) {
+		return true
+	}
 	w.processed.Put(filename, struct{}{})
+	return false
+}
+
+// Run starts the watcher, it stops when the context is done
+func (w *Watcher) Run(ctx context.Context) error {
+	w.setRunning(true, nil)
+	err := w.run(ctx)
+	if ctx.Err() != nil {
+		w.setRunning(false, nil)
+		return ctx.Err()
+	}
+	w.setRunning(false, err)
+	return err
 }
 
-// Run starts the watcher
-func (w *Watcher) Run() error {
-	ticker := time.NewTicker(w.refreshInterval)
+// run scans the directory every refresh interval until the context is done or the directory can't be read
+func (w *Watcher) run(ctx context.Context) error {
+	refreshInterval, _ := w.settings()
+	ticker := time.NewTicker(refreshInterval)
 	defer ticker.Stop()
 
-	for range ticker.C {
-		dir, err := os.Open(w.dir)
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		case <-w.reset:
+			refreshInterval, _ = w.settings()
+			ticker.Reset(refreshInterval)
+			continue
+		}
+
+		_, path := w.settings()
+		dir, err := os.Open(path)
 		if err != nil {
 			return fmt.Errorf("failed to open directory: %s", err)
 		}
 
 		fis, err := dir.Readdir(-1)
 		if err != nil {
+			dir.Close()
 			return fmt.Errorf("failed to read directory: %s", err)
 		}
+		w.scanned(time.Now())
 
 		for _, fi := range fis {
 			if fi.IsDir() {
 				continue
 			}
-			if w.processed.Has(fi.Name()) {
+			if len(fi.Name()) < 4 || fi.Name()[len(fi.Name())-4:] != ".tsv" {
 				continue
 			}
 
-			if len(fi.Name()) < 4 || fi.Name()[len(fi.Name())-4:] != ".tsv" {
+			if w.seen(fi.Name()) {
 				continue
 			}
 
-			w.files <- fi.Name()
-			w.processed.Put(fi.Name(), struct{}{})
+			select {
+			case w.files <- filepath.Join(path, fi.Name()):
+			case <-ctx.Done():
+				// the file is sent again on the next start
+				w.Forget(fi.Name())
+				dir.Close()
+				return ctx.Err()
+			}
 		}
 		dir.Close()
 	}
-
-	return nil
 }
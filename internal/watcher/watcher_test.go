@@ -0,0 +1,168 @@
+package watcher
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// receive returns the next sent path, empty if none is sent within the timeout.
+func receive(files chan string, timeout time.Duration) string {
+	select {
+	case path := <-files:
+		return path
+	case <-time.After(timeout):
+		return ""
+	}
+}
+
+// writeFiles creates the empty files in the directory.
+func writeFiles(t *testing.T, dir string, names ...string) {
+	for _, name := range names {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestWatcher_Run(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, "a.tsv", "done.tsv", "notes.txt")
+
+	files := make(chan string, 10)
+	w := New(10*time.Millisecond, dir, files)
+	w.AddFile("done.tsv")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- w.Run(ctx) }()
+
+	if got := receive(files, time.Second); got != filepath.Join(dir, "a.tsv") {
+		t.Fatalf("Run() sent %q, want a.tsv", got)
+	}
+	// the sent, processed and other files are not sent again
+	if got := receive(files, 50*time.Millisecond); got != "" {
+		t.Errorf("Run() sent %q again", got)
+	}
+
+	stats := w.Stats()
+	if !stats.Running || stats.LastScan.IsZero() || stats.Err != nil {
+		t.Errorf("Stats() = %+v while running", stats)
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Run() error = %v, want %v", err, context.Canceled)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Run() did not return on cancel")
+	}
+
+	if stats = w.Stats(); stats.Running || stats.Err != nil {
+		t.Errorf("Stats() = %+v after cancel", stats)
+	}
+}
+
+func TestWatcher_Run_cancelWhileSending(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, "a.tsv")
+
+	// nobody takes the file, so Run is cancelled while sending it
+	files := make(chan string)
+	w := New(10*time.Millisecond, dir, files)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- w.Run(ctx) }()
+
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("Run() error = %v, want %v", err, context.Canceled)
+	}
+
+	// the file not taken is sent again by the next run
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	go w.Run(ctx)
+	if got := receive(files, time.Second); got != filepath.Join(dir, "a.tsv") {
+		t.Errorf("Run() sent %q, want a.tsv again", got)
+	}
+}
+
+func TestWatcher_Forget(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, "a.tsv")
+
+	files := make(chan string, 10)
+	w := New(10*time.Millisecond, dir, files)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go w.Run(ctx)
+
+	if got := receive(files, time.Second); got == "" {
+		t.Fatal("Run() sent nothing")
+	}
+
+	w.Forget("a.tsv")
+	if got := receive(files, time.Second); got != filepath.Join(dir, "a.tsv") {
+		t.Errorf("Run() sent %q after Forget, want a.tsv again", got)
+	}
+}
+
+func TestWatcher_SetDir(t *testing.T) {
+	first, second := t.TempDir(), t.TempDir()
+	writeFiles(t, second, "b.tsv")
+
+	files := make(chan string, 10)
+	w := New(10*time.Millisecond, first, files)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go w.Run(ctx)
+
+	if got := receive(files, 50*time.Millisecond); got != "" {
+		t.Fatalf("Run() sent %q from the empty directory", got)
+	}
+
+	w.SetDir(second)
+	if got := receive(files, time.Second); got != filepath.Join(second, "b.tsv") {
+		t.Errorf("Run() sent %q, want b.tsv of the new directory", got)
+	}
+}
+
+func TestWatcher_SetRefreshInterval(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, "a.tsv")
+
+	files := make(chan string, 10)
+	w := New(time.Hour, dir, files)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go w.Run(ctx)
+
+	// the running watcher picks up the shorter interval without waiting for the old one
+	w.SetRefreshInterval(10 * time.Millisecond)
+	if got := receive(files, time.Second); got != filepath.Join(dir, "a.tsv") {
+		t.Errorf("Run() sent %q, want a.tsv with the new interval", got)
+	}
+}
+
+func TestWatcher_Run_missingDir(t *testing.T) {
+	w := New(10*time.Millisecond, filepath.Join(t.TempDir(), "missing"), make(chan string))
+
+	err := w.Run(context.Background())
+	if err == nil {
+		t.Fatal("Run() error = nil, want the missing directory")
+	}
+	if stats := w.Stats(); stats.Running || stats.Err == nil {
+		t.Errorf("Stats() = %+v, want the error", stats)
+	}
+}
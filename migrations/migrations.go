@@ -0,0 +1,9 @@
+// Package migrations embeds the SQL schema migrations into the binary.
+package migrations
+
+import "embed"
+
+// FS contains one directory of migrations per storage vendor.
+//
+//go:embed postgres sqlite3
+var FS embed.FS
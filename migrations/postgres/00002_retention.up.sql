@@ -0,0 +1,8 @@
+ALTER TABLE events ADD COLUMN SourceFile VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE events ADD COLUMN IngestedAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP;
+CREATE INDEX events_unit_guid_idx ON events (UnitGUID);
+CREATE INDEX events_ingested_at_idx ON events (IngestedAt);
+CREATE INDEX events_source_file_idx ON events (SourceFile);
+ALTER TABLE files ADD COLUMN events INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE files ADD COLUMN pruned INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE files ADD COLUMN archived INTEGER NOT NULL DEFAULT 0;
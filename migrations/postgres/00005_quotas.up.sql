@@ -0,0 +1,6 @@
+CREATE TABLE quotas (
+    client VARCHAR(255) NOT NULL,
+    day    VARCHAR(10) NOT NULL,
+    used   INTEGER NOT NULL,
+    PRIMARY KEY (client, day)
+);
@@ -0,0 +1,8 @@
+ALTER TABLE files DROP CONSTRAINT files_pkey;
+-- the files of the default pipeline win over the same names of the others
+DELETE FROM files f USING files d
+    WHERE f.name = d.name AND f.pipeline <> 'default' AND (d.pipeline = 'default' OR f.pipeline > d.pipeline);
+ALTER TABLE files ADD PRIMARY KEY (name);
+ALTER TABLE files DROP COLUMN pipeline;
+DROP INDEX events_pipeline_idx;
+ALTER TABLE events DROP COLUMN Pipeline;
@@ -0,0 +1,5 @@
+ALTER TABLE events ADD COLUMN Pipeline VARCHAR(255) NOT NULL DEFAULT 'default';
+CREATE INDEX events_pipeline_idx ON events (Pipeline);
+ALTER TABLE files ADD COLUMN pipeline VARCHAR(255) NOT NULL DEFAULT 'default';
+ALTER TABLE files DROP CONSTRAINT files_pkey;
+ALTER TABLE files ADD PRIMARY KEY (pipeline, name);
@@ -0,0 +1,12 @@
+-- the numbers of the events within their units, kept when the older events are pruned
+ALTER TABLE events ADD COLUMN Seq INTEGER;
+UPDATE events SET Seq = numbered.n
+    FROM (SELECT ID, ROW_NUMBER() OVER (PARTITION BY UnitGUID ORDER BY IngestedAt, Number, ID) AS n FROM events) AS numbered
+    WHERE events.ID = numbered.ID;
+CREATE UNIQUE INDEX events_unit_seq_idx ON events (UnitGUID, Seq);
+CREATE TABLE unit_numbers (
+    unit_guid   VARCHAR(255) PRIMARY KEY,
+    last_number INTEGER NOT NULL
+);
+INSERT INTO unit_numbers (unit_guid, last_number)
+    SELECT UnitGUID, MAX(Seq) FROM events WHERE UnitGUID IS NOT NULL GROUP BY UnitGUID;
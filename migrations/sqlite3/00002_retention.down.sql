@@ -0,0 +1,8 @@
+ALTER TABLE files DROP COLUMN archived;
+ALTER TABLE files DROP COLUMN pruned;
+ALTER TABLE files DROP COLUMN events;
+DROP INDEX events_source_file_idx;
+DROP INDEX events_ingested_at_idx;
+DROP INDEX events_unit_guid_idx;
+ALTER TABLE events DROP COLUMN IngestedAt;
+ALTER TABLE events DROP COLUMN SourceFile;
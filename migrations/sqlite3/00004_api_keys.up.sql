@@ -0,0 +1,7 @@
+CREATE TABLE api_keys (
+    id         VARCHAR(255) PRIMARY KEY,
+    name       VARCHAR(255) NOT NULL,
+    hash       VARCHAR(64) NOT NULL UNIQUE,
+    scopes     VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
@@ -0,0 +1,15 @@
+CREATE TABLE files_names (
+    name       VARCHAR(255) PRIMARY KEY,
+    error      VARCHAR(255),
+    events     INTEGER NOT NULL DEFAULT 0,
+    pruned     INTEGER NOT NULL DEFAULT 0,
+    archived   INTEGER NOT NULL DEFAULT 0,
+    deleted_at TIMESTAMP
+);
+-- the files of the default pipeline win over the same names of the others
+INSERT OR IGNORE INTO files_names (name, error, events, pruned, archived, deleted_at)
+    SELECT name, error, events, pruned, archived, deleted_at FROM files ORDER BY pipeline <> 'default';
+DROP TABLE files;
+ALTER TABLE files_names RENAME TO files;
+DROP INDEX events_pipeline_idx;
+ALTER TABLE events DROP COLUMN Pipeline;
@@ -0,0 +1,16 @@
+ALTER TABLE events ADD COLUMN Pipeline VARCHAR(255) NOT NULL DEFAULT 'default';
+CREATE INDEX events_pipeline_idx ON events (Pipeline);
+CREATE TABLE files_pipelines (
+    pipeline   VARCHAR(255) NOT NULL DEFAULT 'default',
+    name       VARCHAR(255) NOT NULL,
+    error      VARCHAR(255),
+    events     INTEGER NOT NULL DEFAULT 0,
+    pruned     INTEGER NOT NULL DEFAULT 0,
+    archived   INTEGER NOT NULL DEFAULT 0,
+    deleted_at TIMESTAMP,
+    PRIMARY KEY (pipeline, name)
+);
+INSERT INTO files_pipelines (name, error, events, pruned, archived, deleted_at)
+    SELECT name, error, events, pruned, archived, deleted_at FROM files;
+DROP TABLE files;
+ALTER TABLE files_pipelines RENAME TO files;
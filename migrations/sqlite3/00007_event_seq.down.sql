@@ -0,0 +1,3 @@
+DROP TABLE unit_numbers;
+DROP INDEX events_unit_seq_idx;
+ALTER TABLE events DROP COLUMN Seq;
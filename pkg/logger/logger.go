@@ -39,3 +39,13 @@ func (l Logger) Debug(msg string) {
 func (l Logger) Warn(msg string) {
 	l.l.Warn().Msg(msg)
 }
+
+// SetLevel sets the minimum level of all loggers (e.g. debug, info, warn, error)
+func SetLevel(level string) error {
+	lvl, err := zerolog.ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	zerolog.SetGlobalLevel(lvl)
+	return nil
+}
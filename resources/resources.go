@@ -0,0 +1,9 @@
+// Package resources embeds the default fonts of the reports.
+package resources
+
+import "embed"
+
+// Fonts are the embedded TrueType fonts by their file names.
+//
+//go:embed *.ttf
+var Fonts embed.FS